@@ -0,0 +1,58 @@
+// Copyright (C) 2014 Audrius Butkevičius
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/AudriusButkevicius/cli"
+)
+
+func init() {
+	cliCommands = append(cliCommands, cli.Command{
+		Name:     "database",
+		HideHelp: true,
+		Usage:    "Database command group",
+		Subcommands: []cli.Command{
+			{
+				Name:     "backup",
+				Usage:    "Write a consistent copy of the database to an absolute path on the Syncthing host",
+				Requires: &cli.Requires{"path"},
+				Action:   databaseBackup,
+			},
+			{
+				Name:     "export",
+				Usage:    "Download the database in the portable export format",
+				Requires: &cli.Requires{"file"},
+				Action:   databaseExport,
+			},
+		},
+	})
+}
+
+func databaseBackup(c *cli.Context) {
+	response := httpPost(c, "db/backup?path="+url.QueryEscape(c.Args()[0]), "")
+	var result struct {
+		Path string
+		Keys int
+	}
+	die(json.Unmarshal(responseToBArray(response), &result))
+	fmt.Printf("Backed up %d entries to %s\n", result.Keys, result.Path)
+}
+
+func databaseExport(c *cli.Context) {
+	fd, err := os.OpenFile(c.Args()[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	die(err)
+	response := httpGet(c, "db/export")
+	defer response.Body.Close()
+	if _, err := io.Copy(fd, response.Body); err != nil {
+		fd.Close()
+		os.Remove(c.Args()[0])
+		die(err)
+	}
+	die(fd.Close())
+}
@@ -82,6 +82,12 @@ func init() {
 						Requires: &cli.Requires{"folder id", "device id"},
 						Action:   foldersDevicesRemove,
 					},
+					{
+						Name:     "role",
+						Usage:    "Set the role of a device for a folder (sendreceive, receiveonly, sendonly)",
+						Requires: &cli.Requires{"folder id", "device id", "role"},
+						Action:   foldersDevicesRole,
+					},
 					{
 						Name:     "clear",
 						Usage:    "Unshare a folder with all devices",
@@ -280,7 +286,7 @@ func foldersDevicesList(c *cli.Context) {
 			continue
 		}
 		for _, device := range folder.Devices {
-			fmt.Println(device.DeviceID)
+			fmt.Println(device.DeviceID, device.Role)
 		}
 		return
 	}
@@ -314,6 +320,33 @@ func foldersDevicesAdd(c *cli.Context) {
 	die("Folder " + rid + " not found")
 }
 
+func foldersDevicesRole(c *cli.Context) {
+	rid := c.Args()[0]
+	nid := parseDeviceID(c.Args()[1])
+	var role config.FolderDeviceRole
+	switch c.Args()[2] {
+	case "sendreceive", "receiveonly", "sendonly":
+		role.UnmarshalText([]byte(c.Args()[2]))
+	default:
+		die("Invalid role: " + c.Args()[2] + "\nAvailable roles: sendreceive, receiveonly, sendonly")
+	}
+	cfg := getConfig(c)
+	for ri, folder := range cfg.Folders {
+		if folder.ID != rid {
+			continue
+		}
+		for ni, device := range folder.Devices {
+			if device.DeviceID == nid {
+				cfg.Folders[ri].Devices[ni].Role = role
+				setConfig(c, cfg)
+				return
+			}
+		}
+		die("Device " + c.Args()[1] + " not found")
+	}
+	die("Folder " + rid + " not found")
+}
+
 func foldersDevicesRemove(c *cli.Context) {
 	rid := c.Args()[0]
 	nid := parseDeviceID(c.Args()[1])
@@ -0,0 +1,211 @@
+// Copyright (C) 2014 Audrius Butkevičius
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/AudriusButkevicius/cli"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func init() {
+	cliCommands = append(cliCommands, cli.Command{
+		Name:     "groups",
+		HideHelp: true,
+		Usage:    "Device group command group",
+		Subcommands: []cli.Command{
+			{
+				Name:     "list",
+				Usage:    "List device groups",
+				Requires: &cli.Requires{},
+				Action:   groupsList,
+			},
+			{
+				Name:     "add",
+				Usage:    "Add a new device group",
+				Requires: &cli.Requires{"group name", "device id..."},
+				Action:   groupsAdd,
+			},
+			{
+				Name:     "remove",
+				Usage:    "Remove an existing device group",
+				Requires: &cli.Requires{"group name"},
+				Action:   groupsRemove,
+			},
+			{
+				Name:     "share",
+				Usage:    "Share a folder with all devices in a group",
+				Requires: &cli.Requires{"group name", "folder id"},
+				Action:   groupsShare,
+			},
+			{
+				Name:     "unshare",
+				Usage:    "Unshare a folder with a group",
+				Requires: &cli.Requires{"group name", "folder id"},
+				Action:   groupsUnshare,
+			},
+			{
+				Name:     "devices",
+				Usage:    "Group devices command group",
+				HideHelp: true,
+				Subcommands: []cli.Command{
+					{
+						Name:     "add",
+						Usage:    "Add a device to a group",
+						Requires: &cli.Requires{"group name", "device id"},
+						Action:   groupsDevicesAdd,
+					},
+					{
+						Name:     "remove",
+						Usage:    "Remove a device from a group",
+						Requires: &cli.Requires{"group name", "device id"},
+						Action:   groupsDevicesRemove,
+					},
+				},
+			},
+		},
+	})
+}
+
+func groupsList(c *cli.Context) {
+	cfg := getConfig(c)
+	first := true
+	writer := newTableWriter()
+	for _, group := range cfg.DeviceGroups {
+		if !first {
+			fmt.Fprintln(writer)
+		}
+		fmt.Fprintln(writer, "Name:\t", group.Name, "\t")
+		for _, device := range group.Devices {
+			fmt.Fprintln(writer, "Device:\t", device, "\t")
+		}
+		for _, folder := range cfg.Folders {
+			for _, shared := range folder.DeviceGroups {
+				if shared.Name == group.Name {
+					fmt.Fprintln(writer, "Folder:\t", folder.ID, "\t")
+				}
+			}
+		}
+		first = false
+	}
+	writer.Flush()
+}
+
+func groupsAdd(c *cli.Context) {
+	name := c.Args()[0]
+	cfg := getConfig(c)
+	for _, group := range cfg.DeviceGroups {
+		if group.Name == name {
+			die("Group " + name + " already exists")
+		}
+	}
+
+	group := config.DeviceGroupConfiguration{Name: name}
+	for _, nid := range c.Args()[1:] {
+		group.Devices = append(group.Devices, findDevice(cfg, nid))
+	}
+	cfg.DeviceGroups = append(cfg.DeviceGroups, group)
+	setConfig(c, cfg)
+}
+
+func groupsRemove(c *cli.Context) {
+	name := c.Args()[0]
+	cfg := getConfig(c)
+	i := findGroup(cfg, name)
+	cfg.DeviceGroups = append(cfg.DeviceGroups[:i], cfg.DeviceGroups[i+1:]...)
+	setConfig(c, cfg)
+}
+
+func groupsShare(c *cli.Context) {
+	name := c.Args()[0]
+	rid := c.Args()[1]
+	cfg := getConfig(c)
+	findGroup(cfg, name)
+	for i, folder := range cfg.Folders {
+		if folder.ID != rid {
+			continue
+		}
+		for _, group := range folder.DeviceGroups {
+			if group.Name == name {
+				die("Folder " + rid + " is already shared with group " + name)
+			}
+		}
+		cfg.Folders[i].DeviceGroups = append(folder.DeviceGroups, config.FolderDeviceGroupConfiguration{Name: name})
+		setConfig(c, cfg)
+		return
+	}
+	die("Folder " + rid + " not found")
+}
+
+func groupsUnshare(c *cli.Context) {
+	name := c.Args()[0]
+	rid := c.Args()[1]
+	cfg := getConfig(c)
+	for ri, folder := range cfg.Folders {
+		if folder.ID != rid {
+			continue
+		}
+		for gi, group := range folder.DeviceGroups {
+			if group.Name == name {
+				cfg.Folders[ri].DeviceGroups = append(folder.DeviceGroups[:gi], folder.DeviceGroups[gi+1:]...)
+				setConfig(c, cfg)
+				return
+			}
+		}
+		die("Folder " + rid + " is not shared with group " + name)
+	}
+	die("Folder " + rid + " not found")
+}
+
+func groupsDevicesAdd(c *cli.Context) {
+	name := c.Args()[0]
+	cfg := getConfig(c)
+	i := findGroup(cfg, name)
+	id := findDevice(cfg, c.Args()[1])
+	for _, device := range cfg.DeviceGroups[i].Devices {
+		if device == id {
+			die("Device " + c.Args()[1] + " is already part of this group")
+		}
+	}
+	cfg.DeviceGroups[i].Devices = append(cfg.DeviceGroups[i].Devices, id)
+	setConfig(c, cfg)
+}
+
+func groupsDevicesRemove(c *cli.Context) {
+	name := c.Args()[0]
+	id := parseDeviceID(c.Args()[1])
+	cfg := getConfig(c)
+	i := findGroup(cfg, name)
+	for di, device := range cfg.DeviceGroups[i].Devices {
+		if device == id {
+			devices := cfg.DeviceGroups[i].Devices
+			cfg.DeviceGroups[i].Devices = append(devices[:di], devices[di+1:]...)
+			setConfig(c, cfg)
+			return
+		}
+	}
+	die("Device " + c.Args()[1] + " not found")
+}
+
+func findGroup(cfg config.Configuration, name string) int {
+	for i, group := range cfg.DeviceGroups {
+		if group.Name == name {
+			return i
+		}
+	}
+	die("Group " + name + " not found")
+	return -1
+}
+
+func findDevice(cfg config.Configuration, nid string) protocol.DeviceID {
+	id := parseDeviceID(nid)
+	for _, device := range cfg.Devices {
+		if device.DeviceID == id {
+			return id
+		}
+	}
+	die("Device " + nid + " not found in device list")
+	return id
+}
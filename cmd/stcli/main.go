@@ -29,7 +29,7 @@ func main() {
 		cli.StringFlag{
 			Name:   "endpoint, e",
 			Value:  "http://127.0.0.1:8384",
-			Usage:  "End point to connect to",
+			Usage:  "End point to connect to (address or unix:///path/to.sock)",
 			EnvVar: "STENDPOINT",
 		},
 		cli.StringFlag{
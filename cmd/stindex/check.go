@@ -0,0 +1,33 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/db"
+)
+
+// check prints the result of a consistency check of the database as JSON,
+// repairing the problems found if asked to. It exits with status 1 if there
+// were unrepaired problems. Syncthing must not be running.
+func check(ldb *db.Instance, repair bool) {
+	res := ldb.Check(repair)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(res); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(res.Problems) > 0 && !res.Repaired {
+		ldb.Close()
+		os.Exit(1)
+	}
+}
@@ -17,7 +17,7 @@ import (
 )
 
 func dump(ldb *db.Instance) {
-	it := ldb.NewIterator(nil, nil)
+	it := ldb.NewPrefixIterator(nil)
 	for it.Next() {
 		key := it.Key()
 		switch key[0] {
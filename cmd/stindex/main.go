@@ -14,14 +14,17 @@ import (
 	"path/filepath"
 
 	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/db/backend"
 )
 
 func main() {
-	var mode string
+	var mode, to, dest string
 	log.SetFlags(0)
 	log.SetOutput(os.Stdout)
 
-	flag.StringVar(&mode, "mode", "dump", "Mode of operation: dump, dumpsize")
+	flag.StringVar(&mode, "mode", "dump", "Mode of operation: dump, dumpsize, migrate, check, repair")
+	flag.StringVar(&to, "to", backend.Bolt, "Database backend to migrate to: leveldb, bolt")
+	flag.StringVar(&dest, "dest", "", "Path of the migrated database")
 
 	flag.Parse()
 
@@ -30,9 +33,20 @@ func main() {
 		path = filepath.Join(defaultConfigDir(), "index-v0.14.0.db")
 	}
 
-	fmt.Println("Path:", path)
+	if mode == "check" || mode == "repair" {
+		// Keep the JSON result on stdout clean
+		fmt.Fprintln(os.Stderr, "Path:", path)
+	} else {
+		fmt.Println("Path:", path)
+	}
+
+	if mode == "migrate" {
+		migrate(path, to, dest)
+		return
+	}
 
-	ldb, err := db.Open(path)
+	// Only a repair writes to the database
+	ldb, err := db.OpenExisting(path, mode != "repair")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -41,6 +55,8 @@ func main() {
 		dump(ldb)
 	} else if mode == "dumpsize" {
 		dumpsize(ldb)
+	} else if mode == "check" || mode == "repair" {
+		check(ldb, mode == "repair")
 	} else {
 		fmt.Println("Unknown mode")
 	}
@@ -0,0 +1,57 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+)
+
+// migrate copies the database at src into a new database of the given
+// backend at dst. Syncthing must not be running.
+func migrate(src, to, dst string) {
+	if dst == "" {
+		log.Fatal("Destination must be given with -dest")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		log.Fatalf("Destination %s already exists", dst)
+	}
+
+	from, err := backend.Detect(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if from == "" {
+		log.Fatalf("No database at %s", src)
+	}
+
+	srcDB, err := backend.OpenExisting(src, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer srcDB.Close()
+
+	dstDB, err := backend.Open(dst, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	n, err := backend.Copy(dstDB, srcDB)
+	if err != nil {
+		dstDB.Close()
+		os.RemoveAll(dst)
+		log.Fatal(err)
+	}
+	if err := dstDB.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Copied %d keys from %s to %s database at %s\n", n, from, to, dst)
+}
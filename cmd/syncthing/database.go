@@ -0,0 +1,110 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/db"
+)
+
+// backupDatabase writes a consistent copy of the database to the file. The
+// running instance, if any, takes the copy.
+func backupDatabase(file string) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		l.Fatalln("Backup:", err)
+	}
+
+	var keys int
+	if ldb, err := db.Open(locations[locDatabase]); err == nil {
+		keys, err = ldb.Backup(file)
+		ldb.Close()
+		if err != nil {
+			l.Fatalln("Backup:", err)
+		}
+	} else {
+		l.Infoln("Database in use, backing up through running Syncthing...")
+		resp, err := restRequest("POST", "rest/db/backup", url.Values{"path": {file}}, 0)
+		if err != nil {
+			l.Fatalln("Backup:", err)
+		}
+		var res struct {
+			Keys int `json:"keys"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			l.Fatalln("Backup:", err)
+		}
+		keys = res.Keys
+	}
+
+	l.Infof("Backed up %d database entries to %s", keys, file)
+}
+
+// exportDatabase writes the portable database export to the file. The
+// running instance, if any, makes the export.
+func exportDatabase(file string) {
+	fd, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		l.Fatalln("Export:", err)
+	}
+
+	if ldb, err := db.Open(locations[locDatabase]); err == nil {
+		err = ldb.Export(fd)
+		ldb.Close()
+		if err != nil {
+			fd.Close()
+			os.Remove(file)
+			l.Fatalln("Export:", err)
+		}
+	} else {
+		l.Infoln("Database in use, exporting through running Syncthing...")
+		resp, err := restRequest("GET", "rest/db/export", nil, 0)
+		if err == nil {
+			_, err = io.Copy(fd, resp.Body)
+			resp.Body.Close()
+		}
+		if err != nil {
+			fd.Close()
+			os.Remove(file)
+			l.Fatalln("Export:", err)
+		}
+	}
+
+	if err := fd.Close(); err != nil {
+		l.Fatalln("Export:", err)
+	}
+	l.Infoln("Exported database to", file)
+}
+
+// importDatabase reads an export into the database. Syncthing must not be
+// running, and the folders in the export must not be in the database.
+func importDatabase(file string) {
+	fd, err := os.Open(file)
+	if err != nil {
+		l.Fatalln("Import:", err)
+	}
+	defer fd.Close()
+
+	ensureDir(filepath.Dir(locations[locDatabase]), 0700)
+	ldb, err := db.Open(locations[locDatabase])
+	if err != nil {
+		l.Fatalln("Import: opening database (is Syncthing running?):", err)
+	}
+	defer ldb.Close()
+
+	if err := ldb.Import(fd); err != nil {
+		l.Fatalln("Import:", err)
+	}
+	l.Infoln("Imported database from", file)
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net"
@@ -25,10 +26,12 @@ import (
 	"time"
 
 	"github.com/rcrowley/go-metrics"
+	"github.com/syncthing/syncthing/lib/audit"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/discover"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/osutil"
@@ -36,6 +39,7 @@ import (
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/syncthing"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/vitrun/qart/qr"
@@ -64,7 +68,8 @@ type apiService struct {
 	discoverer         discover.CachingMux
 	connectionsService connectionsIntf
 	fss                *folderSummaryService
-	systemConfigMut    sync.Mutex    // serializes posts to /rest/system/config
+	systemConfigMut    sync.Mutex    // serializes changes to the config through the API
+	pendingTOTPSecret  string        // generated but not yet confirmed TOTP secret, protected by systemConfigMut
 	stop               chan struct{} // signals intentional stop
 	configChanged      chan struct{} // signals intentional listener close due to config change
 	started            chan string   // signals startup complete by sending the listener address, for testing only
@@ -72,6 +77,7 @@ type apiService struct {
 
 	guiErrors logger.Recorder
 	systemLog logger.Recorder
+	faults    *fs.FaultFilesystem
 }
 
 type modelIntf interface {
@@ -100,6 +106,19 @@ type modelIntf interface {
 	CurrentSequence(folder string) (int64, bool)
 	RemoteSequence(folder string) (int64, bool)
 	State(folder string) (string, time.Time, error)
+	UnsettledFiles(folder string) []string
+	PendingDevices() map[protocol.DeviceID]db.PendingDevice
+	PendingFolders() map[string]map[protocol.DeviceID]db.PendingFolder
+	DismissPendingDevice(device protocol.DeviceID)
+	DismissPendingFolder(folder string, device protocol.DeviceID)
+	Introductions() []db.Introduction
+	BackupDatabase(location string) (int, error)
+	ExportDatabase(w io.Writer) error
+	FileHistory(folder, file string) ([]db.HistoryEntry, error)
+	AuditLog(folder string, q audit.Query) ([]audit.Entry, error)
+	VerifyAuditLog(folder string) (int, error)
+	SetIdentityMigration(migration *protocol.IdentityMigration)
+	IdentityMigrationConfirmed() []protocol.DeviceID
 }
 
 type configIntf interface {
@@ -113,6 +132,8 @@ type configIntf interface {
 	SetDevice(config.DeviceConfiguration) error
 	SetDevices([]config.DeviceConfiguration) error
 	Save() error
+	SaveFrom(origin string) error
+	History() *config.History
 	ListenAddresses() []string
 	RequiresRestart() bool
 }
@@ -121,7 +142,7 @@ type connectionsIntf interface {
 	Status() map[string]interface{}
 }
 
-func newAPIService(id protocol.DeviceID, cfg configIntf, httpsCertFile, httpsKeyFile, assetDir string, m modelIntf, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connectionsIntf, errors, systemLog logger.Recorder) *apiService {
+func newAPIService(id protocol.DeviceID, cfg configIntf, httpsCertFile, httpsKeyFile, assetDir string, m modelIntf, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connectionsIntf, errors, systemLog logger.Recorder, faults *fs.FaultFilesystem) *apiService {
 	service := &apiService{
 		id:            id,
 		cfg:           cfg,
@@ -142,12 +163,19 @@ func newAPIService(id protocol.DeviceID, cfg configIntf, httpsCertFile, httpsKey
 		startedOnce:        make(chan struct{}),
 		guiErrors:          errors,
 		systemLog:          systemLog,
+		faults:             faults,
 	}
 
 	return service
 }
 
 func (s *apiService) getListener(guiCfg config.GUIConfiguration) (net.Listener, error) {
+	if guiCfg.Network() == "unix" {
+		// Access to the socket is limited by the file system permissions,
+		// and it's served as plain HTTP.
+		return listenUnixSocket(guiCfg)
+	}
+
 	cert, err := tls.LoadX509KeyPair(s.httpsCertFile, s.httpsKeyFile)
 	if err != nil {
 		l.Infoln("Loading HTTPS certificate:", err)
@@ -158,7 +186,7 @@ func (s *apiService) getListener(guiCfg config.GUIConfiguration) (net.Listener,
 		var name string
 		name, err = os.Hostname()
 		if err != nil {
-			name = tlsDefaultCommonName
+			name = syncthing.TLSDefaultCommonName
 		}
 
 		cert, err = tlsutil.NewCertificate(s.httpsCertFile, s.httpsKeyFile, name, httpsRSABits)
@@ -238,51 +266,78 @@ func (s *apiService) Serve() {
 
 	// The GET handlers
 	getRestMux := http.NewServeMux()
-	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)              // device folder
-	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                          // folder file
-	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                    // folder
-	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                          // folder [perpage] [page]
-	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                      // folder
-	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                      // folder [prefix] [dirsonly] [levels]
-	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                      // [since] [limit] [timeout] [events]
-	getRestMux.HandleFunc("/rest/events/disk", s.getDiskEvents)                  // [since] [limit] [timeout]
-	getRestMux.HandleFunc("/rest/stats/device", s.getDeviceStats)                // -
-	getRestMux.HandleFunc("/rest/stats/folder", s.getFolderStats)                // -
-	getRestMux.HandleFunc("/rest/svc/deviceid", s.getDeviceID)                   // id
-	getRestMux.HandleFunc("/rest/svc/lang", s.getLang)                           // -
-	getRestMux.HandleFunc("/rest/svc/report", s.getReport)                       // -
-	getRestMux.HandleFunc("/rest/svc/random/string", s.getRandomString)          // [length]
-	getRestMux.HandleFunc("/rest/system/browse", s.getSystemBrowse)              // current
-	getRestMux.HandleFunc("/rest/system/config", s.getSystemConfig)              // -
-	getRestMux.HandleFunc("/rest/system/config/insync", s.getSystemConfigInsync) // -
-	getRestMux.HandleFunc("/rest/system/connections", s.getSystemConnections)    // -
-	getRestMux.HandleFunc("/rest/system/discovery", s.getSystemDiscovery)        // -
-	getRestMux.HandleFunc("/rest/system/error", s.getSystemError)                // -
-	getRestMux.HandleFunc("/rest/system/ping", s.restPing)                       // -
-	getRestMux.HandleFunc("/rest/system/status", s.getSystemStatus)              // -
-	getRestMux.HandleFunc("/rest/system/upgrade", s.getSystemUpgrade)            // -
-	getRestMux.HandleFunc("/rest/system/version", s.getSystemVersion)            // -
-	getRestMux.HandleFunc("/rest/system/debug", s.getSystemDebug)                // -
-	getRestMux.HandleFunc("/rest/system/log", s.getSystemLog)                    // [since]
-	getRestMux.HandleFunc("/rest/system/log.txt", s.getSystemLogTxt)             // [since]
+	getRestMux.HandleFunc("/rest/audit", s.getAudit)                                            // folder [since] [until] [path] [limit]
+	getRestMux.HandleFunc("/rest/audit/verify", s.getAuditVerify)                               // folder
+	getRestMux.HandleFunc("/rest/cluster/pending/devices", s.getPendingDevices)                 // -
+	getRestMux.HandleFunc("/rest/cluster/pending/folders", s.getPendingFolders)                 // -
+	getRestMux.HandleFunc("/rest/cluster/introductions", s.getIntroductions)                    // -
+	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)                             // device folder
+	getRestMux.HandleFunc("/rest/db/export", s.getDBExport)                                     // -
+	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                                         // folder file
+	getRestMux.HandleFunc("/rest/db/history", s.getDBHistory)                                   // folder file
+	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                                   // folder
+	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                                         // folder [perpage] [page]
+	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                                     // folder
+	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                                     // folder [prefix] [dirsonly] [levels]
+	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                                     // [since] [limit] [timeout] [events]
+	getRestMux.HandleFunc("/rest/events/disk", s.getDiskEvents)                                 // [since] [limit] [timeout]
+	getRestMux.HandleFunc("/rest/stats/device", s.getDeviceStats)                               // -
+	getRestMux.HandleFunc("/rest/stats/folder", s.getFolderStats)                               // -
+	getRestMux.HandleFunc("/rest/svc/deviceid", s.getDeviceID)                                  // id
+	getRestMux.HandleFunc("/rest/svc/lang", s.getLang)                                          // -
+	getRestMux.HandleFunc("/rest/svc/report", s.getReport)                                      // -
+	getRestMux.HandleFunc("/rest/svc/random/string", s.getRandomString)                         // [length]
+	getRestMux.HandleFunc("/rest/system/browse", s.getSystemBrowse)                             // current
+	getRestMux.HandleFunc("/rest/system/config", s.getSystemConfig)                             // -
+	getRestMux.HandleFunc("/rest/system/config/insync", s.getSystemConfigInsync)                // -
+	getRestMux.HandleFunc("/rest/system/config/revisions", s.getSystemConfigRevisions)          // -
+	getRestMux.HandleFunc("/rest/system/config/revisions/diff", s.getSystemConfigRevisionsDiff) // from [to]
+	getRestMux.HandleFunc("/rest/system/connections", s.getSystemConnections)                   // -
+	getRestMux.HandleFunc("/rest/system/discovery", s.getSystemDiscovery)                       // -
+	getRestMux.HandleFunc("/rest/system/error", s.getSystemError)                               // -
+	getRestMux.HandleFunc("/rest/system/groups", s.getSystemDeviceGroups)                       // -
+	getRestMux.HandleFunc("/rest/system/identity", s.getSystemIdentity)                         // -
+	getRestMux.HandleFunc("/rest/system/ping", s.restPing)                                      // -
+	getRestMux.HandleFunc("/rest/system/status", s.getSystemStatus)                             // -
+	getRestMux.HandleFunc("/rest/system/upgrade", s.getSystemUpgrade)                           // -
+	getRestMux.HandleFunc("/rest/system/version", s.getSystemVersion)                           // -
+	getRestMux.HandleFunc("/rest/system/debug", s.getSystemDebug)                               // -
+	getRestMux.HandleFunc("/rest/system/log", s.getSystemLog)                                   // [since]
+	getRestMux.HandleFunc("/rest/system/log.txt", s.getSystemLogTxt)                            // [since]
 
 	// The POST handlers
 	postRestMux := http.NewServeMux()
-	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                          // folder file [perpage] [page]
-	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                    // folder
-	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                  // folder
-	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
-	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)              // <body>
-	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                // <body>
-	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)     // -
-	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                        // -
-	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                // [folder]
-	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)            // -
-	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)          // -
-	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)            // -
-	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))   // [device]
-	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false)) // [device]
-	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                // [enable] [disable]
+	postRestMux.HandleFunc("/rest/cluster/pending/devices/accept", s.postPendingDevicesAccept)            // device [name]
+	postRestMux.HandleFunc("/rest/cluster/pending/devices/dismiss", s.postPendingDevicesDismiss)          // device
+	postRestMux.HandleFunc("/rest/cluster/pending/folders/accept", s.postPendingFoldersAccept)            // folder device [path]
+	postRestMux.HandleFunc("/rest/cluster/pending/folders/dismiss", s.postPendingFoldersDismiss)          // folder device
+	postRestMux.HandleFunc("/rest/db/backup", s.postDBBackup)                                             // path
+	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                                                 // folder file [perpage] [page]
+	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                                           // folder
+	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                                         // folder
+	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                                                 // folder [sub...] [delay]
+	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)                                     // <body>
+	postRestMux.HandleFunc("/rest/system/config/revisions/rollback", s.postSystemConfigRevisionsRollback) // id
+	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                                       // <body>
+	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)                            // -
+	postRestMux.HandleFunc("/rest/system/groups", s.postSystemDeviceGroups)                               // <body>
+	postRestMux.HandleFunc("/rest/system/groups/delete", s.postSystemDeviceGroupsDelete)                  // name
+	postRestMux.HandleFunc("/rest/system/groups/add", s.makeDeviceGroupMemberHandler(true))               // name device
+	postRestMux.HandleFunc("/rest/system/groups/remove", s.makeDeviceGroupMemberHandler(false))           // name device
+	postRestMux.HandleFunc("/rest/system/identity/rotate", s.postSystemIdentityRotate)                    // -
+	postRestMux.HandleFunc("/rest/system/identity/cancel", s.postSystemIdentityCancel)                    // -
+	postRestMux.HandleFunc("/rest/system/identity/complete", s.postSystemIdentityComplete)                // -
+	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                                               // -
+	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                                       // [folder]
+	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)                                   // -
+	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)                                 // -
+	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)                                   // -
+	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))                          // [device]
+	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false))                        // [device]
+	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                                       // [enable] [disable]
+	postRestMux.HandleFunc("/rest/system/totp/enroll", s.postSystemTOTPEnroll)                            // -
+	postRestMux.HandleFunc("/rest/system/totp/confirm", s.postSystemTOTPConfirm)                          // code
+	postRestMux.HandleFunc("/rest/system/totp/disable", s.postSystemTOTPDisable)                          // -
 
 	// Debug endpoints, not for general use
 	debugMux := http.NewServeMux()
@@ -290,8 +345,13 @@ func (s *apiService) Serve() {
 	debugMux.HandleFunc("/rest/debug/httpmetrics", s.getSystemHTTPMetrics)
 	debugMux.HandleFunc("/rest/debug/cpuprof", s.getCPUProf) // duration
 	debugMux.HandleFunc("/rest/debug/heapprof", s.getHeapProf)
+	debugMux.HandleFunc("/rest/debug/faults", s.getDebugFaults)
 	getRestMux.Handle("/rest/debug/", s.whenDebugging(debugMux))
 
+	debugPostMux := http.NewServeMux()
+	debugPostMux.HandleFunc("/rest/debug/faults", s.postDebugFaults) // <body>
+	postRestMux.Handle("/rest/debug/", s.whenDebugging(debugPostMux))
+
 	// A handler that splits requests between the two above and disables
 	// caching
 	restMux := noCacheMiddleware(metricsMiddleware(getPostHandler(getRestMux, postRestMux)))
@@ -310,15 +370,23 @@ func (s *apiService) Serve() {
 	guiCfg := s.cfg.GUI()
 
 	// Wrap everything in CSRF protection. The /rest prefix should be
-	// protected, other requests will grant cookies.
-	handler := csrfMiddleware(s.id.String()[:5], "/rest", guiCfg, mux)
+	// protected, other requests will grant cookies. Unix socket clients are
+	// only trusted based on file permissions when explicitly configured so,
+	// and unless a user and password are set.
+	var handler http.Handler = mux
+	if guiCfg.Network() != "unix" || !guiCfg.UnixSocketTrusted || (len(guiCfg.User) > 0 && len(guiCfg.Password) > 0) {
+		handler = csrfMiddleware(s.id.String()[:5], "/rest", guiCfg, handler)
+	} else {
+		// Tell clients they don't need a CSRF token
+		handler = trustedSocketMiddleware(handler)
+	}
 
 	// Add our version and ID as a header to responses
 	handler = withDetailsMiddleware(s.id, handler)
 
 	// Wrap everything in basic auth, if user/password is set.
 	if len(guiCfg.User) > 0 && len(guiCfg.Password) > 0 {
-		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, handler)
+		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], s.cfg, s.systemConfigMut, handler)
 	}
 
 	// Redirect to HTTPS if we are supposed to
@@ -329,7 +397,7 @@ func (s *apiService) Serve() {
 	// Add the CORS handling
 	handler = corsMiddleware(handler)
 
-	if addressIsLocalhost(guiCfg.Address()) && !guiCfg.InsecureSkipHostCheck {
+	if guiCfg.Network() == "tcp" && addressIsLocalhost(guiCfg.Address()) && !guiCfg.InsecureSkipHostCheck {
 		// Verify source host
 		handler = localhostMiddleware(handler)
 	}
@@ -393,6 +461,10 @@ func (s *apiService) String() string {
 }
 
 func (s *apiService) VerifyConfiguration(from, to config.Configuration) error {
+	if to.GUI.Network() == "unix" {
+		_, err := to.GUI.UnixSocketFileMode()
+		return err
+	}
 	_, err := net.ResolveTCPAddr("tcp", to.GUI.Address())
 	return err
 }
@@ -401,7 +473,7 @@ func (s *apiService) CommitConfiguration(from, to config.Configuration) bool {
 	// No action required when this changes, so mask the fact that it changed at all.
 	from.GUI.Debugging = to.GUI.Debugging
 
-	if to.GUI == from.GUI {
+	if reflect.DeepEqual(to.GUI, from.GUI) {
 		return true
 	}
 
@@ -526,6 +598,13 @@ func withDetailsMiddleware(id protocol.DeviceID, h http.Handler) http.Handler {
 	})
 }
 
+func trustedSocketMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Syncthing-Trusted-Socket", "true")
+		h.ServeHTTP(w, r)
+	})
+}
+
 func localhostMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if addressIsLocalhost(r.Host) {
@@ -660,6 +739,7 @@ func folderSummary(cfg configIntf, m modelIntf, folder string) map[string]interf
 	if err != nil {
 		res["error"] = err.Error()
 	}
+	res["unsettledFiles"] = m.UnsettledFiles(folder) // not scanned while being written
 
 	ourSeq, _ := m.CurrentSequence(folder)
 	remoteSeq, _ := m.RemoteSequence(folder)
@@ -745,6 +825,94 @@ func (s *apiService) getDBFile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *apiService) getAudit(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	q := audit.Query{
+		Path: qs.Get("path"),
+	}
+	var err error
+	if since := qs.Get("since"); since != "" {
+		if q.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if until := qs.Get("until"); until != "" {
+		if q.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := s.model.AuditLog(qs.Get("folder"), q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, entries)
+}
+
+func (s *apiService) getAuditVerify(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.model.VerifyAuditLog(r.URL.Query().Get("folder"))
+	res := map[string]interface{}{
+		"entries": entries,
+		"valid":   err == nil,
+	}
+	if err != nil {
+		res["error"] = err.Error()
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) getDBHistory(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	entries, err := s.model.FileHistory(qs.Get("folder"), qs.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	res := make([]jsonHistoryEntry, len(entries))
+	for i, e := range entries {
+		res[i] = jsonHistoryEntry(e)
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) postDBBackup(w http.ResponseWriter, r *http.Request) {
+	path, err := osutil.ExpandTilde(r.URL.Query().Get("path"))
+	if err != nil || !filepath.IsAbs(path) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := s.model.BackupDatabase(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"path": path,
+		"keys": keys,
+	})
+}
+
+func (s *apiService) getDBExport(w http.ResponseWriter, r *http.Request) {
+	name := fmt.Sprintf("syncthing-index-%s.json.gz", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	if err := s.model.ExportDatabase(w); err != nil {
+		// Too late for an error status, the client sees a broken stream
+		l.Warnln("Exporting database:", err)
+	}
+}
+
 func (s *apiService) getSystemConfig(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, s.cfg.RawCopy())
 }
@@ -761,6 +929,10 @@ func (s *apiService) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The second factor isn't part of the JSON config
+	to.GUI.TOTPSecret = s.cfg.GUI().TOTPSecret
+	to.GUI.TOTPRecoveryCodes = s.cfg.GUI().TOTPRecoveryCodes
+
 	if to.GUI.Password != s.cfg.GUI().Password {
 		if to.GUI.Password != "" {
 			hash, err := bcrypt.GenerateFromPassword([]byte(to.GUI.Password), 0)
@@ -794,13 +966,217 @@ func (s *apiService) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.cfg.Save(); err != nil {
+	if err := s.cfg.SaveFrom(requestOrigin(r)); err != nil {
 		l.Warnln("Saving config:", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+func (s *apiService) getSystemConfigRevisions(w http.ResponseWriter, r *http.Request) {
+	history := s.cfg.History()
+	if history == nil {
+		http.Error(w, "Configuration history is not enabled", http.StatusNotFound)
+		return
+	}
+
+	revs, err := history.Revisions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, revs)
+}
+
+func (s *apiService) getSystemConfigRevisionsDiff(w http.ResponseWriter, r *http.Request) {
+	history := s.cfg.History()
+	if history == nil {
+		http.Error(w, "Configuration history is not enabled", http.StatusNotFound)
+		return
+	}
+
+	qs := r.URL.Query()
+	fromID, toID := qs.Get("from"), qs.Get("to")
+
+	from, err := history.Load(fromID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Compare to the running configuration unless told otherwise
+	to := s.cfg.RawCopy()
+	if toID != "" {
+		to, err = history.Load(toID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	} else {
+		toID = "current"
+	}
+
+	redactTOTP(&from.GUI)
+	redactTOTP(&to.GUI)
+	diff, err := config.Diff(from, to, fromID, toID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, diff)
+}
+
+func (s *apiService) postSystemConfigRevisionsRollback(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	history := s.cfg.History()
+	if history == nil {
+		http.Error(w, "Configuration history is not enabled", http.StatusNotFound)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	to, err := history.Rollback(id, s.cfg.RawCopy())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Rolling back config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.SaveFrom(requestOrigin(r) + ", rollback to " + id); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// replaceConfig replaces and saves the configuration, responding with an
+// error if that fails.
+func (s *apiService) replaceConfig(w http.ResponseWriter, r *http.Request, to config.Configuration) error {
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	if err := s.cfg.SaveFrom(requestOrigin(r)); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	return nil
+}
+
+func (s *apiService) getSystemDeviceGroups(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.cfg.RawCopy().DeviceGroups)
+}
+
+func (s *apiService) postSystemDeviceGroups(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	var group config.DeviceGroupConfiguration
+	err := json.NewDecoder(r.Body).Decode(&group)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if group.Name == "" {
+		http.Error(w, "Group name must be set", http.StatusBadRequest)
+		return
+	}
+	devices := s.cfg.Devices()
+	for _, id := range group.Devices {
+		if _, ok := devices[id]; !ok {
+			http.Error(w, "Unknown device "+id.String(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	raw := s.cfg.RawCopy()
+	replaced := false
+	for i := range raw.DeviceGroups {
+		if raw.DeviceGroups[i].Name == group.Name {
+			raw.DeviceGroups[i] = group
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		raw.DeviceGroups = append(raw.DeviceGroups, group)
+	}
+	s.replaceConfig(w, r, raw)
+}
+
+func (s *apiService) postSystemDeviceGroupsDelete(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	name := r.URL.Query().Get("name")
+	raw := s.cfg.RawCopy()
+	for i := range raw.DeviceGroups {
+		if raw.DeviceGroups[i].Name == name {
+			raw.DeviceGroups = append(raw.DeviceGroups[:i], raw.DeviceGroups[i+1:]...)
+			s.replaceConfig(w, r, raw)
+			return
+		}
+	}
+	http.Error(w, "No such group", http.StatusNotFound)
+}
+
+// makeDeviceGroupMemberHandler returns a handler that adds a device to, or
+// removes it from, a group. Folders shared with the group are shared with,
+// or unshared from, the device accordingly.
+func (s *apiService) makeDeviceGroupMemberHandler(add bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.systemConfigMut.Lock()
+		defer s.systemConfigMut.Unlock()
+
+		qs := r.URL.Query()
+		name := qs.Get("name")
+		id, err := protocol.DeviceIDFromString(qs.Get("device"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.cfg.Devices()[id]; !ok {
+			http.Error(w, "Unknown device", http.StatusBadRequest)
+			return
+		}
+
+		raw := s.cfg.RawCopy()
+		for i := range raw.DeviceGroups {
+			group := &raw.DeviceGroups[i]
+			if group.Name != name {
+				continue
+			}
+
+			devices := group.Devices[:0]
+			for _, dev := range group.Devices {
+				if dev != id {
+					devices = append(devices, dev)
+				}
+			}
+			if add {
+				devices = append(devices, id)
+			}
+			group.Devices = devices
+
+			s.replaceConfig(w, r, raw)
+			return
+		}
+		http.Error(w, "No such group", http.StatusNotFound)
+	}
+}
+
 func (s *apiService) getSystemConfigInsync(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, map[string]bool{"configInSync": !s.cfg.RequiresRestart()})
 }
@@ -1329,6 +1705,42 @@ func (s *apiService) getHeapProf(w http.ResponseWriter, r *http.Request) {
 	pprof.WriteHeapProfile(w)
 }
 
+func (s *apiService) getDebugFaults(w http.ResponseWriter, r *http.Request) {
+	if s.faults == nil {
+		http.Error(w, "Fault injection not available", http.StatusNotFound)
+		return
+	}
+	sendJSON(w, s.faults.Rules())
+}
+
+// postDebugFaults replaces the fault injection rules with the posted ones.
+// Posting an empty list stops fault injection.
+func (s *apiService) postDebugFaults(w http.ResponseWriter, r *http.Request) {
+	if s.faults == nil {
+		http.Error(w, "Fault injection not available", http.StatusNotFound)
+		return
+	}
+
+	var rules []fs.FaultRule
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.faults.SetRules(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(rules) > 0 {
+		l.Warnf("Injecting filesystem faults according to %d rules", len(rules))
+	} else {
+		l.Infoln("Stopped injecting filesystem faults")
+	}
+	sendJSON(w, s.faults.Rules())
+}
+
 func (s *apiService) toNeedSlice(fs []db.FileInfoTruncated) []jsonDBFileInfo {
 	res := make([]jsonDBFileInfo, len(fs))
 	for i, f := range fs {
@@ -1373,6 +1785,22 @@ func (f jsonDBFileInfo) MarshalJSON() ([]byte, error) {
 	})
 }
 
+type jsonHistoryEntry db.HistoryEntry
+
+func (e jsonHistoryEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"version":    jsonVersionVector(e.Version),
+		"modifiedBy": e.ModifiedBy.String(),
+		"modified":   e.Modified,
+		"size":       e.Size,
+		"deleted":    e.Deleted,
+		"hash":       e.Hash,
+		"recorded":   e.Recorded,
+		"origin":     e.Origin,
+		"device":     e.Device,
+	})
+}
+
 type jsonVersionVector protocol.Vector
 
 func (v jsonVersionVector) MarshalJSON() ([]byte, error) {
@@ -8,12 +8,14 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/sync"
@@ -23,17 +25,28 @@ import (
 var (
 	sessions    = make(map[string]bool)
 	sessionsMut = sync.NewMutex()
+
+	loginLockouts = newLoginLockout()
+)
+
+const (
+	maxFailedLogins    = 5               // failed attempts before locking out a remote address
+	failedLoginWindow  = 5 * time.Minute // how far back failed attempts are counted
+	loginLockoutPeriod = 5 * time.Minute // how long a lockout lasts
 )
 
-func emitLoginAttempt(success bool, username string) {
+func emitLoginAttempt(success bool, username, remoteAddress string) {
 	events.Default.Log(events.LoginAttempt, map[string]interface{}{
-		"success":  success,
-		"username": username,
+		"success":       success,
+		"username":      username,
+		"remoteAddress": remoteAddress,
 	})
 }
 
-func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguration, next http.Handler) http.Handler {
+func basicAuthAndSessionMiddleware(cookieName string, cfgw configIntf, configMut sync.Mutex, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cfgw.GUI()
+
 		if cfg.IsValidAPIKey(r.Header.Get("X-API-Key")) {
 			next.ServeHTTP(w, r)
 			return
@@ -77,6 +90,17 @@ func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguratio
 			return
 		}
 
+		// The attempt counts as failed until proven otherwise, so that
+		// parallel guesses can't get past the limit.
+		remoteAddress := loginAddress(r, cfg.TrustForwardedFor)
+		attempted := time.Now()
+		if !loginLockouts.attempt(remoteAddress, attempted) {
+			httpl.Debugln("Login attempt from locked out address", remoteAddress)
+			time.Sleep(time.Duration(rand.Intn(100)+100) * time.Millisecond)
+			http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+			return
+		}
+
 		// Check if the username is correct, assuming it was sent as UTF-8
 		username := string(fields[0])
 		if username == cfg.User {
@@ -90,7 +114,7 @@ func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguratio
 		}
 
 		// Neither of the possible interpretations match the configured username
-		emitLoginAttempt(false, username)
+		emitLoginAttempt(false, username, remoteAddress)
 		error()
 		return
 
@@ -108,26 +132,104 @@ func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguratio
 		}
 
 		// Neither of the attempts to verify the password checked out
-		emitLoginAttempt(false, username)
+		emitLoginAttempt(false, username, remoteAddress)
 		error()
 		return
 
 	passwordOK:
-		sessionid := rand.String(32)
-		sessionsMut.Lock()
-		sessions[sessionid] = true
-		sessionsMut.Unlock()
-		http.SetCookie(w, &http.Cookie{
-			Name:   cookieName,
-			Value:  sessionid,
-			MaxAge: 0,
-		})
-
-		emitLoginAttempt(true, username)
+		if cfg.TOTPEnabled() {
+			// The session cookie is only issued once the second factor has
+			// been verified as well. It's given either in a header, by API
+			// clients, or posted from the form we present to browsers.
+			code := r.Header.Get("X-TOTP-Code")
+			isForm := r.Method == "POST" && r.URL.Path == totpLoginPath
+			if code == "" && isForm {
+				code = r.FormValue("code")
+			}
+			if code == "" {
+				// Not a failure, only the first step
+				loginLockouts.forget(remoteAddress, attempted)
+				serveTOTPForm(w, false)
+				return
+			}
+			if !verifySecondFactor(cfgw, configMut, code) {
+				emitLoginAttempt(false, username, remoteAddress)
+				time.Sleep(time.Duration(rand.Intn(100)+100) * time.Millisecond)
+				serveTOTPForm(w, true)
+				return
+			}
+			if isForm {
+				setSessionCookie(w, cookieName)
+				loginLockouts.succeeded(remoteAddress)
+				emitLoginAttempt(true, username, remoteAddress)
+				http.Redirect(w, r, "/", http.StatusSeeOther)
+				return
+			}
+		}
+
+		setSessionCookie(w, cookieName)
+		loginLockouts.succeeded(remoteAddress)
+		emitLoginAttempt(true, username, remoteAddress)
 		next.ServeHTTP(w, r)
 	})
 }
 
+func setSessionCookie(w http.ResponseWriter, cookieName string) {
+	sessionid := rand.String(32)
+	sessionsMut.Lock()
+	sessions[sessionid] = true
+	sessionsMut.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:   cookieName,
+		Value:  sessionid,
+		MaxAge: 0,
+	})
+}
+
+// requestOrigin describes where a request came from, for the configuration
+// history. The API key itself is not recorded, only a fingerprint of it.
+func requestOrigin(r *http.Request) string {
+	origin := "REST " + r.RemoteAddr
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		hash := sha256.Sum256([]byte(key))
+		origin += fmt.Sprintf(" (API key %x)", hash[:4])
+	} else if user, _, ok := r.BasicAuth(); ok {
+		origin += fmt.Sprintf(" (user %s)", user)
+	}
+
+	if ua := r.UserAgent(); ua != "" {
+		origin += ", " + ua
+	}
+	return origin
+}
+
+// remoteHost returns the host part of the request's remote address.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginAddress returns the address login attempts from the request are
+// counted for. Behind a reverse proxy all requests come from the proxy, so
+// when the proxy is trusted the client address it adds to X-Forwarded-For
+// is used instead. That's the last one in the list, as those before it are
+// given by the client.
+func loginAddress(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			addrs := strings.Split(fwd, ",")
+			if addr := strings.TrimSpace(addrs[len(addrs)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+	return remoteHost(r)
+}
+
 // Convert an ISO-8859-1 encoded byte string to UTF-8. Works by the
 // principle that ISO-8859-1 bytes are equivalent to unicode code points,
 // that a rune slice is a list of code points, and that stringifying a slice
@@ -139,3 +241,82 @@ func iso88591ToUTF8(s []byte) []byte {
 	}
 	return []byte(string(runes))
 }
+
+// A loginLockout keeps track of failed login attempts, and locks out
+// remote addresses with too many recent failures. A successful login
+// clears the failures for the address.
+type loginLockout struct {
+	failures map[string][]time.Time // remote address -> failed attempts
+	mut      sync.Mutex
+}
+
+func newLoginLockout() *loginLockout {
+	return &loginLockout{
+		failures: make(map[string][]time.Time),
+		mut:      sync.NewMutex(),
+	}
+}
+
+// attempt counts a login attempt from the address as failed, unless the
+// address is locked out, in which case it returns false and nothing is
+// counted. The attempt is checked and counted at once, so no more than
+// maxFailedLogins attempts get through even when made in parallel.
+func (s *loginLockout) attempt(addr string, when time.Time) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.tooManyFailures(addr, when) {
+		return false
+	}
+
+	// Keep only the failures that are recent enough to matter, including
+	// those that may be keeping an ongoing lockout in effect.
+	var recent []time.Time
+	for _, t := range s.failures[addr] {
+		if when.Sub(t) < failedLoginWindow+loginLockoutPeriod {
+			recent = append(recent, t)
+		}
+	}
+	s.failures[addr] = append(recent, when)
+	return true
+}
+
+// forget removes an attempt that turned out not to be a failure.
+func (s *loginLockout) forget(addr string, when time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	attempts := s.failures[addr]
+	for i, t := range attempts {
+		if t.Equal(when) {
+			s.failures[addr] = append(attempts[:i], attempts[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *loginLockout) succeeded(addr string) {
+	s.mut.Lock()
+	delete(s.failures, addr)
+	s.mut.Unlock()
+}
+
+// locked returns true if the given remote address has failed to log in at
+// least maxFailedLogins times within failedLoginWindow, and the last of
+// those failures was less than loginLockoutPeriod ago.
+func (s *loginLockout) locked(addr string, now time.Time) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.tooManyFailures(addr, now)
+}
+
+func (s *loginLockout) tooManyFailures(addr string, now time.Time) bool {
+	attempts := s.failures[addr]
+	if len(attempts) < maxFailedLogins {
+		return false
+	}
+
+	last := attempts[len(attempts)-1]
+	first := attempts[len(attempts)-maxFailedLogins]
+	return last.Sub(first) < failedLoginWindow && now.Sub(last) < loginLockoutPeriod
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func (s *apiService) getSystemIdentity(w http.ResponseWriter, r *http.Request) {
+	res := map[string]interface{}{
+		"myID": myID.String(),
+	}
+
+	next, err := tls.LoadX509KeyPair(locations[locNextCertFile], locations[locNextKeyFile])
+	if err == nil {
+		res["nextID"] = protocol.NewDeviceID(next.Certificate[0]).String()
+
+		// Only devices that confirmed the migration will know us under the
+		// new ID; the others have to be updated by hand.
+		confirmed := make(map[protocol.DeviceID]bool)
+		for _, id := range s.model.IdentityMigrationConfirmed() {
+			confirmed[id] = true
+		}
+		var done, waiting []string
+		for id := range s.cfg.Devices() {
+			switch {
+			case id == myID:
+			case confirmed[id]:
+				done = append(done, id.String())
+			default:
+				waiting = append(waiting, id.String())
+			}
+		}
+		res["confirmed"] = done
+		res["notConfirmed"] = waiting
+	}
+
+	sendJSON(w, res)
+}
+
+func (s *apiService) postSystemIdentityRotate(w http.ResponseWriter, r *http.Request) {
+	cert, err := tls.LoadX509KeyPair(locations[locCertFile], locations[locKeyFile])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	migration, err := startIdentityRotation(cert)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.model.SetIdentityMigration(migration)
+	s.getSystemIdentity(w, r)
+}
+
+func (s *apiService) postSystemIdentityCancel(w http.ResponseWriter, r *http.Request) {
+	if err := cancelIdentityRotation(); err == errNoIdentityRotation {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.model.SetIdentityMigration(nil)
+}
+
+func (s *apiService) postSystemIdentityComplete(w http.ResponseWriter, r *http.Request) {
+	if err := completeIdentityRotation(); err == errNoIdentityRotation {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.flushResponse(`{"ok": "restarting"}`, w)
+	go restart()
+}
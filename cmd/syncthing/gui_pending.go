@@ -0,0 +1,145 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Pending devices and folders are those that have been rejected because
+// they are unknown or not shared. They are kept in the database until
+// they are accepted, by adding them to the configuration, or dismissed.
+
+func (s *apiService) getPendingDevices(w http.ResponseWriter, r *http.Request) {
+	res := make(map[string]db.PendingDevice)
+	for id, pd := range s.model.PendingDevices() {
+		res[id.String()] = pd
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) getPendingFolders(w http.ResponseWriter, r *http.Request) {
+	res := make(map[string]map[string]map[string]db.PendingFolder)
+	for folder, offers := range s.model.PendingFolders() {
+		offeredBy := make(map[string]db.PendingFolder, len(offers))
+		for id, pf := range offers {
+			offeredBy[id.String()] = pf
+		}
+		res[folder] = map[string]map[string]db.PendingFolder{
+			"offeredBy": offeredBy,
+		}
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) postPendingDevicesAccept(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	qs := r.URL.Query()
+	id, err := protocol.DeviceIDFromString(qs.Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pd, ok := s.model.PendingDevices()[id]
+	if !ok {
+		http.Error(w, "No such pending device", http.StatusNotFound)
+		return
+	}
+
+	name := qs.Get("name")
+	if name == "" {
+		name = pd.Name
+	}
+
+	deviceCfg := config.NewDeviceConfiguration(id, name)
+	if pd.IntroducedBy != "" {
+		// Approving an introduced device. The introducer shares its
+		// folders with it on the next cluster config.
+		deviceCfg.IntroducedBy, err = protocol.DeviceIDFromString(pd.IntroducedBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	raw := s.cfg.RawCopy()
+	raw.Devices = append(raw.Devices, deviceCfg)
+	// Removed from the pending devices once the configuration is committed
+	s.replaceConfig(w, r, raw)
+}
+
+func (s *apiService) postPendingDevicesDismiss(w http.ResponseWriter, r *http.Request) {
+	id, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.model.DismissPendingDevice(id)
+}
+
+func (s *apiService) postPendingFoldersAccept(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+	id, err := protocol.DeviceIDFromString(qs.Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pf, ok := s.model.PendingFolders()[folder][id]
+	if !ok {
+		http.Error(w, "No such pending folder", http.StatusNotFound)
+		return
+	}
+
+	raw := s.cfg.RawCopy()
+	share := config.FolderDeviceConfiguration{DeviceID: id}
+	for i := range raw.Folders {
+		if raw.Folders[i].ID == folder {
+			// We have the folder, but don't share it with the device
+			raw.Folders[i].Devices = append(raw.Folders[i].Devices, share)
+			s.replaceConfig(w, r, raw)
+			return
+		}
+	}
+
+	path := qs.Get("path")
+	if path == "" {
+		http.Error(w, "A path must be given for a new folder", http.StatusBadRequest)
+		return
+	}
+	folderCfg := config.NewDefaultFolderConfiguration(myID, folder, pf.Label, path)
+	folderCfg.Devices = append(folderCfg.Devices, share)
+	raw.Folders = append(raw.Folders, folderCfg)
+	s.replaceConfig(w, r, raw)
+}
+
+func (s *apiService) getIntroductions(w http.ResponseWriter, r *http.Request) {
+	res := s.model.Introductions()
+	if res == nil {
+		res = []db.Introduction{}
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) postPendingFoldersDismiss(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	id, err := protocol.DeviceIDFromString(qs.Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.model.DismissPendingFolder(qs.Get("folder"), id)
+}
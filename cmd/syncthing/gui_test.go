@@ -16,6 +16,8 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -24,6 +26,7 @@ import (
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 	"github.com/thejerf/suture"
@@ -71,7 +74,7 @@ func TestStopAfterBrokenConfig(t *testing.T) {
 	}
 	w := config.Wrap("/dev/null", cfg)
 
-	srv := newAPIService(protocol.LocalDeviceID, w, "../../test/h1/https-cert.pem", "../../test/h1/https-key.pem", "", nil, nil, nil, nil, nil, nil, nil)
+	srv := newAPIService(protocol.LocalDeviceID, w, "../../test/h1/https-cert.pem", "../../test/h1/https-key.pem", "", nil, nil, nil, nil, nil, nil, nil, nil)
 	srv.started = make(chan string)
 
 	sup := suture.NewSimple("test")
@@ -210,6 +213,28 @@ func TestAPIServiceRequests(t *testing.T) {
 			URL:  "/rest/db/file?folder=default&file=something",
 			Code: 404,
 		},
+		{
+			URL:    "/rest/audit?folder=default&since=2017-10-01T00:00:00Z",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "[",
+		},
+		{
+			URL:  "/rest/audit?folder=default&since=yesterday",
+			Code: 400,
+		},
+		{
+			URL:    "/rest/audit/verify?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "{",
+		},
+		{
+			URL:    "/rest/db/history?folder=default&file=something",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "[",
+		},
 		{
 			URL:    "/rest/db/ignores?folder=default",
 			Code:   200,
@@ -465,6 +490,10 @@ func TestHTTPLogin(t *testing.T) {
 }
 
 func startHTTP(cfg *mockedConfig) (string, error) {
+	return startHTTPWithFaults(cfg, fs.NewFaultFilesystem(fs.NewMemoryFilesystem(fs.MemoryOptions{})))
+}
+
+func startHTTPWithFaults(cfg *mockedConfig, faults *fs.FaultFilesystem) (string, error) {
 	model := new(mockedModel)
 	httpsCertFile := "../../test/h1/https-cert.pem"
 	httpsKeyFile := "../../test/h1/https-key.pem"
@@ -479,7 +508,7 @@ func startHTTP(cfg *mockedConfig) (string, error) {
 
 	// Instantiate the API service
 	svc := newAPIService(protocol.LocalDeviceID, cfg, httpsCertFile, httpsKeyFile, assetDir, model,
-		eventSub, diskEventSub, discoverer, connections, errorLog, systemLog)
+		eventSub, diskEventSub, discoverer, connections, errorLog, systemLog, faults)
 	svc.started = addrChan
 
 	// Actually start the API service
@@ -489,6 +518,10 @@ func startHTTP(cfg *mockedConfig) (string, error) {
 
 	// Make sure the API service is listening, and get the URL to use.
 	addr := <-addrChan
+	if cfg.gui.Network() == "unix" {
+		// The caller needs to dial the socket; any host name will do.
+		return "http://localhost", nil
+	}
 	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
 	if err != nil {
 		return "", fmt.Errorf("Weird address from API service: %v", err)
@@ -576,6 +609,96 @@ func TestCSRFRequired(t *testing.T) {
 	}
 }
 
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "gui.sock")
+
+	cfg := new(mockedConfig)
+	cfg.gui.RawAddress = "unix://" + sock
+	cfg.gui.UnixSocketPermissions = "0600"
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode() & os.ModePerm; perm != 0600 {
+		t.Errorf("Incorrect socket permissions %o", perm)
+	}
+
+	cli := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("unix", sock)
+			},
+		},
+	}
+
+	// Calling on /rest without CSRF token or API key should fail, as the
+	// socket isn't configured as trusted.
+
+	resp, err := cli.Get(baseURL + "/rest/system/config")
+	if err != nil {
+		t.Fatal("Unexpected error from getting /rest/system/config:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("Getting /rest/system/config over Unix socket should fail, not", resp.Status)
+	}
+	if resp.Header.Get("X-Syncthing-Trusted-Socket") != "" {
+		t.Error("Untrusted Unix socket claims to be trusted")
+	}
+}
+
+func TestUnixSocketTrusted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "gui.sock")
+
+	cfg := new(mockedConfig)
+	cfg.gui.RawAddress = "unix://" + sock
+	cfg.gui.UnixSocketTrusted = true
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("unix", sock)
+			},
+		},
+	}
+
+	// Calling on /rest without CSRF token or API key should succeed, as
+	// access is governed by the socket permissions.
+
+	resp, err := cli.Get(baseURL + "/rest/system/config")
+	if err != nil {
+		t.Fatal("Unexpected error from getting /rest/system/config:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("Getting /rest/system/config over trusted Unix socket should succeed, not", resp.Status)
+	}
+	if resp.Header.Get("X-Syncthing-Trusted-Socket") != "true" {
+		t.Error("Trusted Unix socket not announced as such")
+	}
+}
+
 func TestRandomString(t *testing.T) {
 	const testAPIKey = "foobarbaz"
 	cfg := new(mockedConfig)
@@ -677,6 +800,81 @@ func testConfigPost(data io.Reader) (*http.Response, error) {
 	return cli.Do(req)
 }
 
+func TestDebugFaults(t *testing.T) {
+	const testAPIKey = "foobarbaz"
+	cfg := new(mockedConfig)
+	cfg.gui.APIKey = testAPIKey
+	cfg.gui.Debugging = true
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := &http.Client{
+		Timeout: time.Second,
+	}
+
+	post := func(body string) int {
+		req, _ := http.NewRequest("POST", baseURL+"/rest/debug/faults", strings.NewReader(body))
+		req.Header.Set("X-API-Key", testAPIKey)
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post(`[{"pattern": "*.tmp", "ops": ["write"], "error": "ENOSPC"}]`); code != http.StatusOK {
+		t.Fatal("Setting rules: unexpected status", code)
+	}
+	if code := post(`[{"error": "EWHATEVER"}]`); code != http.StatusBadRequest {
+		t.Error("Setting invalid rules: unexpected status", code)
+	}
+
+	req, _ := http.NewRequest("GET", baseURL+"/rest/debug/faults", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []fs.FaultRule
+	err = json.NewDecoder(resp.Body).Decode(&rules)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Pattern != "*.tmp" || rules[0].Error != "ENOSPC" {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+}
+
+func TestDebugFaultsDisabled(t *testing.T) {
+	const testAPIKey = "foobarbaz"
+	cfg := new(mockedConfig)
+	cfg.gui.APIKey = testAPIKey
+	cfg.gui.Debugging = true
+	baseURL, err := startHTTPWithFaults(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := &http.Client{
+		Timeout: time.Second,
+	}
+
+	for _, method := range []string{"GET", "POST"} {
+		req, _ := http.NewRequest(method, baseURL+"/rest/debug/faults", strings.NewReader(`[]`))
+		req.Header.Set("X-API-Key", testAPIKey)
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s without fault injection: unexpected status %d", method, resp.StatusCode)
+		}
+	}
+}
+
 func TestHostCheck(t *testing.T) {
 	// An API service bound to localhost should reject non-localhost host Headers
 
@@ -930,7 +1128,7 @@ func TestEventMasks(t *testing.T) {
 	cfg := new(mockedConfig)
 	defSub := new(mockedEventSub)
 	diskSub := new(mockedEventSub)
-	svc := newAPIService(protocol.LocalDeviceID, cfg, "", "", "", nil, defSub, diskSub, nil, nil, nil, nil)
+	svc := newAPIService(protocol.LocalDeviceID, cfg, "", "", "", nil, defSub, diskSub, nil, nil, nil, nil, nil)
 
 	if mask := svc.getEventMask(""); mask != defaultEventMask {
 		t.Errorf("incorrect default mask %x != %x", int64(mask), int64(defaultEventMask))
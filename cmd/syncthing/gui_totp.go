@@ -0,0 +1,315 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpPeriod        = 30 * time.Second
+	totpDigits        = 6
+	totpSkewSteps     = 1  // accepted clock skew, in periods, in either direction
+	totpSecretBytes   = 20 // 160 bits, as recommended by RFC 4226
+	totpRecoveryCodes = 10
+	totpLoginPath     = "/login/totp"
+)
+
+var (
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	// The last accepted time step per secret, to prevent replay of a code
+	// within its validity period.
+	totpLastStep    = make(map[string]int64)
+	totpLastStepMut = sync.NewMutex()
+)
+
+// newTOTPSecret returns a new random base32 encoded TOTP secret.
+func newTOTPSecret() (string, error) {
+	bs := make([]byte, totpSecretBytes)
+	if _, err := io.ReadFull(rand.Reader, bs); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(bs), nil
+}
+
+// totpURI returns the otpauth:// URI used to enroll the given secret in an
+// authenticator app, usually by scanning it as a QR code.
+func totpURI(secret, user string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", "Syncthing")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/Syncthing:" + user,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// totpCode computes the RFC 6238 code for the given secret and time step.
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod)
+}
+
+// validTOTPCode returns true if the code is valid for the secret at the
+// given time and has not been used before.
+func validTOTPCode(secret, code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return false
+	}
+
+	step := now.Unix() / int64(totpPeriod/time.Second)
+	for i := -totpSkewSteps; i <= totpSkewSteps; i++ {
+		cur := step + int64(i)
+		if !hmac.Equal([]byte(totpCode(key, cur)), []byte(code)) {
+			continue
+		}
+
+		totpLastStepMut.Lock()
+		defer totpLastStepMut.Unlock()
+		if cur <= totpLastStep[secret] {
+			// Already used
+			return false
+		}
+		totpLastStep[secret] = cur
+		return true
+	}
+
+	return false
+}
+
+// newRecoveryCodes returns a set of new recovery codes in plain text, to be
+// shown to the user once, and as bcrypt hashes, to be stored in the config.
+func newRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, totpRecoveryCodes)
+	hashed := make([]string, totpRecoveryCodes)
+	for i := range plain {
+		code := strings.ToLower(rand.String(10))
+		plain[i] = code[:5] + "-" + code[5:]
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashed[i] = string(hash)
+	}
+	return plain, hashed, nil
+}
+
+// matchRecoveryCode returns the index of the hashed recovery code matching
+// the given code, or -1.
+func matchRecoveryCode(hashed []string, code string) int {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 10 {
+		return -1
+	}
+	for i, hash := range hashed {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// verifySecondFactor checks the given code as either a TOTP code or a
+// recovery code. A matching recovery code is removed from the
+// configuration, so that it can't be used again. The match and the removal
+// are done under configMut, against the same copy of the configuration, so
+// that a recovery code can't be used by two concurrent logins.
+func verifySecondFactor(cfg configIntf, configMut sync.Mutex, code string) bool {
+	if validTOTPCode(cfg.GUI().TOTPSecret, code, time.Now()) {
+		return true
+	}
+
+	configMut.Lock()
+	defer configMut.Unlock()
+
+	raw := cfg.RawCopy()
+	idx := matchRecoveryCode(raw.GUI.TOTPRecoveryCodes, code)
+	if idx < 0 {
+		return false
+	}
+
+	raw.GUI.TOTPRecoveryCodes = append(raw.GUI.TOTPRecoveryCodes[:idx], raw.GUI.TOTPRecoveryCodes[idx+1:]...)
+	if err := cfg.Replace(raw); err != nil {
+		l.Warnln("Removing used recovery code:", err)
+		return false
+	}
+	if err := cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+	}
+	l.Infof("Recovery code used for GUI login; %d remaining", len(raw.GUI.TOTPRecoveryCodes))
+	return true
+}
+
+// redactTOTP hides the second factor secrets, which are kept out of the
+// API, while still showing whether they're set.
+func redactTOTP(cfg *config.GUIConfiguration) {
+	if cfg.TOTPSecret != "" {
+		cfg.TOTPSecret = "redacted"
+	}
+	for i := range cfg.TOTPRecoveryCodes {
+		cfg.TOTPRecoveryCodes[i] = "redacted"
+	}
+}
+
+// serveTOTPForm responds with a minimal page asking for the second factor.
+// It's sent with status 401, but without a WWW-Authenticate header, so that
+// the browser keeps using the already given basic auth credentials.
+func serveTOTPForm(w http.ResponseWriter, failed bool) {
+	msg := ""
+	if failed {
+		msg = `<p class="error">Invalid code, please try again.</p>`
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprintf(w, totpFormHTML, msg, totpLoginPath)
+}
+
+const totpFormHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Syncthing</title>
+<style>
+body { font-family: sans-serif; margin: 4em auto; max-width: 20em; }
+input { font-size: 1.5em; width: 100%%; margin: 0.5em 0; }
+.error { color: #d9534f; }
+</style>
+</head>
+<body>
+<h2>Two-factor authentication</h2>
+%s
+<form method="post" action="%s">
+<label for="code">Authentication or recovery code</label>
+<input id="code" name="code" autocomplete="one-time-code" autofocus required>
+<input type="submit" value="Verify">
+</form>
+</body>
+</html>
+`
+
+// The enrollment flow is in two steps: a new secret is generated and
+// returned to the user, and is only activated once the user has proven
+// that it's correctly set up by sending a valid code for it.
+
+func (s *apiService) postSystemTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	guiCfg := s.cfg.GUI()
+	if guiCfg.User == "" || guiCfg.Password == "" {
+		http.Error(w, "GUI user and password must be set to enable two-factor authentication", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.pendingTOTPSecret = secret
+
+	sendJSON(w, map[string]string{
+		"secret": secret,
+		"uri":    totpURI(secret, guiCfg.User),
+	})
+}
+
+func (s *apiService) postSystemTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	if s.pendingTOTPSecret == "" {
+		http.Error(w, "No pending enrollment", http.StatusBadRequest)
+		return
+	}
+	if !validTOTPCode(s.pendingTOTPSecret, r.URL.Query().Get("code"), time.Now()) {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	plain, hashed, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	raw := s.cfg.RawCopy()
+	raw.GUI.TOTPSecret = s.pendingTOTPSecret
+	raw.GUI.TOTPRecoveryCodes = hashed
+	if err := s.replaceGUIConfig(w, r, raw); err != nil {
+		return
+	}
+	s.pendingTOTPSecret = ""
+
+	sendJSON(w, map[string][]string{
+		"recoveryCodes": plain,
+	})
+}
+
+func (s *apiService) postSystemTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	raw := s.cfg.RawCopy()
+	raw.GUI.TOTPSecret = ""
+	raw.GUI.TOTPRecoveryCodes = nil
+	s.replaceGUIConfig(w, r, raw)
+}
+
+func (s *apiService) replaceGUIConfig(w http.ResponseWriter, r *http.Request, to config.Configuration) error {
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	if err := s.cfg.SaveFrom(requestOrigin(r)); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	return nil
+}
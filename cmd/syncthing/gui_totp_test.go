@@ -0,0 +1,245 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors from RFC 6238, appendix B, truncated to six digits.
+	key := []byte("12345678901234567890")
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range cases {
+		if code := totpCode(key, tc.unix/30); code != tc.code {
+			t.Errorf("totpCode at %d = %s, expected %s", tc.unix, code, tc.code)
+		}
+	}
+}
+
+func TestValidTOTPCode(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+
+	now := time.Unix(1500000000, 0)
+	step := now.Unix() / 30
+
+	if validTOTPCode(secret, totpCode(key, step+5), now) {
+		t.Error("code from the future should not be valid")
+	}
+	if !validTOTPCode(secret, totpCode(key, step-1), now) {
+		t.Error("code from the previous period should be valid")
+	}
+	if !validTOTPCode(secret, totpCode(key, step), now) {
+		t.Error("current code should be valid")
+	}
+	if validTOTPCode(secret, totpCode(key, step), now) {
+		t.Error("current code should not be valid a second time")
+	}
+	if validTOTPCode(secret, totpCode(key, step-1), now) {
+		t.Error("older code should not be valid after a newer one was used")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	plain, hashed, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != totpRecoveryCodes || len(hashed) != totpRecoveryCodes {
+		t.Fatalf("unexpected number of codes, %d and %d", len(plain), len(hashed))
+	}
+
+	if idx := matchRecoveryCode(hashed, plain[3]); idx != 3 {
+		t.Errorf("recovery code matched at %d, expected 3", idx)
+	}
+	if idx := matchRecoveryCode(hashed, "notar-ecode"); idx != -1 {
+		t.Errorf("invalid recovery code matched at %d", idx)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newLoginLockout()
+	now := time.Now()
+
+	for i := 0; i < maxFailedLogins-1; i++ {
+		if !s.attempt("192.0.2.42", now) {
+			t.Fatalf("attempt %d refused", i)
+		}
+	}
+	if s.locked("192.0.2.42", now) {
+		t.Error("should not be locked out yet")
+	}
+
+	if !s.attempt("192.0.2.42", now) {
+		t.Fatal("last attempt refused")
+	}
+	if !s.locked("192.0.2.42", now) || s.attempt("192.0.2.42", now) {
+		t.Error("should be locked out")
+	}
+	if s.locked("192.0.2.43", now) {
+		t.Error("other address should not be locked out")
+	}
+	if s.locked("192.0.2.42", now.Add(loginLockoutPeriod)) {
+		t.Error("lockout should have expired")
+	}
+
+	s.succeeded("192.0.2.42")
+	if s.locked("192.0.2.42", now) {
+		t.Error("successful login should clear the lockout")
+	}
+}
+
+func TestLoginLockoutParallel(t *testing.T) {
+	s := newLoginLockout()
+	now := time.Now()
+
+	wg := sync.NewWaitGroup()
+	mut := sync.NewMutex()
+	allowed := 0
+	for i := 0; i < 4*maxFailedLogins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.attempt("192.0.2.42", now) {
+				mut.Lock()
+				allowed++
+				mut.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != maxFailedLogins {
+		t.Errorf("%d parallel attempts allowed, expected %d", allowed, maxFailedLogins)
+	}
+}
+
+func TestLoginAddress(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 192.0.2.42")
+
+	if addr := loginAddress(r, false); addr != "127.0.0.1" {
+		t.Errorf("got %q without trusting the proxy", addr)
+	}
+	if addr := loginAddress(r, true); addr != "192.0.2.42" {
+		t.Errorf("got %q trusting the proxy", addr)
+	}
+}
+
+func TestHTTPLoginTOTP(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+
+	cfg := new(mockedConfig)
+	cfg.gui.User = "üser"
+	cfg.gui.Password = "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq" // bcrypt of "räksmörgås" in UTF-8
+	cfg.gui.TOTPSecret = secret
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that the password alone results in the code being asked for
+
+	req, _ := http.NewRequest("GET", baseURL, nil)
+	req.SetBasicAuth("üser", "räksmörgås")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected non-401 return code %d for request without code", resp.StatusCode)
+	}
+	if len(resp.Cookies()) != 0 {
+		t.Error("Unexpected session cookie for request without code")
+	}
+
+	// Verify that an incorrect code is rejected
+
+	req.Header.Set("X-TOTP-Code", "000000")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected non-401 return code %d for incorrect code", resp.StatusCode)
+	}
+
+	// Verify that the correct code is accepted
+
+	req.Header.Set("X-TOTP-Code", totpCode(key, time.Now().Unix()/30))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected non-200 return code %d for correct code", resp.StatusCode)
+	}
+}
+
+func TestRecoveryCodeUsedOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "totp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain, hashed, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.New(myID)
+	cfg.GUI.TOTPRecoveryCodes = hashed
+	w := config.Wrap(filepath.Join(dir, "config.xml"), cfg)
+	configMut := sync.NewMutex()
+
+	// Concurrent logins with the same code
+	accepted := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			accepted <- verifySecondFactor(w, configMut, plain[3])
+		}()
+	}
+	if a, b := <-accepted, <-accepted; a == b {
+		t.Errorf("recovery code accepted %v and %v, expected exactly once", a, b)
+	}
+
+	codes := w.GUI().TOTPRecoveryCodes
+	if len(codes) != len(hashed)-1 {
+		t.Fatalf("%d recovery codes left, expected %d", len(codes), len(hashed)-1)
+	}
+	for _, code := range codes {
+		if code == hashed[3] {
+			t.Error("the wrong recovery code was removed")
+		}
+	}
+}
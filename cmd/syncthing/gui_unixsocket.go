@@ -0,0 +1,104 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+// listenUnixSocket creates the Unix socket for the GUI and sets the
+// configured permissions and owner on it. A stale socket file from an
+// earlier run is removed first.
+func listenUnixSocket(guiCfg config.GUIConfiguration) (net.Listener, error) {
+	path := guiCfg.Address()
+
+	mode, err := guiCfg.UnixSocketFileMode()
+	if err != nil {
+		return nil, fmt.Errorf("unix socket permissions: %v", err)
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// A left over socket file would make the listen fail. If there is
+		// someone still listening on it we can't get at it anyway.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket %s is in use", path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	if guiCfg.UnixSocketOwner != "" {
+		uid, gid, err := lookupOwner(guiCfg.UnixSocketOwner)
+		if err == nil {
+			err = os.Chown(path, uid, gid)
+		}
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("unix socket owner: %v", err)
+		}
+	}
+
+	return listener, nil
+}
+
+// lookupOwner resolves an owner given as "user" or "user:group", by name
+// or numeric ID, into a uid and gid. A gid of -1 leaves the group
+// unchanged.
+func lookupOwner(owner string) (int, int, error) {
+	userName, groupName := owner, ""
+	if idx := strings.IndexByte(owner, ':'); idx >= 0 {
+		userName, groupName = owner[:idx], owner[idx+1:]
+	}
+
+	uid, gid := -1, -1
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			u, err = user.LookupId(userName)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			g, err = user.LookupGroupId(groupName)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Rotating the device certificate happens in two steps. First a new
+// certificate is generated next to the current one, and the move to it is
+// announced to the other devices while we're still connecting with the
+// current certificate. The other devices confirm the announcement by
+// sending it back, and keep knowing us under the current ID until we
+// connect with the new certificate. Once the other devices have confirmed,
+// the new certificate replaces the current one, which is kept as a backup,
+// and we restart under the new identity. On that start the config is moved
+// over from the previous device ID to the new one.
+
+var errNoIdentityRotation = errors.New("no identity rotation in progress")
+
+// loadIdentityMigration returns the migration from the current certificate
+// to the next one, or nil if there is no next certificate.
+func loadIdentityMigration(cert tls.Certificate) (*protocol.IdentityMigration, error) {
+	next, err := tls.LoadX509KeyPair(locations[locNextCertFile], locations[locNextKeyFile])
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	migration, err := protocol.NewIdentityMigration(cert, next)
+	if err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+// startIdentityRotation generates the next certificate, replacing any
+// previously generated one, and returns the migration to it.
+func startIdentityRotation(cert tls.Certificate) (*protocol.IdentityMigration, error) {
+	next, err := newDeviceCertificate(locations[locNextCertFile], locations[locNextKeyFile])
+	if err != nil {
+		return nil, err
+	}
+
+	migration, err := protocol.NewIdentityMigration(cert, next)
+	if err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+// cancelIdentityRotation removes the next certificate.
+func cancelIdentityRotation() error {
+	if _, err := os.Stat(locations[locNextCertFile]); os.IsNotExist(err) {
+		return errNoIdentityRotation
+	}
+	if err := os.Remove(locations[locNextCertFile]); err != nil {
+		return err
+	}
+	return os.Remove(locations[locNextKeyFile])
+}
+
+// completeIdentityRotation moves the next certificate in place of the
+// current one, which is kept as the previous certificate. The new identity
+// takes effect on restart.
+func completeIdentityRotation() error {
+	if _, err := os.Stat(locations[locNextCertFile]); os.IsNotExist(err) {
+		return errNoIdentityRotation
+	}
+
+	moves := []struct{ from, to string }{
+		{locations[locCertFile], locations[locPreviousCertFile]},
+		{locations[locKeyFile], locations[locPreviousKeyFile]},
+		{locations[locNextCertFile], locations[locCertFile]},
+		{locations[locNextKeyFile], locations[locKeyFile]},
+	}
+	for _, move := range moves {
+		if err := os.Rename(move.from, move.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateOwnIdentity moves the config at cfgFile over to our new device ID
+// from the ID of the previous certificate, after an identity rotation. It
+// does nothing when there is no previous certificate or config, or when the
+// config already knows the new ID.
+func migrateOwnIdentity(cfgFile, previousCertFile, previousKeyFile string, myID protocol.DeviceID) error {
+	previous, err := tls.LoadX509KeyPair(previousCertFile, previousKeyFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	previousID := protocol.NewDeviceID(previous.Certificate[0])
+	if previousID == myID {
+		return nil
+	}
+
+	cfg, err := config.Load(cfgFile, previousID)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, ok := cfg.Device(myID); ok {
+		return nil
+	}
+
+	l.Infof("Moving the config from previous device ID %s to %s", previousID, myID)
+	if err := cfg.MigrateDevice(previousID, myID); err != nil {
+		return err
+	}
+	return cfg.Save()
+}
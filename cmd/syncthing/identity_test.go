@@ -0,0 +1,86 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestMigrateOwnIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previousCertFile := filepath.Join(dir, "cert-previous.pem")
+	previousKeyFile := filepath.Join(dir, "key-previous.pem")
+	cfgFile := filepath.Join(dir, "config.xml")
+
+	previous, err := tlsutil.NewCertificate(previousCertFile, previousKeyFile, "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := tlsutil.NewCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousID := protocol.NewDeviceID(previous.Certificate[0])
+	myID := protocol.NewDeviceID(current.Certificate[0])
+	other := protocol.DeviceID{1, 2, 3}
+
+	// Without a config there is nothing to do
+	if err := migrateOwnIdentity(cfgFile, previousCertFile, previousKeyFile, myID); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := config.New(previousID)
+	raw.Devices = append(raw.Devices, config.NewDeviceConfiguration(other, "other"))
+	for _, id := range []string{"one", "two"} {
+		folder := config.NewDefaultFolderConfiguration(previousID, id, id, filepath.Join(dir, id))
+		folder.Devices = append(folder.Devices, config.FolderDeviceConfiguration{DeviceID: other})
+		raw.Folders = append(raw.Folders, folder)
+	}
+	if err := config.Wrap(cfgFile, raw).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating a second time, as on the following start, changes nothing
+	for i := 0; i < 2; i++ {
+		if err := migrateOwnIdentity(cfgFile, previousCertFile, previousKeyFile, myID); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := config.Load(cfgFile, myID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cfg.Devices()[previousID]; ok {
+			t.Error("Previous device ID is still in the config")
+		}
+		if _, ok := cfg.Devices()[myID]; !ok {
+			t.Error("New device ID is not in the config")
+		}
+		for _, folder := range cfg.Folders() {
+			if len(folder.Devices) != 2 {
+				t.Errorf("Folder %s is shared with %v", folder.ID, folder.DeviceIDs())
+			}
+			for _, dev := range folder.DeviceIDs() {
+				if dev == previousID {
+					t.Errorf("Folder %s is still shared with the previous device ID", folder.ID)
+				}
+			}
+		}
+	}
+}
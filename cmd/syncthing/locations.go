@@ -21,18 +21,23 @@ type locationEnum string
 // Use strings as keys to make printout and serialization of the locations map
 // more meaningful.
 const (
-	locConfigFile    locationEnum = "config"
-	locCertFile                   = "certFile"
-	locKeyFile                    = "keyFile"
-	locHTTPSCertFile              = "httpsCertFile"
-	locHTTPSKeyFile               = "httpsKeyFile"
-	locDatabase                   = "database"
-	locLogFile                    = "logFile"
-	locCsrfTokens                 = "csrfTokens"
-	locPanicLog                   = "panicLog"
-	locAuditLog                   = "auditLog"
-	locGUIAssets                  = "GUIAssets"
-	locDefFolder                  = "defFolder"
+	locConfigFile       locationEnum = "config"
+	locConfigHistory                 = "configHistory"
+	locCertFile                      = "certFile"
+	locKeyFile                       = "keyFile"
+	locNextCertFile                  = "nextCertFile"
+	locNextKeyFile                   = "nextKeyFile"
+	locPreviousCertFile              = "previousCertFile"
+	locPreviousKeyFile               = "previousKeyFile"
+	locHTTPSCertFile                 = "httpsCertFile"
+	locHTTPSKeyFile                  = "httpsKeyFile"
+	locDatabase                      = "database"
+	locLogFile                       = "logFile"
+	locCsrfTokens                    = "csrfTokens"
+	locPanicLog                      = "panicLog"
+	locAuditLog                      = "auditLog"
+	locGUIAssets                     = "GUIAssets"
+	locDefFolder                     = "defFolder"
 )
 
 // Platform dependent directories
@@ -43,18 +48,23 @@ var baseDirs = map[string]string{
 
 // Use the variables from baseDirs here
 var locations = map[locationEnum]string{
-	locConfigFile:    "${config}/config.xml",
-	locCertFile:      "${config}/cert.pem",
-	locKeyFile:       "${config}/key.pem",
-	locHTTPSCertFile: "${config}/https-cert.pem",
-	locHTTPSKeyFile:  "${config}/https-key.pem",
-	locDatabase:      "${config}/index-v0.14.0.db",
-	locLogFile:       "${config}/syncthing.log", // -logfile on Windows
-	locCsrfTokens:    "${config}/csrftokens.txt",
-	locPanicLog:      "${config}/panic-${timestamp}.log",
-	locAuditLog:      "${config}/audit-${timestamp}.log",
-	locGUIAssets:     "${config}/gui",
-	locDefFolder:     "${home}/Sync",
+	locConfigFile:       "${config}/config.xml",
+	locConfigHistory:    "${config}/config-history",
+	locCertFile:         "${config}/cert.pem",
+	locKeyFile:          "${config}/key.pem",
+	locNextCertFile:     "${config}/cert-next.pem",
+	locNextKeyFile:      "${config}/key-next.pem",
+	locPreviousCertFile: "${config}/cert-previous.pem",
+	locPreviousKeyFile:  "${config}/key-previous.pem",
+	locHTTPSCertFile:    "${config}/https-cert.pem",
+	locHTTPSKeyFile:     "${config}/https-key.pem",
+	locDatabase:         "${config}/index-v0.14.0.db",
+	locLogFile:          "${config}/syncthing.log", // -logfile on Windows
+	locCsrfTokens:       "${config}/csrftokens.txt",
+	locPanicLog:         "${config}/panic-${timestamp}.log",
+	locAuditLog:         "${config}/audit-${timestamp}.log",
+	locGUIAssets:        "${config}/gui",
+	locDefFolder:        "${home}/Sync",
 }
 
 // expandLocations replaces the variables in the location map with actual
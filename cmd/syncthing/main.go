@@ -37,12 +37,14 @@ import (
 	"github.com/syncthing/syncthing/lib/dialer"
 	"github.com/syncthing/syncthing/lib/discover"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/sha256"
+	"github.com/syncthing/syncthing/lib/syncthing"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/weakhash"
@@ -76,21 +78,11 @@ const (
 )
 
 const (
-	bepProtocolName      = "bep/1.0"
-	tlsDefaultCommonName = "syncthing"
-	httpsRSABits         = 2048
-	bepRSABits           = 0 // 384 bit ECDSA used instead
-	defaultEventTimeout  = time.Minute
-	maxSystemErrors      = 5
-	initialSystemLog     = 10
-	maxSystemLog         = 250
-)
-
-// The discovery results are sorted by their source priority.
-const (
-	ipv6LocalDiscoveryPriority = iota
-	ipv4LocalDiscoveryPriority
-	globalDiscoveryPriority
+	httpsRSABits        = 2048
+	defaultEventTimeout = time.Minute
+	maxSystemErrors     = 5
+	initialSystemLog    = 10
+	maxSystemLog        = 250
 )
 
 func init() {
@@ -134,9 +126,9 @@ func setBuildMetadata() {
 }
 
 var (
-	myID protocol.DeviceID
-	stop = make(chan int)
-	lans []*net.IPNet
+	myID       protocol.DeviceID
+	stop       = make(chan int)
+	bepKeyType = tlsutil.KeyTypeECDSA // for newly generated device certificates
 )
 
 const (
@@ -201,6 +193,16 @@ are mostly useful for developers. Use with care.
 
  STNOUPGRADE       Disable automatic upgrades.
 
+ STFAULTINJECTION  Route all folder access through the filesystem fault
+                   injector, whose rules are set over /rest/debug/faults.
+                   For testing only; never use with data you care about.
+
+ STDBBACKEND       Select the database backend used when creating a new
+                   index database. Possible values are "leveldb" (the
+                   default) and "bolt". An existing database keeps the
+                   backend it was created with; use "stindex -mode migrate"
+                   to convert it to another backend.
+
  STHASHING         Select the SHA256 hashing package to use. Possible values
                    are "standard" for the Go standard library implementation,
                    "minio" for the github.com/minio/sha256-simd implementation,
@@ -233,6 +235,9 @@ type RuntimeOptions struct {
 	confDir        string
 	resetDatabase  bool
 	resetDeltaIdxs bool
+	backupDB       string
+	exportDB       string
+	importDB       string
 	showVersion    bool
 	showPaths      bool
 	doUpgrade      bool
@@ -250,22 +255,27 @@ type RuntimeOptions struct {
 	guiAddress     string
 	guiAPIKey      string
 	generateDir    string
+	keyType        string
+	provision      string
+	provisionDry   bool
 	noRestart      bool
 	profiler       string
 	assetDir       string
 	cpuProfile     bool
+	faultInjection bool
 	stRestarting   bool
 	logFlags       int
 }
 
 func defaultRuntimeOptions() RuntimeOptions {
 	options := RuntimeOptions{
-		noRestart:    os.Getenv("STNORESTART") != "",
-		profiler:     os.Getenv("STPROFILER"),
-		assetDir:     os.Getenv("STGUIASSETS"),
-		cpuProfile:   os.Getenv("STCPUPROFILE") != "",
-		stRestarting: os.Getenv("STRESTART") != "",
-		logFlags:     log.Ltime,
+		noRestart:      os.Getenv("STNORESTART") != "",
+		profiler:       os.Getenv("STPROFILER"),
+		assetDir:       os.Getenv("STGUIASSETS"),
+		cpuProfile:     os.Getenv("STCPUPROFILE") != "",
+		faultInjection: os.Getenv("STFAULTINJECTION") != "",
+		stRestarting:   os.Getenv("STRESTART") != "",
+		logFlags:       log.Ltime,
 	}
 
 	if os.Getenv("STTRACE") != "" {
@@ -287,6 +297,9 @@ func parseCommandLineOptions() RuntimeOptions {
 	options := defaultRuntimeOptions()
 
 	flag.StringVar(&options.generateDir, "generate", "", "Generate key and config in specified dir, then exit")
+	flag.StringVar(&options.keyType, "key-type", string(bepKeyType), "Type of key to generate for a new device: ecdsa, ed25519 or rsa")
+	flag.StringVar(&options.provision, "provision", "", "Merge the YAML file, or directory of YAML files, into the config, then exit")
+	flag.BoolVar(&options.provisionDry, "provision-dry-run", false, "Show the changes -provision would make, without saving them")
 	flag.StringVar(&options.guiAddress, "gui-address", options.guiAddress, "Override GUI address (e.g. \"http://192.0.2.42:8443\")")
 	flag.StringVar(&options.guiAPIKey, "gui-apikey", options.guiAPIKey, "Override GUI API key")
 	flag.StringVar(&options.confDir, "home", "", "Set configuration directory")
@@ -296,6 +309,9 @@ func parseCommandLineOptions() RuntimeOptions {
 	flag.BoolVar(&options.noRestart, "no-restart", options.noRestart, "Disable monitor process, managed restarts and log file writing")
 	flag.BoolVar(&options.resetDatabase, "reset-database", false, "Reset the database, forcing a full rescan and resync")
 	flag.BoolVar(&options.resetDeltaIdxs, "reset-deltas", false, "Reset delta index IDs, forcing a full index exchange")
+	flag.StringVar(&options.backupDB, "backup-db", "", "Write a consistent copy of the database to the file, then exit")
+	flag.StringVar(&options.exportDB, "export-db", "", "Export the database in the portable format to the file, then exit")
+	flag.StringVar(&options.importDB, "import-db", "", "Import a database export into a fresh database, then exit")
 	flag.BoolVar(&options.doUpgrade, "upgrade", false, "Perform upgrade")
 	flag.BoolVar(&options.doUpgradeCheck, "upgrade-check", false, "Check for available upgrade")
 	flag.BoolVar(&options.showVersion, "version", false, "Show version")
@@ -351,6 +367,12 @@ func main() {
 		osutil.HideConsole()
 	}
 
+	keyType, err := tlsutil.ParseKeyType(options.keyType)
+	if err != nil {
+		l.Fatalln("-key-type:", err)
+	}
+	bepKeyType = keyType
+
 	if options.confDir != "" {
 		// Not set as default above because the string can be really long.
 		if !filepath.IsAbs(options.confDir) {
@@ -399,6 +421,11 @@ func main() {
 		return
 	}
 
+	if options.provision != "" {
+		provision(options.provision, options.provisionDry)
+		return
+	}
+
 	// Ensure that our home directory exists.
 	ensureDir(baseDirs["config"], 0700)
 
@@ -427,6 +454,21 @@ func main() {
 		return
 	}
 
+	if options.backupDB != "" {
+		backupDatabase(options.backupDB)
+		return
+	}
+
+	if options.exportDB != "" {
+		exportDatabase(options.exportDB)
+		return
+	}
+
+	if options.importDB != "" {
+		importDatabase(options.importDB)
+		return
+	}
+
 	// ---BEGIN TEMPORARY HACK---
 	//
 	// Remove once v0.14.21-v0.14.22 are rare enough. Those versions,
@@ -464,13 +506,21 @@ func main() {
 
 func openGUI() {
 	cfg, _ := loadConfig()
-	if cfg.GUI().Enabled {
+	if cfg.GUI().Network() == "unix" {
+		l.Warnln("Browser: GUI is listening on a Unix socket,", cfg.GUI().Address())
+	} else if cfg.GUI().Enabled {
 		openURL(cfg.GUI().URL())
 	} else {
 		l.Warnln("Browser: GUI is currently disabled")
 	}
 }
 
+// newDeviceCertificate generates a device certificate and key of the type
+// selected with -key-type.
+func newDeviceCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	return syncthing.NewDeviceCertificate(certFile, keyFile, bepKeyType)
+}
+
 func generate(generateDir string) {
 	dir, err := osutil.ExpandTilde(generateDir)
 	if err != nil {
@@ -484,7 +534,7 @@ func generate(generateDir string) {
 		l.Warnln("Key exists; will not overwrite.")
 		l.Infoln("Device ID:", protocol.NewDeviceID(cert.Certificate[0]))
 	} else {
-		cert, err = tlsutil.NewCertificate(certFile, keyFile, tlsDefaultCommonName, bepRSABits)
+		cert, err = newDeviceCertificate(certFile, keyFile)
 		if err != nil {
 			l.Fatalln("Create certificate:", err)
 		}
@@ -572,39 +622,61 @@ func performUpgrade(release upgrade.Release) {
 }
 
 func upgradeViaRest() error {
-	cfg, _ := loadConfig()
-	u, err := url.Parse(cfg.GUI().URL())
+	resp, err := restRequest("POST", "rest/system/upgrade", nil, 60*time.Second)
 	if err != nil {
 		return err
 	}
-	u.Path = path.Join(u.Path, "rest/system/upgrade")
-	target := u.String()
-	r, _ := http.NewRequest("POST", target, nil)
-	r.Header.Set("X-API-Key", cfg.GUI().APIKey)
+	resp.Body.Close()
+	return nil
+}
+
+// restRequest calls the REST API of the running instance. Responses other
+// than 200 OK are returned as errors. A zero timeout means none.
+func restRequest(method, endpoint string, query url.Values, timeout time.Duration) (*http.Response, error) {
+	cfg, _ := loadConfig()
+	guiCfg := cfg.GUI()
+	u, err := url.Parse(guiCfg.URL())
+	if err != nil {
+		return nil, err
+	}
 
 	tr := &http.Transport{
 		Dial:            dialer.Dial,
 		Proxy:           http.ProxyFromEnvironment,
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
+	if guiCfg.Network() == "unix" {
+		tr.Dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", guiCfg.Address())
+		}
+		tr.Proxy = nil
+		u = &url.URL{Scheme: "http", Host: "localhost", Path: "/"}
+	}
+
+	u.Path = path.Join(u.Path, endpoint)
+	u.RawQuery = query.Encode()
+	target := u.String()
+	r, _ := http.NewRequest(method, target, nil)
+	r.Header.Set("X-API-Key", guiCfg.APIKey)
+
 	client := &http.Client{
 		Transport: tr,
-		Timeout:   60 * time.Second,
+		Timeout:   timeout,
 	}
 	resp, err := client.Do(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if resp.StatusCode != 200 {
 		bs, err := ioutil.ReadAll(resp.Body)
 		defer resp.Body.Close()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return errors.New(string(bs))
+		return nil, errors.New(string(bs))
 	}
 
-	return err
+	return resp, nil
 }
 
 func syncthingMain(runtimeOptions RuntimeOptions) {
@@ -650,13 +722,9 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	osutil.MaximizeOpenFileLimit()
 
 	// Ensure that we have a certificate and key.
-	cert, err := tls.LoadX509KeyPair(locations[locCertFile], locations[locKeyFile])
+	cert, err := syncthing.LoadOrGenerateCertificate(locations[locCertFile], locations[locKeyFile], bepKeyType)
 	if err != nil {
-		l.Infof("Generating ECDSA key and certificate for %s...", tlsDefaultCommonName)
-		cert, err = tlsutil.NewCertificate(locations[locCertFile], locations[locKeyFile], tlsDefaultCommonName, bepRSABits)
-		if err != nil {
-			l.Fatalln(err)
-		}
+		l.Fatalln(err)
 	}
 
 	myID = protocol.NewDeviceID(cert.Certificate[0])
@@ -679,6 +747,10 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		"myID": myID.String(),
 	})
 
+	if err := migrateOwnIdentity(locations[locConfigFile], locations[locPreviousCertFile], locations[locPreviousKeyFile], myID); err != nil {
+		l.Fatalln("Moving config to new device ID:", err)
+	}
+
 	cfg := loadOrCreateConfig()
 
 	if err := checkShortIDs(cfg); err != nil {
@@ -696,30 +768,6 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		}()
 	}
 
-	// The TLS configuration is used for both the listening socket and outgoing
-	// connections.
-
-	tlsCfg := &tls.Config{
-		Certificates:           []tls.Certificate{cert},
-		NextProtos:             []string{bepProtocolName},
-		ClientAuth:             tls.RequestClientCert,
-		SessionTicketsDisabled: true,
-		InsecureSkipVerify:     true,
-		MinVersion:             tls.VersionTLS12,
-		CipherSuites: []uint16{
-			0xCCA8, // TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305, Go 1.8
-			0xCCA9, // TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305, Go 1.8
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-		},
-	}
-
 	opts := cfg.Options()
 
 	if opts.WeakHashSelectionMethod == config.WeakHashAuto {
@@ -738,138 +786,57 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		weakhash.Enabled = true
 	}
 
-	if (opts.MaxRecvKbps > 0 || opts.MaxSendKbps > 0) && !opts.LimitBandwidthInLan {
-		lans, _ = osutil.GetLans()
-		for _, lan := range opts.AlwaysLocalNets {
-			_, ipnet, err := net.ParseCIDR(lan)
-			if err != nil {
-				l.Infoln("Network", lan, "is malformed:", err)
-				continue
-			}
-			lans = append(lans, ipnet)
-		}
-
-		networks := make([]string, len(lans))
-		for i, lan := range lans {
-			networks[i] = lan.String()
-		}
-		l.Infoln("Local networks:", strings.Join(networks, ", "))
-	}
-
-	dbFile := locations[locDatabase]
-	ldb, err := db.Open(dbFile)
-
-	if err != nil {
-		l.Fatalln("Cannot open database:", err, "- Is another copy of Syncthing already running?")
-	}
-
-	if runtimeOptions.resetDeltaIdxs {
-		l.Infoln("Reinitializing delta index IDs")
-		ldb.DropDeltaIndexIDs()
-	}
-
-	protectedFiles := []string{
-		locations[locDatabase],
-		locations[locConfigFile],
-		locations[locCertFile],
-		locations[locKeyFile],
-	}
-
-	// Remove database entries for folders that no longer exist in the config
-	folders := cfg.Folders()
-	for _, folder := range ldb.ListFolders() {
-		if _, ok := folders[folder]; !ok {
-			l.Infof("Cleaning data for dropped folder %q", folder)
-			db.DropFolder(ldb, folder)
-		}
-	}
-
-	if cfg.RawCopy().OriginalVersion == 15 {
-		// The config version 15->16 migration is about handling ignores and
-		// delta indexes and requires that we drop existing indexes that
-		// have been incorrectly ignore filtered.
-		ldb.DropDeltaIndexIDs()
-	}
-	if cfg.RawCopy().OriginalVersion < 19 {
-		// Converts old symlink types to new in the entire database.
-		ldb.ConvertSymlinkTypes()
-	}
-
-	m := model.NewModel(cfg, myID, myDeviceName(cfg), "syncthing", Version, ldb, protectedFiles)
-
-	if t := os.Getenv("STDEADLOCKTIMEOUT"); len(t) > 0 {
-		it, err := strconv.Atoi(t)
-		if err == nil {
-			m.StartDeadlockDetector(time.Duration(it) * time.Second)
-		}
-	} else if !IsRelease || IsBeta {
-		m.StartDeadlockDetector(20 * time.Minute)
-	}
-
 	if runtimeOptions.unpaused {
 		setPauseState(cfg, false)
 	} else if runtimeOptions.paused {
 		setPauseState(cfg, true)
 	}
 
-	// Add and start folders
-	for _, folderCfg := range cfg.Folders() {
-		if folderCfg.Paused {
-			folderCfg.CreateRoot()
-			continue
-		}
-		m.AddFolder(folderCfg)
-		m.StartFolder(folderCfg.ID)
-	}
-
-	mainService.Add(m)
-
-	// Start discovery
-
-	cachedDiscovery := discover.NewCachingMux()
-	mainService.Add(cachedDiscovery)
-
-	// Start connection management
-
-	connectionsService := connections.NewService(cfg, myID, m, tlsCfg, cachedDiscovery, bepProtocolName, tlsDefaultCommonName, lans)
-	mainService.Add(connectionsService)
-
-	if cfg.Options().GlobalAnnEnabled {
-		for _, srv := range cfg.GlobalDiscoveryServers() {
-			l.Infoln("Using discovery server", srv)
-			gd, err := discover.NewGlobal(srv, cert, connectionsService)
-			if err != nil {
-				l.Warnln("Global discovery:", err)
-				continue
-			}
-
-			// Each global discovery server gets its results cached for five
-			// minutes, and is not asked again for a minute when it's returned
-			// unsuccessfully.
-			cachedDiscovery.Add(gd, 5*time.Minute, time.Minute, globalDiscoveryPriority)
+	var deadlockTimeout time.Duration
+	if t := os.Getenv("STDEADLOCKTIMEOUT"); len(t) > 0 {
+		it, err := strconv.Atoi(t)
+		if err == nil {
+			deadlockTimeout = time.Duration(it) * time.Second
 		}
+	} else if !IsRelease || IsBeta {
+		deadlockTimeout = 20 * time.Minute
+	}
+
+	// When enabled, all folder access goes through the fault injector, which
+	// does nothing until rules are set over the debug API.
+	var faults *fs.FaultFilesystem
+	if runtimeOptions.faultInjection {
+		l.Warnln("Filesystem fault injection is enabled; do not use with real data")
+		faults = fs.NewFaultFilesystem(fs.NewBasicFilesystem())
+		fs.DefaultFilesystem = fs.NewWalkFilesystem(faults)
+	}
+
+	app := syncthing.New(syncthing.Options{
+		HomeDir:           baseDirs["config"],
+		Config:            cfg,
+		Certificate:       &cert,
+		Logger:            l,
+		ClientVersion:     Version,
+		DeadlockTimeout:   deadlockTimeout,
+		ResetDeltaIndexes: runtimeOptions.resetDeltaIdxs,
+	})
+	if err := app.Start(); err != nil {
+		l.Fatalln(err)
 	}
+	m := app.Model()
 
-	if cfg.Options().LocalAnnEnabled {
-		// v4 broadcasts
-		bcd, err := discover.NewLocal(myID, fmt.Sprintf(":%d", cfg.Options().LocalAnnPort), connectionsService)
-		if err != nil {
-			l.Warnln("IPv4 local discovery:", err)
-		} else {
-			cachedDiscovery.Add(bcd, 0, 0, ipv4LocalDiscoveryPriority)
-		}
-		// v6 multicasts
-		mcd, err := discover.NewLocal(myID, cfg.Options().LocalAnnMCAddr, connectionsService)
-		if err != nil {
-			l.Warnln("IPv6 local discovery:", err)
-		} else {
-			cachedDiscovery.Add(mcd, 0, 0, ipv6LocalDiscoveryPriority)
-		}
+	// Keep announcing an identity rotation that was started but not yet
+	// completed.
+	if migration, err := loadIdentityMigration(cert); err != nil {
+		l.Warnln("Loading next certificate:", err)
+	} else if migration != nil {
+		l.Infoln("Moving to new device ID", protocol.NewDeviceID(migration.NewCertificate))
+		m.SetIdentityMigration(migration)
 	}
 
 	// GUI
 
-	setupGUI(mainService, cfg, m, defaultSub, diskSub, cachedDiscovery, connectionsService, errors, systemLog, runtimeOptions)
+	setupGUI(app, cfg, m, defaultSub, diskSub, app.Discovery(), app.Connections(), errors, systemLog, faults, runtimeOptions)
 
 	if runtimeOptions.cpuProfile {
 		f, err := os.Create(fmt.Sprintf("cpu-%d.pprof", os.Getpid()))
@@ -950,6 +917,7 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	code := <-stop
 
 	mainService.Stop()
+	app.Stop()
 
 	l.Infoln("Exiting")
 
@@ -960,15 +928,6 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	os.Exit(code)
 }
 
-func myDeviceName(cfg *config.Wrapper) string {
-	devices := cfg.Devices()
-	myName := devices[myID].Name
-	if myName == "" {
-		myName, _ = os.Hostname()
-	}
-	return myName
-}
-
 func setupSignalHandling() {
 	// Exit cleanly with "restarting" code on SIGHUP.
 
@@ -1006,6 +965,7 @@ func loadConfig() (*config.Wrapper, error) {
 
 func loadOrCreateConfig() *config.Wrapper {
 	cfg, err := loadConfig()
+	cfg.SetHistory(config.NewHistory(locations[locConfigHistory]))
 	if os.IsNotExist(err) {
 		cfg.Save()
 		l.Infof("Defaults saved. Edit %s to taste or use the GUI\n", cfg.ConfigPath())
@@ -1087,7 +1047,7 @@ func startAuditing(mainService *suture.Supervisor, auditFile string) {
 	l.Infoln("Audit log in", auditDest)
 }
 
-func setupGUI(mainService *suture.Supervisor, cfg *config.Wrapper, m *model.Model, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService *connections.Service, errors, systemLog logger.Recorder, runtimeOptions RuntimeOptions) {
+func setupGUI(app *syncthing.App, cfg *config.Wrapper, m *model.Model, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService *connections.Service, errors, systemLog logger.Recorder, faults *fs.FaultFilesystem, runtimeOptions RuntimeOptions) {
 	guiCfg := cfg.GUI()
 
 	if !guiCfg.Enabled {
@@ -1098,11 +1058,11 @@ func setupGUI(mainService *suture.Supervisor, cfg *config.Wrapper, m *model.Mode
 		l.Warnln("Insecure admin access is enabled.")
 	}
 
-	api := newAPIService(myID, cfg, locations[locHTTPSCertFile], locations[locHTTPSKeyFile], runtimeOptions.assetDir, m, defaultSub, diskSub, discoverer, connectionsService, errors, systemLog)
+	api := newAPIService(myID, cfg, locations[locHTTPSCertFile], locations[locHTTPSKeyFile], runtimeOptions.assetDir, m, defaultSub, diskSub, discoverer, connectionsService, errors, systemLog, faults)
 	cfg.Subscribe(api)
-	mainService.Add(api)
+	app.AddService(api)
 
-	if cfg.Options().StartBrowser && !runtimeOptions.noBrowser && !runtimeOptions.stRestarting {
+	if cfg.Options().StartBrowser && !runtimeOptions.noBrowser && !runtimeOptions.stRestarting && guiCfg.Network() == "tcp" {
 		// Can potentially block if the utility we are invoking doesn't
 		// fork, and just execs, hence keep it in it's own routine.
 		<-api.startedOnce
@@ -1115,13 +1075,7 @@ func defaultConfig(myName string) config.Configuration {
 
 	if !noDefaultFolder {
 		l.Infoln("Default folder created and/or linked to new config")
-		defaultFolder = config.NewFolderConfiguration("default", locations[locDefFolder])
-		defaultFolder.Label = "Default Folder"
-		defaultFolder.RescanIntervalS = 60
-		defaultFolder.MinDiskFree = config.Size{Value: 1, Unit: "%"}
-		defaultFolder.Devices = []config.FolderDeviceConfiguration{{DeviceID: myID}}
-		defaultFolder.AutoNormalize = true
-		defaultFolder.MaxConflicts = -1
+		defaultFolder = config.NewDefaultFolderConfiguration(myID, "default", "Default Folder", locations[locDefFolder])
 	} else {
 		l.Infoln("We will skip creation of a default folder on first start since the proper envvar is set")
 	}
@@ -60,6 +60,14 @@ func (c *mockedConfig) Save() error {
 	return nil
 }
 
+func (c *mockedConfig) SaveFrom(origin string) error {
+	return nil
+}
+
+func (c *mockedConfig) History() *config.History {
+	return nil
+}
+
 func (c *mockedConfig) RequiresRestart() bool {
 	return false
 }
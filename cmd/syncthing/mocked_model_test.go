@@ -7,8 +7,10 @@
 package main
 
 import (
+	"io"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/audit"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -111,6 +113,52 @@ func (m *mockedModel) RemoteSequence(folder string) (int64, bool) {
 	return 0, false
 }
 
+func (m *mockedModel) UnsettledFiles(folder string) []string {
+	return nil
+}
+
 func (m *mockedModel) State(folder string) (string, time.Time, error) {
 	return "", time.Time{}, nil
 }
+
+func (m *mockedModel) PendingDevices() map[protocol.DeviceID]db.PendingDevice {
+	return nil
+}
+
+func (m *mockedModel) PendingFolders() map[string]map[protocol.DeviceID]db.PendingFolder {
+	return nil
+}
+
+func (m *mockedModel) DismissPendingDevice(device protocol.DeviceID) {}
+
+func (m *mockedModel) DismissPendingFolder(folder string, device protocol.DeviceID) {}
+
+func (m *mockedModel) Introductions() []db.Introduction {
+	return nil
+}
+
+func (m *mockedModel) BackupDatabase(location string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockedModel) ExportDatabase(w io.Writer) error {
+	return nil
+}
+
+func (m *mockedModel) FileHistory(folder, file string) ([]db.HistoryEntry, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) AuditLog(folder string, q audit.Query) ([]audit.Entry, error) {
+	return []audit.Entry{}, nil
+}
+
+func (m *mockedModel) VerifyAuditLog(folder string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockedModel) SetIdentityMigration(migration *protocol.IdentityMigration) {}
+
+func (m *mockedModel) IdentityMigrationConfirmed() []protocol.DeviceID {
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/syncthing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// provision merges the provisioning file, or all *.yaml and *.yml files in
+// the provisioning directory in name order, into the configuration. The
+// device key and the configuration are created if they don't exist yet.
+// The resulting change is printed as a diff, and saved unless dryRun is
+// set.
+func provision(provisionPath string, dryRun bool) {
+	files, err := provisioningFiles(provisionPath)
+	if err != nil {
+		l.Fatalln("Provision:", err)
+	}
+
+	if !dryRun {
+		ensureDir(baseDirs["config"], 0700)
+	}
+
+	// Without a certificate, a dry run has no device ID to use. The config
+	// is prepared with a placeholder, which is shown as such in the diff.
+	placeholderID := false
+	cert, err := tls.LoadX509KeyPair(locations[locCertFile], locations[locKeyFile])
+	switch {
+	case err == nil:
+		myID = protocol.NewDeviceID(cert.Certificate[0])
+	case dryRun:
+		l.Infoln("A new device key and certificate would be generated")
+		myID = protocol.LocalDeviceID
+		placeholderID = true
+	default:
+		l.Infof("Generating %s key and certificate for %s...", bepKeyType, syncthing.TLSDefaultCommonName)
+		cert, err = newDeviceCertificate(locations[locCertFile], locations[locKeyFile])
+		if err != nil {
+			l.Fatalln("Provision:", err)
+		}
+		myID = protocol.NewDeviceID(cert.Certificate[0])
+	}
+	if !placeholderID {
+		l.Infoln("Device ID:", myID)
+	}
+
+	cfg, err := loadConfig()
+	isNew := os.IsNotExist(err)
+	if err != nil && !isNew {
+		l.Fatalln("Config:", err)
+	}
+	cfg.SetHistory(config.NewHistory(locations[locConfigHistory]))
+
+	from := cfg.RawCopy()
+	to := from
+	for _, file := range files {
+		fd, err := os.Open(file)
+		if err != nil {
+			l.Fatalln("Provision:", err)
+		}
+		p, err := config.ReadProvisioning(fd)
+		fd.Close()
+		if err != nil {
+			l.Fatalf("Provision: %s: %v", file, err)
+		}
+		to, err = p.Apply(to, myID)
+		if err != nil {
+			l.Fatalf("Provision: %s: %v", file, err)
+		}
+	}
+
+	if err := hashProvisionedPassword(from.GUI, &to.GUI); err != nil {
+		l.Fatalln("Provision: bcrypting password:", err)
+	}
+
+	fromName := cfg.ConfigPath()
+	if isNew {
+		fromName = "default configuration"
+	}
+	diff, err := config.Diff(from, to, fromName, provisionPath)
+	if err != nil {
+		l.Fatalln("Provision:", err)
+	}
+	if diff == "" && !isNew {
+		l.Infoln("Provision: configuration is up to date")
+		return
+	}
+	if placeholderID {
+		diff = strings.Replace(diff, myID.String(), "<new device>", -1)
+	}
+	fmt.Print(diff)
+
+	if dryRun {
+		return
+	}
+
+	if !isNew && from.OriginalVersion != config.CurrentVersion {
+		// Keep a copy of the old format, as on a regular startup
+		archivePath := cfg.ConfigPath() + fmt.Sprintf(".v%d", from.OriginalVersion)
+		l.Infoln("Archiving a copy of old config file format at:", archivePath)
+		if err := copyFile(cfg.ConfigPath(), archivePath); err != nil {
+			l.Fatalln("Config archive:", err)
+		}
+	}
+
+	if err := cfg.Replace(to); err != nil {
+		l.Fatalln("Provision:", err)
+	}
+	if err := cfg.SaveFrom("provisioning " + provisionPath); err != nil {
+		l.Fatalln("Provision: saving config:", err)
+	}
+	l.Infoln("Provision: saved", cfg.ConfigPath())
+}
+
+// provisioningFiles returns the given path if it's a file, or the sorted
+// YAML files within it if it's a directory.
+func provisioningFiles(provisionPath string) ([]string, error) {
+	provisionPath, err := osutil.ExpandTilde(provisionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(provisionPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{provisionPath}, nil
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(provisionPath, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// hashProvisionedPassword bcrypts a GUI password given in plain text. If it
+// matches the currently set password the current hash is kept, so that
+// provisioning the same password again isn't seen as a change.
+func hashProvisionedPassword(from config.GUIConfiguration, to *config.GUIConfiguration) error {
+	if to.Password == "" || to.Password == from.Password {
+		return nil
+	}
+
+	if from.Password != "" && bcrypt.CompareHashAndPassword([]byte(from.Password), []byte(to.Password)) == nil {
+		to.Password = from.Password
+		return nil
+	}
+
+	if _, err := bcrypt.Cost([]byte(to.Password)); err == nil {
+		// Already a bcrypt hash
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(to.Password), 0)
+	if err != nil {
+		return err
+	}
+	to.Password = string(hash)
+	return nil
+}
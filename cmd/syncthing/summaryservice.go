@@ -78,8 +78,8 @@ func (c *folderSummaryService) listenForUpdates() {
 				c.foldersMut.Lock()
 			nextFolder:
 				for _, folder := range c.cfg.Folders() {
-					for _, dev := range folder.Devices {
-						if dev.DeviceID == deviceID {
+					for _, dev := range folder.DeviceIDs() {
+						if dev == deviceID {
 							c.folders[folder.ID] = struct{}{}
 							continue nextFolder
 						}
@@ -193,22 +193,23 @@ func (c *folderSummaryService) sendSummary(folder string) {
 		"summary": data,
 	})
 
-	for _, devCfg := range c.cfg.Folders()[folder].Devices {
-		if devCfg.DeviceID.Equals(myID) {
+	folderCfg := c.cfg.Folders()[folder]
+	for _, devID := range folderCfg.DeviceIDs() {
+		if devID.Equals(myID) {
 			// We already know about ourselves.
 			continue
 		}
-		if !c.model.ConnectedTo(devCfg.DeviceID) {
+		if !c.model.ConnectedTo(devID) {
 			// We're not interested in disconnected devices.
 			continue
 		}
 
 		// Get completion percentage of this folder for the
 		// remote device.
-		comp := c.model.Completion(devCfg.DeviceID, folder)
+		comp := c.model.Completion(devID, folder)
 		events.Default.Log(events.FolderCompletion, map[string]interface{}{
 			"folder":      folder,
-			"device":      devCfg.DeviceID.String(),
+			"device":      devID.String(),
 			"completion":  comp.CompletionPct,
 			"needBytes":   comp.NeedBytes,
 			"globalBytes": comp.GlobalBytes,
@@ -195,6 +195,21 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 			success = "failed"
 		}
 		return fmt.Sprintf("Login %s for username %s.", success, username)
+
+	case events.IntroducerAction:
+		data := ev.Data.(map[string]string)
+		if folder := data["folder"]; folder != "" {
+			return fmt.Sprintf("Introducer %s: %s %s for folder %q", data["introducer"], data["action"], data["device"], folder)
+		}
+		return fmt.Sprintf("Introducer %s: %s %s", data["introducer"], data["action"], data["device"])
+
+	case events.DeviceIdentityMigrated:
+		data := ev.Data.(map[string]string)
+		return fmt.Sprintf("Device %v moved to new identity %v", data["device"], data["newDevice"])
+
+	case events.FolderScrubResult:
+		data := ev.Data.(map[string]interface{})
+		return fmt.Sprintf("Scrubbed folder %q: %d files checked, %d corrupted, %d repaired", data["folder"], data["files"], len(data["corrupted"].([]string)), len(data["repaired"].([]string)))
 	}
 
 	return fmt.Sprintf("%s %#v", ev.Type, ev)
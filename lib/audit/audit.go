@@ -0,0 +1,451 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package audit keeps a persistent log of the operations on the files of
+// each folder.
+//
+// Each folder has its own directory of log files, holding one JSON encoded
+// Entry per line. The current file is rotated when it grows too large or
+// old, and only a limited number of rotated files are kept. With hash
+// chaining enabled every entry carries a hash covering itself and all the
+// entries before it, so that changes to the log can be detected.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sha256"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The operations recorded.
+const (
+	ActionCreate   = "create"
+	ActionModify   = "modify"
+	ActionDelete   = "delete"
+	ActionRename   = "rename"   // recorded in addition to the delete and create
+	ActionConflict = "conflict" // the local version was moved to a conflict copy
+	ActionArchive  = "archive"  // the local version was archived by the versioner
+)
+
+// Where the operations originate.
+const (
+	OriginLocal  = "local"  // found by scanning
+	OriginRemote = "remote" // pulled from another device
+)
+
+const (
+	currentName  = "audit.log"
+	rotatedGlob  = "audit-*.log"
+	rotateFormat = "20060102-150405.000"
+)
+
+// An Entry is an operation on a file.
+type Entry struct {
+	Time         time.Time `json:"time"`
+	Folder       string    `json:"folder"`
+	Path         string    `json:"path"`
+	Action       string    `json:"action"`
+	Type         string    `json:"type"` // file, dir or symlink
+	Origin       string    `json:"origin"`
+	Device       string    `json:"device,omitempty"` // short ID of the device that made the change
+	Size         int64     `json:"size"`
+	Hash         string    `json:"hash,omitempty"`         // hex SHA-256 of the block hashes
+	OldPath      string    `json:"oldPath,omitempty"`      // for renames
+	ConflictCopy string    `json:"conflictCopy,omitempty"` // empty if the local version was discarded
+	Prev         string    `json:"prev,omitempty"`         // chain hash of the entry before
+	Chain        string    `json:"chain,omitempty"`
+}
+
+// chainHash returns the hash of the entry, without its chain hash, chained
+// to the hash of the entry before.
+func (e Entry) chainHash() string {
+	e.Chain = ""
+	bs, _ := json.Marshal(e)
+	h := sha256.New()
+	h.Write([]byte(e.Prev))
+	h.Write(bs)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Options control the rotation of the log files and the hash chaining.
+// Zero values disable the respective limit.
+type Options struct {
+	MaxSize   int64         // rotate the current file when it would grow larger
+	MaxAge    time.Duration // rotate the current file when its first entry is older
+	MaxFiles  int           // rotated files to keep
+	HashChain bool
+}
+
+// A Query selects entries of a folder. Zero values match everything.
+type Query struct {
+	Since time.Time
+	Until time.Time
+	Path  string // the file, or everything in the directory
+	Limit int    // the newest entries to return
+}
+
+func (q Query) matches(e Entry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if q.Path != "" && e.Path != q.Path && !strings.HasPrefix(e.Path, q.Path+"/") && e.OldPath != q.Path {
+		return false
+	}
+	return true
+}
+
+// A Log is the audit log of all folders, kept in a directory.
+type Log struct {
+	dir     string
+	opts    Options
+	folders map[string]*folderLog
+	mut     sync.Mutex
+}
+
+// New returns the audit log kept in the directory.
+func New(dir string, opts Options) *Log {
+	return &Log{
+		dir:     dir,
+		opts:    opts,
+		folders: make(map[string]*folderLog),
+		mut:     sync.NewMutex(),
+	}
+}
+
+// Record appends the entries, setting their times if unset. Failures are
+// logged, as there's nothing the caller could do about them.
+func (a *Log) Record(entries ...Entry) {
+	now := time.Now().UTC()
+	for _, e := range entries {
+		if e.Time.IsZero() {
+			e.Time = now
+		}
+		e.Time = e.Time.UTC()
+		f := a.folder(e.Folder)
+		if err := f.record(e); err != nil {
+			l.Warnf("Audit log of folder %q: %v", e.Folder, err)
+		}
+	}
+}
+
+// Query returns the matching entries of the folder, oldest first.
+func (a *Log) Query(folder string, q Query) ([]Entry, error) {
+	f := a.folder(folder)
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	res := []Entry{}
+	err := f.walk(func(e Entry) error {
+		if !q.matches(e) {
+			return nil
+		}
+		if q.Limit > 0 && len(res) == q.Limit {
+			copy(res, res[1:])
+			res = res[:len(res)-1]
+		}
+		res = append(res, e)
+		return nil
+	})
+	return res, err
+}
+
+// Verify checks the hash chain of the folder's entries and returns the
+// number of entries checked. Entries from before chaining was enabled are
+// skipped. The first kept entry can't be checked against the entries of
+// deleted rotated files.
+func (a *Log) Verify(folder string) (int, error) {
+	f := a.folder(folder)
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	checked := 0
+	prev := ""
+	err := f.walk(func(e Entry) error {
+		if e.Chain == "" && checked == 0 {
+			return nil
+		}
+		if e.Chain == "" {
+			return fmt.Errorf("entry of %s at %v is not chained", e.Path, e.Time)
+		}
+		if checked > 0 && e.Prev != prev {
+			return fmt.Errorf("entry of %s at %v doesn't follow the one before", e.Path, e.Time)
+		}
+		if e.chainHash() != e.Chain {
+			return fmt.Errorf("entry of %s at %v has been modified", e.Path, e.Time)
+		}
+		prev = e.Chain
+		checked++
+		return nil
+	})
+	return checked, err
+}
+
+// Close closes the open log files.
+func (a *Log) Close() error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	var firstErr error
+	for _, f := range a.folders {
+		f.mut.Lock()
+		if err := f.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		f.mut.Unlock()
+	}
+	return firstErr
+}
+
+func (a *Log) folder(folder string) *folderLog {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	f, ok := a.folders[folder]
+	if !ok {
+		f = &folderLog{
+			dir:  filepath.Join(a.dir, dirName(folder)),
+			opts: a.opts,
+			mut:  sync.NewMutex(),
+		}
+		a.folders[folder] = f
+	}
+	return f
+}
+
+// dirName returns the name of the folder's directory. Folder IDs that aren't
+// safe file names are hex encoded.
+func dirName(folder string) string {
+	for _, r := range folder {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "x" + hex.EncodeToString([]byte(folder))
+		}
+	}
+	if folder == "" {
+		return "x"
+	}
+	return folder
+}
+
+// A folderLog is the directory of log files of one folder.
+type folderLog struct {
+	dir  string
+	opts Options
+
+	fd      *os.File // the current file, nil until needed
+	size    int64
+	started time.Time // of the first entry in the current file
+	last    string    // chain hash of the last entry
+	mut     sync.Mutex
+}
+
+func (f *folderLog) record(e Entry) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if f.fd == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	if f.opts.HashChain {
+		e.Prev = f.last
+		e.Chain = e.chainHash()
+	}
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	if f.size > 0 && (f.opts.MaxSize > 0 && f.size+int64(len(bs)) > f.opts.MaxSize || f.opts.MaxAge > 0 && e.Time.Sub(f.started) > f.opts.MaxAge) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.fd.Write(bs); err != nil {
+		return err
+	}
+	if f.size == 0 {
+		f.started = e.Time
+	}
+	f.size += int64(len(bs))
+	f.last = e.Chain
+	return nil
+}
+
+// open opens the current file, and finds out where the existing entries
+// left off.
+func (f *folderLog) open() error {
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+
+	current := filepath.Join(f.dir, currentName)
+	var first, last *Entry
+	find := func(e Entry) error {
+		if first == nil {
+			first = &e
+		}
+		last = &e
+		return nil
+	}
+	if err := walkFile(current, find); err != nil {
+		return err
+	}
+	if last == nil {
+		// The chain continues from the newest rotated file
+		rotated, err := f.rotated()
+		if err != nil {
+			return err
+		}
+		if len(rotated) > 0 {
+			if err := walkFile(rotated[len(rotated)-1], find); err != nil {
+				return err
+			}
+		}
+		first = nil
+	}
+
+	fd, err := os.OpenFile(current, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+
+	f.fd = fd
+	f.size = info.Size()
+	f.last = ""
+	if last != nil {
+		f.last = last.Chain
+	}
+	if first != nil {
+		f.started = first.Time
+	}
+	return nil
+}
+
+func (f *folderLog) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	rotated, err := f.rotated()
+	if err != nil {
+		return err
+	}
+
+	// The names sort in rotation order, also when rotating more than once
+	// per millisecond.
+	t := time.Now().UTC().Truncate(time.Millisecond)
+	if len(rotated) > 0 {
+		newest := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(rotated[len(rotated)-1]), "audit-"), ".log")
+		if nt, err := time.Parse(rotateFormat, newest); err == nil && !t.After(nt) {
+			t = nt.Add(time.Millisecond)
+		}
+	}
+	name := "audit-" + t.Format(rotateFormat) + ".log"
+	if err := os.Rename(filepath.Join(f.dir, currentName), filepath.Join(f.dir, name)); err != nil {
+		return err
+	}
+	l.Debugln("rotated", f.dir, "to", name)
+
+	if f.opts.MaxFiles > 0 {
+		rotated = append(rotated, filepath.Join(f.dir, name))
+		for len(rotated) > f.opts.MaxFiles {
+			if err := os.Remove(rotated[0]); err != nil {
+				return err
+			}
+			l.Debugln("removed", rotated[0])
+			rotated = rotated[1:]
+		}
+	}
+
+	return f.open()
+}
+
+func (f *folderLog) close() error {
+	if f.fd == nil {
+		return nil
+	}
+	err := f.fd.Close()
+	f.fd = nil
+	return err
+}
+
+// rotated returns the rotated files, oldest first.
+func (f *folderLog) rotated() ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(f.dir, rotatedGlob))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// walk calls fn with every entry, oldest first, until it returns an error.
+func (f *folderLog) walk(fn func(Entry) error) error {
+	names, err := f.rotated()
+	if err != nil {
+		return err
+	}
+	names = append(names, filepath.Join(f.dir, currentName))
+
+	for _, name := range names {
+		if err := walkFile(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkFile(name string, fn func(Entry) error) error {
+	fd, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	br := bufio.NewReader(fd)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Entry
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				return fmt.Errorf("%s line %d: %v", name, n, jerr)
+			}
+			if ferr := fn(e); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestQuery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	a := New(dir, Options{})
+	defer a.Close()
+
+	t0 := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	a.Record(
+		Entry{Time: t0, Folder: "default", Path: "a", Action: ActionCreate},
+		Entry{Time: t0.Add(time.Minute), Folder: "default", Path: "dir/b", Action: ActionCreate},
+		Entry{Time: t0.Add(2 * time.Minute), Folder: "other", Path: "dir/c", Action: ActionCreate},
+		Entry{Time: t0.Add(3 * time.Minute), Folder: "default", Path: "dir/b", Action: ActionModify},
+		Entry{Time: t0.Add(4 * time.Minute), Folder: "default", Path: "dir2/d", OldPath: "a", Action: ActionRename},
+	)
+
+	cases := []struct {
+		q       Query
+		actions []string
+	}{
+		{Query{}, []string{ActionCreate, ActionCreate, ActionModify, ActionRename}},
+		{Query{Path: "dir"}, []string{ActionCreate, ActionModify}},
+		{Query{Path: "a"}, []string{ActionCreate, ActionRename}},
+		{Query{Since: t0.Add(time.Minute), Until: t0.Add(4 * time.Minute)}, []string{ActionCreate, ActionModify}},
+		{Query{Limit: 2}, []string{ActionModify, ActionRename}},
+	}
+	for i, tc := range cases {
+		res, err := a.Query("default", tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != len(tc.actions) {
+			t.Errorf("%d: got %d entries, expected %d", i, len(res), len(tc.actions))
+			continue
+		}
+		for j, e := range res {
+			if e.Action != tc.actions[j] {
+				t.Errorf("%d: entry %d is %s, expected %s", i, j, e.Action, tc.actions[j])
+			}
+		}
+	}
+
+	if res, err := a.Query("unknown", Query{}); err != nil || len(res) != 0 {
+		t.Errorf("unexpected result for unknown folder: %v, %v", res, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	a := New(dir, Options{MaxSize: 1000, MaxFiles: 2, HashChain: true})
+	for i := 0; i < 40; i++ {
+		a.Record(Entry{Folder: "default", Path: "file", Action: ActionModify, Size: int64(i)})
+	}
+	a.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "default", rotatedGlob))
+	if len(rotated) != 2 {
+		t.Errorf("got %d rotated files, expected 2", len(rotated))
+	}
+
+	// The newest entries are kept, and the chain survives reopening the log
+	a = New(dir, Options{MaxSize: 1000, MaxFiles: 2, HashChain: true})
+	defer a.Close()
+	a.Record(Entry{Folder: "default", Path: "file", Action: ActionDelete})
+
+	res, err := a.Query("default", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) == 0 || len(res) >= 41 || res[len(res)-2].Size != 39 || res[len(res)-1].Action != ActionDelete {
+		t.Errorf("unexpected entries after rotation: %+v", res)
+	}
+	if n, err := a.Verify("default"); err != nil || n != len(res) {
+		t.Errorf("verified %d of %d entries: %v", n, len(res), err)
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	a := New(dir, Options{HashChain: true})
+	defer a.Close()
+	for _, path := range []string{"a", "b", "c"} {
+		a.Record(Entry{Folder: "default", Path: path, Action: ActionCreate, Size: 10})
+	}
+	if n, err := a.Verify("default"); err != nil || n != 3 {
+		t.Fatalf("verified %d entries: %v", n, err)
+	}
+
+	name := filepath.Join(dir, "default", currentName)
+	orig, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A changed entry
+	ioutil.WriteFile(name, bytes.Replace(orig, []byte(`"size":10`), []byte(`"size":11`), 1), 0600)
+	if _, err := a.Verify("default"); err == nil {
+		t.Error("changed entry not detected")
+	}
+
+	// A removed entry
+	lines := bytes.SplitAfter(orig, []byte("\n"))
+	ioutil.WriteFile(name, append(append([]byte{}, lines[0]...), lines[2]...), 0600)
+	if _, err := a.Verify("default"); err == nil {
+		t.Error("removed entry not detected")
+	}
+}
+
+func TestDirName(t *testing.T) {
+	cases := map[string]string{
+		"default":   "default",
+		"abcd-1234": "abcd-1234",
+		"../x":      "x2e2e2f78",
+		"":          "x",
+	}
+	for folder, expected := range cases {
+		if name := dirName(folder); name != expected {
+			t.Errorf("dirName(%q) = %q, expected %q", folder, name, expected)
+		}
+	}
+}
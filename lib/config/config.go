@@ -149,13 +149,14 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 }
 
 type Configuration struct {
-	Version        int                   `xml:"version,attr" json:"version"`
-	Folders        []FolderConfiguration `xml:"folder" json:"folders"`
-	Devices        []DeviceConfiguration `xml:"device" json:"devices"`
-	GUI            GUIConfiguration      `xml:"gui" json:"gui"`
-	Options        OptionsConfiguration  `xml:"options" json:"options"`
-	IgnoredDevices []protocol.DeviceID   `xml:"ignoredDevice" json:"ignoredDevices"`
-	XMLName        xml.Name              `xml:"configuration" json:"-"`
+	Version        int                        `xml:"version,attr" json:"version"`
+	Folders        []FolderConfiguration      `xml:"folder" json:"folders"`
+	Devices        []DeviceConfiguration      `xml:"device" json:"devices"`
+	DeviceGroups   []DeviceGroupConfiguration `xml:"deviceGroup" json:"deviceGroups"`
+	GUI            GUIConfiguration           `xml:"gui" json:"gui"`
+	Options        OptionsConfiguration       `xml:"options" json:"options"`
+	IgnoredDevices []protocol.DeviceID        `xml:"ignoredDevice" json:"ignoredDevices"`
+	XMLName        xml.Name                   `xml:"configuration" json:"-"`
 
 	OriginalVersion int `xml:"-" json:"-"` // The version we read from disk, before any conversion
 }
@@ -175,6 +176,13 @@ func (cfg Configuration) Copy() Configuration {
 		newCfg.Devices[i] = cfg.Devices[i].Copy()
 	}
 
+	// Deep copy DeviceGroupConfigurations
+	newCfg.DeviceGroups = make([]DeviceGroupConfiguration, len(cfg.DeviceGroups))
+	for i := range newCfg.DeviceGroups {
+		newCfg.DeviceGroups[i] = cfg.DeviceGroups[i].Copy()
+	}
+
+	newCfg.GUI = cfg.GUI.Copy()
 	newCfg.Options = cfg.Options.Copy()
 
 	// DeviceIDs are values
@@ -221,10 +229,24 @@ found:
 	for i := range cfg.Folders {
 		cfg.Folders[i].Devices = ensureDevicePresent(cfg.Folders[i].Devices, myID)
 	}
+	// We may be a group member as well
+	cfg.resolveDeviceGroups()
 
 	return nil
 }
 
+// resolveDeviceGroups updates the folders with the current members of the
+// device groups they are shared with.
+func (cfg *Configuration) resolveDeviceGroups() {
+	groups := make(map[string]DeviceGroupConfiguration, len(cfg.DeviceGroups))
+	for _, group := range cfg.DeviceGroups {
+		groups[group.Name] = group
+	}
+	for i := range cfg.Folders {
+		cfg.Folders[i].resolveDeviceGroups(groups)
+	}
+}
+
 func (cfg *Configuration) clean() error {
 	util.FillNilSlices(&cfg.Options)
 
@@ -235,12 +257,18 @@ func (cfg *Configuration) clean() error {
 	if cfg.IgnoredDevices == nil {
 		cfg.IgnoredDevices = []protocol.DeviceID{}
 	}
+	if cfg.DeviceGroups == nil {
+		cfg.DeviceGroups = []DeviceGroupConfiguration{}
+	}
 	if cfg.Options.AlwaysLocalNets == nil {
 		cfg.Options.AlwaysLocalNets = []string{}
 	}
 	if cfg.Options.UnackedNotificationIDs == nil {
 		cfg.Options.UnackedNotificationIDs = []string{}
 	}
+	if cfg.GUI.TOTPRecoveryCodes == nil {
+		cfg.GUI.TOTPRecoveryCodes = []string{}
+	}
 
 	// Prepare folders and check for duplicates. Duplicates are bad and
 	// dangerous, can't currently be resolved in the GUI, and shouldn't
@@ -306,6 +334,16 @@ func (cfg *Configuration) clean() error {
 	cfg.Devices = ensureNoDuplicateDevices(cfg.Devices)
 
 	sort.Sort(DeviceConfigurationList(cfg.Devices))
+
+	// Ensure that the introducer folder patterns are valid
+	for _, device := range cfg.Devices {
+		for _, pattern := range device.IntroducerFolders {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid introducer folder pattern %q for device %v", pattern, device.DeviceID)
+			}
+		}
+	}
+
 	// Ensure that any loose devices are not present in the wrong places
 	// Ensure that there are no duplicate devices
 	// Ensure that the versioning configuration parameter map is not nil
@@ -318,6 +356,26 @@ func (cfg *Configuration) clean() error {
 		sort.Sort(FolderDeviceConfigurationList(cfg.Folders[i].Devices))
 	}
 
+	// Ensure that device groups are uniquely named, contain only existing
+	// devices, and that folders only refer to existing groups
+	existingGroups := make(map[string]bool)
+	for i := range cfg.DeviceGroups {
+		group := &cfg.DeviceGroups[i]
+		if group.Name == "" {
+			return fmt.Errorf("device group without name in configuration")
+		}
+		if existingGroups[group.Name] {
+			return fmt.Errorf("duplicate device group %q in configuration", group.Name)
+		}
+		existingGroups[group.Name] = true
+		group.prepare(existingDevices)
+	}
+	sort.Sort(DeviceGroupConfigurationList(cfg.DeviceGroups))
+	for i := range cfg.Folders {
+		cfg.Folders[i].DeviceGroups = ensureExistingGroups(cfg.Folders[i].DeviceGroups, existingGroups)
+	}
+	cfg.resolveDeviceGroups()
+
 	for i := range cfg.Devices {
 		cfg.Devices[i].prepare()
 	}
@@ -604,6 +662,22 @@ loop:
 	return devices[0:count]
 }
 
+func ensureExistingGroups(groups []FolderDeviceGroupConfiguration, existingGroups map[string]bool) []FolderDeviceGroupConfiguration {
+	if len(groups) == 0 {
+		return groups
+	}
+	seen := make(map[string]bool, len(groups))
+	existing := groups[:0]
+	for _, group := range groups {
+		if existingGroups[group.Name] && !seen[group.Name] {
+			existing = append(existing, group)
+			seen[group.Name] = true
+		}
+	}
+	sort.Stable(FolderDeviceGroupConfigurationList(existing))
+	return existing
+}
+
 func ensureNoDuplicateDevices(devices []DeviceConfiguration) []DeviceConfiguration {
 	count := len(devices)
 	i := 0
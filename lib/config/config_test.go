@@ -73,6 +73,12 @@ func TestDefaultValues(t *testing.T) {
 		KCPSendWindowSize:       128,
 		KCPUpdateIntervalMs:     25,
 		KCPFastResend:           false,
+		MaxConfigRevisions:      100,
+		AuditLogEnabled:         false,
+		AuditLogMaxSizeKiB:      10240,
+		AuditLogMaxAgeDays:      30,
+		AuditLogMaxFiles:        12,
+		AuditLogHashChain:       false,
 	}
 
 	cfg := New(device1)
@@ -117,6 +123,8 @@ func TestDeviceConfig(t *testing.T) {
 					Params: map[string]string{},
 				},
 				WeakHashThresholdPct: 25,
+				FullScanIntervalS:    3600,
+				ScrubMaxKbps:         4096,
 			},
 		}
 
@@ -221,6 +229,12 @@ func TestOverriddenValues(t *testing.T) {
 		KCPSendWindowSize:       1280,
 		KCPUpdateIntervalMs:     1000,
 		KCPFastResend:           true,
+		MaxConfigRevisions:      10,
+		AuditLogEnabled:         true,
+		AuditLogMaxSizeKiB:      1024,
+		AuditLogMaxAgeDays:      7,
+		AuditLogMaxFiles:        4,
+		AuditLogHashChain:       true,
 	}
 
 	os.Unsetenv("STNOUPGRADE")
@@ -625,6 +639,7 @@ func TestGUIConfigURL(t *testing.T) {
 		{"127.0.0.2:8080", "http://127.0.0.2:8080/"},
 		{"[::]:8080", "http://[::1]:8080/"},
 		{"[2001::42]:8080", "http://[2001::42]:8080/"},
+		{"unix:///var/run/syncthing.sock", "unix:///var/run/syncthing.sock"},
 	}
 
 	for _, tc := range testcases {
@@ -638,6 +653,37 @@ func TestGUIConfigURL(t *testing.T) {
 	}
 }
 
+func TestGUIConfigUnixSocket(t *testing.T) {
+	c := GUIConfiguration{
+		RawAddress:            "unix:///var/run/syncthing.sock",
+		RawUseTLS:             true,
+		UnixSocketPermissions: "0660",
+	}
+
+	if n := c.Network(); n != "unix" {
+		t.Errorf("Incorrect network %q for Unix socket", n)
+	}
+	if a := c.Address(); a != "/var/run/syncthing.sock" {
+		t.Errorf("Incorrect address %q for Unix socket", a)
+	}
+	if c.UseTLS() {
+		t.Error("TLS should not be used on a Unix socket")
+	}
+	if mode, err := c.UnixSocketFileMode(); err != nil || mode != 0660 {
+		t.Errorf("Incorrect file mode %o (%v)", mode, err)
+	}
+
+	c.UnixSocketPermissions = "rw-rw----"
+	if _, err := c.UnixSocketFileMode(); err == nil {
+		t.Error("Unexpected nil error for non-octal permissions")
+	}
+
+	c.RawAddress = "127.0.0.1:8384"
+	if n := c.Network(); n != "tcp" {
+		t.Errorf("Incorrect network %q for TCP address", n)
+	}
+}
+
 func TestDuplicateDevices(t *testing.T) {
 	// Duplicate devices should be removed
 
@@ -807,3 +853,210 @@ func TestSharesRemovedOnDeviceRemoval(t *testing.T) {
 		t.Error("Unexpected extra device")
 	}
 }
+
+func TestDeviceGroups(t *testing.T) {
+	wrapper := Wrap("/tmp/test", New(device1))
+
+	raw := wrapper.RawCopy()
+	raw.Devices = append(raw.Devices, NewDeviceConfiguration(device2, "two"), NewDeviceConfiguration(device3, "three"))
+	raw.DeviceGroups = []DeviceGroupConfiguration{{Name: "laptops", Devices: []protocol.DeviceID{device3, device2, device4}}}
+	folder := NewFolderConfiguration("f", "/tmp/f")
+	folder.Devices = []FolderDeviceConfiguration{{DeviceID: device2}}
+	folder.DeviceGroups = []FolderDeviceGroupConfiguration{{Name: "laptops"}, {Name: "nonexistent"}, {Name: "laptops"}}
+	raw.Folders = []FolderConfiguration{folder}
+	if err := wrapper.Replace(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := wrapper.DeviceGroups()
+	// Unknown devices are removed
+	if devs := groups["laptops"].Devices; !reflect.DeepEqual(devs, []protocol.DeviceID{device2, device3}) {
+		t.Errorf("Unexpected group devices %v", devs)
+	}
+
+	f := wrapper.Folders()["f"]
+	// Unknown groups are removed
+	if !reflect.DeepEqual(f.DeviceGroups, []FolderDeviceGroupConfiguration{{Name: "laptops"}}) {
+		t.Errorf("Unexpected folder groups %v", f.DeviceGroups)
+	}
+	// Group members are shared with, in addition to the folder's own devices
+	if devs := f.DeviceIDs(); !reflect.DeepEqual(devs, []protocol.DeviceID{device2, device3}) {
+		t.Errorf("Unexpected folder devices %v", devs)
+	}
+	if len(f.Devices) != 1 {
+		t.Errorf("Group members should not be added to the folder devices: %v", f.Devices)
+	}
+
+	// Membership changes apply to the folder
+	if err := wrapper.SetDeviceGroup(DeviceGroupConfiguration{Name: "laptops", Devices: []protocol.DeviceID{device2}}); err != nil {
+		t.Fatal(err)
+	}
+	f = wrapper.Folders()["f"]
+	if devs := f.DeviceIDs(); !reflect.DeepEqual(devs, []protocol.DeviceID{device2}) {
+		t.Errorf("Unexpected folder devices %v", devs)
+	}
+
+	if err := wrapper.RemoveDeviceGroup("laptops"); err != nil {
+		t.Fatal(err)
+	}
+	f = wrapper.Folders()["f"]
+	if len(f.DeviceGroups) != 0 {
+		t.Errorf("Removed group still referenced: %v", f.DeviceGroups)
+	}
+
+	// Group names must be unique
+	raw = wrapper.RawCopy()
+	raw.DeviceGroups = []DeviceGroupConfiguration{{Name: "a"}, {Name: "a"}}
+	if err := wrapper.Replace(raw); err == nil {
+		t.Error("Expected error for duplicate group name")
+	}
+}
+
+func TestDeviceGroupRoles(t *testing.T) {
+	wrapper := Wrap("/tmp/test", New(device1))
+
+	raw := wrapper.RawCopy()
+	raw.Devices = append(raw.Devices, NewDeviceConfiguration(device2, "two"), NewDeviceConfiguration(device3, "three"), NewDeviceConfiguration(device4, "four"))
+	raw.DeviceGroups = []DeviceGroupConfiguration{
+		{Name: "backups", Devices: []protocol.DeviceID{device2, device3}},
+		{Name: "cameras", Devices: []protocol.DeviceID{device3, device4}},
+	}
+	folder := NewFolderConfiguration("f", "/tmp/f")
+	folder.Devices = []FolderDeviceConfiguration{{DeviceID: device2}}
+	folder.DeviceGroups = []FolderDeviceGroupConfiguration{
+		{Name: "cameras", Role: FolderDeviceRoleSendOnly},
+		{Name: "backups", Role: FolderDeviceRoleReceiveOnly},
+	}
+	raw.Folders = []FolderConfiguration{folder}
+	if err := wrapper.Replace(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	f := wrapper.Folders()["f"]
+	// The direct share wins over the group, and the first group by name
+	// over the others
+	roles := map[protocol.DeviceID]FolderDeviceRole{
+		device2: FolderDeviceRoleSendReceive,
+		device3: FolderDeviceRoleReceiveOnly,
+		device4: FolderDeviceRoleSendOnly,
+	}
+	for dev, role := range roles {
+		if r := f.DeviceRole(dev); r != role {
+			t.Errorf("%v has role %v, expected %v", dev, r, role)
+		}
+	}
+	if devs := f.DevicesWithRole(FolderDeviceRoleSendOnly); !reflect.DeepEqual(devs, []protocol.DeviceID{device4}) {
+		t.Errorf("Unexpected send only devices %v", devs)
+	}
+
+	// The roles survive saving and loading the config
+	buf := new(bytes.Buffer)
+	saved := wrapper.RawCopy()
+	if err := saved.WriteXML(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`<deviceGroup role="sendonly">cameras</deviceGroup>`)) {
+		t.Errorf("Unexpected XML %s", buf.Bytes())
+	}
+	loaded, err := ReadXML(buf, device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Folders[0].DeviceGroups, f.DeviceGroups) {
+		t.Errorf("Loaded groups %v, expected %v", loaded.Folders[0].DeviceGroups, f.DeviceGroups)
+	}
+}
+
+func TestIntroducerPolicy(t *testing.T) {
+	var open DeviceConfiguration
+	if !open.IntroducesFolder("anything") || !open.IntroducesDevice(device2) {
+		t.Error("An empty policy should allow everything")
+	}
+
+	dev := DeviceConfiguration{
+		IntroducerFolders:        []string{"work-*", "shared"},
+		IntroducerAllowedDevices: []protocol.DeviceID{device2, device3},
+		IntroducerDeniedDevices:  []protocol.DeviceID{device3},
+	}
+	for folder, ok := range map[string]bool{"work-docs": true, "shared": true, "private": false, "shared2": false} {
+		if dev.IntroducesFolder(folder) != ok {
+			t.Errorf("IntroducesFolder(%q) should be %v", folder, ok)
+		}
+	}
+	if !dev.IntroducesDevice(device2) {
+		t.Error("Allowed device should be introduced")
+	}
+	if dev.IntroducesDevice(device3) {
+		t.Error("Denied device should not be introduced, even if allowed")
+	}
+	if dev.IntroducesDevice(device1) {
+		t.Error("Device not in the allowed list should not be introduced")
+	}
+
+	wrapper := Wrap("/tmp/test", Configuration{})
+	raw := wrapper.RawCopy()
+	raw.Devices = []DeviceConfiguration{{DeviceID: device2, IntroducerFolders: []string{"[bad"}}}
+	if err := wrapper.Replace(raw); err == nil {
+		t.Error("Expected error for invalid introducer folder pattern")
+	}
+}
+
+func TestMigrateDevice(t *testing.T) {
+	cfg := Configuration{
+		Devices: []DeviceConfiguration{
+			{DeviceID: device1, Name: "one", Introducer: true},
+			{DeviceID: device2, IntroducedBy: device1, IntroducerDeniedDevices: []protocol.DeviceID{device1}},
+		},
+		Folders: []FolderConfiguration{
+			{ID: "folder", Devices: []FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2, IntroducedBy: device1}}},
+		},
+		DeviceGroups:   []DeviceGroupConfiguration{{Name: "group", Devices: []protocol.DeviceID{device1}}},
+		IgnoredDevices: []protocol.DeviceID{device1},
+	}
+
+	if err := cfg.MigrateDevice(device1, device2); err == nil {
+		t.Error("Migrating to an existing device should fail")
+	}
+	if err := cfg.MigrateDevice(device3, device4); err == nil {
+		t.Error("Migrating an unknown device should fail")
+	}
+	if err := cfg.MigrateDevice(device1, device3); err != nil {
+		t.Fatal(err)
+	}
+
+	if dev := cfg.Devices[0]; dev.DeviceID != device3 || dev.Name != "one" || !dev.Introducer {
+		t.Errorf("Device not migrated correctly: %+v", dev)
+	}
+	if dev := cfg.Devices[1]; dev.IntroducedBy != device3 || dev.IntroducerDeniedDevices[0] != device3 {
+		t.Errorf("References to the device not migrated: %+v", dev)
+	}
+	if devs := cfg.Folders[0].Devices; devs[0].DeviceID != device3 || devs[1].IntroducedBy != device3 {
+		t.Errorf("Folder devices not migrated: %+v", devs)
+	}
+	if cfg.DeviceGroups[0].Devices[0] != device3 || cfg.IgnoredDevices[0] != device3 {
+		t.Error("Device groups or ignored devices not migrated")
+	}
+}
+
+func TestNewDefaultFolderConfiguration(t *testing.T) {
+	f := NewDefaultFolderConfiguration(device1, "f", "Label", "/tmp/f")
+	if f.ID != "f" || f.Label != "Label" {
+		t.Errorf("Unexpected folder %+v", f)
+	}
+	if ids := f.DeviceIDs(); !reflect.DeepEqual(ids, []protocol.DeviceID{device1}) {
+		t.Errorf("Unexpected devices %v", ids)
+	}
+	if f.RescanIntervalS != 60 || f.MinDiskFree != (Size{Value: 1, Unit: "%"}) || !f.AutoNormalize || f.MaxConflicts != -1 {
+		t.Errorf("Unexpected defaults %+v", f)
+	}
+}
+
+func TestScrubMaxKbps(t *testing.T) {
+	for _, tc := range []struct{ set, expected int }{{0, 4096}, {-1, -1}, {100, 100}} {
+		f := FolderConfiguration{ID: "f", RawPath: "testdata", ScrubMaxKbps: tc.set}
+		f.prepare()
+		if f.ScrubMaxKbps != tc.expected {
+			t.Errorf("ScrubMaxKbps %d became %d, expected %d", tc.set, f.ScrubMaxKbps, tc.expected)
+		}
+	}
+}
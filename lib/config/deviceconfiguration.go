@@ -6,7 +6,11 @@
 
 package config
 
-import "github.com/syncthing/syncthing/lib/protocol"
+import (
+	"path"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
 
 type DeviceConfiguration struct {
 	DeviceID                 protocol.DeviceID    `xml:"id,attr" json:"deviceID"`
@@ -19,8 +23,29 @@ type DeviceConfiguration struct {
 	IntroducedBy             protocol.DeviceID    `xml:"introducedBy,attr" json:"introducedBy"`
 	Paused                   bool                 `xml:"paused" json:"paused"`
 	AllowedNetworks          []string             `xml:"allowedNetwork,omitempty" json:"allowedNetworks"`
+
+	// The introducer policy restricts what an introducer may introduce. An
+	// empty list of folder patterns or allowed devices means no restriction.
+	// Devices introduced while approval is required end up as pending
+	// devices instead of being added.
+	IntroducerFolders         []string            `xml:"introducerFolder,omitempty" json:"introducerFolders"`
+	IntroducerAllowedDevices  []protocol.DeviceID `xml:"introducerAllowedDevice,omitempty" json:"introducerAllowedDevices"`
+	IntroducerDeniedDevices   []protocol.DeviceID `xml:"introducerDeniedDevice,omitempty" json:"introducerDeniedDevices"`
+	IntroducerRequireApproval bool                `xml:"introducerRequireApproval" json:"introducerRequireApproval"`
+
+	// Folders offered by the device are added automatically, at the path
+	// given by the template, with the given type and versioning.
+	AutoAcceptFolders    bool                     `xml:"autoAcceptFolders" json:"autoAcceptFolders"`
+	AutoAcceptPath       string                   `xml:"autoAcceptPath,omitempty" json:"autoAcceptPath"`
+	AutoAcceptType       FolderType               `xml:"autoAcceptType,omitempty" json:"autoAcceptType"`
+	AutoAcceptVersioning *VersioningConfiguration `xml:"autoAcceptVersioning,omitempty" json:"autoAcceptVersioning,omitempty"`
 }
 
+// DefaultAutoAcceptPath is the path template used for auto accepted folders
+// when none is set for the device. "{id}" and "{label}" are replaced by the
+// folder ID and label.
+const DefaultAutoAcceptPath = "~/{label}"
+
 func NewDeviceConfiguration(id protocol.DeviceID, name string) DeviceConfiguration {
 	d := DeviceConfiguration{
 		DeviceID: id,
@@ -36,6 +61,16 @@ func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
 	copy(c.Addresses, cfg.Addresses)
 	c.AllowedNetworks = make([]string, len(cfg.AllowedNetworks))
 	copy(c.AllowedNetworks, cfg.AllowedNetworks)
+	c.IntroducerFolders = make([]string, len(cfg.IntroducerFolders))
+	copy(c.IntroducerFolders, cfg.IntroducerFolders)
+	c.IntroducerAllowedDevices = make([]protocol.DeviceID, len(cfg.IntroducerAllowedDevices))
+	copy(c.IntroducerAllowedDevices, cfg.IntroducerAllowedDevices)
+	c.IntroducerDeniedDevices = make([]protocol.DeviceID, len(cfg.IntroducerDeniedDevices))
+	copy(c.IntroducerDeniedDevices, cfg.IntroducerDeniedDevices)
+	if cfg.AutoAcceptVersioning != nil {
+		versioning := cfg.AutoAcceptVersioning.Copy()
+		c.AutoAcceptVersioning = &versioning
+	}
 	return c
 }
 
@@ -48,6 +83,41 @@ func (cfg *DeviceConfiguration) prepare() {
 	}
 }
 
+// IntroducesFolder returns true if the introducer policy of the device
+// allows it to introduce devices to the given folder. Folder IDs are matched
+// against the patterns using path.Match.
+func (cfg DeviceConfiguration) IntroducesFolder(folder string) bool {
+	if len(cfg.IntroducerFolders) == 0 {
+		return true
+	}
+	for _, pattern := range cfg.IntroducerFolders {
+		if ok, _ := path.Match(pattern, folder); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IntroducesDevice returns true if the introducer policy of the device
+// allows it to introduce the given device. Denied devices take precedence
+// over allowed ones.
+func (cfg DeviceConfiguration) IntroducesDevice(device protocol.DeviceID) bool {
+	for _, id := range cfg.IntroducerDeniedDevices {
+		if id == device {
+			return false
+		}
+	}
+	if len(cfg.IntroducerAllowedDevices) == 0 {
+		return true
+	}
+	for _, id := range cfg.IntroducerAllowedDevices {
+		if id == device {
+			return true
+		}
+	}
+	return false
+}
+
 type DeviceConfigurationList []DeviceConfiguration
 
 func (l DeviceConfigurationList) Less(a, b int) bool {
@@ -0,0 +1,90 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A DeviceGroupConfiguration is a named set of devices. Folders shared with
+// a group are shared with all of its current members.
+type DeviceGroupConfiguration struct {
+	Name    string              `xml:"name,attr" json:"name"`
+	Devices []protocol.DeviceID `xml:"device" json:"devices"`
+}
+
+func (g DeviceGroupConfiguration) Copy() DeviceGroupConfiguration {
+	c := g
+	c.Devices = make([]protocol.DeviceID, len(g.Devices))
+	copy(c.Devices, g.Devices)
+	return c
+}
+
+func (g *DeviceGroupConfiguration) prepare(existingDevices map[protocol.DeviceID]bool) {
+	seen := make(map[protocol.DeviceID]bool, len(g.Devices))
+	devices := make([]protocol.DeviceID, 0, len(g.Devices))
+	for _, id := range g.Devices {
+		if existingDevices[id] && !seen[id] {
+			devices = append(devices, id)
+			seen[id] = true
+		}
+	}
+	sort.Sort(deviceIDList(devices))
+	g.Devices = devices
+}
+
+// A FolderDeviceGroupConfiguration shares a folder with a device group.
+// The members of the group have the given role for the folder, unless the
+// folder is also shared with them directly.
+type FolderDeviceGroupConfiguration struct {
+	Name string           `xml:",chardata" json:"name"`
+	Role FolderDeviceRole `xml:"role,attr,omitempty" json:"role"`
+}
+
+type FolderDeviceGroupConfigurationList []FolderDeviceGroupConfiguration
+
+func (l FolderDeviceGroupConfigurationList) Less(a, b int) bool {
+	return l[a].Name < l[b].Name
+}
+
+func (l FolderDeviceGroupConfigurationList) Swap(a, b int) {
+	l[a], l[b] = l[b], l[a]
+}
+
+func (l FolderDeviceGroupConfigurationList) Len() int {
+	return len(l)
+}
+
+type DeviceGroupConfigurationList []DeviceGroupConfiguration
+
+func (l DeviceGroupConfigurationList) Less(a, b int) bool {
+	return l[a].Name < l[b].Name
+}
+
+func (l DeviceGroupConfigurationList) Swap(a, b int) {
+	l[a], l[b] = l[b], l[a]
+}
+
+func (l DeviceGroupConfigurationList) Len() int {
+	return len(l)
+}
+
+type deviceIDList []protocol.DeviceID
+
+func (l deviceIDList) Less(a, b int) bool {
+	return l[a].Compare(l[b]) == -1
+}
+
+func (l deviceIDList) Swap(a, b int) {
+	l[a], l[b] = l[b], l[a]
+}
+
+func (l deviceIDList) Len() int {
+	return len(l)
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// MigrateDevice replaces the device ID of a device that has moved to a new
+// identity, everywhere it's referenced. Everything else about the device,
+// its folder shares and its introducer settings are kept.
+func (cfg *Configuration) MigrateDevice(from, to protocol.DeviceID) error {
+	found := false
+	for _, dev := range cfg.Devices {
+		switch dev.DeviceID {
+		case from:
+			found = true
+		case to:
+			return fmt.Errorf("device %v already exists", to)
+		}
+	}
+	if !found {
+		return fmt.Errorf("device %v not found", from)
+	}
+
+	replace := func(id *protocol.DeviceID) {
+		if *id == from {
+			*id = to
+		}
+	}
+	replaceAll := func(ids []protocol.DeviceID) {
+		for i := range ids {
+			replace(&ids[i])
+		}
+	}
+
+	for i := range cfg.Devices {
+		dev := &cfg.Devices[i]
+		replace(&dev.DeviceID)
+		replace(&dev.IntroducedBy)
+		replaceAll(dev.IntroducerAllowedDevices)
+		replaceAll(dev.IntroducerDeniedDevices)
+	}
+	for i := range cfg.Folders {
+		for j := range cfg.Folders[i].Devices {
+			replace(&cfg.Folders[i].Devices[j].DeviceID)
+			replace(&cfg.Folders[i].Devices[j].IntroducedBy)
+		}
+	}
+	for i := range cfg.DeviceGroups {
+		replaceAll(cfg.DeviceGroups[i].Devices)
+	}
+	replaceAll(cfg.IgnoredDevices)
+
+	return nil
+}
@@ -8,42 +8,53 @@ package config
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 type FolderConfiguration struct {
-	ID                    string                      `xml:"id,attr" json:"id"`
-	Label                 string                      `xml:"label,attr" json:"label"`
-	RawPath               string                      `xml:"path,attr" json:"path"`
-	Type                  FolderType                  `xml:"type,attr" json:"type"`
-	Devices               []FolderDeviceConfiguration `xml:"device" json:"devices"`
-	RescanIntervalS       int                         `xml:"rescanIntervalS,attr" json:"rescanIntervalS"`
-	IgnorePerms           bool                        `xml:"ignorePerms,attr" json:"ignorePerms"`
-	AutoNormalize         bool                        `xml:"autoNormalize,attr" json:"autoNormalize"`
-	MinDiskFree           Size                        `xml:"minDiskFree" json:"minDiskFree"`
-	Versioning            VersioningConfiguration     `xml:"versioning" json:"versioning"`
-	Copiers               int                         `xml:"copiers" json:"copiers"` // This defines how many files are handled concurrently.
-	Pullers               int                         `xml:"pullers" json:"pullers"` // Defines how many blocks are fetched at the same time, possibly between separate copier routines.
-	Hashers               int                         `xml:"hashers" json:"hashers"` // Less than one sets the value to the number of cores. These are CPU bound due to hashing.
-	Order                 PullOrder                   `xml:"order" json:"order"`
-	IgnoreDelete          bool                        `xml:"ignoreDelete" json:"ignoreDelete"`
-	ScanProgressIntervalS int                         `xml:"scanProgressIntervalS" json:"scanProgressIntervalS"` // Set to a negative value to disable. Value of 0 will get replaced with value of 2 (default value)
-	PullerSleepS          int                         `xml:"pullerSleepS" json:"pullerSleepS"`
-	PullerPauseS          int                         `xml:"pullerPauseS" json:"pullerPauseS"`
-	MaxConflicts          int                         `xml:"maxConflicts" json:"maxConflicts"`
-	DisableSparseFiles    bool                        `xml:"disableSparseFiles" json:"disableSparseFiles"`
-	DisableTempIndexes    bool                        `xml:"disableTempIndexes" json:"disableTempIndexes"`
-	Fsync                 bool                        `xml:"fsync" json:"fsync"`
-	Paused                bool                        `xml:"paused" json:"paused"`
-	WeakHashThresholdPct  int                         `xml:"weakHashThresholdPct" json:"weakHashThresholdPct"` // Use weak hash if more than X percent of the file has changed. Set to -1 to always use weak hash.
-
-	cachedPath string
+	ID                    string                           `xml:"id,attr" json:"id"`
+	Label                 string                           `xml:"label,attr" json:"label"`
+	RawPath               string                           `xml:"path,attr" json:"path"`
+	Type                  FolderType                       `xml:"type,attr" json:"type"`
+	Devices               []FolderDeviceConfiguration      `xml:"device" json:"devices"`
+	DeviceGroups          []FolderDeviceGroupConfiguration `xml:"deviceGroup" json:"deviceGroups"`
+	RescanIntervalS       int                              `xml:"rescanIntervalS,attr" json:"rescanIntervalS"`
+	IgnorePerms           bool                             `xml:"ignorePerms,attr" json:"ignorePerms"`
+	AutoNormalize         bool                             `xml:"autoNormalize,attr" json:"autoNormalize"`
+	MinDiskFree           Size                             `xml:"minDiskFree" json:"minDiskFree"`
+	Versioning            VersioningConfiguration          `xml:"versioning" json:"versioning"`
+	Copiers               int                              `xml:"copiers" json:"copiers"` // This defines how many files are handled concurrently.
+	Pullers               int                              `xml:"pullers" json:"pullers"` // Defines how many blocks are fetched at the same time, possibly between separate copier routines.
+	Hashers               int                              `xml:"hashers" json:"hashers"` // Less than one sets the value to the number of cores. These are CPU bound due to hashing.
+	Walkers               int                              `xml:"walkers" json:"walkers"` // Files stated concurrently when scanning, which helps on network file systems. One or less scans sequentially.
+	Order                 PullOrder                        `xml:"order" json:"order"`
+	IgnoreDelete          bool                             `xml:"ignoreDelete" json:"ignoreDelete"`
+	ScanProgressIntervalS int                              `xml:"scanProgressIntervalS" json:"scanProgressIntervalS"` // Set to a negative value to disable. Value of 0 will get replaced with value of 2 (default value)
+	PullerSleepS          int                              `xml:"pullerSleepS" json:"pullerSleepS"`
+	PullerPauseS          int                              `xml:"pullerPauseS" json:"pullerPauseS"`
+	MaxConflicts          int                              `xml:"maxConflicts" json:"maxConflicts"`
+	DisableSparseFiles    bool                             `xml:"disableSparseFiles" json:"disableSparseFiles"`
+	DisableTempIndexes    bool                             `xml:"disableTempIndexes" json:"disableTempIndexes"`
+	Fsync                 bool                             `xml:"fsync" json:"fsync"`
+	Paused                bool                             `xml:"paused" json:"paused"`
+	WeakHashThresholdPct  int                              `xml:"weakHashThresholdPct" json:"weakHashThresholdPct"`   // Use weak hash if more than X percent of the file has changed. Set to -1 to always use weak hash.
+	FileHistoryEntries    int                              `xml:"fileHistoryEntries" json:"fileHistoryEntries"`       // Versions of each file kept in the file history. Zero disables the history.
+	FileHistoryMaxAgeDays int                              `xml:"fileHistoryMaxAgeDays" json:"fileHistoryMaxAgeDays"` // Versions older than this are removed from the file history. Zero keeps them.
+	IncrementalScan       bool                             `xml:"incrementalScan" json:"incrementalScan"`             // Skip listing directories unchanged since the last scan. In place changes to files in them are found by the full scans.
+	FullScanIntervalS     int                              `xml:"fullScanIntervalS" json:"fullScanIntervalS"`         // How often an incremental folder is scanned fully, which bounds how long in place changes can go unnoticed. Defaults to an hour.
+	SettleTimeS           int                              `xml:"settleTimeS" json:"settleTimeS"`                     // Changed files are only scanned once their size and modification time have stayed the same this long. Zero scans them right away.
+	ScrubIntervalS        int                              `xml:"scrubIntervalS" json:"scrubIntervalS"`               // How often the files are read back and checked against the index, to find corrupted data. Zero disables scrubbing.
+	ScrubMaxKbps          int                              `xml:"scrubMaxKbps" json:"scrubMaxKbps"`                   // Read rate of scrubbing in KiB/s. Zero sets the default of 4096, -1 is unlimited.
+
+	cachedPath   string
+	groupDevices []FolderDeviceConfiguration // members of DeviceGroups not in Devices, with the role of their group
 
 	DeprecatedReadOnly       bool    `xml:"ro,attr,omitempty" json:"-"`
 	DeprecatedMinDiskFreePct float64 `xml:"minDiskFreePct,omitempty" json:"-"`
@@ -52,6 +63,7 @@ type FolderConfiguration struct {
 type FolderDeviceConfiguration struct {
 	DeviceID     protocol.DeviceID `xml:"id,attr" json:"deviceID"`
 	IntroducedBy protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	Role         FolderDeviceRole  `xml:"role,attr,omitempty" json:"role"`
 }
 
 func NewFolderConfiguration(id, path string) FolderConfiguration {
@@ -63,10 +75,26 @@ func NewFolderConfiguration(id, path string) FolderConfiguration {
 	return f
 }
 
+// NewDefaultFolderConfiguration returns a folder shared only with ourselves,
+// with the settings we give new folders created on the user's behalf.
+func NewDefaultFolderConfiguration(myID protocol.DeviceID, id, label, path string) FolderConfiguration {
+	f := NewFolderConfiguration(id, path)
+	f.Label = label
+	f.Devices = []FolderDeviceConfiguration{{DeviceID: myID}}
+	f.RescanIntervalS = 60
+	f.MinDiskFree = Size{Value: 1, Unit: "%"}
+	f.AutoNormalize = true
+	f.MaxConflicts = -1
+	return f
+}
+
 func (f FolderConfiguration) Copy() FolderConfiguration {
 	c := f
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
+	c.DeviceGroups = make([]FolderDeviceGroupConfiguration, len(f.DeviceGroups))
+	copy(c.DeviceGroups, f.DeviceGroups)
+	c.groupDevices = append([]FolderDeviceConfiguration(nil), f.groupDevices...)
 	c.Versioning = f.Versioning.Copy()
 	return c
 }
@@ -85,37 +113,37 @@ func (f FolderConfiguration) Path() string {
 func (f *FolderConfiguration) CreateMarker() error {
 	if !f.HasMarker() {
 		marker := filepath.Join(f.Path(), ".stfolder")
-		fd, err := os.Create(marker)
+		fd, err := fs.DefaultFilesystem.Create(marker)
 		if err != nil {
 			return err
 		}
 		fd.Close()
-		if err := osutil.SyncDir(filepath.Dir(marker)); err != nil {
+		if err := fs.SyncDir(fs.DefaultFilesystem, filepath.Dir(marker)); err != nil {
 			l.Infof("fsync %q failed: %v", filepath.Dir(marker), err)
 		}
-		osutil.HideFile(marker)
+		fs.DefaultFilesystem.Hide(marker)
 	}
 
 	return nil
 }
 
 func (f *FolderConfiguration) HasMarker() bool {
-	_, err := os.Stat(filepath.Join(f.Path(), ".stfolder"))
+	_, err := fs.DefaultFilesystem.Stat(filepath.Join(f.Path(), ".stfolder"))
 	return err == nil
 }
 
 func (f *FolderConfiguration) CreateRoot() (err error) {
 	// Directory permission bits. Will be filtered down to something
 	// sane by umask on Unixes.
-	permBits := os.FileMode(0777)
+	permBits := fs.FileMode(0777)
 	if runtime.GOOS == "windows" {
 		// Windows has no umask so we must chose a safer set of bits to
 		// begin with.
 		permBits = 0700
 	}
 
-	if _, err = os.Stat(f.Path()); os.IsNotExist(err) {
-		if err = osutil.MkdirAll(f.Path(), permBits); err != nil {
+	if _, err = fs.DefaultFilesystem.Stat(f.Path()); fs.IsNotExist(err) {
+		if err = fs.DefaultFilesystem.MkdirAll(f.Path(), permBits); err != nil {
 			l.Warnf("Creating directory for %v: %v",
 				f.Description(), err)
 		}
@@ -131,14 +159,76 @@ func (f FolderConfiguration) Description() string {
 	return fmt.Sprintf("%q (%s)", f.Label, f.ID)
 }
 
+// DeviceIDs returns the devices the folder is shared with, either directly
+// or as members of the device groups it's shared with.
 func (f *FolderConfiguration) DeviceIDs() []protocol.DeviceID {
-	deviceIDs := make([]protocol.DeviceID, len(f.Devices))
-	for i, n := range f.Devices {
-		deviceIDs[i] = n.DeviceID
+	deviceIDs := make([]protocol.DeviceID, 0, len(f.Devices)+len(f.groupDevices))
+	for _, n := range f.Devices {
+		deviceIDs = append(deviceIDs, n.DeviceID)
+	}
+	for _, n := range f.groupDevices {
+		deviceIDs = append(deviceIDs, n.DeviceID)
+	}
+	return deviceIDs
+}
+
+// DeviceRole returns the role of the device for the folder. A device the
+// folder is shared with directly has the role given there, otherwise it
+// has the role of the device group it's shared through.
+func (f *FolderConfiguration) DeviceRole(device protocol.DeviceID) FolderDeviceRole {
+	for _, dev := range f.Devices {
+		if dev.DeviceID == device {
+			return dev.Role
+		}
+	}
+	for _, dev := range f.groupDevices {
+		if dev.DeviceID == device {
+			return dev.Role
+		}
+	}
+	return FolderDeviceRoleSendReceive
+}
+
+// DevicesWithRole returns the devices the folder is shared with, directly
+// or through device groups, that have the given role.
+func (f *FolderConfiguration) DevicesWithRole(role FolderDeviceRole) []protocol.DeviceID {
+	var deviceIDs []protocol.DeviceID
+	for _, dev := range f.Devices {
+		if dev.Role == role {
+			deviceIDs = append(deviceIDs, dev.DeviceID)
+		}
+	}
+	for _, dev := range f.groupDevices {
+		if dev.Role == role {
+			deviceIDs = append(deviceIDs, dev.DeviceID)
+		}
 	}
 	return deviceIDs
 }
 
+func (f *FolderConfiguration) resolveDeviceGroups(groups map[string]DeviceGroupConfiguration) {
+	f.groupDevices = nil
+	if len(f.DeviceGroups) == 0 {
+		return
+	}
+
+	seen := make(map[protocol.DeviceID]bool, len(f.Devices))
+	for _, dev := range f.Devices {
+		seen[dev.DeviceID] = true
+	}
+	// A device in several of the groups gets the role of the first of
+	// them, by name.
+	for _, group := range f.DeviceGroups {
+		for _, id := range groups[group.Name].Devices {
+			if !seen[id] {
+				f.groupDevices = append(f.groupDevices, FolderDeviceConfiguration{DeviceID: id, Role: group.Role})
+				seen[id] = true
+			}
+		}
+	}
+	sort.Sort(FolderDeviceConfigurationList(f.groupDevices))
+}
+
 func (f *FolderConfiguration) prepare() {
 	if f.RawPath != "" {
 		// The reason it's done like this:
@@ -171,6 +261,14 @@ func (f *FolderConfiguration) prepare() {
 	if f.WeakHashThresholdPct == 0 {
 		f.WeakHashThresholdPct = 25
 	}
+
+	if f.FullScanIntervalS <= 0 {
+		f.FullScanIntervalS = 3600
+	}
+
+	if f.ScrubMaxKbps == 0 {
+		f.ScrubMaxKbps = 4096
+	}
 }
 
 func (f *FolderConfiguration) cleanedPath() string {
@@ -0,0 +1,46 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// FolderDeviceRole restricts the direction in which changes flow between us
+// and a device a folder is shared with.
+type FolderDeviceRole int
+
+const (
+	FolderDeviceRoleSendReceive FolderDeviceRole = iota // default is sendreceive
+	FolderDeviceRoleReceiveOnly                         // the device only receives from us; its changes are ignored
+	FolderDeviceRoleSendOnly                            // the device only sends to us; its requests are refused
+)
+
+func (r FolderDeviceRole) String() string {
+	switch r {
+	case FolderDeviceRoleSendReceive:
+		return "sendreceive"
+	case FolderDeviceRoleReceiveOnly:
+		return "receiveonly"
+	case FolderDeviceRoleSendOnly:
+		return "sendonly"
+	default:
+		return "unknown"
+	}
+}
+
+func (r FolderDeviceRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *FolderDeviceRole) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "receiveonly":
+		*r = FolderDeviceRoleReceiveOnly
+	case "sendonly":
+		*r = FolderDeviceRoleSendOnly
+	default:
+		*r = FolderDeviceRoleSendReceive
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ package config
 import (
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -23,9 +24,67 @@ type GUIConfiguration struct {
 	Theme                 string `xml:"theme" json:"theme" default:"default"`
 	Debugging             bool   `xml:"debugging,attr" json:"debugging"`
 	InsecureSkipHostCheck bool   `xml:"insecureSkipHostcheck,omitempty" json:"insecureSkipHostcheck"`
+
+	// When listening on a Unix socket ("unix:///path/to.sock"), the socket
+	// file is given the permissions (in octal, i.e. "0660") and owner
+	// ("user" or "user:group") set here, if any.
+	UnixSocketPermissions string `xml:"unixSocketPermissions,omitempty" json:"unixSocketPermissions"`
+	UnixSocketOwner       string `xml:"unixSocketOwner,omitempty" json:"unixSocketOwner"`
+
+	// UnixSocketTrusted skips the CSRF and API key checks for clients of
+	// the Unix socket, leaving access to the file system permissions. Any
+	// process that can reach the socket, including a reverse proxy in
+	// front of it, gets full access to the API.
+	UnixSocketTrusted bool `xml:"unixSocketTrusted,omitempty" json:"unixSocketTrusted"`
+
+	// TrustForwardedFor makes failed logins be counted for the client
+	// address given by a reverse proxy in X-Forwarded-For, rather than for
+	// the proxy. Only set it when the GUI can't be reached other than
+	// through the proxy, as the header is otherwise set by the client.
+	TrustForwardedFor bool `xml:"trustForwardedFor,omitempty" json:"trustForwardedFor"`
+
+	// TOTPSecret is the base32 encoded shared secret for time based one
+	// time password verification, enabling the second authentication
+	// factor when set. TOTPRecoveryCodes holds bcrypt hashes of the single
+	// use recovery codes that can be given in place of a TOTP code. They're
+	// kept out of the JSON config served by the API, and only managed
+	// through the enrollment endpoints.
+	TOTPSecret        string   `xml:"totpSecret,omitempty" json:"-"`
+	TOTPRecoveryCodes []string `xml:"totpRecoveryCode,omitempty" json:"-"`
+}
+
+func (c GUIConfiguration) Copy() GUIConfiguration {
+	n := c
+	n.TOTPRecoveryCodes = make([]string, len(c.TOTPRecoveryCodes))
+	copy(n.TOTPRecoveryCodes, c.TOTPRecoveryCodes)
+	return n
+}
+
+const unixSocketPrefix = "unix://"
+
+// Network returns the network to listen on for the GUI; "unix" when the
+// address is a Unix socket path, "tcp" otherwise.
+func (c GUIConfiguration) Network() string {
+	if strings.HasPrefix(c.rawAddress(), unixSocketPrefix) {
+		return "unix"
+	}
+	return "tcp"
+}
+
+func (c GUIConfiguration) rawAddress() string {
+	if override := os.Getenv("STGUIADDRESS"); override != "" {
+		return override
+	}
+	return c.RawAddress
 }
 
+// Address returns the address to listen on; a host:port pair for TCP, or
+// the path of the socket file for a Unix socket.
 func (c GUIConfiguration) Address() string {
+	if addr := c.rawAddress(); strings.HasPrefix(addr, unixSocketPrefix) {
+		return addr[len(unixSocketPrefix):]
+	}
+
 	if override := os.Getenv("STGUIADDRESS"); override != "" {
 		// This value may be of the form "scheme://address:port" or just
 		// "address:port". We need to chop off the scheme. We try to parse it as
@@ -47,6 +106,10 @@ func (c GUIConfiguration) Address() string {
 }
 
 func (c GUIConfiguration) UseTLS() bool {
+	if c.Network() == "unix" {
+		// Unix sockets are served as plain HTTP only.
+		return false
+	}
 	if override := os.Getenv("STGUIADDRESS"); override != "" && strings.HasPrefix(override, "http") {
 		return strings.HasPrefix(override, "https:")
 	}
@@ -54,6 +117,10 @@ func (c GUIConfiguration) UseTLS() bool {
 }
 
 func (c GUIConfiguration) URL() string {
+	if c.Network() == "unix" {
+		return unixSocketPrefix + c.Address()
+	}
+
 	u := url.URL{
 		Scheme: "http",
 		Host:   c.Address(),
@@ -78,6 +145,25 @@ func (c GUIConfiguration) URL() string {
 	return u.String()
 }
 
+// UnixSocketFileMode returns the configured permissions of the Unix socket
+// file, or zero if none are set.
+func (c GUIConfiguration) UnixSocketFileMode() (os.FileMode, error) {
+	if c.UnixSocketPermissions == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(c.UnixSocketPermissions, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return os.FileMode(mode) & os.ModePerm, nil
+}
+
+// TOTPEnabled returns true when a second authentication factor is required
+// in addition to the user name and password.
+func (c GUIConfiguration) TOTPEnabled() bool {
+	return c.User != "" && c.Password != "" && c.TOTPSecret != ""
+}
+
 // IsValidAPIKey returns true when the given API key is valid, including both
 // the value in config and any overrides
 func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
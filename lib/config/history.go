@@ -0,0 +1,334 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+const (
+	revisionSuffix     = ".xml"
+	revisionTimeFormat = "20060102-150405.000000000"
+)
+
+var errNoSuchRevision = errors.New("no such revision")
+
+// A Revision describes a configuration as it was saved at some point in
+// time.
+type Revision struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Origin string    `json:"origin"` // who caused the change, i.e. a REST client
+}
+
+// The revision file is the configuration wrapped in an element carrying the
+// metadata.
+type revisionFile struct {
+	XMLName xml.Name      `xml:"revision"`
+	Time    time.Time     `xml:"time,attr"`
+	Origin  string        `xml:"origin,attr"`
+	Config  Configuration `xml:"configuration"`
+}
+
+// History keeps a copy of every saved configuration in a directory, one
+// file per revision, named after the time it was saved.
+type History struct {
+	dir string
+	mut sync.Mutex
+}
+
+func NewHistory(dir string) *History {
+	return &History{
+		dir: dir,
+		mut: sync.NewMutex(),
+	}
+}
+
+// Add stores the configuration as a new revision, unless it's identical to
+// the latest one. The oldest revisions are removed so that at most keep
+// revisions remain.
+func (h *History) Add(cfg Configuration, origin string, keep int) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return err
+	}
+
+	ids, err := h.ids()
+	if err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		if latest, err := h.load(ids[len(ids)-1]); err == nil && equalConfigs(latest.Config, cfg) {
+			return nil
+		}
+	}
+
+	now := time.Now().UTC()
+	id := now.Format(revisionTimeFormat)
+	for len(ids) > 0 && id <= ids[len(ids)-1] {
+		// Saved within the same nanosecond (or the clock went backwards);
+		// keep the ordering of revisions intact anyway.
+		now = now.Add(time.Nanosecond)
+		id = now.Format(revisionTimeFormat)
+	}
+
+	fd, err := osutil.CreateAtomic(filepath.Join(h.dir, id+revisionSuffix))
+	if err != nil {
+		return err
+	}
+	e := xml.NewEncoder(fd)
+	e.Indent("", "    ")
+	if err := e.Encode(revisionFile{Time: now, Origin: origin, Config: cfg}); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	ids = append(ids, id)
+	for keep > 0 && len(ids) > keep {
+		os.Remove(filepath.Join(h.dir, ids[0]+revisionSuffix))
+		ids = ids[1:]
+	}
+
+	return nil
+}
+
+// Revisions returns the stored revisions, oldest first.
+func (h *History) Revisions() ([]Revision, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	revs := make([]Revision, 0, len(ids))
+	for _, id := range ids {
+		rf, err := h.load(id)
+		if err != nil {
+			l.Debugln("loading revision:", err)
+			continue
+		}
+		revs = append(revs, Revision{ID: id, Time: rf.Time, Origin: rf.Origin})
+	}
+	return revs, nil
+}
+
+// Load returns the configuration stored as the given revision.
+func (h *History) Load(id string) (Configuration, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	rf, err := h.load(id)
+	if err != nil {
+		return Configuration{}, err
+	}
+	return rf.Config, nil
+}
+
+// Rollback returns the configuration stored as the given revision, with
+// the credentials of the GUI taken from the current configuration. An old
+// password may have been changed for a reason, and old recovery codes may
+// have been used since.
+func (h *History) Rollback(id string, current Configuration) (Configuration, error) {
+	cfg, err := h.Load(id)
+	if err != nil {
+		return Configuration{}, err
+	}
+	cur := current.GUI.Copy()
+	cfg.GUI.User = cur.User
+	cfg.GUI.Password = cur.Password
+	cfg.GUI.APIKey = cur.APIKey
+	cfg.GUI.TOTPSecret = cur.TOTPSecret
+	cfg.GUI.TOTPRecoveryCodes = cur.TOTPRecoveryCodes
+	return cfg, nil
+}
+
+func (h *History) load(id string) (revisionFile, error) {
+	if _, err := time.Parse(revisionTimeFormat, id); err != nil {
+		// Also keeps us from reading anything outside the history dir
+		return revisionFile{}, errNoSuchRevision
+	}
+
+	fd, err := os.Open(filepath.Join(h.dir, id+revisionSuffix))
+	if os.IsNotExist(err) {
+		return revisionFile{}, errNoSuchRevision
+	} else if err != nil {
+		return revisionFile{}, err
+	}
+	defer fd.Close()
+
+	// Options added after the revision was saved should get their default
+	// values, as when loading an old config file.
+	var rf revisionFile
+	util.SetDefaults(&rf.Config)
+	util.SetDefaults(&rf.Config.Options)
+	util.SetDefaults(&rf.Config.GUI)
+	if err := xml.NewDecoder(fd).Decode(&rf); err != nil {
+		return revisionFile{}, err
+	}
+	rf.Config.OriginalVersion = rf.Config.Version
+	return rf, nil
+}
+
+// ids returns the sorted IDs of the stored revisions
+func (h *History) ids() ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(h.dir, "*"+revisionSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), revisionSuffix)
+		if _, err := time.Parse(revisionTimeFormat, id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func equalConfigs(a, b Configuration) bool {
+	var abs, bbs bytes.Buffer
+	if err := a.WriteXML(&abs); err != nil {
+		return false
+	}
+	if err := b.WriteXML(&bbs); err != nil {
+		return false
+	}
+	return bytes.Equal(abs.Bytes(), bbs.Bytes())
+}
+
+// Diff returns a unified diff between the XML forms of the two
+// configurations.
+func Diff(from, to Configuration, fromName, toName string) (string, error) {
+	var fbs, tbs bytes.Buffer
+	if err := from.WriteXML(&fbs); err != nil {
+		return "", err
+	}
+	if err := to.WriteXML(&tbs); err != nil {
+		return "", err
+	}
+	return unifiedDiff(strings.Split(fbs.String(), "\n"), strings.Split(tbs.String(), "\n"), fromName, toName), nil
+}
+
+const diffContext = 3
+
+// unifiedDiff returns the difference between the two sets of lines, based
+// on their longest common subsequence. Configurations are small enough
+// that the quadratic cost doesn't matter.
+func unifiedDiff(a, b []string, aName, bName string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte // ' ', '-' or '+'
+		text string
+		ai   int // line index in a
+		bi   int // line index in b
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var buf bytes.Buffer
+	for start := 0; start < len(lines); {
+		// Find the next change
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// Extend the hunk until there is more than twice the context of
+		// unchanged lines after a change.
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			}
+		}
+
+		hs := start - diffContext
+		if hs < 0 {
+			hs = 0
+		}
+		he := end + diffContext
+		if he > len(lines) {
+			he = len(lines)
+		}
+
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", aName, bName)
+		}
+		var acount, bcount int
+		for _, ln := range lines[hs:he] {
+			if ln.op != '+' {
+				acount++
+			}
+			if ln.op != '-' {
+				bcount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", lines[hs].ai+1, acount, lines[hs].bi+1, bcount)
+		for _, ln := range lines[hs:he] {
+			fmt.Fprintf(&buf, "%c%s\n", ln.op, ln.text)
+		}
+
+		start = he
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := NewHistory(filepath.Join(dir, "history"))
+
+	cfg := New(device1)
+	for i := 0; i < 5; i++ {
+		cfg.Options.MaxSendKbps = i
+		if err := h.Add(cfg, "test", 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An unchanged configuration should not result in a new revision
+	if err := h.Add(cfg, "test", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err := h.Revisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("Expected three revisions to be kept, not %d", len(revs))
+	}
+	for i := 1; i < len(revs); i++ {
+		if revs[i].ID <= revs[i-1].ID {
+			t.Errorf("Revisions not in order: %s after %s", revs[i].ID, revs[i-1].ID)
+		}
+	}
+	if revs[0].Origin != "test" {
+		t.Errorf("Incorrect origin %q", revs[0].Origin)
+	}
+
+	old, err := h.Load(revs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Options.MaxSendKbps != 2 {
+		t.Errorf("Incorrect loaded revision, MaxSendKbps %d != 2", old.Options.MaxSendKbps)
+	}
+
+	if _, err := h.Load("../config"); err != errNoSuchRevision {
+		t.Error("Unexpected error for invalid revision:", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := New(device1)
+	to := from.Copy()
+	to.Options.MaxRecvKbps = 42
+
+	diff, err := Diff(from, to, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(diff, "--- a\n+++ b\n@@ ") {
+		t.Errorf("Unexpected diff header:\n%s", diff)
+	}
+	if !strings.Contains(diff, "\n-        <maxRecvKbps>0</maxRecvKbps>\n+        <maxRecvKbps>42</maxRecvKbps>\n") {
+		t.Errorf("Diff does not contain the change:\n%s", diff)
+	}
+
+	if diff, _ := Diff(from, from, "a", "b"); diff != "" {
+		t.Errorf("Unexpected diff between identical configurations:\n%s", diff)
+	}
+}
+
+func TestHistoryRollbackKeepsCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := NewHistory(filepath.Join(dir, "history"))
+
+	cfg := New(device1)
+	cfg.Options.MaxSendKbps = 1
+	cfg.GUI.User = "old"
+	cfg.GUI.TOTPSecret = "OLDSECRET"
+	cfg.GUI.TOTPRecoveryCodes = []string{"used", "unused"}
+	if err := h.Add(cfg, "test", 0); err != nil {
+		t.Fatal(err)
+	}
+	revs, err := h.Revisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cur := New(device1)
+	cur.Options.MaxSendKbps = 2
+	cur.GUI.User = "new"
+	cur.GUI.TOTPSecret = "NEWSECRET"
+	cur.GUI.TOTPRecoveryCodes = []string{"unused"}
+
+	to, err := h.Rollback(revs[0].ID, cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to.Options.MaxSendKbps != 1 {
+		t.Errorf("Settings not rolled back, MaxSendKbps %d != 1", to.Options.MaxSendKbps)
+	}
+	if to.GUI.User != "new" || to.GUI.TOTPSecret != "NEWSECRET" || len(to.GUI.TOTPRecoveryCodes) != 1 {
+		t.Errorf("Credentials rolled back: %+v", to.GUI)
+	}
+}
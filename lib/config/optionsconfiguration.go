@@ -140,6 +140,12 @@ type OptionsConfiguration struct {
 	KCPCongestionControl    bool                    `xml:"kcpCongestionControl" json:"kcpCongestionControl" default:"true"`
 	KCPSendWindowSize       int                     `xml:"kcpSendWindowSize" json:"kcpSendWindowSize" default:"128"`
 	KCPReceiveWindowSize    int                     `xml:"kcpReceiveWindowSize" json:"kcpReceiveWindowSize" default:"128"`
+	MaxConfigRevisions      int                     `xml:"maxConfigRevisions" json:"maxConfigRevisions" default:"100"` // 0 for unlimited
+	AuditLogEnabled         bool                    `xml:"auditLogEnabled" json:"auditLogEnabled" default:"false"`
+	AuditLogMaxSizeKiB      int                     `xml:"auditLogMaxSizeKiB" json:"auditLogMaxSizeKiB" default:"10240"` // 0 for unlimited
+	AuditLogMaxAgeDays      int                     `xml:"auditLogMaxAgeDays" json:"auditLogMaxAgeDays" default:"30"`    // 0 for unlimited
+	AuditLogMaxFiles        int                     `xml:"auditLogMaxFiles" json:"auditLogMaxFiles" default:"12"`        // rotated files kept per folder, 0 for unlimited
+	AuditLogHashChain       bool                    `xml:"auditLogHashChain" json:"auditLogHashChain" default:"false"`
 
 	DeprecatedUPnPEnabled        bool     `xml:"upnpEnabled,omitempty" json:"-"`
 	DeprecatedUPnPLeaseM         int      `xml:"upnpLeaseMinutes,omitempty" json:"-"`
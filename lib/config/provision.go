@@ -0,0 +1,185 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"gopkg.in/yaml.v2"
+)
+
+// Provisioning is a partial configuration that is merged into an existing
+// one. It's written in YAML, using the same attribute names as the JSON
+// form of the configuration:
+//
+//	devices:
+//	  - deviceID: MFZWI3D-BONSGYC-YLTMRWG-C43ENR5-QXGZDMM-FZWI3DP-BONSGYY-LTMRWAD
+//	    name: fileserver
+//	    introducer: true
+//	folders:
+//	  - id: photos
+//	    path: ~/Photos
+//	    devices:
+//	      - MFZWI3D-BONSGYC-YLTMRWG-C43ENR5-QXGZDMM-FZWI3DP-BONSGYY-LTMRWAD
+//	options:
+//	  globalAnnounceEnabled: false
+//	gui:
+//	  address: 0.0.0.0:8384
+//
+// Devices and folders are matched on their ID; existing ones get the given
+// attributes changed and new ones are created. Devices listed for a folder
+// are added to those it's already shared with. Anything not mentioned is
+// left as is, so applying the same provisioning again changes nothing.
+type Provisioning struct {
+	Devices []map[string]interface{} `yaml:"devices"`
+	Folders []map[string]interface{} `yaml:"folders"`
+	Options map[string]interface{}   `yaml:"options"`
+	GUI     map[string]interface{}   `yaml:"gui"`
+}
+
+// ReadProvisioning parses a YAML provisioning document.
+func ReadProvisioning(r io.Reader) (Provisioning, error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Provisioning{}, err
+	}
+
+	var p Provisioning
+	if err := yaml.Unmarshal(bs, &p); err != nil {
+		return Provisioning{}, err
+	}
+	return p, nil
+}
+
+// Apply returns a copy of the given configuration, with the provisioning
+// merged into it.
+func (p Provisioning) Apply(cfg Configuration, myID protocol.DeviceID) (Configuration, error) {
+	to := cfg.Copy()
+
+	if err := mergeJSON(p.Options, &to.Options); err != nil {
+		return Configuration{}, fmt.Errorf("options: %v", err)
+	}
+	if err := mergeJSON(p.GUI, &to.GUI); err != nil {
+		return Configuration{}, fmt.Errorf("gui: %v", err)
+	}
+
+	for _, attrs := range p.Devices {
+		idStr, _ := attrs["deviceID"].(string)
+		if idStr == "" {
+			return Configuration{}, fmt.Errorf("device without deviceID")
+		}
+		id, err := protocol.DeviceIDFromString(idStr)
+		if err != nil {
+			return Configuration{}, fmt.Errorf("device %q: %v", idStr, err)
+		}
+
+		idx := -1
+		for i := range to.Devices {
+			if to.Devices[i].DeviceID == id {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			to.Devices = append(to.Devices, NewDeviceConfiguration(id, ""))
+			idx = len(to.Devices) - 1
+		}
+
+		if err := mergeJSON(attrs, &to.Devices[idx]); err != nil {
+			return Configuration{}, fmt.Errorf("device %s: %v", id, err)
+		}
+	}
+
+	for _, attrs := range p.Folders {
+		id, _ := attrs["id"].(string)
+		if id == "" {
+			return Configuration{}, fmt.Errorf("folder without id")
+		}
+
+		idx := -1
+		for i := range to.Folders {
+			if to.Folders[i].ID == id {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			to.Folders = append(to.Folders, NewFolderConfiguration(id, ""))
+			idx = len(to.Folders) - 1
+		}
+
+		// Device IDs may be given as plain strings instead of objects.
+		if devs, ok := attrs["devices"].([]interface{}); ok {
+			for i, dev := range devs {
+				if s, ok := dev.(string); ok {
+					devs[i] = map[string]interface{}{"deviceID": s}
+				}
+			}
+		}
+
+		folder := &to.Folders[idx]
+		existing := append([]FolderDeviceConfiguration(nil), folder.Devices...)
+		if err := mergeJSON(attrs, folder); err != nil {
+			return Configuration{}, fmt.Errorf("folder %s: %v", id, err)
+		}
+		if folder.RawPath == "" {
+			return Configuration{}, fmt.Errorf("folder %s: missing path", id)
+		}
+		// Duplicates are removed when the configuration is prepared below.
+		folder.Devices = append(existing, folder.Devices...)
+	}
+
+	if err := to.prepare(myID); err != nil {
+		return Configuration{}, err
+	}
+	return to, nil
+}
+
+// mergeJSON sets the attributes present in attrs on dst, by way of its JSON
+// representation. Attributes not present are left untouched.
+func mergeJSON(attrs map[string]interface{}, dst interface{}) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+	bs, err := json.Marshal(yamlToJSON(attrs))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, dst)
+}
+
+// yamlToJSON converts the map[interface{}]interface{} values produced by
+// the YAML decoder into map[string]interface{}, which can be marshalled as
+// JSON.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = yamlToJSON(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = yamlToJSON(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = yamlToJSON(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
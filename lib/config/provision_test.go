@@ -0,0 +1,108 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const testProvisioning = `
+devices:
+  - deviceID: P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2
+    name: provisioned
+    introducer: true
+folders:
+  - id: photos
+    path: /tmp/photos
+    rescanIntervalS: 120
+    devices:
+      - P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2
+options:
+  globalAnnounceEnabled: false
+gui:
+  address: 127.0.0.1:8385
+`
+
+func TestProvisioning(t *testing.T) {
+	p, err := ReadProvisioning(strings.NewReader(testProvisioning))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New(device1)
+	cfg.Folders = []FolderConfiguration{NewFolderConfiguration("photos", "/old/photos")}
+	cfg.Folders[0].Devices = []FolderDeviceConfiguration{{DeviceID: device1}}
+	origPath := cfg.Folders[0].RawPath
+
+	to, err := p.Apply(cfg, device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if to.Options.GlobalAnnEnabled {
+		t.Error("Global announce should be disabled")
+	}
+	if to.GUI.RawAddress != "127.0.0.1:8385" {
+		t.Errorf("Incorrect GUI address %q", to.GUI.RawAddress)
+	}
+
+	var dev DeviceConfiguration
+	for _, d := range to.Devices {
+		if d.DeviceID == device4 {
+			dev = d
+		}
+	}
+	if dev.Name != "provisioned" || !dev.Introducer {
+		t.Errorf("Device not provisioned correctly: %+v", dev)
+	}
+
+	if len(to.Folders) != 1 {
+		t.Fatalf("Expected one folder, not %d", len(to.Folders))
+	}
+	folder := to.Folders[0]
+	if !strings.HasPrefix(folder.RawPath, "/tmp/photos") || folder.RescanIntervalS != 120 {
+		t.Errorf("Folder not provisioned correctly: %+v", folder)
+	}
+	// The folder is shared with the existing and the provisioned device
+	if devs := folder.DeviceIDs(); len(devs) != 2 || devs[0] != device1 || devs[1] != device4 {
+		t.Errorf("Incorrect folder devices %v", devs)
+	}
+
+	// The original configuration is untouched
+	if cfg.Folders[0].RawPath != origPath || len(cfg.Folders[0].Devices) != 1 {
+		t.Error("Original configuration was modified")
+	}
+
+	// Provisioning is idempotent
+	again, err := p.Apply(to, device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := Diff(to, again, "a", "b"); diff != "" {
+		t.Errorf("Applying the provisioning again changed the configuration:\n%s", diff)
+	}
+}
+
+func TestProvisioningErrors(t *testing.T) {
+	cases := []string{
+		"devices:\n  - name: no id\n",
+		"devices:\n  - deviceID: invalid\n",
+		"folders:\n  - path: /no/id\n",
+		"folders:\n  - id: nopath\n",
+	}
+
+	for _, tc := range cases {
+		p, err := ReadProvisioning(strings.NewReader(tc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Apply(New(device1), device1); err == nil {
+			t.Errorf("Expected error for %q", tc)
+		}
+	}
+}
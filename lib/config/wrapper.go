@@ -54,6 +54,7 @@ type Wrapper struct {
 	folderMap map[string]FolderConfiguration
 	replaces  chan Configuration
 	subs      []Committer
+	history   *History
 	mut       sync.Mutex
 
 	requiresRestart uint32 // an atomic bool
@@ -276,6 +277,76 @@ func (w *Wrapper) SetFolder(fld FolderConfiguration) error {
 	return w.replaceLocked(newCfg)
 }
 
+// DeviceGroups returns a map of device groups, by name.
+func (w *Wrapper) DeviceGroups() map[string]DeviceGroupConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	groups := make(map[string]DeviceGroupConfiguration, len(w.cfg.DeviceGroups))
+	for _, group := range w.cfg.DeviceGroups {
+		groups[group.Name] = group.Copy()
+	}
+	return groups
+}
+
+// SetDeviceGroup adds a new device group to the configuration, or
+// overwrites an existing group with the same name.
+func (w *Wrapper) SetDeviceGroup(group DeviceGroupConfiguration) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	replaced := false
+	for i := range newCfg.DeviceGroups {
+		if newCfg.DeviceGroups[i].Name == group.Name {
+			newCfg.DeviceGroups[i] = group
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		newCfg.DeviceGroups = append(newCfg.DeviceGroups, group)
+	}
+
+	return w.replaceLocked(newCfg)
+}
+
+// MigrateDevice replaces the device ID of a device that has moved to a new
+// identity.
+func (w *Wrapper) MigrateDevice(from, to protocol.DeviceID) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	if err := newCfg.MigrateDevice(from, to); err != nil {
+		return err
+	}
+
+	return w.replaceLocked(newCfg)
+}
+
+// RemoveDeviceGroup removes the device group from the configuration. Folders
+// shared with the group are no longer shared with its members, unless they
+// are shared with them directly.
+func (w *Wrapper) RemoveDeviceGroup(name string) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	removed := false
+	for i := range newCfg.DeviceGroups {
+		if newCfg.DeviceGroups[i].Name == name {
+			newCfg.DeviceGroups = append(newCfg.DeviceGroups[:i], newCfg.DeviceGroups[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	if !removed {
+		return nil
+	}
+
+	return w.replaceLocked(newCfg)
+}
+
 // Options returns the current options configuration object.
 func (w *Wrapper) Options() OptionsConfiguration {
 	w.mut.Lock()
@@ -345,8 +416,29 @@ func (w *Wrapper) Folder(id string) (FolderConfiguration, bool) {
 	return FolderConfiguration{}, false
 }
 
+// SetHistory makes every future save keep a copy of the configuration as a
+// revision in the given history.
+func (w *Wrapper) SetHistory(h *History) {
+	w.mut.Lock()
+	w.history = h
+	w.mut.Unlock()
+}
+
+// History returns the configuration history, or nil if none is kept.
+func (w *Wrapper) History() *History {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.history
+}
+
 // Save writes the configuration to disk, and generates a ConfigSaved event.
 func (w *Wrapper) Save() error {
+	return w.SaveFrom("")
+}
+
+// SaveFrom is like Save, with the origin of the change recorded in the
+// configuration history.
+func (w *Wrapper) SaveFrom(origin string) error {
 	fd, err := osutil.CreateAtomic(w.path)
 	if err != nil {
 		l.Debugln("CreateAtomic:", err)
@@ -364,6 +456,18 @@ func (w *Wrapper) Save() error {
 		return err
 	}
 
+	w.mut.Lock()
+	history, cfg := w.history, w.cfg.Copy()
+	w.mut.Unlock()
+	if history != nil {
+		if origin == "" {
+			origin = "syncthing"
+		}
+		if err := history.Add(cfg, origin, cfg.Options.MaxConfigRevisions); err != nil {
+			l.Warnln("Saving configuration revision:", err)
+		}
+	}
+
 	events.Default.Log(events.ConfigSaved, w.cfg)
 	return nil
 }
@@ -0,0 +1,198 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package backend provides the ordered key-value stores the database can
+// live in.
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The available backends.
+const (
+	LevelDB = "leveldb"
+	Bolt    = "bolt"
+)
+
+// Flush copy batches when they contain this many records.
+const copyBatchSize = 1000
+
+// ErrNotFound is returned by Get when the key doesn't exist.
+var ErrNotFound = errors.New("key not found")
+
+// A Reader reads keys and values.
+type Reader interface {
+	// Get returns the value stored at the key, or ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	// NewPrefixIterator returns an iterator over the keys with the given
+	// prefix, in byte order. A nil prefix iterates over all keys.
+	NewPrefixIterator(prefix []byte) Iterator
+}
+
+// A Writer writes keys and values, one at a time.
+type Writer interface {
+	Put(key, val []byte) error
+	Delete(key []byte) error
+}
+
+// An Iterator walks over a range of keys in order. Key and Value are only
+// valid until the next call to Next.
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// A Snapshot is a view of the database for a series of reads. It must be
+// released when done.
+type Snapshot interface {
+	Reader
+	Release()
+}
+
+// A Batch collects writes that are applied together by Write. The batch
+// keeps copies of the keys and values given to it.
+type Batch interface {
+	Put(key, val []byte)
+	Delete(key []byte)
+	Len() int
+	Reset()
+	// Write applies the writes in the batch atomically. The batch keeps
+	// its contents until Reset.
+	Write() error
+}
+
+// A Backend is an ordered key-value store.
+type Backend interface {
+	Reader
+	Writer
+	// NewSnapshot returns a view of the database as it is now, unaffected
+	// by later writes. A bolt snapshot holds a read transaction, which
+	// writes that need to grow the database file wait for, so nothing may
+	// wait for a write while holding one. It's used from one goroutine at
+	// a time.
+	NewSnapshot() (Snapshot, error)
+	// NewView returns a view for a series of reads with writes in between.
+	// It's a snapshot where the backend can write while one is held, and
+	// otherwise reads the current state of the database as it goes.
+	NewView() (Snapshot, error)
+	NewBatch() Batch
+	Close() error
+}
+
+// Open opens, or creates, a database of the named backend at the location.
+func Open(location, backend string) (Backend, error) {
+	switch backend {
+	case LevelDB:
+		return OpenLevelDB(location)
+	case Bolt:
+		return OpenBolt(location)
+	default:
+		return nil, fmt.Errorf("unknown database backend %q", backend)
+	}
+}
+
+// OpenExisting opens the database at the location with the backend it was
+// created with. Unlike Open it fails if there is no database there, and it
+// neither recovers nor drops a corrupted database. A database opened read
+// only fails all writes.
+func OpenExisting(location string, readOnly bool) (Backend, error) {
+	backend, err := Detect(location)
+	if err != nil {
+		return nil, err
+	}
+	switch backend {
+	case LevelDB:
+		return openLevelDBExisting(location, readOnly)
+	case Bolt:
+		return openBoltExisting(location, readOnly)
+	default:
+		return nil, fmt.Errorf("no database at %s", location)
+	}
+}
+
+// OpenMemory returns a database that is never persisted, for testing.
+func OpenMemory() Backend {
+	return OpenLevelDBMemory()
+}
+
+// Detect returns the backend of the existing database at the location, or
+// an empty string if there is none.
+func Detect(location string) (string, error) {
+	info, err := os.Stat(location)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		// LevelDB keeps a directory of files
+		return LevelDB, nil
+	}
+	return Bolt, nil
+}
+
+// Copy copies all keys and values from the source to the destination
+// database and returns the number of keys copied.
+func Copy(dst Backend, src Reader) (int, error) {
+	it := src.NewPrefixIterator(nil)
+	defer it.Release()
+
+	batch := dst.NewBatch()
+	copied := 0
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		copied++
+		if batch.Len() >= copyBatchSize {
+			if err := batch.Write(); err != nil {
+				return copied, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return copied, err
+	}
+	return copied, batch.Write()
+}
+
+// Backup writes a consistent copy of the database to a new bolt database
+// file at the location, and returns the number of keys copied. The copy can
+// be used as a database as is, or migrated to another backend.
+func Backup(src Backend, location string) (int, error) {
+	if _, err := os.Stat(location); err == nil {
+		return 0, fmt.Errorf("%s already exists", location)
+	}
+
+	if b, ok := src.(*boltBackend); ok {
+		// Bolt copies its file as is, which is faster
+		return b.backup(location)
+	}
+
+	snap, err := src.NewSnapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer snap.Release()
+
+	dst, err := OpenBolt(location)
+	if err != nil {
+		return 0, err
+	}
+	n, err := Copy(dst, snap)
+	if err != nil {
+		dst.Close()
+		os.Remove(location)
+		return n, err
+	}
+	return n, dst.Close()
+}
@@ -0,0 +1,326 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T, name string) (Backend, func()) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bk, err := Open(filepath.Join(dir, "db"), name)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return bk, func() {
+		bk.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	for _, name := range []string{LevelDB, Bolt} {
+		t.Run(name, func(t *testing.T) {
+			bk, cleanup := openTemp(t, name)
+			defer cleanup()
+			testBackend(t, bk)
+		})
+	}
+}
+
+func testBackend(t *testing.T, bk Backend) {
+	if _, err := bk.Get([]byte("missing")); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := bk.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bk.Put([]byte("empty"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := bk.Get([]byte("a")); err != nil || string(val) != "1" {
+		t.Errorf("got %q, %v", val, err)
+	}
+	if val, err := bk.Get([]byte("empty")); err != nil || len(val) != 0 {
+		t.Errorf("got %q, %v", val, err)
+	}
+	if err := bk.Delete([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bk.Get([]byte("a")); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+
+	// Enough keys to span several bolt iterator chunks, with a reused key
+	// buffer to check that the batch copies.
+	batch := bk.NewBatch()
+	key := make([]byte, 0, 16)
+	for i := 0; i < 1000; i++ {
+		key = append(key[:0], fmt.Sprintf("p/%04d", i)...)
+		batch.Put(key, []byte{byte(i)})
+	}
+	batch.Put([]byte("q"), []byte("outside"))
+	batch.Delete([]byte("empty"))
+	if batch.Len() != 1002 {
+		t.Errorf("batch has %d entries", batch.Len())
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := bk.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := snap.NewPrefixIterator([]byte("p/"))
+	n := 0
+	for it.Next() {
+		if exp := fmt.Sprintf("p/%04d", n); string(it.Key()) != exp {
+			t.Fatalf("got key %q, expected %q", it.Key(), exp)
+		}
+		if it.Value()[0] != byte(n) {
+			t.Fatalf("wrong value for %q", it.Key())
+		}
+		n++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	it.Release()
+	snap.Release()
+	if n != 1000 {
+		t.Errorf("iterated over %d keys, expected 1000", n)
+	}
+
+	it = bk.NewPrefixIterator(nil)
+	n = 0
+	for it.Next() {
+		n++
+	}
+	it.Release()
+	if n != 1001 {
+		t.Errorf("iterated over %d keys, expected 1001", n)
+	}
+}
+
+// A snapshot doesn't see the writes made after it was taken.
+func TestSnapshotIsolation(t *testing.T) {
+	for _, name := range []string{LevelDB, Bolt} {
+		t.Run(name, func(t *testing.T) {
+			bk, cleanup := openTemp(t, name)
+			defer cleanup()
+
+			batch := bk.NewBatch()
+			for i := 0; i < 600; i++ {
+				batch.Put([]byte(fmt.Sprintf("%04d", i)), []byte("old"))
+			}
+			if err := batch.Write(); err != nil {
+				t.Fatal(err)
+			}
+
+			snap, err := bk.NewSnapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Writes from elsewhere while the snapshot is held. On bolt
+			// they may have to wait for it to be released.
+			done := make(chan error, 1)
+			go func() {
+				batch := bk.NewBatch()
+				for i := 0; i < 600; i++ {
+					batch.Put([]byte(fmt.Sprintf("%04d", i)), []byte("new"))
+				}
+				batch.Put([]byte("added"), nil)
+				done <- batch.Write()
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+				done <- nil
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			if _, err := snap.Get([]byte("added")); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound for key added after the snapshot, got %v", err)
+			}
+			it := snap.NewPrefixIterator(nil)
+			n := 0
+			for it.Next() {
+				if string(it.Value()) != "old" {
+					t.Fatalf("got %q for %q in snapshot", it.Value(), it.Key())
+				}
+				n++
+			}
+			it.Release()
+			snap.Release()
+			if n != 600 {
+				t.Errorf("iterated over %d keys, expected 600", n)
+			}
+
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			if val, err := bk.Get([]byte("0000")); err != nil || string(val) != "new" {
+				t.Errorf("got %q, %v outside the snapshot", val, err)
+			}
+		})
+	}
+}
+
+// Deleting the keys an iterator is going over must not upset the iteration.
+func TestDeleteWhileIterating(t *testing.T) {
+	for _, name := range []string{LevelDB, Bolt} {
+		t.Run(name, func(t *testing.T) {
+			bk, cleanup := openTemp(t, name)
+			defer cleanup()
+
+			batch := bk.NewBatch()
+			for i := 0; i < 600; i++ {
+				batch.Put([]byte(fmt.Sprintf("%04d", i)), nil)
+			}
+			if err := batch.Write(); err != nil {
+				t.Fatal(err)
+			}
+
+			it := bk.NewPrefixIterator(nil)
+			defer it.Release()
+			n := 0
+			for it.Next() {
+				if err := bk.Delete(it.Key()); err != nil {
+					t.Fatal(err)
+				}
+				n++
+			}
+			if n != 600 {
+				t.Errorf("iterated over %d keys, expected 600", n)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := OpenMemory()
+	defer src.Close()
+	for i := 0; i < 2500; i++ {
+		src.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("val%d", i)))
+	}
+
+	dst, cleanup := openTemp(t, Bolt)
+	defer cleanup()
+
+	n, err := Copy(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2500 {
+		t.Errorf("copied %d keys, expected 2500", n)
+	}
+	for i := 0; i < 2500; i++ {
+		val, err := dst.Get([]byte(fmt.Sprintf("key%d", i)))
+		if err != nil || string(val) != fmt.Sprintf("val%d", i) {
+			t.Fatalf("key%d: got %q, %v", i, val, err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{LevelDB, Bolt} {
+		path := filepath.Join(dir, name)
+		if detected, err := Detect(path); err != nil || detected != "" {
+			t.Errorf("%s: detected %q, %v before creation", name, detected, err)
+		}
+		bk, err := Open(path, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bk.Close()
+		if detected, err := Detect(path); err != nil || detected != name {
+			t.Errorf("detected %q, %v, expected %s", detected, err, name)
+		}
+	}
+}
+
+func TestOpenExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nothing is created at a path without a database
+	missing := filepath.Join(dir, "missing")
+	if bk, err := OpenExisting(missing, false); err == nil {
+		bk.Close()
+		t.Error("opened a database that doesn't exist")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("something was created at %s: %v", missing, err)
+	}
+
+	for _, name := range []string{LevelDB, Bolt} {
+		path := filepath.Join(dir, name)
+		bk, err := Open(path, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bk.Put([]byte("key"), []byte("val"))
+		bk.Close()
+
+		bk, err = OpenExisting(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val, err := bk.Get([]byte("key")); err != nil || string(val) != "val" {
+			t.Errorf("%s: got %q, %v", name, val, err)
+		}
+		if err := bk.Put([]byte("key"), []byte("other")); err == nil {
+			t.Errorf("%s: wrote to a read only database", name)
+		}
+		bk.Close()
+
+		bk, err = OpenExisting(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bk.Put([]byte("key"), []byte("other")); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		bk.Close()
+	}
+
+	// A corrupted LevelDB database is left alone
+	corrupt := filepath.Join(dir, LevelDB)
+	if err := ioutil.WriteFile(filepath.Join(corrupt, "CURRENT"), []byte("garbage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if bk, err := OpenExisting(corrupt, false); err == nil {
+		bk.Close()
+		t.Error("opened a corrupted database")
+	}
+	if _, err := os.Stat(filepath.Join(corrupt, "CURRENT")); err != nil {
+		t.Errorf("corrupted database was dropped: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// All keys live in a single bucket.
+var boltBucket = []byte("syncthing")
+
+// Iterators read this many keys per read transaction.
+const boltIteratorChunk = 256
+
+// boltBackend is a Backend on top of a bolt database file.
+//
+// Bolt can't grow its memory map while a read transaction is open, so a
+// write that needs to grow the file blocks until all reads are done.
+// Snapshots hold a read transaction until released, and are only used for
+// reads that don't wait for writes. The database layer writes while
+// iterating, hence its views and the iterators of the backend itself never
+// hold a transaction across calls. Instead they read a chunk of keys at a
+// time in short transactions, and see writes made while they're in use.
+type boltBackend struct {
+	bdb *bbolt.DB
+}
+
+// OpenBolt opens, or creates, the bolt database file at the location.
+func OpenBolt(location string) (Backend, error) {
+	bdb, err := bbolt.Open(location, 0600, boltOptions(false))
+	if err != nil {
+		return nil, err
+	}
+
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+
+	return &boltBackend{bdb}, nil
+}
+
+func openBoltExisting(location string, readOnly bool) (Backend, error) {
+	bdb, err := bbolt.Open(location, 0600, boltOptions(readOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	err = bdb.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(boltBucket) == nil {
+			return fmt.Errorf("no database at %s", location)
+		}
+		return nil
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+
+	return &boltBackend{bdb}, nil
+}
+
+func boltOptions(readOnly bool) *bbolt.Options {
+	return &bbolt.Options{
+		// Fail instead of hanging if another instance has it open
+		Timeout:  10 * time.Second,
+		ReadOnly: readOnly,
+	}
+}
+
+func (b *boltBackend) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := b.bdb.View(func(tx *bbolt.Tx) error {
+		var err error
+		val, err = boltGet(tx, key)
+		return err
+	})
+	return val, err
+}
+
+func (b *boltBackend) NewPrefixIterator(prefix []byte) Iterator {
+	return &boltIterator{
+		bdb:    b.bdb,
+		prefix: prefix,
+		next:   prefix,
+		pos:    -1,
+	}
+}
+
+func (b *boltBackend) Put(key, val []byte) error {
+	return b.bdb.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, val)
+	})
+}
+
+func (b *boltBackend) Delete(key []byte) error {
+	return b.bdb.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+func (b *boltBackend) NewSnapshot() (Snapshot, error) {
+	tx, err := b.bdb.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return boltSnapshot{tx}, nil
+}
+
+func (b *boltBackend) NewView() (Snapshot, error) {
+	return boltView{b}, nil
+}
+
+func (b *boltBackend) NewBatch() Batch {
+	return &boltBatch{bdb: b.bdb}
+}
+
+func (b *boltBackend) Close() error {
+	return b.bdb.Close()
+}
+
+// backup copies the database file as it is in a single read transaction.
+// Writes that need to grow the database wait until it's done.
+func (b *boltBackend) backup(location string) (int, error) {
+	var n int
+	err := b.bdb.View(func(tx *bbolt.Tx) error {
+		n = tx.Bucket(boltBucket).Stats().KeyN
+		return tx.CopyFile(location, 0600)
+	})
+	return n, err
+}
+
+// boltGet returns a copy of the value at the key, as the value itself is
+// only valid during the transaction.
+func boltGet(tx *bbolt.Tx, key []byte) ([]byte, error) {
+	k, v := tx.Bucket(boltBucket).Cursor().Seek(key)
+	if !bytes.Equal(k, key) {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+// boltSnapshot reads in a single read transaction.
+type boltSnapshot struct {
+	tx *bbolt.Tx
+}
+
+func (s boltSnapshot) Get(key []byte) ([]byte, error) {
+	return boltGet(s.tx, key)
+}
+
+func (s boltSnapshot) NewPrefixIterator(prefix []byte) Iterator {
+	return &boltSnapshotIterator{
+		c:      s.tx.Bucket(boltBucket).Cursor(),
+		prefix: prefix,
+	}
+}
+
+func (s boltSnapshot) Release() {
+	s.tx.Rollback()
+}
+
+// boltSnapshotIterator walks the keys with a cursor of the snapshot
+// transaction. The keys and values stay valid until the snapshot is
+// released.
+type boltSnapshotIterator struct {
+	c       *bbolt.Cursor
+	prefix  []byte
+	started bool
+	key     []byte
+	val     []byte
+}
+
+func (it *boltSnapshotIterator) Next() bool {
+	if it.c == nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.prefix == nil {
+			it.key, it.val = it.c.First()
+		} else {
+			it.key, it.val = it.c.Seek(it.prefix)
+		}
+	} else {
+		it.key, it.val = it.c.Next()
+	}
+	if it.key == nil || !bytes.HasPrefix(it.key, it.prefix) {
+		it.Release()
+		return false
+	}
+	return true
+}
+
+func (it *boltSnapshotIterator) Key() []byte {
+	return it.key
+}
+
+func (it *boltSnapshotIterator) Value() []byte {
+	return it.val
+}
+
+func (it *boltSnapshotIterator) Error() error {
+	return nil
+}
+
+func (it *boltSnapshotIterator) Release() {
+	it.c = nil
+	it.key = nil
+	it.val = nil
+}
+
+// boltView reads the current state of the database, in short transactions.
+type boltView struct {
+	*boltBackend
+}
+
+func (boltView) Release() {}
+
+// boltIterator reads ahead a chunk of keys and values at a time.
+type boltIterator struct {
+	bdb    *bbolt.DB
+	prefix []byte
+	next   []byte // where the next chunk starts, nil when done
+	keys   [][]byte
+	vals   [][]byte
+	pos    int
+	err    error
+}
+
+func (it *boltIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.pos++
+	if it.pos < len(it.keys) {
+		return true
+	}
+	if it.next == nil && it.keys != nil {
+		return false
+	}
+	it.err = it.readChunk()
+	it.pos = 0
+	return it.err == nil && len(it.keys) > 0
+}
+
+func (it *boltIterator) readChunk() error {
+	it.keys = it.keys[:0]
+	it.vals = it.vals[:0]
+	if it.keys == nil {
+		it.keys = make([][]byte, 0, boltIteratorChunk)
+		it.vals = make([][]byte, 0, boltIteratorChunk)
+	}
+
+	return it.bdb.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		var k, v []byte
+		if it.next == nil {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(it.next)
+		}
+		for ; k != nil; k, v = c.Next() {
+			if !bytes.HasPrefix(k, it.prefix) {
+				break
+			}
+			if len(it.keys) == boltIteratorChunk {
+				// Resume at this key next time
+				it.next = append([]byte{}, k...)
+				return nil
+			}
+			it.keys = append(it.keys, append([]byte{}, k...))
+			it.vals = append(it.vals, append([]byte{}, v...))
+		}
+		it.next = nil
+		return nil
+	})
+}
+
+func (it *boltIterator) Key() []byte {
+	if it.pos < 0 || it.pos >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.pos]
+}
+
+func (it *boltIterator) Value() []byte {
+	if it.pos < 0 || it.pos >= len(it.vals) {
+		return nil
+	}
+	return it.vals[it.pos]
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+func (it *boltIterator) Release() {
+	it.keys = nil
+	it.vals = nil
+	it.next = nil
+	it.pos = 0
+}
+
+type boltOp struct {
+	key, val []byte
+	delete   bool
+}
+
+// boltBatch applies its operations in a single write transaction.
+type boltBatch struct {
+	bdb *bbolt.DB
+	ops []boltOp
+}
+
+// Put and Delete copy their arguments, like the LevelDB batch does, as the
+// caller is free to reuse them.
+func (b *boltBatch) Put(key, val []byte) {
+	b.ops = append(b.ops, boltOp{key: append([]byte{}, key...), val: append([]byte{}, val...)})
+}
+
+func (b *boltBatch) Delete(key []byte) {
+	b.ops = append(b.ops, boltOp{key: append([]byte{}, key...), delete: true})
+}
+
+func (b *boltBatch) Len() int {
+	return len(b.ops)
+}
+
+func (b *boltBatch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+func (b *boltBatch) Write() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	return b.bdb.Update(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(boltBucket)
+		for _, op := range b.ops {
+			var err error
+			if op.delete {
+				err = bkt.Delete(op.key)
+			} else {
+				err = bkt.Put(op.key, op.val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
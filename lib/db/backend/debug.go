@@ -0,0 +1,22 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"os"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/logger"
+)
+
+var (
+	l = logger.DefaultLogger.NewFacility("dbbackend", "The database storage backends")
+)
+
+func init() {
+	l.SetDebug("dbbackend", strings.Contains(os.Getenv("STTRACE"), "dbbackend") || os.Getenv("STTRACE") == "all")
+}
@@ -0,0 +1,168 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"os"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// leveldbBackend is a Backend on top of a LevelDB database directory.
+type leveldbBackend struct {
+	ldb *leveldb.DB
+}
+
+// OpenLevelDB opens, or creates, the LevelDB database at the location. A
+// corrupted database is recovered if possible, or dropped otherwise.
+func OpenLevelDB(location string) (Backend, error) {
+	opts := leveldbOptions()
+	ldb, err := leveldb.OpenFile(location, opts)
+	if leveldbIsCorrupted(err) {
+		ldb, err = leveldb.RecoverFile(location, opts)
+	}
+	if leveldbIsCorrupted(err) {
+		// The database is corrupted, and we've tried to recover it but it
+		// didn't work. At this point there isn't much to do beyond dropping
+		// the database and reindexing...
+		l.Infoln("Database corruption detected, unable to recover. Reinitializing...")
+		if err := os.RemoveAll(location); err != nil {
+			return nil, err
+		}
+		ldb, err = leveldb.OpenFile(location, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &leveldbBackend{ldb}, nil
+}
+
+func openLevelDBExisting(location string, readOnly bool) (Backend, error) {
+	opts := leveldbOptions()
+	opts.ErrorIfMissing = true
+	opts.ReadOnly = readOnly
+	ldb, err := leveldb.OpenFile(location, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &leveldbBackend{ldb}, nil
+}
+
+func leveldbOptions() *opt.Options {
+	return &opt.Options{
+		OpenFilesCacheCapacity: 100,
+		WriteBuffer:            4 << 20,
+	}
+}
+
+// OpenLevelDBMemory returns a LevelDB database kept in memory.
+func OpenLevelDBMemory() Backend {
+	ldb, _ := leveldb.Open(storage.NewMemStorage(), nil)
+	return &leveldbBackend{ldb}
+}
+
+func (b *leveldbBackend) Get(key []byte) ([]byte, error) {
+	val, err := b.ldb.Get(key, nil)
+	return val, leveldbError(err)
+}
+
+func (b *leveldbBackend) NewPrefixIterator(prefix []byte) Iterator {
+	return b.ldb.NewIterator(leveldbPrefix(prefix), nil)
+}
+
+func (b *leveldbBackend) Put(key, val []byte) error {
+	return b.ldb.Put(key, val, nil)
+}
+
+func (b *leveldbBackend) Delete(key []byte) error {
+	return b.ldb.Delete(key, nil)
+}
+
+func (b *leveldbBackend) NewSnapshot() (Snapshot, error) {
+	snap, err := b.ldb.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return leveldbSnapshot{snap}, nil
+}
+
+// NewView returns a snapshot, as LevelDB writes while snapshots are held.
+func (b *leveldbBackend) NewView() (Snapshot, error) {
+	return b.NewSnapshot()
+}
+
+func (b *leveldbBackend) NewBatch() Batch {
+	return &leveldbBatch{ldb: b.ldb}
+}
+
+func (b *leveldbBackend) Close() error {
+	return b.ldb.Close()
+}
+
+type leveldbSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s leveldbSnapshot) Get(key []byte) ([]byte, error) {
+	val, err := s.snap.Get(key, nil)
+	return val, leveldbError(err)
+}
+
+func (s leveldbSnapshot) NewPrefixIterator(prefix []byte) Iterator {
+	return s.snap.NewIterator(leveldbPrefix(prefix), nil)
+}
+
+func (s leveldbSnapshot) Release() {
+	s.snap.Release()
+}
+
+type leveldbBatch struct {
+	leveldb.Batch
+	ldb *leveldb.DB
+}
+
+func (b *leveldbBatch) Write() error {
+	return b.ldb.Write(&b.Batch, nil)
+}
+
+// leveldbPrefix returns the range of keys with the prefix, with nil meaning
+// the whole database.
+func leveldbPrefix(prefix []byte) *util.Range {
+	if prefix == nil {
+		return nil
+	}
+	return util.BytesPrefix(prefix)
+}
+
+func leveldbError(err error) error {
+	if err == leveldb.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// A "better" version of leveldb's errors.IsCorrupted.
+func leveldbIsCorrupted(err error) bool {
+	switch {
+	case err == nil:
+		return false
+
+	case errors.IsCorrupted(err):
+		return true
+
+	case strings.Contains(err.Error(), "corrupted"):
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The export is a gzipped stream of JSON objects, one per line. The first
+// is an exportHeader and the rest are exportRecords. Folders and devices
+// are given by name, so unlike a backup the export doesn't depend on how
+// the database happens to number them.
+const (
+	exportFormat  = "syncthing-index-export"
+	exportVersion = 1
+)
+
+// Import files in batches of this many records.
+const importBatchSize = 1000
+
+var errNotExport = errors.New("not a database export")
+
+type exportHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// An exportRecord holds one of a file, an index ID or a virtual mtime.
+type exportRecord struct {
+	Folder  string             `json:"folder"`
+	Device  *protocol.DeviceID `json:"device,omitempty"`
+	File    *protocol.FileInfo `json:"file,omitempty"`
+	IndexID protocol.IndexID   `json:"indexID,omitempty"`
+	Mtime   *exportMtime       `json:"mtime,omitempty"`
+}
+
+type exportMtime struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Backup writes a consistent copy of the database to a new database file at
+// the location, and returns the number of keys copied. The copy can replace
+// the database as is.
+func (db *Instance) Backup(location string) (int, error) {
+	return backend.Backup(db.Backend, location)
+}
+
+// Export writes the files, index IDs and virtual mtimes of all folders, as
+// of a single snapshot, to the writer.
+func (db *Instance) Export(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	enc := json.NewEncoder(gw)
+
+	err := enc.Encode(exportHeader{
+		Format:  exportFormat,
+		Version: exportVersion,
+		Created: time.Now().Truncate(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	t := db.newSnapshotTransaction()
+	defer t.close()
+
+	dbi := t.NewPrefixIterator([]byte{KeyTypeDevice})
+	for dbi.Next() {
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			l.Debugln("unmarshal error:", err)
+			continue
+		}
+		device := protocol.DeviceIDFromBytes(db.deviceKeyDevice(dbi.Key()))
+		rec := exportRecord{
+			Folder: string(db.deviceKeyFolder(dbi.Key())),
+			Device: &device,
+			File:   &f,
+		}
+		if err := enc.Encode(rec); err != nil {
+			dbi.Release()
+			return err
+		}
+	}
+	dbi.Release()
+
+	dbi = t.NewPrefixIterator([]byte{KeyTypeIndexID})
+	for dbi.Next() {
+		key := dbi.Key()
+		device, ok := db.deviceIdx.Val(binary.BigEndian.Uint32(key[keyPrefixLen:]))
+		if !ok {
+			continue
+		}
+		folder, ok := db.folderIdx.Val(binary.BigEndian.Uint32(key[keyPrefixLen+keyDeviceLen:]))
+		if !ok {
+			continue
+		}
+		var id protocol.IndexID
+		if err := id.Unmarshal(dbi.Value()); err != nil {
+			continue
+		}
+		deviceID := protocol.DeviceIDFromBytes(device)
+		rec := exportRecord{
+			Folder:  string(folder),
+			Device:  &deviceID,
+			IndexID: id,
+		}
+		if err := enc.Encode(rec); err != nil {
+			dbi.Release()
+			return err
+		}
+	}
+	dbi.Release()
+
+	dbi = t.NewPrefixIterator([]byte{KeyTypeVirtualMtime})
+	for dbi.Next() {
+		key := dbi.Key()
+		folder, ok := db.folderIdx.Val(binary.BigEndian.Uint32(key[keyPrefixLen:]))
+		if !ok {
+			continue
+		}
+		rec := exportRecord{
+			Folder: string(folder),
+			Mtime: &exportMtime{
+				Name: string(key[keyPrefixLen+keyFolderLen:]),
+				Data: dbi.Value(),
+			},
+		}
+		if err := enc.Encode(rec); err != nil {
+			dbi.Release()
+			return err
+		}
+	}
+	dbi.Release()
+
+	return gw.Close()
+}
+
+// Import reads an export into the database. The folders in it must not
+// already be in the database. Sequence numbers and index IDs are kept, so
+// other devices don't need to send their full indexes again, and the local
+// files, being already hashed, aren't rehashed on the next scan. Nothing
+// else may be using the database during the import. If the import fails,
+// the folders imported so far are dropped again, so that it can be retried.
+func (db *Instance) Import(r io.Reader) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return errNotExport
+	}
+	dec := json.NewDecoder(gr)
+
+	var hdr exportHeader
+	if err := dec.Decode(&hdr); err != nil || hdr.Format != exportFormat {
+		return errNotExport
+	}
+	if hdr.Version != exportVersion {
+		return fmt.Errorf("unsupported export version %d", hdr.Version)
+	}
+
+	sets := make(map[string]*FileSet)
+	if err := db.importRecords(dec, sets); err != nil {
+		for folder := range sets {
+			l.Debugln("dropping partially imported folder", folder)
+			DropFolder(db, folder)
+			db.dropIndexIDs([]byte(folder))
+		}
+		return err
+	}
+	return nil
+}
+
+// importRecords reads the records following the header, adding the file
+// sets of the folders written to to sets.
+func (db *Instance) importRecords(dec *json.Decoder, sets map[string]*FileSet) error {
+	existing := make(map[string]bool)
+	for _, folder := range db.ListFolders() {
+		existing[folder] = true
+	}
+	fileSet := func(folder string) (*FileSet, error) {
+		if s, ok := sets[folder]; ok {
+			return s, nil
+		}
+		if existing[folder] {
+			return nil, fmt.Errorf("folder %q is already in the database", folder)
+		}
+		s := NewFileSet(folder, db)
+		sets[folder] = s
+		return s, nil
+	}
+
+	// Consecutive files of the same folder and device are restored together
+	var batch []protocol.FileInfo
+	var batchSet *FileSet
+	var batchDevice protocol.DeviceID
+	flush := func() {
+		if len(batch) > 0 {
+			batchSet.restore(batchDevice, batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		var rec exportRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		s, err := fileSet(rec.Folder)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case rec.File != nil && rec.Device != nil:
+			if s != batchSet || *rec.Device != batchDevice || len(batch) >= importBatchSize {
+				flush()
+				batchSet = s
+				batchDevice = *rec.Device
+			}
+			batch = append(batch, *rec.File)
+
+		case rec.IndexID != 0 && rec.Device != nil:
+			db.setIndexID(rec.Device[:], []byte(rec.Folder), rec.IndexID)
+
+		case rec.Mtime != nil:
+			key := append(db.mtimesKey([]byte(rec.Folder)), rec.Mtime.Name...)
+			if err := db.Put(key, rec.Mtime.Data); err != nil {
+				return err
+			}
+		}
+	}
+	flush()
+
+	return nil
+}
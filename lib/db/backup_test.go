@@ -0,0 +1,158 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var exportRemote, _ = protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+
+func exportTestDB() *Instance {
+	ldb := OpenMemory()
+	s := NewFileSet("default", ldb)
+	s.Replace(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(1), Blocks: []protocol.BlockInfo{{Size: 1, Hash: []byte("hash a")}}},
+		{Name: "b", Version: protocol.Vector{}.Update(1)},
+	})
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(1).Update(1)},
+	})
+	s.Replace(exportRemote, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(1), Sequence: 42},
+		{Name: "c", Version: protocol.Vector{}.Update(2), Sequence: 43},
+	})
+	s.IndexID(protocol.LocalDeviceID)
+	s.SetIndexID(exportRemote, 1234)
+	NewNamespacedKV(ldb, string(ldb.mtimesKey([]byte("default")))).PutBytes("a", []byte("mtime"))
+	return ldb
+}
+
+func TestExportImport(t *testing.T) {
+	src := exportTestDB()
+	srcSet := NewFileSet("default", src)
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := OpenMemory()
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	dstSet := NewFileSet("default", dst)
+
+	for _, device := range []protocol.DeviceID{protocol.LocalDeviceID, exportRemote} {
+		if srcSeq, dstSeq := srcSet.Sequence(device), dstSet.Sequence(device); srcSeq != dstSeq {
+			t.Errorf("%v: sequence %d, expected %d", device, dstSeq, srcSeq)
+		}
+		if srcID, dstID := srcSet.IndexID(device), dstSet.IndexID(device); srcID != dstID {
+			t.Errorf("%v: index ID %v, expected %v", device, dstID, srcID)
+		}
+		for _, name := range []string{"a", "b", "c"} {
+			srcFile, srcOk := srcSet.Get(device, name)
+			dstFile, dstOk := dstSet.Get(device, name)
+			if srcOk != dstOk || srcFile.Sequence != dstFile.Sequence || !srcFile.Version.Equal(dstFile.Version) {
+				t.Errorf("%v: %s imported as %v, expected %v", device, name, dstFile, srcFile)
+			}
+		}
+	}
+
+	if srcSize, dstSize := srcSet.GlobalSize(), dstSet.GlobalSize(); srcSize != dstSize {
+		t.Errorf("global size %v, expected %v", dstSize, srcSize)
+	}
+
+	kv := NewNamespacedKV(dst, string(dst.mtimesKey([]byte("default"))))
+	if data, ok := kv.Bytes("a"); !ok || string(data) != "mtime" {
+		t.Errorf("mtime imported as %q", data)
+	}
+
+	// The folder is there now, so it can't be imported again.
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("importing an existing folder should fail")
+	}
+	if err := dst.Import(bytes.NewReader([]byte("garbage"))); err != errNotExport {
+		t.Errorf("importing garbage should fail with errNotExport, got %v", err)
+	}
+}
+
+func TestImportFailureDropsFolders(t *testing.T) {
+	src := exportTestDB()
+	other := []protocol.FileInfo{{Name: "x", Version: protocol.Vector{}.Update(1)}}
+	NewFileSet("other", src).Replace(protocol.LocalDeviceID, other)
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second folder is already there, so the import fails after the
+	// first one was written.
+	dst := OpenMemory()
+	NewFileSet("other", dst).Replace(protocol.LocalDeviceID, other)
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("importing an existing folder should fail")
+	}
+
+	if folders := dst.ListFolders(); len(folders) != 1 || folders[0] != "other" {
+		t.Errorf("folders after failed import: %v", folders)
+	}
+	if id := dst.getIndexID(exportRemote[:], []byte("default")); id != 0 {
+		t.Errorf("index ID %v left after failed import", id)
+	}
+	kv := NewNamespacedKV(dst, string(dst.mtimesKey([]byte("default"))))
+	if _, ok := kv.Bytes("a"); ok {
+		t.Error("mtime left after failed import")
+	}
+
+	// Once out of the way, the import can be done again.
+	DropFolder(dst, "other")
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if folders := dst.ListFolders(); len(folders) != 2 {
+		t.Errorf("folders after import: %v", folders)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := exportTestDB()
+	path := filepath.Join(dir, "backup.db")
+	if _, err := src.Backup(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Backup(path); err == nil {
+		t.Error("backup should not overwrite an existing file")
+	}
+
+	backup, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	s := NewFileSet("default", backup)
+	if f, ok := s.Get(exportRemote, "c"); !ok || f.Sequence != 43 {
+		t.Errorf("backup has %v, %v", f, ok)
+	}
+	if id := s.IndexID(exportRemote); id != 1234 {
+		t.Errorf("backup has index ID %v", id)
+	}
+}
@@ -12,9 +12,6 @@ import (
 
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
-
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 var blockFinder *BlockFinder
@@ -35,12 +32,12 @@ func NewBlockMap(db *Instance, folder uint32) *BlockMap {
 
 // Add files to the block map, ignoring any deleted or invalid files.
 func (m *BlockMap) Add(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch := m.db.NewBatch()
 	buf := make([]byte, 4)
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := batch.Write(); err != nil {
 				return err
 			}
 			batch.Reset()
@@ -56,17 +53,17 @@ func (m *BlockMap) Add(files []protocol.FileInfo) error {
 			batch.Put(key, buf)
 		}
 	}
-	return m.db.Write(batch, nil)
+	return batch.Write()
 }
 
 // Update block map state, removing any deleted or invalid files.
 func (m *BlockMap) Update(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch := m.db.NewBatch()
 	buf := make([]byte, 4)
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := batch.Write(); err != nil {
 				return err
 			}
 			batch.Reset()
@@ -90,16 +87,16 @@ func (m *BlockMap) Update(files []protocol.FileInfo) error {
 			batch.Put(key, buf)
 		}
 	}
-	return m.db.Write(batch, nil)
+	return batch.Write()
 }
 
 // Discard block map state, removing the given files
 func (m *BlockMap) Discard(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch := m.db.NewBatch()
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := batch.Write(); err != nil {
 				return err
 			}
 			batch.Reset()
@@ -110,17 +107,17 @@ func (m *BlockMap) Discard(files []protocol.FileInfo) error {
 			batch.Delete(key)
 		}
 	}
-	return m.db.Write(batch, nil)
+	return batch.Write()
 }
 
 // Drop block map, removing all entries related to this block map from the db.
 func (m *BlockMap) Drop() error {
-	batch := new(leveldb.Batch)
-	iter := m.db.NewIterator(util.BytesPrefix(m.blockKeyInto(nil, nil, "")[:keyPrefixLen+keyFolderLen]), nil)
+	batch := m.db.NewBatch()
+	iter := m.db.NewPrefixIterator(m.blockKeyInto(nil, nil, "")[:keyPrefixLen+keyFolderLen])
 	defer iter.Release()
 	for iter.Next() {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := batch.Write(); err != nil {
 				return err
 			}
 			batch.Reset()
@@ -131,7 +128,7 @@ func (m *BlockMap) Drop() error {
 	if iter.Error() != nil {
 		return iter.Error()
 	}
-	return m.db.Write(batch, nil)
+	return batch.Write()
 }
 
 func (m *BlockMap) blockKeyInto(o, hash []byte, file string) []byte {
@@ -168,7 +165,7 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 	for _, folder := range folders {
 		folderID := f.db.folderIdx.ID([]byte(folder))
 		key = blockKeyInto(key, hash, folderID, "")
-		iter := f.db.NewIterator(util.BytesPrefix(key), nil)
+		iter := f.db.NewPrefixIterator(key)
 		defer iter.Release()
 
 		for iter.Next() && iter.Error() == nil {
@@ -189,10 +186,10 @@ func (f *BlockFinder) Fix(folder, file string, index int32, oldHash, newHash []b
 	binary.BigEndian.PutUint32(buf, uint32(index))
 
 	folderID := f.db.folderIdx.ID([]byte(folder))
-	batch := new(leveldb.Batch)
+	batch := f.db.NewBatch()
 	batch.Delete(blockKeyInto(nil, oldHash, folderID, file))
 	batch.Put(blockKeyInto(nil, newHash, folderID, file), buf)
-	return f.db.Write(batch, nil)
+	return batch.Write()
 }
 
 // m.blockKey returns a byte slice encoding the following information:
@@ -0,0 +1,511 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The kinds of problems found by Check.
+const (
+	ProblemIndex    = "index"    // unknown or duplicate folder or device index number
+	ProblemDecode   = "decode"   // entry that can't be decoded
+	ProblemGlobal   = "global"   // global version list not matching the device entries
+	ProblemBlockMap = "blockmap" // block map entry not matching the local files
+	ProblemSequence = "sequence" // local sequence number that's invalid or used twice
+)
+
+// A CheckProblem is an inconsistency found by Check.
+type CheckProblem struct {
+	Kind   string `json:"kind"`
+	Folder string `json:"folder,omitempty"`
+	Device string `json:"device,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// A FolderCheck sums up a folder as seen by Check.
+type FolderCheck struct {
+	Folder     string `json:"folder"`
+	Entries    int    `json:"entries"` // file entries of all devices
+	LocalSize  Counts `json:"localSize"`
+	GlobalSize Counts `json:"globalSize"`
+	Sequence   int64  `json:"sequence"`
+}
+
+// A CheckResult is the outcome of Check.
+type CheckResult struct {
+	Folders  []FolderCheck  `json:"folders"`
+	Problems []CheckProblem `json:"problems"`
+	Repaired bool           `json:"repaired"`
+}
+
+// Check verifies the folder and device index numbers, the global version
+// lists against the device entries, the block map against the local files,
+// and the local sequence numbers. If repair is set, the problems found are
+// fixed afterwards. Nothing else may use the database during a repair.
+func (db *Instance) Check(repair bool) CheckResult {
+	c := &checker{
+		db:          db,
+		t:           db.newSnapshotTransaction(),
+		folders:     make(map[uint32]*folderCheck),
+		badFolders:  make(map[uint32]bool),
+		badDevices:  make(map[uint32]bool),
+		missingKeys: make(map[string][]byte),
+	}
+
+	c.checkIndex([]byte{KeyTypeFolderIdx}, db.folderIdx, c.badFolders, "folder")
+	c.checkIndex([]byte{KeyTypeDeviceIdx}, db.deviceIdx, c.badDevices, "device")
+	c.checkReferences()
+	c.checkDeviceEntries()
+	c.checkGlobals()
+	c.checkBlockMap()
+	c.t.close()
+
+	res := c.result()
+	if repair && len(res.Problems) > 0 {
+		c.repair()
+		res.Repaired = true
+	}
+	return res
+}
+
+type checker struct {
+	db          *Instance
+	t           readOnlyTransaction
+	folders     map[uint32]*folderCheck
+	badFolders  map[uint32]bool
+	badDevices  map[uint32]bool
+	problems    []CheckProblem
+	staleKeys   [][]byte          // removed by repair
+	missingKeys map[string][]byte // added by repair
+}
+
+type folderCheck struct {
+	FolderCheck
+	id             uint32
+	receiveOnly    map[protocol.DeviceID]bool
+	localSize      sizeTracker
+	globalSize     sizeTracker
+	devices        map[uint32]bool
+	sequences      map[int64]bool
+	renumber       [][]byte // keys of local files needing a new sequence number
+	rebuildGlobals bool
+}
+
+func (c *checker) problem(kind, folder string, device []byte, name, format string, args ...interface{}) {
+	p := CheckProblem{
+		Kind:   kind,
+		Folder: folder,
+		Name:   name,
+		Detail: fmt.Sprintf(format, args...),
+	}
+	if device != nil {
+		p.Device = protocol.DeviceIDFromBytes(device).String()
+	}
+	c.problems = append(c.problems, p)
+}
+
+// stale marks a key to be removed by repair
+func (c *checker) stale(key []byte) {
+	c.staleKeys = append(c.staleKeys, append([]byte{}, key...))
+}
+
+// checkIndex finds index numbers that aren't the one their value is looked
+// up by, which happens if a value got two numbers.
+func (c *checker) checkIndex(prefix []byte, idx *smallIndex, bad map[uint32]bool, what string) {
+	dbi := c.t.NewPrefixIterator(prefix)
+	defer dbi.Release()
+
+	for dbi.Next() {
+		id := binary.BigEndian.Uint32(dbi.Key()[len(prefix):])
+		if cur, ok := idx.lookup(dbi.Value()); !ok || cur != id {
+			bad[id] = true
+			c.problem(ProblemIndex, "", nil, "", "%s index number %d duplicates %d for %q", what, id, cur, dbi.Value())
+			c.stale(dbi.Key())
+		}
+	}
+}
+
+// folder returns the folder with the index number, or nil if the number is
+// unknown.
+func (c *checker) folder(id uint32) *folderCheck {
+	if fc, ok := c.folders[id]; ok {
+		return fc
+	}
+	if c.badFolders[id] {
+		return nil
+	}
+	name, ok := c.db.folderIdx.Val(id)
+	if !ok {
+		return nil
+	}
+	fc := &folderCheck{
+		FolderCheck: FolderCheck{Folder: string(name)},
+		id:          id,
+		receiveOnly: c.db.receiveOnlyDevices(name),
+		devices:     make(map[uint32]bool),
+		sequences:   make(map[int64]bool),
+	}
+	c.folders[id] = fc
+	return fc
+}
+
+// device returns the device with the index number, or false if the number
+// is unknown.
+func (c *checker) device(id uint32) ([]byte, bool) {
+	if c.badDevices[id] {
+		return nil, false
+	}
+	return c.db.deviceIdx.Val(id)
+}
+
+// deviceKey returns the device entry key, or nil if the device has no index
+// number.
+func (c *checker) deviceKey(folder uint32, device, name []byte) []byte {
+	id, ok := c.db.deviceIdx.lookup(device)
+	if !ok {
+		return nil
+	}
+	k := make([]byte, keyPrefixLen+keyFolderLen+keyDeviceLen+len(name))
+	k[0] = KeyTypeDevice
+	binary.BigEndian.PutUint32(k[keyPrefixLen:], folder)
+	binary.BigEndian.PutUint32(k[keyPrefixLen+keyFolderLen:], id)
+	copy(k[keyPrefixLen+keyFolderLen+keyDeviceLen:], name)
+	return k
+}
+
+// checkReferences finds index IDs, mtimes, receive only marks, file
+// histories and directory states of unknown folders and devices. They are
+// left over and can be removed.
+func (c *checker) checkReferences() {
+	for _, prefix := range []byte{KeyTypeIndexID, KeyTypeVirtualMtime, KeyTypeReceiveOnlyDevice, KeyTypeFileHistory, KeyTypeDirState} {
+		dbi := c.t.NewPrefixIterator([]byte{prefix})
+		for dbi.Next() {
+			key := dbi.Key()
+			var known bool
+			if prefix == KeyTypeIndexID {
+				_, known = c.device(binary.BigEndian.Uint32(key[keyPrefixLen:]))
+				known = known && c.folder(binary.BigEndian.Uint32(key[keyPrefixLen+keyDeviceLen:])) != nil
+			} else {
+				known = c.folder(binary.BigEndian.Uint32(key[keyPrefixLen:])) != nil
+			}
+			if !known {
+				c.problem(ProblemIndex, "", nil, "", "entry of type %d for unknown folder or device", prefix)
+				c.stale(key)
+			}
+		}
+		dbi.Release()
+	}
+}
+
+// checkDeviceEntries checks every file entry against the global version
+// list, and the local ones against the block map and each other's sequence
+// numbers.
+func (c *checker) checkDeviceEntries() {
+	dbi := c.t.NewPrefixIterator([]byte{KeyTypeDevice})
+	defer dbi.Release()
+
+	for dbi.Next() {
+		key := dbi.Key()
+		folderID := binary.BigEndian.Uint32(key[keyPrefixLen:])
+		deviceID := binary.BigEndian.Uint32(key[keyPrefixLen+keyFolderLen:])
+		fc := c.folder(folderID)
+		device, ok := c.device(deviceID)
+		if fc == nil || !ok {
+			c.problem(ProblemIndex, "", nil, "", "file entry for unknown folder %d or device %d", folderID, deviceID)
+			c.stale(key)
+			continue
+		}
+		name := c.db.deviceKeyName(key)
+
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			c.problem(ProblemDecode, fc.Folder, device, string(name), "file entry: %v", err)
+			c.stale(key)
+			fc.rebuildGlobals = true
+			continue
+		}
+		fc.Entries++
+		fc.devices[deviceID] = true
+
+		if bytes.Equal(device, protocol.LocalDeviceID[:]) {
+			fc.localSize.addFile(f)
+			c.checkSequence(fc, key, f)
+			c.checkBlocks(fc, name, f)
+		}
+
+		// The file should be in the global version list, with its version,
+		// unless it's not taking part.
+		shouldList := !f.IsInvalid() && !fc.receiveOnly[protocol.DeviceIDFromBytes(device)]
+		listed := false
+		if bs, err := c.t.Get(c.db.globalKey([]byte(fc.Folder), name)); err == nil {
+			var vl VersionList
+			if vl.Unmarshal(bs) == nil {
+				for _, v := range vl.Versions {
+					if bytes.Equal(v.Device, device) && v.Version.Equal(f.Version) {
+						listed = true
+						break
+					}
+				}
+			}
+		}
+		if listed != shouldList {
+			if shouldList {
+				c.problem(ProblemGlobal, fc.Folder, device, string(name), "file missing from global version list")
+			} else {
+				c.problem(ProblemGlobal, fc.Folder, device, string(name), "file shouldn't be in global version list")
+			}
+			fc.rebuildGlobals = true
+		}
+	}
+}
+
+func (c *checker) checkSequence(fc *folderCheck, key []byte, f protocol.FileInfo) {
+	switch {
+	case f.Sequence <= 0:
+		c.problem(ProblemSequence, fc.Folder, nil, f.Name, "invalid sequence number %d", f.Sequence)
+	case fc.sequences[f.Sequence]:
+		c.problem(ProblemSequence, fc.Folder, nil, f.Name, "sequence number %d used twice", f.Sequence)
+	default:
+		fc.sequences[f.Sequence] = true
+		if f.Sequence > fc.Sequence {
+			fc.Sequence = f.Sequence
+		}
+		return
+	}
+	fc.renumber = append(fc.renumber, append([]byte{}, key...))
+}
+
+func (c *checker) checkBlocks(fc *folderCheck, name []byte, f protocol.FileInfo) {
+	if f.IsDirectory() || f.IsDeleted() || f.IsInvalid() {
+		return
+	}
+
+	buf := make([]byte, 4)
+	for i, block := range f.Blocks {
+		key := blockKeyInto(nil, block.Hash, fc.id, string(name))
+		if val, err := c.t.Get(key); err == nil && len(val) == 4 {
+			// Files with repeated blocks have one entry with the index of
+			// any of them.
+			idx := binary.BigEndian.Uint32(val)
+			if int(idx) < len(f.Blocks) && bytes.Equal(f.Blocks[idx].Hash, block.Hash) {
+				continue
+			}
+		}
+		if _, ok := c.missingKeys[string(key)]; ok {
+			continue
+		}
+		c.problem(ProblemBlockMap, fc.Folder, nil, f.Name, "block %d missing from block map", i)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		c.missingKeys[string(key)] = append([]byte{}, buf...)
+	}
+}
+
+// checkGlobals checks that every global version list refers to existing
+// file entries with the same version.
+func (c *checker) checkGlobals() {
+	dbi := c.t.NewPrefixIterator([]byte{KeyTypeGlobal})
+	defer dbi.Release()
+
+	for dbi.Next() {
+		key := dbi.Key()
+		folderID := binary.BigEndian.Uint32(key[keyPrefixLen:])
+		fc := c.folder(folderID)
+		if fc == nil {
+			c.problem(ProblemIndex, "", nil, "", "global version list for unknown folder %d", folderID)
+			c.stale(key)
+			continue
+		}
+		name := c.db.globalKeyName(key)
+
+		var vl VersionList
+		if err := vl.Unmarshal(dbi.Value()); err != nil {
+			c.problem(ProblemDecode, fc.Folder, nil, string(name), "global version list: %v", err)
+			fc.rebuildGlobals = true
+			continue
+		}
+		if len(vl.Versions) == 0 {
+			c.problem(ProblemGlobal, fc.Folder, nil, string(name), "empty global version list")
+			fc.rebuildGlobals = true
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for i, v := range vl.Versions {
+			if seen[string(v.Device)] {
+				c.problem(ProblemGlobal, fc.Folder, v.Device, string(name), "device listed twice")
+				fc.rebuildGlobals = true
+				continue
+			}
+			seen[string(v.Device)] = true
+
+			var f protocol.FileInfo
+			var ok bool
+			if fk := c.deviceKey(folderID, v.Device, name); fk != nil {
+				f, ok = getFile(c.t, fk)
+			}
+			switch {
+			case !ok:
+				c.problem(ProblemGlobal, fc.Folder, v.Device, string(name), "listed file doesn't exist")
+			case !f.Version.Equal(v.Version):
+				c.problem(ProblemGlobal, fc.Folder, v.Device, string(name), "listed version %v differs from file version %v", v.Version, f.Version)
+			case f.IsInvalid():
+				c.problem(ProblemGlobal, fc.Folder, v.Device, string(name), "listed file is invalid")
+			case fc.receiveOnly[protocol.DeviceIDFromBytes(v.Device)]:
+				c.problem(ProblemGlobal, fc.Folder, v.Device, string(name), "listed device is receive only")
+			default:
+				if i == 0 {
+					fc.globalSize.addFile(f)
+				}
+				continue
+			}
+			fc.rebuildGlobals = true
+		}
+	}
+}
+
+// checkBlockMap checks that every block map entry refers to a block of a
+// local file.
+func (c *checker) checkBlockMap() {
+	dbi := c.t.NewPrefixIterator([]byte{KeyTypeBlock})
+	defer dbi.Release()
+
+	localKey := protocol.LocalDeviceID[:]
+	for dbi.Next() {
+		key := dbi.Key()
+		folderID := binary.BigEndian.Uint32(key[keyPrefixLen:])
+		fc := c.folder(folderID)
+		if fc == nil || len(key) <= keyPrefixLen+keyFolderLen+keyHashLen {
+			c.problem(ProblemIndex, "", nil, "", "block map entry for unknown folder %d", folderID)
+			c.stale(key)
+			continue
+		}
+		name := blockKeyName(key)
+		hash := key[keyPrefixLen+keyFolderLen : keyPrefixLen+keyFolderLen+keyHashLen]
+
+		var f protocol.FileInfo
+		var ok bool
+		if fk := c.deviceKey(folderID, localKey, []byte(name)); fk != nil {
+			f, ok = getFile(c.t, fk)
+		}
+		val := dbi.Value()
+		switch {
+		case !ok || f.IsDirectory() || f.IsDeleted() || f.IsInvalid():
+			c.problem(ProblemBlockMap, fc.Folder, nil, name, "block map entry for missing file")
+		case len(val) != 4:
+			c.problem(ProblemDecode, fc.Folder, nil, name, "block map entry of %d bytes", len(val))
+		default:
+			idx := binary.BigEndian.Uint32(val)
+			if int(idx) < len(f.Blocks) && bytes.Equal(f.Blocks[idx].Hash, hash) {
+				continue
+			}
+			c.problem(ProblemBlockMap, fc.Folder, nil, name, "block map entry for block %d doesn't match the file", idx)
+		}
+		c.stale(key)
+	}
+}
+
+func (c *checker) result() CheckResult {
+	res := CheckResult{
+		Folders:  make([]FolderCheck, 0, len(c.folders)),
+		Problems: c.problems,
+	}
+	byName := make(map[string]*folderCheck, len(c.folders))
+	names := make([]string, 0, len(c.folders))
+	for _, fc := range c.folders {
+		byName[fc.Folder] = fc
+		names = append(names, fc.Folder)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fc := byName[name]
+		fc.LocalSize = fc.localSize.Size()
+		fc.GlobalSize = fc.globalSize.Size()
+		res.Folders = append(res.Folders, fc.FolderCheck)
+	}
+	if res.Problems == nil {
+		res.Problems = []CheckProblem{}
+	}
+	return res
+}
+
+func (c *checker) repair() {
+	batch := c.db.NewBatch()
+	for _, key := range c.staleKeys {
+		batch.Delete(key)
+	}
+	for key, val := range c.missingKeys {
+		batch.Put([]byte(key), val)
+	}
+	if err := batch.Write(); err != nil {
+		panic(err)
+	}
+
+	for _, fc := range c.folders {
+		c.renumberSequences(fc)
+		if fc.rebuildGlobals {
+			c.rebuildGlobals(fc)
+		}
+	}
+}
+
+// renumberSequences gives the local files with bad sequence numbers new
+// ones after the highest one in use.
+func (c *checker) renumberSequences(fc *folderCheck) {
+	if len(fc.renumber) == 0 {
+		return
+	}
+
+	t := c.db.newReadWriteTransaction()
+	defer t.close()
+
+	for _, key := range fc.renumber {
+		f, ok := getFile(t, key)
+		if !ok {
+			continue
+		}
+		fc.Sequence++
+		f.Sequence = fc.Sequence
+		t.Put(key, mustMarshal(&f))
+		t.checkFlush()
+	}
+}
+
+// rebuildGlobals throws away the global version lists of the folder and
+// creates them again from the file entries, one device at a time.
+func (c *checker) rebuildGlobals(fc *folderCheck) {
+	folder := []byte(fc.Folder)
+	prefix := c.db.globalKey(folder, nil)[:keyPrefixLen+keyFolderLen]
+	c.db.dropPrefix(prefix)
+
+	var globalSize sizeTracker
+	for deviceID := range fc.devices {
+		device, ok := c.device(deviceID)
+		if !ok || fc.receiveOnly[protocol.DeviceIDFromBytes(device)] {
+			continue
+		}
+
+		t := c.db.newReadWriteTransaction()
+		dbi := t.NewPrefixIterator(c.db.deviceKey(folder, device, nil))
+		for dbi.Next() {
+			var f protocol.FileInfo
+			if err := f.Unmarshal(dbi.Value()); err != nil || f.IsInvalid() {
+				continue
+			}
+			t.updateGlobal(folder, device, f, &globalSize)
+			t.checkFlush()
+		}
+		dbi.Release()
+		t.close()
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestCheckCleanDatabase(t *testing.T) {
+	ldb := exportTestDB()
+
+	res := ldb.Check(false)
+	if len(res.Problems) != 0 {
+		t.Fatalf("unexpected problems: %+v", res.Problems)
+	}
+	if len(res.Folders) != 1 {
+		t.Fatalf("unexpected folders: %+v", res.Folders)
+	}
+	fc := res.Folders[0]
+	if fc.Folder != "default" || fc.Entries != 4 || fc.LocalSize.Files != 2 || fc.GlobalSize.Files != 3 || fc.Sequence != 3 {
+		t.Errorf("unexpected folder summary %+v", fc)
+	}
+}
+
+func TestCheckRepair(t *testing.T) {
+	ldb := exportTestDB()
+	folder := []byte("default")
+	folderID := ldb.folderIdx.ID(folder)
+	hash := bytes.Repeat([]byte{0xd}, 32)
+	NewFileSet("default", ldb).Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "d", Version: protocol.Vector{}.Update(1), Blocks: []protocol.BlockInfo{{Size: 1, Hash: hash}}},
+	})
+
+	// A global version of a file that's gone
+	ldb.Delete(ldb.deviceKey(folder, exportRemote[:], []byte("c")))
+	// A file missing from the global versions
+	ldb.Delete(ldb.globalKey(folder, []byte("b")))
+	// A block map entry for a file that's gone, and a missing one for "d"
+	ldb.Put(blockKeyInto(nil, make([]byte, 32), folderID, "gone"), []byte{0, 0, 0, 0})
+	ldb.Delete(blockKeyInto(nil, hash, folderID, "d"))
+	// A sequence number used twice
+	f, _ := ldb.getFile(folder, protocol.LocalDeviceID[:], []byte("b"))
+	f.Sequence = 3
+	ldb.Put(ldb.deviceKey(folder, protocol.LocalDeviceID[:], []byte("b")), mustMarshal(&f))
+	// A file entry of an unknown folder
+	key := ldb.deviceKey(folder, exportRemote[:], []byte("x"))
+	binary.BigEndian.PutUint32(key[keyPrefixLen:], 1234)
+	ldb.Put(key, mustMarshal(&f))
+
+	res := ldb.Check(false)
+	kinds := make(map[string]int)
+	for _, p := range res.Problems {
+		kinds[p.Kind]++
+	}
+	expected := map[string]int{
+		ProblemGlobal:   2,
+		ProblemBlockMap: 2,
+		ProblemSequence: 1,
+		ProblemIndex:    1,
+	}
+	for kind, n := range expected {
+		if kinds[kind] != n {
+			t.Errorf("found %d %s problems, expected %d: %+v", kinds[kind], kind, n, res.Problems)
+		}
+	}
+	if res.Repaired {
+		t.Error("check shouldn't repair")
+	}
+
+	if rep := ldb.Check(true); !rep.Repaired || len(rep.Problems) != len(res.Problems) {
+		t.Errorf("unexpected repair result %+v", rep)
+	}
+	if res := ldb.Check(false); len(res.Problems) != 0 {
+		t.Errorf("problems left after repair: %+v", res.Problems)
+	}
+
+	s := NewFileSet("default", ldb)
+	if _, ok := s.GetGlobal("c"); ok {
+		t.Error("c should no longer be global")
+	}
+	if g, ok := s.GetGlobal("b"); !ok || g.Sequence == 3 {
+		t.Errorf("b should be global with a new sequence number, got %v, %v", g, ok)
+	}
+}
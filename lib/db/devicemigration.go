@@ -0,0 +1,112 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// MigrateDevice moves what we know about a device that has moved to a new
+// identity over to its new device ID: the files it has announced, its index
+// IDs, its folder roles and its statistics. The new device ID must not be
+// known already. Any announcement of the move is forgotten.
+//
+// All changes are written in a single batch, so that the index numbers and
+// the entries referring to the device directly stay in step.
+func (db *Instance) MigrateDevice(from, to protocol.DeviceID) error {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	// Files and index IDs are keyed by the index number of the device,
+	// which the new device ID takes over.
+	if !db.deviceIdx.rename(t, from[:], to[:]) {
+		return fmt.Errorf("device %v is already in the database", to)
+	}
+
+	// The global version lists refer to the device directly
+	dbi := t.NewPrefixIterator([]byte{KeyTypeGlobal})
+	for dbi.Next() {
+		var vl VersionList
+		if err := vl.Unmarshal(dbi.Value()); err != nil {
+			l.Debugln("unmarshal error:", err)
+			continue
+		}
+		changed := false
+		for i := range vl.Versions {
+			if bytes.Equal(vl.Versions[i].Device, from[:]) {
+				vl.Versions[i].Device = to[:]
+				changed = true
+			}
+		}
+		if changed {
+			t.Put(dbi.Key(), mustMarshal(&vl))
+		}
+	}
+	dbi.Release()
+
+	// As do the receive only markers
+	dbi = t.NewPrefixIterator([]byte{KeyTypeReceiveOnlyDevice})
+	for dbi.Next() {
+		key := dbi.Key()
+		if bytes.Equal(key[keyPrefixLen+keyFolderLen:], from[:]) {
+			newKey := make([]byte, len(key))
+			copy(newKey, key)
+			copy(newKey[keyPrefixLen+keyFolderLen:], to[:])
+			t.Put(newKey, nil)
+			t.Delete(key)
+		}
+	}
+	dbi.Release()
+
+	// Statistics are namespaced by the device ID string
+	oldPrefix := append([]byte{KeyTypeDeviceStatistic}, from.String()...)
+	newPrefix := append([]byte{KeyTypeDeviceStatistic}, to.String()...)
+	dbi = t.NewPrefixIterator(oldPrefix)
+	for dbi.Next() {
+		newKey := append(append([]byte(nil), newPrefix...), dbi.Key()[len(oldPrefix):]...)
+		t.Put(newKey, append([]byte(nil), dbi.Value()...))
+		t.Delete(dbi.Key())
+	}
+	dbi.Release()
+
+	t.Delete(identityMigrationKey(to))
+
+	return nil
+}
+
+// AddIdentityMigration remembers that a device has announced its move to a
+// new identity, until the new identity is seen.
+func (db *Instance) AddIdentityMigration(from, to protocol.DeviceID) {
+	if err := db.Put(identityMigrationKey(to), from[:]); err != nil {
+		l.Debugln("storing identity migration:", err)
+	}
+}
+
+// IdentityMigrationTo returns the device that announced its move to the
+// given new identity, if any.
+func (db *Instance) IdentityMigrationTo(to protocol.DeviceID) (protocol.DeviceID, bool) {
+	bs, err := db.Get(identityMigrationKey(to))
+	if err != nil || len(bs) != protocol.DeviceIDLength {
+		return protocol.DeviceID{}, false
+	}
+	return protocol.DeviceIDFromBytes(bs), true
+}
+
+// RemoveIdentityMigration forgets an announced move to the given identity.
+func (db *Instance) RemoveIdentityMigration(to protocol.DeviceID) {
+	db.Delete(identityMigrationKey(to))
+}
+
+func identityMigrationKey(to protocol.DeviceID) []byte {
+	k := make([]byte, keyPrefixLen+protocol.DeviceIDLength)
+	k[0] = KeyTypeIdentityMigration
+	copy(k[keyPrefixLen:], to[:])
+	return k
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The history of a file is the list of versions it has had, oldest first.
+// A version is recorded when it's first seen, announced by either us or a
+// remote device, so the history survives newer versions replacing the file
+// entries. Each file's history is stored as a FileHistory message.
+
+const (
+	HistoryOriginLocal  = "local"
+	HistoryOriginRemote = "remote"
+)
+
+// A HistoryEntry is a version of a file.
+type HistoryEntry struct {
+	Version    protocol.Vector   `json:"version"`
+	ModifiedBy protocol.ShortID  `json:"modifiedBy"`
+	Modified   time.Time         `json:"modified"`
+	Size       int64             `json:"size"`
+	Deleted    bool              `json:"deleted"`
+	Hash       string            `json:"hash,omitempty"` // hex SHA-256 of the block hashes, for regular files
+	Recorded   time.Time         `json:"recorded"`       // when the version was first seen
+	Origin     string            `json:"origin"`         // HistoryOriginLocal or HistoryOriginRemote
+	Device     protocol.DeviceID `json:"device"`         // the device first announcing the version
+}
+
+func newHistoryEntry(f protocol.FileInfo, device protocol.DeviceID, now time.Time) FileHistoryEntry {
+	e := FileHistoryEntry{
+		Version:    f.Version,
+		ModifiedBy: f.ModifiedBy,
+		ModifiedS:  f.ModifiedS,
+		ModifiedNs: f.ModifiedNs,
+		Size:       f.Size,
+		Deleted:    f.Deleted,
+		RecordedS:  now.Unix(),
+		Device:     device[:],
+	}
+	if !f.IsDeleted() && !f.IsDirectory() && !f.IsSymlink() {
+		e.Hash = f.BlocksHash()
+	}
+	return e
+}
+
+func (e FileHistoryEntry) historyEntry() HistoryEntry {
+	h := HistoryEntry{
+		Version:    e.Version,
+		ModifiedBy: e.ModifiedBy,
+		Modified:   time.Unix(e.ModifiedS, int64(e.ModifiedNs)),
+		Size:       e.Size,
+		Deleted:    e.Deleted,
+		Recorded:   time.Unix(e.RecordedS, 0),
+		Origin:     HistoryOriginRemote,
+	}
+	copy(h.Device[:], e.Device)
+	if h.Device == protocol.LocalDeviceID {
+		h.Origin = HistoryOriginLocal
+	}
+	if len(e.Hash) > 0 {
+		h.Hash = hex.EncodeToString(e.Hash)
+	}
+	return h
+}
+
+// contains returns whether the version is in the history. The newest entry
+// is checked first as that's usually the one announced again.
+func (h FileHistory) contains(v protocol.Vector) bool {
+	for i := len(h.Entries) - 1; i >= 0; i-- {
+		if h.Entries[i].Version.Equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneHistory returns the entries that are at most maxEntries long and, if
+// maxAge is set, recorded within maxAge of now.
+func pruneHistory(entries []FileHistoryEntry, maxEntries int, maxAge time.Duration, now time.Time) []FileHistoryEntry {
+	if maxAge > 0 {
+		cutoff := now.Add(-maxAge).Unix()
+		i := 0
+		for i < len(entries) && entries[i].RecordedS < cutoff {
+			i++
+		}
+		entries = entries[i:]
+	}
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+	return entries
+}
+
+// recordHistory adds the versions of the files that aren't in their
+// histories yet. Files whose history is up to date are left untouched.
+func (db *Instance) recordHistory(folder []byte, device protocol.DeviceID, fs []protocol.FileInfo, maxEntries int, maxAge time.Duration) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	now := time.Now()
+	var key []byte
+	for _, f := range fs {
+		if f.IsInvalid() {
+			continue
+		}
+
+		key = db.historyKeyInto(key[:cap(key)], folder, []byte(f.Name))
+		hist := getHistory(t, key)
+		if hist.contains(f.Version) {
+			continue
+		}
+
+		hist.Entries = append(hist.Entries, newHistoryEntry(f, device, now))
+		hist.Entries = pruneHistory(hist.Entries, maxEntries, maxAge, now)
+		bs, _ := hist.Marshal()
+		t.Put(key, bs)
+		t.checkFlush()
+	}
+}
+
+// pruneAllHistory applies the limits to the histories of all the files in
+// the folder.
+func (db *Instance) pruneAllHistory(folder []byte, maxEntries int, maxAge time.Duration) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	dbi := t.NewPrefixIterator(db.historyKey(folder, nil))
+	defer dbi.Release()
+
+	now := time.Now()
+	for dbi.Next() {
+		var hist FileHistory
+		if err := hist.Unmarshal(dbi.Value()); err != nil {
+			l.Debugln("unmarshal error:", err)
+			continue
+		}
+		pruned := pruneHistory(hist.Entries, maxEntries, maxAge, now)
+		switch {
+		case len(pruned) == len(hist.Entries):
+			continue
+		case len(pruned) == 0:
+			t.Delete(dbi.Key())
+		default:
+			hist.Entries = pruned
+			bs, _ := hist.Marshal()
+			t.Put(dbi.Key(), bs)
+		}
+		t.checkFlush()
+	}
+}
+
+func (db *Instance) history(folder, name []byte) []FileHistoryEntry {
+	return getHistory(db, db.historyKey(folder, name)).Entries
+}
+
+func (db *Instance) dropHistory(folder []byte) {
+	db.dropPrefix(db.historyKey(folder, nil))
+}
+
+func getHistory(db dbReader, key []byte) FileHistory {
+	var hist FileHistory
+	bs, err := db.Get(key)
+	if err != nil {
+		return hist
+	}
+	if err := hist.Unmarshal(bs); err != nil {
+		l.Debugln("unmarshal error:", err)
+		return FileHistory{}
+	}
+	return hist
+}
+
+// historyKey returns a byte slice encoding the following information:
+//
+//	keyTypeFileHistory (1 byte)
+//	folder (4 bytes)
+//	name (variable size)
+func (db *Instance) historyKey(folder, name []byte) []byte {
+	return db.historyKeyInto(nil, folder, name)
+}
+
+func (db *Instance) historyKeyInto(k, folder, name []byte) []byte {
+	reqLen := keyPrefixLen + keyFolderLen + len(name)
+	if cap(k) < reqLen {
+		k = make([]byte, reqLen)
+	} else {
+		k = k[:reqLen]
+	}
+	k[0] = KeyTypeFileHistory
+	binary.BigEndian.PutUint32(k[keyPrefixLen:], db.folderIdx.ID(folder))
+	copy(k[keyPrefixLen+keyFolderLen:], name)
+	return k
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFileHistory(t *testing.T) {
+	ldb := OpenMemory()
+	s := NewFileSet("test", ldb)
+	s.SetHistory(2, 0)
+
+	remote := protocol.DeviceID{42}
+	v1 := protocol.Vector{}.Update(1)
+	v2 := protocol.Vector{}.Update(1).Update(remote.Short())
+	v3 := protocol.Vector{}.Update(1).Update(remote.Short()).Update(1)
+
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "a", Version: v1, Size: 1, Blocks: []protocol.BlockInfo{{Size: 1, Hash: []byte("hash a")}}},
+	})
+	// Versions already known aren't recorded again
+	s.Update(remote, []protocol.FileInfo{{Name: "a", Version: v1, Size: 1}})
+	s.Update(remote, []protocol.FileInfo{{Name: "a", Version: v2, Size: 2, ModifiedBy: remote.Short()}})
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "a", Version: v2, Size: 2, ModifiedBy: remote.Short()}})
+
+	h := s.History("a")
+	if len(h) != 2 {
+		t.Fatalf("got %d history entries, expected 2", len(h))
+	}
+	if !h[0].Version.Equal(v1) || h[0].Origin != HistoryOriginLocal || h[0].Device != protocol.LocalDeviceID || h[0].Hash == "" {
+		t.Errorf("unexpected first entry %+v", h[0])
+	}
+	if !h[1].Version.Equal(v2) || h[1].Origin != HistoryOriginRemote || h[1].Device != remote || h[1].ModifiedBy != remote.Short() || h[1].Size != 2 {
+		t.Errorf("unexpected second entry %+v", h[1])
+	}
+
+	// Only the newest two versions are kept
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "a", Version: v3, Deleted: true}})
+	h = s.History("a")
+	if len(h) != 2 || !h[0].Version.Equal(v2) || !h[1].Version.Equal(v3) || !h[1].Deleted || h[1].Hash != "" {
+		t.Errorf("unexpected history after pruning %+v", h)
+	}
+
+	// The history survives reloading the folder, and is removed when
+	// disabled
+	s = NewFileSet("test", ldb)
+	s.SetHistory(2, 0)
+	if h := s.History("a"); len(h) != 2 {
+		t.Errorf("got %d history entries after reload, expected 2", len(h))
+	}
+	s.SetHistory(0, 0)
+	s.SetHistory(2, 0)
+	if h := s.History("a"); len(h) != 0 {
+		t.Errorf("history left after disabling it: %+v", h)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	now := time.Now()
+	var entries []FileHistoryEntry
+	for i := 5; i > 0; i-- {
+		entries = append(entries, FileHistoryEntry{Size: int64(i), RecordedS: now.Add(-time.Duration(i) * time.Hour).Unix()})
+	}
+
+	if res := pruneHistory(entries, 10, 0, now); len(res) != 5 {
+		t.Errorf("pruned to %d entries, expected 5", len(res))
+	}
+	if res := pruneHistory(entries, 2, 0, now); len(res) != 2 || res[0].Size != 2 {
+		t.Errorf("unexpected entries pruned by count: %+v", res)
+	}
+	if res := pruneHistory(entries, 10, 150*time.Minute, now); len(res) != 2 || res[0].Size != 2 {
+		t.Errorf("unexpected entries pruned by age: %+v", res)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The actions taken on behalf of an introducer.
+const (
+	IntroductionAddDevice     = "addDevice"
+	IntroductionPendingDevice = "pendingDevice"
+	IntroductionShareFolder   = "shareFolder"
+	IntroductionUnshareFolder = "unshareFolder"
+	IntroductionRemoveDevice  = "removeDevice"
+)
+
+// maxIntroductions is the number of introductions kept in the audit trail.
+const maxIntroductions = 1000
+
+// introductionSeq tells apart introductions made at the same time, which
+// happens with coarse clocks and when a cluster config causes several.
+var introductionSeq uint64
+
+// An Introduction records a change made on behalf of an introducer.
+type Introduction struct {
+	Time       time.Time         `json:"time"`
+	Introducer protocol.DeviceID `json:"introducer"`
+	Action     string            `json:"action"`
+	Device     protocol.DeviceID `json:"device"`
+	Folder     string            `json:"folder,omitempty"`
+}
+
+// AddIntroduction appends the introduction to the audit trail, dropping the
+// oldest entries beyond the last maxIntroductions.
+func (db *Instance) AddIntroduction(in Introduction) {
+	if in.Time.IsZero() {
+		in.Time = time.Now()
+	}
+	bs, _ := json.Marshal(&in)
+	if err := db.Put(db.introductionKey(in.Time, atomic.AddUint64(&introductionSeq, 1)), bs); err != nil {
+		l.Debugln("storing introduction:", err)
+		return
+	}
+
+	it := db.NewPrefixIterator([]byte{KeyTypeIntroduction})
+	defer it.Release()
+	var keys [][]byte
+	for it.Next() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+	}
+	for len(keys) > maxIntroductions {
+		db.Delete(keys[0])
+		keys = keys[1:]
+	}
+}
+
+// Introductions returns the audit trail of introductions, oldest first.
+func (db *Instance) Introductions() []Introduction {
+	it := db.NewPrefixIterator([]byte{KeyTypeIntroduction})
+	defer it.Release()
+
+	var res []Introduction
+	for it.Next() {
+		var in Introduction
+		if err := json.Unmarshal(it.Value(), &in); err != nil {
+			l.Debugln("decoding introduction:", err)
+			continue
+		}
+		res = append(res, in)
+	}
+	return res
+}
+
+// introductionKey returns the key of an introduction, sorting by time and
+// then sequence number.
+func (db *Instance) introductionKey(t time.Time, seq uint64) []byte {
+	k := make([]byte, keyPrefixLen+8+8)
+	k[0] = KeyTypeIntroduction
+	binary.BigEndian.PutUint64(k[keyPrefixLen:], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(k[keyPrefixLen+8:], seq)
+	return k
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestIntroductions(t *testing.T) {
+	ldb := OpenMemory()
+
+	introducer := protocol.DeviceID{1}
+	start := time.Now()
+	for i := 0; i < maxIntroductions+10; i++ {
+		ldb.AddIntroduction(Introduction{
+			Time:       start.Add(time.Duration(i) * time.Second),
+			Introducer: introducer,
+			Action:     IntroductionShareFolder,
+			Device:     protocol.DeviceID{byte(i)},
+			Folder:     "default",
+		})
+	}
+
+	intros := ldb.Introductions()
+	if len(intros) != maxIntroductions {
+		t.Fatalf("Expected %d introductions, not %d", maxIntroductions, len(intros))
+	}
+	// The oldest ones are dropped
+	if intros[0].Device != (protocol.DeviceID{10}) || !intros[0].Time.Equal(start.Add(10*time.Second)) {
+		t.Errorf("Unexpected oldest introduction: %+v", intros[0])
+	}
+	if intros[0].Introducer != introducer || intros[0].Action != IntroductionShareFolder || intros[0].Folder != "default" {
+		t.Errorf("Introduction not stored correctly: %+v", intros[0])
+	}
+}
+
+func TestIntroductionsAtSameTime(t *testing.T) {
+	ldb := OpenMemory()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		ldb.AddIntroduction(Introduction{
+			Time:   now,
+			Action: IntroductionShareFolder,
+			Device: protocol.DeviceID{byte(i)},
+		})
+	}
+
+	intros := ldb.Introductions()
+	if len(intros) != 3 {
+		t.Fatalf("Expected 3 introductions, not %d", len(intros))
+	}
+	for i, in := range intros {
+		if in.Device != (protocol.DeviceID{byte(i)}) {
+			t.Errorf("Introduction %d out of order: %+v", i, in)
+		}
+	}
+}
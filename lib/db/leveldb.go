@@ -10,9 +10,8 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/protocol"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 const (
@@ -25,6 +24,13 @@ const (
 	KeyTypeFolderIdx
 	KeyTypeDeviceIdx
 	KeyTypeIndexID
+	KeyTypePendingDevice
+	KeyTypePendingFolder
+	KeyTypeIntroduction
+	KeyTypeReceiveOnlyDevice
+	KeyTypeFileHistory
+	KeyTypeDirState
+	KeyTypeIdentityMigration
 )
 
 func (l VersionList) String() string {
@@ -57,15 +63,15 @@ func (l fileList) Less(a, b int) bool {
 }
 
 type dbReader interface {
-	Get([]byte, *opt.ReadOptions) ([]byte, error)
+	Get([]byte) ([]byte, error)
 }
 
 // Flush batches to disk when they contain this many records.
 const batchFlushSize = 64
 
 func getFile(db dbReader, key []byte) (protocol.FileInfo, bool) {
-	bs, err := db.Get(key, nil)
-	if err == leveldb.ErrNotFound {
+	bs, err := db.Get(key)
+	if err == backend.ErrNotFound {
 		return protocol.FileInfo{}, false
 	}
 	if err != nil {
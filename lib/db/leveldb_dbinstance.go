@@ -11,24 +11,18 @@ import (
 	"encoding/binary"
 	"os"
 	"sort"
-	"strings"
 	"sync/atomic"
 
+	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/errors"
-	"github.com/syndtr/goleveldb/leveldb/iterator"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/storage"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-type deletionHandler func(t readWriteTransaction, folder, device, name []byte, dbi iterator.Iterator)
+type deletionHandler func(t readWriteTransaction, folder, device, name []byte, dbi backend.Iterator)
 
 type Instance struct {
 	committed int64 // this must be the first attribute in the struct to ensure 64 bit alignment on 32 bit plaforms
-	*leveldb.DB
+	backend.Backend
 	location  string
 	folderIdx *smallIndex
 	deviceIdx *smallIndex
@@ -41,41 +35,49 @@ const (
 	keyHashLen   = 32
 )
 
+// Open opens the database at the location. An existing database is opened
+// with the backend it was created with; a new one is created with the
+// backend set in STDBBACKEND, or LevelDB by default.
 func Open(file string) (*Instance, error) {
-	opts := &opt.Options{
-		OpenFilesCacheCapacity: 100,
-		WriteBuffer:            4 << 20,
+	name, err := backend.Detect(file)
+	if err != nil {
+		return nil, err
 	}
-
-	db, err := leveldb.OpenFile(file, opts)
-	if leveldbIsCorrupted(err) {
-		db, err = leveldb.RecoverFile(file, opts)
+	if name == "" {
+		name = os.Getenv("STDBBACKEND")
 	}
-	if leveldbIsCorrupted(err) {
-		// The database is corrupted, and we've tried to recover it but it
-		// didn't work. At this point there isn't much to do beyond dropping
-		// the database and reindexing...
-		l.Infoln("Database corruption detected, unable to recover. Reinitializing...")
-		if err := os.RemoveAll(file); err != nil {
-			return nil, err
-		}
-		db, err = leveldb.OpenFile(file, opts)
+	if name == "" {
+		name = backend.LevelDB
 	}
+	l.Debugf("opening %s database at %s", name, file)
+
+	bk, err := backend.Open(file, name)
 	if err != nil {
 		return nil, err
 	}
 
-	return newDBInstance(db, file), nil
+	return newDBInstance(bk, file), nil
+}
+
+// OpenExisting opens the database at the location for inspection or
+// repair. It fails if there is no database there, and never drops a
+// corrupted one.
+func OpenExisting(file string, readOnly bool) (*Instance, error) {
+	bk, err := backend.OpenExisting(file, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDBInstance(bk, file), nil
 }
 
 func OpenMemory() *Instance {
-	db, _ := leveldb.Open(storage.NewMemStorage(), nil)
-	return newDBInstance(db, "<memory>")
+	return newDBInstance(backend.OpenMemory(), "<memory>")
 }
 
-func newDBInstance(db *leveldb.DB, location string) *Instance {
+func newDBInstance(bk backend.Backend, location string) *Instance {
 	i := &Instance{
-		DB:       db,
+		Backend:  bk,
 		location: location,
 	}
 	i.folderIdx = newSmallIndex(i, []byte{KeyTypeFolderIdx})
@@ -93,13 +95,13 @@ func (db *Instance) Location() string {
 	return db.location
 }
 
-func (db *Instance) genericReplace(folder, device []byte, fs []protocol.FileInfo, localSize, globalSize *sizeTracker, deleteFn deletionHandler) {
+func (db *Instance) genericReplace(folder, device []byte, fs []protocol.FileInfo, inGlobal bool, localSize, globalSize *sizeTracker, deleteFn deletionHandler) {
 	sort.Sort(fileList(fs)) // sort list on name, same as in the database
 
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.deviceKey(folder, device, nil)[:keyPrefixLen+keyFolderLen+keyDeviceLen]), nil)
+	dbi := t.NewPrefixIterator(db.deviceKey(folder, device, nil)[:keyPrefixLen+keyFolderLen+keyDeviceLen])
 	defer dbi.Release()
 
 	moreDb := dbi.Next()
@@ -134,7 +136,7 @@ func (db *Instance) genericReplace(folder, device []byte, fs []protocol.FileInfo
 			if isLocalDevice {
 				localSize.addFile(fs[fsi])
 			}
-			if fs[fsi].IsInvalid() {
+			if fs[fsi].IsInvalid() || !inGlobal {
 				t.removeFromGlobal(folder, device, newName, globalSize)
 			} else {
 				t.updateGlobal(folder, device, fs[fsi], globalSize)
@@ -155,7 +157,7 @@ func (db *Instance) genericReplace(folder, device []byte, fs []protocol.FileInfo
 					localSize.removeFile(ef)
 					localSize.addFile(fs[fsi])
 				}
-				if fs[fsi].IsInvalid() {
+				if fs[fsi].IsInvalid() || !inGlobal {
 					t.removeFromGlobal(folder, device, newName, globalSize)
 				} else {
 					t.updateGlobal(folder, device, fs[fsi], globalSize)
@@ -179,8 +181,8 @@ func (db *Instance) genericReplace(folder, device []byte, fs []protocol.FileInfo
 	}
 }
 
-func (db *Instance) replace(folder, device []byte, fs []protocol.FileInfo, localSize, globalSize *sizeTracker) {
-	db.genericReplace(folder, device, fs, localSize, globalSize, func(t readWriteTransaction, folder, device, name []byte, dbi iterator.Iterator) {
+func (db *Instance) replace(folder, device []byte, fs []protocol.FileInfo, inGlobal bool, localSize, globalSize *sizeTracker) {
+	db.genericReplace(folder, device, fs, inGlobal, localSize, globalSize, func(t readWriteTransaction, folder, device, name []byte, dbi backend.Iterator) {
 		// Database has a file that we are missing. Remove it.
 		l.Debugf("delete; folder=%q device=%v name=%q", folder, protocol.DeviceIDFromBytes(device), name)
 		t.removeFromGlobal(folder, device, name, globalSize)
@@ -188,7 +190,7 @@ func (db *Instance) replace(folder, device []byte, fs []protocol.FileInfo, local
 	})
 }
 
-func (db *Instance) updateFiles(folder, device []byte, fs []protocol.FileInfo, localSize, globalSize *sizeTracker) {
+func (db *Instance) updateFiles(folder, device []byte, fs []protocol.FileInfo, inGlobal bool, localSize, globalSize *sizeTracker) {
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
@@ -200,7 +202,7 @@ func (db *Instance) updateFiles(folder, device []byte, fs []protocol.FileInfo, l
 
 		// Get and unmarshal the file entry. If it doesn't exist or can't be
 		// unmarshalled we'll add it as a new entry.
-		bs, err := t.Get(fk, nil)
+		bs, err := t.Get(fk)
 		var ef FileInfoTruncated
 		if err == nil {
 			err = ef.Unmarshal(bs)
@@ -212,7 +214,7 @@ func (db *Instance) updateFiles(folder, device []byte, fs []protocol.FileInfo, l
 			}
 
 			t.insertFile(folder, device, f)
-			if f.IsInvalid() {
+			if f.IsInvalid() || !inGlobal {
 				t.removeFromGlobal(folder, device, name, globalSize)
 			} else {
 				t.updateGlobal(folder, device, f, globalSize)
@@ -228,7 +230,7 @@ func (db *Instance) updateFiles(folder, device []byte, fs []protocol.FileInfo, l
 			}
 
 			t.insertFile(folder, device, f)
-			if f.IsInvalid() {
+			if f.IsInvalid() || !inGlobal {
 				t.removeFromGlobal(folder, device, name, globalSize)
 			} else {
 				t.updateGlobal(folder, device, f, globalSize)
@@ -245,7 +247,7 @@ func (db *Instance) withHave(folder, device, prefix []byte, truncate bool, fn It
 	t := db.newReadOnlyTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.deviceKey(folder, device, prefix)[:keyPrefixLen+keyFolderLen+keyDeviceLen+len(prefix)]), nil)
+	dbi := t.NewPrefixIterator(db.deviceKey(folder, device, prefix)[:keyPrefixLen+keyFolderLen+keyDeviceLen+len(prefix)])
 	defer dbi.Release()
 
 	slashedPrefix := prefix
@@ -278,7 +280,7 @@ func (db *Instance) withAllFolderTruncated(folder []byte, fn func(device []byte,
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.deviceKey(folder, nil, nil)[:keyPrefixLen+keyFolderLen]), nil)
+	dbi := t.NewPrefixIterator(db.deviceKey(folder, nil, nil)[:keyPrefixLen+keyFolderLen])
 	defer dbi.Release()
 
 	for dbi.Next() {
@@ -319,14 +321,14 @@ func (db *Instance) getGlobal(folder, file []byte, truncate bool) (FileIntf, boo
 	t := db.newReadOnlyTransaction()
 	defer t.close()
 
-	bs, err := t.Get(k, nil)
+	bs, err := t.Get(k)
 	if err != nil {
 		return nil, false
 	}
 
 	var vl VersionList
 	err = vl.Unmarshal(bs)
-	if err == leveldb.ErrNotFound {
+	if err == backend.ErrNotFound {
 		return nil, false
 	}
 	if err != nil {
@@ -339,7 +341,7 @@ func (db *Instance) getGlobal(folder, file []byte, truncate bool) (FileIntf, boo
 	}
 
 	k = db.deviceKey(folder, vl.Versions[0].Device, file)
-	bs, err = t.Get(k, nil)
+	bs, err = t.Get(k)
 	if err != nil {
 		l.Debugln("surprise error:", k, err)
 		return nil, false
@@ -357,7 +359,7 @@ func (db *Instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator
 	t := db.newReadOnlyTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.globalKey(folder, prefix)), nil)
+	dbi := t.NewPrefixIterator(db.globalKey(folder, prefix))
 	defer dbi.Release()
 
 	slashedPrefix := prefix
@@ -384,7 +386,7 @@ func (db *Instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator
 		}
 
 		fk = db.deviceKeyInto(fk[:cap(fk)], folder, vl.Versions[0].Device, name)
-		bs, err := t.Get(fk, nil)
+		bs, err := t.Get(fk)
 		if err != nil {
 			l.Debugln("surprise error:", err)
 			continue
@@ -404,8 +406,8 @@ func (db *Instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator
 
 func (db *Instance) availability(folder, file []byte) []protocol.DeviceID {
 	k := db.globalKey(folder, file)
-	bs, err := db.Get(k, nil)
-	if err == leveldb.ErrNotFound {
+	bs, err := db.Get(k)
+	if err == backend.ErrNotFound {
 		return nil
 	}
 	if err != nil {
@@ -436,7 +438,7 @@ func (db *Instance) withNeed(folder, device []byte, truncate bool, fn Iterator)
 	t := db.newReadOnlyTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.globalKey(folder, nil)[:keyPrefixLen+keyFolderLen]), nil)
+	dbi := t.NewPrefixIterator(db.globalKey(folder, nil)[:keyPrefixLen+keyFolderLen])
 	defer dbi.Release()
 
 	var fk []byte
@@ -472,6 +474,18 @@ nextFile:
 			name := db.globalKeyName(dbi.Key())
 			needVersion := vl.Versions[0].Version
 
+			if !have {
+				// Files announced by receive only devices are not in the
+				// version list. They don't need what they already have.
+				fk = db.deviceKeyInto(fk[:cap(fk)], folder, device, name)
+				if bs, err := t.Get(fk); err == nil {
+					var hf FileInfoTruncated
+					if err := hf.Unmarshal(bs); err == nil && !hf.IsInvalid() && hf.Version.GreaterEqual(needVersion) {
+						continue nextFile
+					}
+				}
+			}
+
 		nextVersion:
 			for i := range vl.Versions {
 				if !vl.Versions[i].Version.Equal(needVersion) {
@@ -479,7 +493,7 @@ nextFile:
 					continue nextFile
 				}
 				fk = db.deviceKeyInto(fk[:cap(fk)], folder, vl.Versions[i].Device, name)
-				bs, err := t.Get(fk, nil)
+				bs, err := t.Get(fk)
 				if err != nil {
 					l.Debugln("surprise error:", err)
 					continue nextVersion
@@ -518,7 +532,7 @@ func (db *Instance) ListFolders() []string {
 	t := db.newReadOnlyTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix([]byte{KeyTypeGlobal}), nil)
+	dbi := t.NewPrefixIterator([]byte{KeyTypeGlobal})
 	defer dbi.Release()
 
 	folderExists := make(map[string]bool)
@@ -543,21 +557,21 @@ func (db *Instance) dropFolder(folder []byte) {
 	defer t.close()
 
 	// Remove all items related to the given folder from the device->file bucket
-	dbi := t.NewIterator(util.BytesPrefix([]byte{KeyTypeDevice}), nil)
+	dbi := t.NewPrefixIterator([]byte{KeyTypeDevice})
 	for dbi.Next() {
 		itemFolder := db.deviceKeyFolder(dbi.Key())
 		if bytes.Equal(folder, itemFolder) {
-			db.Delete(dbi.Key(), nil)
+			db.Delete(dbi.Key())
 		}
 	}
 	dbi.Release()
 
 	// Remove all items related to the given folder from the global bucket
-	dbi = t.NewIterator(util.BytesPrefix([]byte{KeyTypeGlobal}), nil)
+	dbi = t.NewPrefixIterator([]byte{KeyTypeGlobal})
 	for dbi.Next() {
 		itemFolder, ok := db.globalKeyFolder(dbi.Key())
 		if ok && bytes.Equal(folder, itemFolder) {
-			db.Delete(dbi.Key(), nil)
+			db.Delete(dbi.Key())
 		}
 	}
 	dbi.Release()
@@ -567,10 +581,11 @@ func (db *Instance) checkGlobals(folder []byte, globalSize *sizeTracker) {
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(db.globalKey(folder, nil)[:keyPrefixLen+keyFolderLen]), nil)
+	dbi := t.NewPrefixIterator(db.globalKey(folder, nil)[:keyPrefixLen+keyFolderLen])
 	defer dbi.Release()
 
 	var fk []byte
+	dropped := 0
 	for dbi.Next() {
 		gk := dbi.Key()
 		var vl VersionList
@@ -590,8 +605,8 @@ func (db *Instance) checkGlobals(folder []byte, globalSize *sizeTracker) {
 		for i, version := range vl.Versions {
 			fk = db.deviceKeyInto(fk[:cap(fk)], folder, version.Device, name)
 
-			_, err := t.Get(fk, nil)
-			if err == leveldb.ErrNotFound {
+			_, err := t.Get(fk)
+			if err == backend.ErrNotFound {
 				continue
 			}
 			if err != nil {
@@ -608,10 +623,14 @@ func (db *Instance) checkGlobals(folder []byte, globalSize *sizeTracker) {
 		}
 
 		if len(newVL.Versions) != len(vl.Versions) {
+			dropped += len(vl.Versions) - len(newVL.Versions)
 			t.Put(dbi.Key(), mustMarshal(&newVL))
 			t.checkFlush()
 		}
 	}
+	if dropped > 0 {
+		l.Infof("Removed %d global versions of no longer existing files in folder %q", dropped, folder)
+	}
 	l.Debugf("db check completed for %q", folder)
 }
 
@@ -625,7 +644,7 @@ func (db *Instance) ConvertSymlinkTypes() {
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix([]byte{KeyTypeDevice}), nil)
+	dbi := t.NewPrefixIterator([]byte{KeyTypeDevice})
 	defer dbi.Release()
 
 	conv := 0
@@ -718,7 +737,7 @@ func (db *Instance) globalKeyFolder(key []byte) ([]byte, bool) {
 
 func (db *Instance) getIndexID(device, folder []byte) protocol.IndexID {
 	key := db.indexIDKey(device, folder)
-	cur, err := db.Get(key, nil)
+	cur, err := db.Get(key)
 	if err != nil {
 		return 0
 	}
@@ -734,7 +753,7 @@ func (db *Instance) getIndexID(device, folder []byte) protocol.IndexID {
 func (db *Instance) setIndexID(device, folder []byte, id protocol.IndexID) {
 	key := db.indexIDKey(device, folder)
 	bs, _ := id.Marshal() // marshalling can't fail
-	if err := db.Put(key, bs, nil); err != nil {
+	if err := db.Put(key, bs); err != nil {
 		panic("storing index ID: " + err.Error())
 	}
 }
@@ -760,15 +779,42 @@ func (db *Instance) DropDeltaIndexIDs() {
 	db.dropPrefix([]byte{KeyTypeIndexID})
 }
 
+// dropIndexIDs removes the index IDs of all devices for the folder.
+func (db *Instance) dropIndexIDs(folder []byte) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	id := db.folderIdx.ID(folder)
+	dbi := t.NewPrefixIterator([]byte{KeyTypeIndexID})
+	defer dbi.Release()
+
+	for dbi.Next() {
+		if binary.BigEndian.Uint32(dbi.Key()[keyPrefixLen+keyDeviceLen:]) == id {
+			t.Delete(dbi.Key())
+		}
+	}
+}
+
 func (db *Instance) dropMtimes(folder []byte) {
 	db.dropPrefix(db.mtimesKey(folder))
 }
 
+func (db *Instance) dirStatesKey(folder []byte) []byte {
+	prefix := make([]byte, 5) // key type + 4 bytes folder idx number
+	prefix[0] = KeyTypeDirState
+	binary.BigEndian.PutUint32(prefix[1:], db.folderIdx.ID(folder))
+	return prefix
+}
+
+func (db *Instance) dropDirStates(folder []byte) {
+	db.dropPrefix(db.dirStatesKey(folder))
+}
+
 func (db *Instance) dropPrefix(prefix []byte) {
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	dbi := t.NewIterator(util.BytesPrefix(prefix), nil)
+	dbi := t.NewPrefixIterator(prefix)
 	defer dbi.Release()
 
 	for dbi.Next() {
@@ -788,22 +834,6 @@ func unmarshalTrunc(bs []byte, truncate bool) (FileIntf, error) {
 	return tf, err
 }
 
-// A "better" version of leveldb's errors.IsCorrupted.
-func leveldbIsCorrupted(err error) bool {
-	switch {
-	case err == nil:
-		return false
-
-	case errors.IsCorrupted(err):
-		return true
-
-	case strings.Contains(err.Error(), "corrupted"):
-		return true
-	}
-
-	return false
-}
-
 // A smallIndex is an in memory bidirectional []byte to uint32 map. It gives
 // fast lookups in both directions and persists to the database. Don't use for
 // storing more items than fit comfortably in RAM.
@@ -832,7 +862,7 @@ func newSmallIndex(db *Instance, prefix []byte) *smallIndex {
 // memory maps.
 func (i *smallIndex) load() {
 	tr := i.db.newReadOnlyTransaction()
-	it := tr.NewIterator(util.BytesPrefix(i.prefix), nil)
+	it := tr.NewPrefixIterator(i.prefix)
 	for it.Next() {
 		val := string(it.Value())
 		id := binary.BigEndian.Uint32(it.Key()[len(i.prefix):])
@@ -870,12 +900,48 @@ func (i *smallIndex) ID(val []byte) uint32 {
 	key := make([]byte, len(i.prefix)+8) // prefix plus uint32 id
 	copy(key, i.prefix)
 	binary.BigEndian.PutUint32(key[len(i.prefix):], id)
-	i.db.Put(key, val, nil)
+	i.db.Put(key, val)
 
 	i.mut.Unlock()
 	return id
 }
 
+// lookup returns the index number for the given byte slice, without
+// allocating one if it doesn't exist.
+func (i *smallIndex) lookup(val []byte) (uint32, bool) {
+	i.mut.Lock()
+	id, ok := i.val2id[string(val)]
+	i.mut.Unlock()
+	return id, ok
+}
+
+// rename gives the index number of one value to another value, storing the
+// change in the transaction. It returns false if the other value already
+// has an index number of its own.
+func (i *smallIndex) rename(t readWriteTransaction, from, to []byte) bool {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	if _, ok := i.val2id[string(to)]; ok {
+		return false
+	}
+	id, ok := i.val2id[string(from)]
+	if !ok {
+		// Nothing is stored for the old value
+		return true
+	}
+
+	delete(i.val2id, string(from))
+	i.val2id[string(to)] = id
+	i.id2val[id] = string(to)
+
+	key := make([]byte, len(i.prefix)+8) // prefix plus uint32 id
+	copy(key, i.prefix)
+	binary.BigEndian.PutUint32(key[len(i.prefix):], id)
+	t.Put(key, to)
+	return true
+}
+
 // Val returns the value for the given index number, or (nil, false) if there
 // is no such index number.
 func (i *smallIndex) Val(id uint32) ([]byte, bool) {
@@ -10,18 +10,34 @@ import (
 	"bytes"
 	"sync/atomic"
 
+	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/protocol"
-	"github.com/syndtr/goleveldb/leveldb"
 )
 
-// A readOnlyTransaction represents a database snapshot.
+// A readOnlyTransaction represents a view of the database. It's a snapshot
+// on LevelDB; on bolt it sees the writes made while it's in use, unless
+// made with newSnapshotTransaction.
 type readOnlyTransaction struct {
-	*leveldb.Snapshot
+	backend.Snapshot
 	db *Instance
 }
 
 func (db *Instance) newReadOnlyTransaction() readOnlyTransaction {
-	snap, err := db.GetSnapshot()
+	view, err := db.NewView()
+	if err != nil {
+		panic(err)
+	}
+	return readOnlyTransaction{
+		Snapshot: view,
+		db:       db,
+	}
+}
+
+// newSnapshotTransaction returns a readOnlyTransaction that is a snapshot
+// on all backends. Nothing may wait for a write to the database while
+// holding one.
+func (db *Instance) newSnapshotTransaction() readOnlyTransaction {
+	snap, err := db.NewSnapshot()
 	if err != nil {
 		panic(err)
 	}
@@ -44,14 +60,14 @@ func (t readOnlyTransaction) getFile(folder, device, file []byte) (protocol.File
 // batch size.
 type readWriteTransaction struct {
 	readOnlyTransaction
-	*leveldb.Batch
+	backend.Batch
 }
 
 func (db *Instance) newReadWriteTransaction() readWriteTransaction {
 	t := db.newReadOnlyTransaction()
 	return readWriteTransaction{
 		readOnlyTransaction: t,
-		Batch:               new(leveldb.Batch),
+		Batch:               db.NewBatch(),
 	}
 }
 
@@ -68,7 +84,7 @@ func (t readWriteTransaction) checkFlush() {
 }
 
 func (t readWriteTransaction) flush() {
-	if err := t.db.Write(t.Batch, nil); err != nil {
+	if err := t.Batch.Write(); err != nil {
 		panic(err)
 	}
 	atomic.AddInt64(&t.db.committed, int64(t.Batch.Len()))
@@ -89,7 +105,7 @@ func (t readWriteTransaction) updateGlobal(folder, device []byte, file protocol.
 	l.Debugf("update global; folder=%q device=%v file=%q version=%d", folder, protocol.DeviceIDFromBytes(device), file.Name, file.Version)
 	name := []byte(file.Name)
 	gk := t.db.globalKey(folder, name)
-	svl, _ := t.Get(gk, nil) // skip error, we check len(svl) != 0 later
+	svl, _ := t.Get(gk) // skip error, we check len(svl) != 0 later
 
 	var fl VersionList
 	var oldFile protocol.FileInfo
@@ -188,7 +204,7 @@ func (t readWriteTransaction) removeFromGlobal(folder, device, file []byte, glob
 	l.Debugf("remove from global; folder=%q device=%v file=%q", folder, protocol.DeviceIDFromBytes(device), file)
 
 	gk := t.db.globalKey(folder, file)
-	svl, err := t.Get(gk, nil)
+	svl, err := t.Get(gk)
 	if err != nil {
 		// We might be called to "remove" a global version that doesn't exist
 		// if the first update for the file is already marked invalid.
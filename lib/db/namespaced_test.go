@@ -110,3 +110,23 @@ func TestNamespacedReset(t *testing.T) {
 		t.Errorf("Incorrect return v %q != \"\" || ok %v != false", v, ok)
 	}
 }
+
+func TestNamespacedPutAllBytes(t *testing.T) {
+	ldb := OpenMemory()
+
+	n1 := NewNamespacedKV(ldb, "foo")
+	n2 := NewNamespacedKV(ldb, "bar")
+
+	n1.PutBytes("test1", []byte("old"))
+	n1.PutAllBytes(map[string][]byte{"test1": []byte("yo1"), "test2": []byte("yo2")})
+
+	if v, ok := n1.Bytes("test1"); string(v) != "yo1" || !ok {
+		t.Errorf("Incorrect return v %q != \"yo1\" || ok %v != true", v, ok)
+	}
+	if v, ok := n1.Bytes("test2"); string(v) != "yo2" || !ok {
+		t.Errorf("Incorrect return v %q != \"yo2\" || ok %v != true", v, ok)
+	}
+	if v, ok := n2.Bytes("test2"); v != nil || ok {
+		t.Errorf("Incorrect return v %q != nil || ok %v != false", v, ok)
+	}
+}
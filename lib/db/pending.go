@@ -0,0 +1,252 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Anyone can make us remember a pending device by connecting with a new
+// certificate, so the number of them is limited, and they're forgotten
+// when they haven't been seen for a while.
+const (
+	maxPendingDevices   = 100
+	pendingDeviceMaxAge = 30 * 24 * time.Hour
+)
+
+// A PendingDevice is an unknown device that has tried to connect to us, or
+// that an introducer wants to introduce but which awaits our approval. A
+// dismissed device is remembered as such, to not show up again when it
+// tries to connect again.
+type PendingDevice struct {
+	Time         time.Time `json:"time"` // last seen
+	Name         string    `json:"name"`
+	Address      string    `json:"address"`
+	IntroducedBy string    `json:"introducedBy,omitempty"`
+	Dismissed    bool      `json:"dismissed,omitempty"`
+}
+
+// A PendingFolder is a folder offered to us by a device that we don't share
+// it with.
+type PendingFolder struct {
+	Time  time.Time `json:"time"`
+	Label string    `json:"label"`
+}
+
+// AddOrUpdatePendingDevice remembers, or updates the information about, an
+// unknown device that tried to connect.
+func (db *Instance) AddOrUpdatePendingDevice(device protocol.DeviceID, name, address string) {
+	pd := PendingDevice{
+		Time:    time.Now().Round(time.Second),
+		Name:    name,
+		Address: address,
+	}
+	// Keep track of who introduced the device, if anyone
+	if old, ok := db.pendingDevice(device); ok {
+		pd.IntroducedBy = old.IntroducedBy
+		pd.Dismissed = old.Dismissed
+	} else {
+		db.prunePendingDevices(pd.Time)
+	}
+	db.putPendingDevice(device, pd)
+}
+
+// AddPendingIntroducedDevice remembers a device that an introducer wants to
+// introduce, for us to approve.
+func (db *Instance) AddPendingIntroducedDevice(device protocol.DeviceID, name string, introducer protocol.DeviceID) {
+	pd := PendingDevice{
+		Time:         time.Now().Round(time.Second),
+		Name:         name,
+		IntroducedBy: introducer.String(),
+	}
+	if old, ok := db.pendingDevice(device); ok {
+		pd.Address = old.Address
+		pd.Dismissed = old.Dismissed
+	} else {
+		db.prunePendingDevices(pd.Time)
+	}
+	db.putPendingDevice(device, pd)
+}
+
+// DismissPendingDevice hides a pending device, until it's removed or
+// forgotten after not being seen for a while.
+func (db *Instance) DismissPendingDevice(device protocol.DeviceID) {
+	if pd, ok := db.pendingDevice(device); ok {
+		pd.Dismissed = true
+		db.putPendingDevice(device, pd)
+	}
+}
+
+// PendingDeviceDismissed returns whether the pending device was dismissed.
+func (db *Instance) PendingDeviceDismissed(device protocol.DeviceID) bool {
+	pd, ok := db.pendingDevice(device)
+	return ok && pd.Dismissed
+}
+
+func (db *Instance) pendingDevice(device protocol.DeviceID) (PendingDevice, bool) {
+	var pd PendingDevice
+	bs, err := db.Get(db.pendingDeviceKey(device))
+	if err != nil {
+		return pd, false
+	}
+	if err := json.Unmarshal(bs, &pd); err != nil {
+		return pd, false
+	}
+	return pd, true
+}
+
+func (db *Instance) putPendingDevice(device protocol.DeviceID, pd PendingDevice) {
+	bs, _ := json.Marshal(pd)
+	if err := db.Put(db.pendingDeviceKey(device), bs); err != nil {
+		l.Debugln("storing pending device:", err)
+	}
+}
+
+// RemovePendingDevice forgets about a pending device.
+func (db *Instance) RemovePendingDevice(device protocol.DeviceID) {
+	db.Delete(db.pendingDeviceKey(device))
+}
+
+// PendingDevices returns the pending devices that weren't dismissed.
+func (db *Instance) PendingDevices() map[protocol.DeviceID]PendingDevice {
+	res := make(map[protocol.DeviceID]PendingDevice)
+	for device, pd := range db.AllPendingDevices() {
+		if !pd.Dismissed {
+			res[device] = pd
+		}
+	}
+	return res
+}
+
+// AllPendingDevices returns the pending devices, including the dismissed
+// ones.
+func (db *Instance) AllPendingDevices() map[protocol.DeviceID]PendingDevice {
+	it := db.NewPrefixIterator([]byte{KeyTypePendingDevice})
+	defer it.Release()
+
+	res := make(map[protocol.DeviceID]PendingDevice)
+	for it.Next() {
+		device := protocol.DeviceIDFromBytes(it.Key()[keyPrefixLen:])
+		var pd PendingDevice
+		if err := json.Unmarshal(it.Value(), &pd); err != nil {
+			l.Debugln("decoding pending device:", err)
+			continue
+		}
+		res[device] = pd
+	}
+	return res
+}
+
+// prunePendingDevices forgets the pending devices not seen for too long,
+// and makes room for a new one by forgetting the least recently seen ones.
+// Dismissed devices are kept over the others, so that dismissals aren't
+// undone by devices trying to connect.
+func (db *Instance) prunePendingDevices(now time.Time) {
+	var kept pendingDeviceList
+	for device, pd := range db.AllPendingDevices() {
+		if now.Sub(pd.Time) > pendingDeviceMaxAge {
+			l.Debugln("forgetting pending device", device, "last seen", pd.Time)
+			db.RemovePendingDevice(device)
+			continue
+		}
+		kept.devices = append(kept.devices, device)
+		kept.pds = append(kept.pds, pd)
+	}
+	if kept.Len() < maxPendingDevices {
+		return
+	}
+
+	sort.Sort(kept)
+	for _, device := range kept.devices[:kept.Len()-maxPendingDevices+1] {
+		l.Debugln("forgetting pending device", device, "to make room")
+		db.RemovePendingDevice(device)
+	}
+}
+
+// A pendingDeviceList sorts the pending devices in the order they're
+// forgotten in; those not dismissed before the dismissed ones, and the least
+// recently seen first.
+type pendingDeviceList struct {
+	devices []protocol.DeviceID
+	pds     []PendingDevice
+}
+
+func (s pendingDeviceList) Len() int {
+	return len(s.devices)
+}
+
+func (s pendingDeviceList) Swap(a, b int) {
+	s.devices[a], s.devices[b] = s.devices[b], s.devices[a]
+	s.pds[a], s.pds[b] = s.pds[b], s.pds[a]
+}
+
+func (s pendingDeviceList) Less(a, b int) bool {
+	if s.pds[a].Dismissed != s.pds[b].Dismissed {
+		return !s.pds[a].Dismissed
+	}
+	return s.pds[a].Time.Before(s.pds[b].Time)
+}
+
+// AddOrUpdatePendingFolder remembers, or updates the information about, a
+// folder offered by a device.
+func (db *Instance) AddOrUpdatePendingFolder(folder, label string, device protocol.DeviceID) {
+	bs, _ := json.Marshal(PendingFolder{
+		Time:  time.Now().Round(time.Second),
+		Label: label,
+	})
+	if err := db.Put(db.pendingFolderKey(device, folder), bs); err != nil {
+		l.Debugln("storing pending folder:", err)
+	}
+}
+
+// RemovePendingFolder forgets about a folder offered by the given device.
+func (db *Instance) RemovePendingFolder(folder string, device protocol.DeviceID) {
+	db.Delete(db.pendingFolderKey(device, folder))
+}
+
+// PendingFolders returns the pending folders, by folder ID and offering
+// device.
+func (db *Instance) PendingFolders() map[string]map[protocol.DeviceID]PendingFolder {
+	it := db.NewPrefixIterator([]byte{KeyTypePendingFolder})
+	defer it.Release()
+
+	res := make(map[string]map[protocol.DeviceID]PendingFolder)
+	for it.Next() {
+		key := it.Key()
+		device := protocol.DeviceIDFromBytes(key[keyPrefixLen : keyPrefixLen+protocol.DeviceIDLength])
+		folder := string(key[keyPrefixLen+protocol.DeviceIDLength:])
+		var pf PendingFolder
+		if err := json.Unmarshal(it.Value(), &pf); err != nil {
+			l.Debugln("decoding pending folder:", err)
+			continue
+		}
+		if res[folder] == nil {
+			res[folder] = make(map[protocol.DeviceID]PendingFolder)
+		}
+		res[folder][device] = pf
+	}
+	return res
+}
+
+func (db *Instance) pendingDeviceKey(device protocol.DeviceID) []byte {
+	k := make([]byte, keyPrefixLen+protocol.DeviceIDLength)
+	k[0] = KeyTypePendingDevice
+	copy(k[keyPrefixLen:], device[:])
+	return k
+}
+
+func (db *Instance) pendingFolderKey(device protocol.DeviceID, folder string) []byte {
+	k := make([]byte, keyPrefixLen+protocol.DeviceIDLength+len(folder))
+	k[0] = KeyTypePendingFolder
+	copy(k[keyPrefixLen:], device[:])
+	copy(k[keyPrefixLen+protocol.DeviceIDLength:], folder)
+	return k
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestPendingDevices(t *testing.T) {
+	ldb := OpenMemory()
+
+	dev1 := protocol.DeviceID{1}
+	dev2 := protocol.DeviceID{2}
+
+	ldb.AddOrUpdatePendingDevice(dev1, "one", "tcp://192.0.2.1:22000")
+	ldb.AddOrUpdatePendingDevice(dev2, "two", "tcp://192.0.2.2:22000")
+	ldb.AddOrUpdatePendingDevice(dev1, "renamed", "tcp://192.0.2.3:22000")
+
+	pending := ldb.PendingDevices()
+	if len(pending) != 2 {
+		t.Fatalf("Expected two pending devices, not %d", len(pending))
+	}
+	if pd := pending[dev1]; pd.Name != "renamed" || pd.Address != "tcp://192.0.2.3:22000" || pd.Time.IsZero() {
+		t.Errorf("Pending device not updated: %+v", pd)
+	}
+
+	ldb.RemovePendingDevice(dev1)
+	if _, ok := ldb.PendingDevices()[dev1]; ok {
+		t.Error("Pending device should have been removed")
+	}
+}
+
+func TestPendingFolders(t *testing.T) {
+	ldb := OpenMemory()
+
+	dev1 := protocol.DeviceID{1}
+	dev2 := protocol.DeviceID{2}
+
+	ldb.AddOrUpdatePendingFolder("a", "Folder A", dev1)
+	ldb.AddOrUpdatePendingFolder("a", "Folder A", dev2)
+	ldb.AddOrUpdatePendingFolder("b", "Folder B", dev1)
+
+	pending := ldb.PendingFolders()
+	if len(pending) != 2 || len(pending["a"]) != 2 || len(pending["b"]) != 1 {
+		t.Fatalf("Unexpected pending folders %v", pending)
+	}
+	if pf := pending["b"][dev1]; pf.Label != "Folder B" {
+		t.Errorf("Incorrect label %q", pf.Label)
+	}
+
+	ldb.RemovePendingFolder("a", dev2)
+	if pending := ldb.PendingFolders(); len(pending["a"]) != 1 {
+		t.Errorf("Unexpected pending folders %v", pending)
+	}
+
+	// Folder IDs that are prefixes of one another are kept apart
+	ldb.AddOrUpdatePendingFolder("bb", "Folder BB", dev1)
+	ldb.RemovePendingFolder("b", dev1)
+	if pending := ldb.PendingFolders(); len(pending["b"]) != 0 || len(pending["bb"]) != 1 {
+		t.Errorf("Unexpected pending folders %v", pending)
+	}
+}
+
+func TestPendingIntroducedDevice(t *testing.T) {
+	ldb := OpenMemory()
+
+	dev := protocol.DeviceID{1}
+	introducer := protocol.DeviceID{2}
+
+	ldb.AddPendingIntroducedDevice(dev, "introduced", introducer)
+	// Connection attempts keep the introducer
+	ldb.AddOrUpdatePendingDevice(dev, "introduced", "tcp://192.0.2.1:22000")
+
+	pd := ldb.PendingDevices()[dev]
+	if pd.IntroducedBy != introducer.String() || pd.Address != "tcp://192.0.2.1:22000" {
+		t.Errorf("Unexpected pending device: %+v", pd)
+	}
+}
+
+func TestPendingDeviceDismissed(t *testing.T) {
+	ldb := OpenMemory()
+
+	dev := protocol.DeviceID{1}
+	ldb.AddOrUpdatePendingDevice(dev, "one", "tcp://192.0.2.1:22000")
+	ldb.DismissPendingDevice(dev)
+
+	// Trying to connect again doesn't undo the dismissal
+	ldb.AddOrUpdatePendingDevice(dev, "one", "tcp://192.0.2.1:22000")
+	if _, ok := ldb.PendingDevices()[dev]; ok {
+		t.Error("Dismissed device should not be pending")
+	}
+	if !ldb.PendingDeviceDismissed(dev) {
+		t.Error("Device should be remembered as dismissed")
+	}
+
+	ldb.RemovePendingDevice(dev)
+	ldb.AddOrUpdatePendingDevice(dev, "one", "tcp://192.0.2.1:22000")
+	if _, ok := ldb.PendingDevices()[dev]; !ok {
+		t.Error("Device should be pending again once removed")
+	}
+}
+
+func TestPendingDevicesPruned(t *testing.T) {
+	ldb := OpenMemory()
+
+	now := time.Now().Round(time.Second)
+	old := protocol.DeviceID{1}
+	ldb.putPendingDevice(old, PendingDevice{Time: now.Add(-pendingDeviceMaxAge - time.Hour)})
+	dismissed := protocol.DeviceID{2}
+	ldb.putPendingDevice(dismissed, PendingDevice{Time: now.Add(-time.Hour), Dismissed: true})
+	for i := 0; i < maxPendingDevices; i++ {
+		dev := protocol.DeviceID{3, byte(i)}
+		ldb.putPendingDevice(dev, PendingDevice{Time: now.Add(time.Duration(i-maxPendingDevices) * time.Minute)})
+	}
+
+	newDev := protocol.DeviceID{4}
+	ldb.AddOrUpdatePendingDevice(newDev, "new", "tcp://192.0.2.1:22000")
+
+	all := ldb.AllPendingDevices()
+	if len(all) != maxPendingDevices {
+		t.Errorf("Expected %d pending devices, not %d", maxPendingDevices, len(all))
+	}
+	if _, ok := all[old]; ok {
+		t.Error("Device not seen for too long should have been forgotten")
+	}
+	if _, ok := all[dismissed]; !ok {
+		t.Error("Dismissed device should have been kept")
+	}
+	// Room is made for the new device and the dismissed one
+	if _, ok := all[protocol.DeviceID{3, 1}]; ok {
+		t.Error("Least recently seen devices should have been forgotten")
+	}
+	if _, ok := all[protocol.DeviceID{3, 2}]; !ok {
+		t.Error("More recently seen device should have been kept")
+	}
+	if _, ok := all[newDev]; !ok {
+		t.Error("New device should have been added")
+	}
+}
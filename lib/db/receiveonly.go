@@ -0,0 +1,75 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/binary"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The receive only devices of each folder are stored, so that we know which
+// devices' files are left out of the global versions when the folder is
+// loaded again.
+
+func (db *Instance) receiveOnlyDevices(folder []byte) map[protocol.DeviceID]bool {
+	it := db.NewPrefixIterator(db.receiveOnlyKey(folder, nil))
+	defer it.Release()
+
+	res := make(map[protocol.DeviceID]bool)
+	for it.Next() {
+		res[protocol.DeviceIDFromBytes(it.Key()[keyPrefixLen+keyFolderLen:])] = true
+	}
+	return res
+}
+
+// setReceiveOnly marks or unmarks the device as receive only for the folder,
+// and removes its files from, or adds them to, the global versions.
+func (db *Instance) setReceiveOnly(folder []byte, device protocol.DeviceID, receiveOnly bool, globalSize *sizeTracker) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	if receiveOnly {
+		t.Put(db.receiveOnlyKey(folder, device[:]), nil)
+	} else {
+		t.Delete(db.receiveOnlyKey(folder, device[:]))
+	}
+
+	dbi := t.NewPrefixIterator(db.deviceKey(folder, device[:], nil)[:keyPrefixLen+keyFolderLen+keyDeviceLen])
+	defer dbi.Release()
+
+	for dbi.Next() {
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			l.Debugln("unmarshal error:", err)
+			continue
+		}
+		if receiveOnly || f.IsInvalid() {
+			t.removeFromGlobal(folder, device[:], []byte(f.Name), globalSize)
+		} else {
+			t.updateGlobal(folder, device[:], f, globalSize)
+		}
+		t.checkFlush()
+	}
+}
+
+func (db *Instance) dropReceiveOnly(folder []byte) {
+	db.dropPrefix(db.receiveOnlyKey(folder, nil))
+}
+
+// receiveOnlyKey returns a byte slice encoding the following information:
+//
+//	keyTypeReceiveOnlyDevice (1 byte)
+//	folder (4 bytes)
+//	device (32 bytes)
+func (db *Instance) receiveOnlyKey(folder, device []byte) []byte {
+	k := make([]byte, keyPrefixLen+keyFolderLen+len(device))
+	k[0] = KeyTypeReceiveOnlyDevice
+	binary.BigEndian.PutUint32(k[keyPrefixLen:], db.folderIdx.ID(folder))
+	copy(k[keyPrefixLen+keyFolderLen:], device)
+	return k
+}
@@ -15,6 +15,7 @@ package db
 import (
 	stdsync "sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
@@ -31,7 +32,12 @@ type FileSet struct {
 	globalSize sizeTracker
 
 	remoteSequence map[protocol.DeviceID]int64 // Highest seen sequence numbers for other devices
-	updateMutex    sync.Mutex                  // protects remoteSequence and database updates
+	receiveOnly    map[protocol.DeviceID]bool  // Devices whose files don't take part in the global version
+	updateMutex    sync.Mutex                  // protects remoteSequence, receiveOnly and database updates
+
+	historyEntries int           // versions to keep per file, zero for no history
+	historyMaxAge  time.Duration // age of the oldest version to keep, zero for any
+	historyMut     sync.Mutex    // protects historyEntries and historyMaxAge
 }
 
 // FileIntf is the set of methods implemented by both protocol.FileInfo and
@@ -116,10 +122,12 @@ func (s *sizeTracker) Size() Counts {
 func NewFileSet(folder string, db *Instance) *FileSet {
 	var s = FileSet{
 		remoteSequence: make(map[protocol.DeviceID]int64),
+		receiveOnly:    db.receiveOnlyDevices([]byte(folder)),
 		folder:         folder,
 		db:             db,
 		blockmap:       NewBlockMap(db, db.folderIdx.ID([]byte(folder))),
 		updateMutex:    sync.NewMutex(),
+		historyMut:     sync.NewMutex(),
 	}
 
 	s.db.checkGlobals([]byte(folder), &s.globalSize)
@@ -163,11 +171,12 @@ func (s *FileSet) Replace(device protocol.DeviceID, fs []protocol.FileInfo) {
 	} else {
 		s.remoteSequence[device] = maxSequence(fs)
 	}
-	s.db.replace([]byte(s.folder), device[:], fs, &s.localSize, &s.globalSize)
+	s.db.replace([]byte(s.folder), device[:], fs, !s.receiveOnly[device], &s.localSize, &s.globalSize)
 	if device == protocol.LocalDeviceID {
 		s.blockmap.Drop()
 		s.blockmap.Add(fs)
 	}
+	s.recordHistory(device, fs)
 }
 
 func (s *FileSet) Update(device protocol.DeviceID, fs []protocol.FileInfo) {
@@ -177,6 +186,9 @@ func (s *FileSet) Update(device protocol.DeviceID, fs []protocol.FileInfo) {
 	s.updateMutex.Lock()
 	defer s.updateMutex.Unlock()
 
+	// The history only needs the files that changed, when we know which
+	// those are.
+	history := fs
 	if device == protocol.LocalDeviceID {
 		discards := make([]protocol.FileInfo, 0, len(fs))
 		updates := make([]protocol.FileInfo, 0, len(fs))
@@ -190,10 +202,111 @@ func (s *FileSet) Update(device protocol.DeviceID, fs []protocol.FileInfo) {
 		}
 		s.blockmap.Discard(discards)
 		s.blockmap.Update(updates)
+		history = updates
 	} else {
 		s.remoteSequence[device] = maxSequence(fs)
 	}
-	s.db.updateFiles([]byte(s.folder), device[:], fs, &s.localSize, &s.globalSize)
+	s.db.updateFiles([]byte(s.folder), device[:], fs, !s.receiveOnly[device], &s.localSize, &s.globalSize)
+	s.recordHistory(device, history)
+}
+
+// restore adds files like Update, but keeps their sequence numbers. It's
+// used to import files into an empty set.
+func (s *FileSet) restore(device protocol.DeviceID, fs []protocol.FileInfo) {
+	l.Debugf("%s restore(%v, [%d])", s.folder, device, len(fs))
+	normalizeFilenames(fs)
+
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	if device == protocol.LocalDeviceID {
+		if seq := maxSequence(fs); seq > s.sequence {
+			s.sequence = seq
+		}
+		s.blockmap.Add(fs)
+	} else if seq := maxSequence(fs); seq > s.remoteSequence[device] {
+		s.remoteSequence[device] = seq
+	}
+	s.db.updateFiles([]byte(s.folder), device[:], fs, !s.receiveOnly[device], &s.localSize, &s.globalSize)
+}
+
+// SetReceiveOnlyDevices sets the devices that only receive changes from us.
+// The files they announce are kept, but are never selected as the global
+// version. Devices that changed role have their files added to or removed
+// from the global versions accordingly.
+func (s *FileSet) SetReceiveOnlyDevices(devices []protocol.DeviceID) {
+	l.Debugf("%s SetReceiveOnlyDevices(%v)", s.folder, devices)
+
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	receiveOnly := make(map[protocol.DeviceID]bool, len(devices))
+	for _, device := range devices {
+		if device == protocol.LocalDeviceID {
+			continue
+		}
+		receiveOnly[device] = true
+		if !s.receiveOnly[device] {
+			s.db.setReceiveOnly([]byte(s.folder), device, true, &s.globalSize)
+		}
+	}
+	for device := range s.receiveOnly {
+		if !receiveOnly[device] {
+			s.db.setReceiveOnly([]byte(s.folder), device, false, &s.globalSize)
+		}
+	}
+	s.receiveOnly = receiveOnly
+}
+
+// SetHistory enables keeping the history of the files, with at most
+// maxEntries versions of each file that are at most maxAge old. A maxAge of
+// zero keeps versions of any age. Zero maxEntries disables the history and
+// removes the existing one.
+func (s *FileSet) SetHistory(maxEntries int, maxAge time.Duration) {
+	l.Debugf("%s SetHistory(%d, %v)", s.folder, maxEntries, maxAge)
+
+	s.historyMut.Lock()
+	s.historyEntries = maxEntries
+	s.historyMaxAge = maxAge
+	s.historyMut.Unlock()
+
+	if maxEntries <= 0 {
+		s.db.dropHistory([]byte(s.folder))
+		return
+	}
+	s.db.pruneAllHistory([]byte(s.folder), maxEntries, maxAge)
+}
+
+// History returns the known versions of the file, oldest first.
+func (s *FileSet) History(file string) []HistoryEntry {
+	s.historyMut.Lock()
+	maxEntries, maxAge := s.historyEntries, s.historyMaxAge
+	s.historyMut.Unlock()
+
+	if maxEntries <= 0 {
+		return nil
+	}
+	// Versions that have become too old since they were stored are left out.
+	entries := s.db.history([]byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+	entries = pruneHistory(entries, maxEntries, maxAge, time.Now())
+	if len(entries) == 0 {
+		return nil
+	}
+	res := make([]HistoryEntry, len(entries))
+	for i, e := range entries {
+		res[i] = e.historyEntry()
+	}
+	return res
+}
+
+func (s *FileSet) recordHistory(device protocol.DeviceID, fs []protocol.FileInfo) {
+	s.historyMut.Lock()
+	maxEntries, maxAge := s.historyEntries, s.historyMaxAge
+	s.historyMut.Unlock()
+
+	if maxEntries > 0 {
+		s.db.recordHistory([]byte(s.folder), device, fs, maxEntries, maxAge)
+	}
 }
 
 func (s *FileSet) WithNeed(device protocol.DeviceID, fn Iterator) {
@@ -306,6 +419,13 @@ func (s *FileSet) MtimeFS() *fs.MtimeFS {
 	return fs.NewMtimeFS(fs.DefaultFilesystem, kv)
 }
 
+// DirStates returns the store of the states of the folder's directories,
+// kept for incremental scans.
+func (s *FileSet) DirStates() *NamespacedKV {
+	prefix := s.db.dirStatesKey([]byte(s.folder))
+	return NewNamespacedKV(s.db, string(prefix))
+}
+
 func (s *FileSet) ListDevices() []protocol.DeviceID {
 	s.updateMutex.Lock()
 	devices := make([]protocol.DeviceID, 0, len(s.remoteSequence))
@@ -337,6 +457,9 @@ func maxSequence(fs []protocol.FileInfo) int64 {
 func DropFolder(db *Instance, folder string) {
 	db.dropFolder([]byte(folder))
 	db.dropMtimes([]byte(folder))
+	db.dropReceiveOnly([]byte(folder))
+	db.dropHistory([]byte(folder))
+	db.dropDirStates([]byte(folder))
 	bm := &BlockMap{
 		db:     db,
 		folder: db.folderIdx.ID([]byte(folder)),
@@ -346,6 +346,120 @@ func TestNeedWithInvalid(t *testing.T) {
 	}
 }
 
+func TestMigrateDevice(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", ldb)
+	s.SetReceiveOnlyDevices([]protocol.DeviceID{remoteDevice0})
+
+	files := fileList{
+		protocol.FileInfo{Name: "a", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1000}}}, Blocks: genBlocks(1)},
+	}
+	s.Replace(remoteDevice0, files)
+	s.SetIndexID(remoteDevice0, 42)
+
+	ldb.AddIdentityMigration(remoteDevice0, remoteDevice1)
+	if from, ok := ldb.IdentityMigrationTo(remoteDevice1); !ok || from != remoteDevice0 {
+		t.Errorf("Announced migration should be from the old device, not %v", from)
+	}
+
+	if err := ldb.MigrateDevice(remoteDevice0, remoteDevice1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ldb.IdentityMigrationTo(remoteDevice1); ok {
+		t.Error("Announced migration should be forgotten")
+	}
+	if err := ldb.MigrateDevice(remoteDevice0, remoteDevice1); err == nil {
+		t.Error("Migrating to a known device should fail")
+	}
+
+	s = db.NewFileSet("test", ldb)
+
+	if have := fileList(haveList(s, remoteDevice1)); fmt.Sprint(have) != fmt.Sprint(files) {
+		t.Errorf("Have incorrect;\n A: %v !=\n E: %v", have, files)
+	}
+	if have := haveList(s, remoteDevice0); len(have) != 0 {
+		t.Errorf("Old device should have nothing, not %v", have)
+	}
+	if id := s.IndexID(remoteDevice1); id != 42 {
+		t.Errorf("Index ID should be kept, not %v", id)
+	}
+
+	// The device is still receive only, so its file isn't global
+	if g := globalList(s); len(g) != 0 {
+		t.Errorf("Global should be empty, not %v", g)
+	}
+	s.SetReceiveOnlyDevices(nil)
+	if av := s.Availability("a"); len(av) != 1 || av[0] != remoteDevice1 {
+		t.Errorf("Availability should be the new device, not %v", av)
+	}
+}
+
+func TestReceiveOnlyDevice(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", ldb)
+	s.SetReceiveOnlyDevices([]protocol.DeviceID{remoteDevice1})
+
+	v1 := protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1000}}}
+	v2 := v1.Update(42)
+
+	localHave := fileList{
+		protocol.FileInfo{Name: "a", Version: v1, Blocks: genBlocks(1)},
+		protocol.FileInfo{Name: "b", Version: v1, Blocks: genBlocks(1)},
+		protocol.FileInfo{Name: "e", Version: v1, Blocks: genBlocks(1)},
+	}
+	remote1Have := fileList{
+		// Same as ours
+		protocol.FileInfo{Name: "a", Version: v1, Blocks: genBlocks(1)},
+		// Changed and new files that should be ignored
+		protocol.FileInfo{Name: "b", Version: v2, Blocks: genBlocks(2)},
+		protocol.FileInfo{Name: "c", Version: v1, Blocks: genBlocks(3)},
+	}
+
+	s.Replace(protocol.LocalDeviceID, localHave)
+	s.Replace(remoteDevice1, remote1Have)
+
+	if g := fileList(globalList(s)); fmt.Sprint(g) != fmt.Sprint(localHave) {
+		t.Errorf("Global incorrect;\n A: %v !=\n E: %v", g, localHave)
+	}
+	if need := needList(s, protocol.LocalDeviceID); len(need) != 0 {
+		t.Errorf("Should not need anything from a receive only device, not %v", need)
+	}
+	// The receive only device needs what it doesn't have
+	need := fileList(needList(s, remoteDevice1))
+	if len(need) != 1 || need[0].Name != "e" {
+		t.Errorf("Need incorrect for receive only device: %v", need)
+	}
+
+	// The role is remembered when the folder is loaded again
+
+	s = db.NewFileSet("test", ldb)
+	s.Update(remoteDevice1, fileList{protocol.FileInfo{Name: "d", Version: v1, Blocks: genBlocks(1)}})
+	if g := globalList(s); len(g) != 3 {
+		t.Errorf("Global should still only have our files, not %v", g)
+	}
+
+	// No longer receive only, the device's changes become global
+
+	s.SetReceiveOnlyDevices(nil)
+	expectedGlobal := fileList{
+		protocol.FileInfo{Name: "a", Version: v1, Blocks: genBlocks(1)},
+		protocol.FileInfo{Name: "b", Version: v2, Blocks: genBlocks(2)},
+		protocol.FileInfo{Name: "c", Version: v1, Blocks: genBlocks(3)},
+		protocol.FileInfo{Name: "d", Version: v1, Blocks: genBlocks(1)},
+		protocol.FileInfo{Name: "e", Version: v1, Blocks: genBlocks(1)},
+	}
+	g := fileList(globalList(s))
+	sort.Sort(g)
+	if fmt.Sprint(g) != fmt.Sprint(expectedGlobal) {
+		t.Errorf("Global incorrect;\n A: %v !=\n E: %v", g, expectedGlobal)
+	}
+	if size := s.GlobalSize(); size.Files != 5 {
+		t.Errorf("Global size incorrect: %+v", size)
+	}
+}
+
 func TestUpdateToInvalid(t *testing.T) {
 	ldb := db.OpenMemory()
 
@@ -12,6 +12,9 @@
 		FileVersion
 		VersionList
 		FileInfoTruncated
+		FileHistoryEntry
+		FileHistory
+		DirState
 */
 package db
 
@@ -75,10 +78,52 @@ func (m *FileInfoTruncated) Reset()                    { *m = FileInfoTruncated{
 func (*FileInfoTruncated) ProtoMessage()               {}
 func (*FileInfoTruncated) Descriptor() ([]byte, []int) { return fileDescriptorStructs, []int{2} }
 
+type FileHistoryEntry struct {
+	Version    protocol.Vector                                     `protobuf:"bytes,1,opt,name=version" json:"version"`
+	ModifiedBy github_com_syncthing_syncthing_lib_protocol.ShortID `protobuf:"varint,2,opt,name=modified_by,json=modifiedBy,proto3,customtype=github.com/syncthing/syncthing/lib/protocol.ShortID" json:"modified_by"`
+	ModifiedS  int64                                               `protobuf:"varint,3,opt,name=modified_s,json=modifiedS,proto3" json:"modified_s,omitempty"`
+	ModifiedNs int32                                               `protobuf:"varint,4,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
+	Size       int64                                               `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+	Deleted    bool                                                `protobuf:"varint,6,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Hash       []byte                                              `protobuf:"bytes,7,opt,name=hash,proto3" json:"hash,omitempty"`
+	RecordedS  int64                                               `protobuf:"varint,8,opt,name=recorded_s,json=recordedS,proto3" json:"recorded_s,omitempty"`
+	Device     []byte                                              `protobuf:"bytes,9,opt,name=device,proto3" json:"device,omitempty"`
+}
+
+func (m *FileHistoryEntry) Reset()                    { *m = FileHistoryEntry{} }
+func (m *FileHistoryEntry) String() string            { return proto.CompactTextString(m) }
+func (*FileHistoryEntry) ProtoMessage()               {}
+func (*FileHistoryEntry) Descriptor() ([]byte, []int) { return fileDescriptorStructs, []int{3} }
+
+type FileHistory struct {
+	Entries []FileHistoryEntry `protobuf:"bytes,1,rep,name=entries" json:"entries"`
+}
+
+func (m *FileHistory) Reset()                    { *m = FileHistory{} }
+func (m *FileHistory) String() string            { return proto.CompactTextString(m) }
+func (*FileHistory) ProtoMessage()               {}
+func (*FileHistory) Descriptor() ([]byte, []int) { return fileDescriptorStructs, []int{4} }
+
+type DirState struct {
+	ModifiedS  int64    `protobuf:"varint,1,opt,name=modified_s,json=modifiedS,proto3" json:"modified_s,omitempty"`
+	ModifiedNs int32    `protobuf:"varint,2,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
+	Inode      uint64   `protobuf:"varint,3,opt,name=inode,proto3" json:"inode,omitempty"`
+	Children   int32    `protobuf:"varint,4,opt,name=children,proto3" json:"children,omitempty"`
+	Subdirs    []string `protobuf:"bytes,5,rep,name=subdirs" json:"subdirs,omitempty"`
+}
+
+func (m *DirState) Reset()                    { *m = DirState{} }
+func (m *DirState) String() string            { return proto.CompactTextString(m) }
+func (*DirState) ProtoMessage()               {}
+func (*DirState) Descriptor() ([]byte, []int) { return fileDescriptorStructs, []int{5} }
+
 func init() {
 	proto.RegisterType((*FileVersion)(nil), "db.FileVersion")
 	proto.RegisterType((*VersionList)(nil), "db.VersionList")
 	proto.RegisterType((*FileInfoTruncated)(nil), "db.FileInfoTruncated")
+	proto.RegisterType((*FileHistoryEntry)(nil), "db.FileHistoryEntry")
+	proto.RegisterType((*FileHistory)(nil), "db.FileHistory")
+	proto.RegisterType((*DirState)(nil), "db.DirState")
 }
 func (m *FileVersion) Marshal() (dAtA []byte, err error) {
 	size := m.ProtoSize()
@@ -247,6 +292,162 @@ func (m *FileInfoTruncated) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *FileHistoryEntry) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FileHistoryEntry) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	dAtA[i] = 0xa
+	i++
+	i = encodeVarintStructs(dAtA, i, uint64(m.Version.ProtoSize()))
+	n3, err := m.Version.MarshalTo(dAtA[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n3
+	if m.ModifiedBy != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedBy))
+	}
+	if m.ModifiedS != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedS))
+	}
+	if m.ModifiedNs != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedNs))
+	}
+	if m.Size != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.Size))
+	}
+	if m.Deleted {
+		dAtA[i] = 0x30
+		i++
+		if m.Deleted {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Hash) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(len(m.Hash)))
+		i += copy(dAtA[i:], m.Hash)
+	}
+	if m.RecordedS != 0 {
+		dAtA[i] = 0x40
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.RecordedS))
+	}
+	if len(m.Device) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(len(m.Device)))
+		i += copy(dAtA[i:], m.Device)
+	}
+	return i, nil
+}
+
+func (m *FileHistory) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FileHistory) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Entries) > 0 {
+		for _, msg := range m.Entries {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStructs(dAtA, i, uint64(msg.ProtoSize()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *DirState) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DirState) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ModifiedS != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedS))
+	}
+	if m.ModifiedNs != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedNs))
+	}
+	if m.Inode != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.Inode))
+	}
+	if m.Children != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.Children))
+	}
+	if len(m.Subdirs) > 0 {
+		for _, s := range m.Subdirs {
+			dAtA[i] = 0x2a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
 func encodeFixed64Structs(dAtA []byte, offset int, v uint64) int {
 	dAtA[offset] = uint8(v)
 	dAtA[offset+1] = uint8(v >> 8)
@@ -344,6 +545,76 @@ func (m *FileInfoTruncated) ProtoSize() (n int) {
 	return n
 }
 
+func (m *FileHistoryEntry) ProtoSize() (n int) {
+	var l int
+	_ = l
+	l = m.Version.ProtoSize()
+	n += 1 + l + sovStructs(uint64(l))
+	if m.ModifiedBy != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedBy))
+	}
+	if m.ModifiedS != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedS))
+	}
+	if m.ModifiedNs != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedNs))
+	}
+	if m.Size != 0 {
+		n += 1 + sovStructs(uint64(m.Size))
+	}
+	if m.Deleted {
+		n += 2
+	}
+	l = len(m.Hash)
+	if l > 0 {
+		n += 1 + l + sovStructs(uint64(l))
+	}
+	if m.RecordedS != 0 {
+		n += 1 + sovStructs(uint64(m.RecordedS))
+	}
+	l = len(m.Device)
+	if l > 0 {
+		n += 1 + l + sovStructs(uint64(l))
+	}
+	return n
+}
+
+func (m *FileHistory) ProtoSize() (n int) {
+	var l int
+	_ = l
+	if len(m.Entries) > 0 {
+		for _, e := range m.Entries {
+			l = e.ProtoSize()
+			n += 1 + l + sovStructs(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *DirState) ProtoSize() (n int) {
+	var l int
+	_ = l
+	if m.ModifiedS != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedS))
+	}
+	if m.ModifiedNs != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedNs))
+	}
+	if m.Inode != 0 {
+		n += 1 + sovStructs(uint64(m.Inode))
+	}
+	if m.Children != 0 {
+		n += 1 + sovStructs(uint64(m.Children))
+	}
+	if len(m.Subdirs) > 0 {
+		for _, s := range m.Subdirs {
+			l = len(s)
+			n += 1 + l + sovStructs(uint64(l))
+		}
+	}
+	return n
+}
+
 func sovStructs(x uint64) (n int) {
 	for {
 		n++
@@ -880,6 +1151,499 @@ func (m *FileInfoTruncated) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *FileHistoryEntry) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStructs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FileHistoryEntry: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FileHistoryEntry: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedBy", wireType)
+			}
+			m.ModifiedBy = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedBy |= (github_com_syncthing_syncthing_lib_protocol.ShortID(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedS", wireType)
+			}
+			m.ModifiedS = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedS |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedNs", wireType)
+			}
+			m.ModifiedNs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedNs |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			}
+			m.Size = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Deleted", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Deleted = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RecordedS", wireType)
+			}
+			m.RecordedS = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RecordedS |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Device", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Device = append(m.Device[:0], dAtA[iNdEx:postIndex]...)
+			if m.Device == nil {
+				m.Device = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStructs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FileHistory) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStructs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FileHistory: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FileHistory: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Entries = append(m.Entries, FileHistoryEntry{})
+			if err := m.Entries[len(m.Entries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStructs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DirState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStructs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DirState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DirState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedS", wireType)
+			}
+			m.ModifiedS = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedS |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedNs", wireType)
+			}
+			m.ModifiedNs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedNs |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Inode", wireType)
+			}
+			m.Inode = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Inode |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Children", wireType)
+			}
+			m.Children = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Children |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Subdirs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Subdirs = append(m.Subdirs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStructs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipStructs(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -988,36 +1752,45 @@ var (
 func init() { proto.RegisterFile("structs.proto", fileDescriptorStructs) }
 
 var fileDescriptorStructs = []byte{
-	// 483 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0x4f, 0x6b, 0xdb, 0x4e,
-	0x10, 0xf5, 0xc6, 0x8a, 0xff, 0xac, 0xe2, 0xfc, 0x7e, 0x59, 0x4a, 0x58, 0x0c, 0x95, 0x85, 0xa1,
-	0x20, 0x0a, 0x95, 0x5b, 0x87, 0x5e, 0xda, 0x9b, 0x29, 0x81, 0x40, 0x29, 0x45, 0x09, 0xe9, 0xa5,
-	0x60, 0x2c, 0x69, 0x2c, 0x2f, 0x95, 0x76, 0x55, 0xed, 0xca, 0xa0, 0x7e, 0x92, 0x1e, 0xf3, 0x71,
-	0x7c, 0xec, 0xb9, 0x87, 0xd0, 0xba, 0x9f, 0xa3, 0x50, 0xb4, 0x92, 0x15, 0x1d, 0xdb, 0xdb, 0xbc,
-	0xd9, 0xf7, 0xe6, 0xbd, 0x61, 0x16, 0x8f, 0xa4, 0xca, 0xf2, 0x40, 0x49, 0x37, 0xcd, 0x84, 0x12,
-	0xe4, 0x28, 0xf4, 0xc7, 0xcf, 0x22, 0xa6, 0x36, 0xb9, 0xef, 0x06, 0x22, 0x99, 0x45, 0x22, 0x12,
-	0x33, 0xfd, 0xe4, 0xe7, 0x6b, 0x8d, 0x34, 0xd0, 0x55, 0x25, 0x19, 0xbf, 0x6c, 0xd1, 0x65, 0xc1,
-	0x03, 0xb5, 0x61, 0x3c, 0x6a, 0x55, 0x31, 0xf3, 0xab, 0x09, 0x81, 0x88, 0x67, 0x3e, 0xa4, 0x95,
-	0x6c, 0xfa, 0x01, 0x9b, 0x97, 0x2c, 0x86, 0x5b, 0xc8, 0x24, 0x13, 0x9c, 0x3c, 0xc7, 0xfd, 0x6d,
-	0x55, 0x52, 0x64, 0x23, 0xc7, 0x9c, 0xff, 0xef, 0x1e, 0x44, 0xee, 0x2d, 0x04, 0x4a, 0x64, 0x0b,
-	0x63, 0x77, 0x3f, 0xe9, 0x78, 0x07, 0x1a, 0x39, 0xc7, 0xbd, 0x10, 0xb6, 0x2c, 0x00, 0x7a, 0x64,
-	0x23, 0xe7, 0xc4, 0xab, 0xd1, 0xf4, 0x12, 0x9b, 0xf5, 0xd0, 0xb7, 0x4c, 0x2a, 0xf2, 0x02, 0x0f,
-	0x6a, 0x85, 0xa4, 0xc8, 0xee, 0x3a, 0xe6, 0xfc, 0x3f, 0x37, 0xf4, 0xdd, 0x96, 0x77, 0x3d, 0xb8,
-	0xa1, 0xbd, 0x32, 0xbe, 0xde, 0x4d, 0x3a, 0xd3, 0xdf, 0x5d, 0x7c, 0x56, 0xb2, 0xae, 0xf8, 0x5a,
-	0xdc, 0x64, 0x39, 0x0f, 0x56, 0x0a, 0x42, 0x42, 0xb0, 0xc1, 0x57, 0x09, 0xe8, 0x90, 0x43, 0x4f,
-	0xd7, 0xe4, 0x29, 0x36, 0x54, 0x91, 0x56, 0x39, 0x4e, 0xe7, 0xe7, 0x0f, 0xc1, 0x1b, 0x79, 0x91,
-	0x82, 0xa7, 0x39, 0xa5, 0x5e, 0xb2, 0x2f, 0x40, 0xbb, 0x36, 0x72, 0xba, 0x9e, 0xae, 0x89, 0x8d,
-	0xcd, 0x14, 0xb2, 0x84, 0xc9, 0x2a, 0xa5, 0x61, 0x23, 0x67, 0xe4, 0xb5, 0x5b, 0xe4, 0x31, 0xc6,
-	0x89, 0x08, 0xd9, 0x9a, 0x41, 0xb8, 0x94, 0xf4, 0x58, 0x6b, 0x87, 0x87, 0xce, 0x35, 0xa1, 0xb8,
-	0x1f, 0x42, 0x0c, 0x0a, 0x42, 0xda, 0xb3, 0x91, 0x33, 0xf0, 0x0e, 0xb0, 0x7c, 0x61, 0x7c, 0xbb,
-	0x8a, 0x59, 0x48, 0xfb, 0xd5, 0x4b, 0x0d, 0xc9, 0x13, 0x7c, 0xca, 0xc5, 0xb2, 0xed, 0x3b, 0xd0,
-	0x84, 0x11, 0x17, 0xef, 0x5b, 0xce, 0xad, 0xbb, 0x0c, 0xff, 0xee, 0x2e, 0x63, 0x3c, 0x90, 0xf0,
-	0x39, 0x07, 0x1e, 0x00, 0xc5, 0x3a, 0x69, 0x83, 0xc9, 0x04, 0x9b, 0xcd, 0x1e, 0x5c, 0x52, 0xd3,
-	0x46, 0xce, 0xb1, 0xd7, 0xac, 0xf6, 0x4e, 0x92, 0x8f, 0x2d, 0x82, 0x5f, 0xd0, 0x13, 0x1b, 0x39,
-	0xc6, 0xe2, 0x75, 0x69, 0xf0, 0xfd, 0x7e, 0x72, 0xf1, 0x0f, 0x3f, 0xcd, 0xbd, 0xde, 0x88, 0x4c,
-	0x5d, 0xbd, 0x79, 0x98, 0xbe, 0x28, 0xca, 0x9d, 0x65, 0x91, 0xc4, 0x8c, 0x7f, 0x5a, 0xaa, 0x55,
-	0x16, 0x81, 0xa2, 0x67, 0xfa, 0x8c, 0xa3, 0xba, 0x7b, 0xa3, 0x9b, 0xd5, 0xfd, 0x17, 0x8f, 0x76,
-	0x3f, 0xad, 0xce, 0x6e, 0x6f, 0xa1, 0x6f, 0x7b, 0x0b, 0xfd, 0xd8, 0x5b, 0x9d, 0xbb, 0x5f, 0x16,
-	0xf2, 0x7b, 0xda, 0xe0, 0xe2, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9e, 0xcd, 0xe3, 0xfd, 0x38,
-	0x03, 0x00, 0x00,
+	// 637 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x53, 0x5f, 0x6b, 0x13, 0x41,
+	0x10, 0xcf, 0x26, 0x97, 0x26, 0xd9, 0x6b, 0x6a, 0xbb, 0x94, 0x72, 0x14, 0x4c, 0x8e, 0x80, 0x70,
+	0x08, 0x5e, 0xb5, 0xd5, 0x17, 0x7d, 0x8b, 0xb5, 0x58, 0x10, 0x91, 0x4b, 0xa9, 0x2f, 0x42, 0xc9,
+	0xdd, 0x4e, 0x93, 0xc5, 0xcb, 0x6e, 0xdc, 0xdd, 0x14, 0xce, 0x0f, 0x22, 0x3e, 0xf6, 0xe3, 0xf4,
+	0xd1, 0x67, 0x85, 0xa2, 0xf1, 0x73, 0x08, 0x72, 0x7b, 0x77, 0xe9, 0x35, 0x45, 0xfc, 0x83, 0x6f,
+	0xf3, 0x9b, 0x9d, 0xd9, 0x99, 0xf9, 0xcd, 0x6f, 0x70, 0x5b, 0x69, 0x39, 0x8b, 0xb4, 0xf2, 0xa7,
+	0x52, 0x68, 0x41, 0xaa, 0x34, 0xdc, 0xbe, 0x37, 0x62, 0x7a, 0x3c, 0x0b, 0xfd, 0x48, 0x4c, 0x76,
+	0x46, 0x62, 0x24, 0x76, 0xcc, 0x53, 0x38, 0x3b, 0x35, 0xc8, 0x00, 0x63, 0x65, 0x29, 0xdb, 0x8f,
+	0x4a, 0xe1, 0x2a, 0xe1, 0x91, 0x1e, 0x33, 0x3e, 0x2a, 0x59, 0x31, 0x0b, 0xb3, 0x1f, 0x22, 0x11,
+	0xef, 0x84, 0x30, 0xcd, 0xd2, 0x7a, 0xaf, 0xb1, 0x7d, 0xc0, 0x62, 0x38, 0x06, 0xa9, 0x98, 0xe0,
+	0xe4, 0x3e, 0x6e, 0x9c, 0x65, 0xa6, 0x83, 0x5c, 0xe4, 0xd9, 0xbb, 0xeb, 0x7e, 0x91, 0xe4, 0x1f,
+	0x43, 0xa4, 0x85, 0xec, 0x5b, 0x17, 0x97, 0xdd, 0x4a, 0x50, 0x84, 0x91, 0x2d, 0xbc, 0x42, 0xe1,
+	0x8c, 0x45, 0xe0, 0x54, 0x5d, 0xe4, 0xad, 0x06, 0x39, 0xea, 0x1d, 0x60, 0x3b, 0xff, 0xf4, 0x05,
+	0x53, 0x9a, 0x3c, 0xc0, 0xcd, 0x3c, 0x43, 0x39, 0xc8, 0xad, 0x79, 0xf6, 0xee, 0x2d, 0x9f, 0x86,
+	0x7e, 0xa9, 0x76, 0xfe, 0xf1, 0x22, 0xec, 0xb1, 0xf5, 0xf1, 0xbc, 0x5b, 0xe9, 0xfd, 0xa8, 0xe1,
+	0x8d, 0x34, 0xea, 0x90, 0x9f, 0x8a, 0x23, 0x39, 0xe3, 0xd1, 0x50, 0x03, 0x25, 0x04, 0x5b, 0x7c,
+	0x38, 0x01, 0xd3, 0x64, 0x2b, 0x30, 0x36, 0xb9, 0x8b, 0x2d, 0x9d, 0x4c, 0xb3, 0x3e, 0xd6, 0x76,
+	0xb7, 0xae, 0x1a, 0x5f, 0xa4, 0x27, 0x53, 0x08, 0x4c, 0x4c, 0x9a, 0xaf, 0xd8, 0x7b, 0x70, 0x6a,
+	0x2e, 0xf2, 0x6a, 0x81, 0xb1, 0x89, 0x8b, 0xed, 0x29, 0xc8, 0x09, 0x53, 0x59, 0x97, 0x96, 0x8b,
+	0xbc, 0x76, 0x50, 0x76, 0x91, 0xdb, 0x18, 0x4f, 0x04, 0x65, 0xa7, 0x0c, 0xe8, 0x89, 0x72, 0xea,
+	0x26, 0xb7, 0x55, 0x78, 0x06, 0xc4, 0xc1, 0x0d, 0x0a, 0x31, 0x68, 0xa0, 0xce, 0x8a, 0x8b, 0xbc,
+	0x66, 0x50, 0xc0, 0xf4, 0x85, 0xf1, 0xb3, 0x61, 0xcc, 0xa8, 0xd3, 0xc8, 0x5e, 0x72, 0x48, 0xee,
+	0xe0, 0x35, 0x2e, 0x4e, 0xca, 0x75, 0x9b, 0x26, 0xa0, 0xcd, 0xc5, 0xab, 0x52, 0xe5, 0xd2, 0x5e,
+	0x5a, 0x7f, 0xb6, 0x97, 0x6d, 0xdc, 0x54, 0xf0, 0x6e, 0x06, 0x3c, 0x02, 0x07, 0x9b, 0x4e, 0x17,
+	0x98, 0x74, 0xb1, 0xbd, 0x98, 0x83, 0x2b, 0xc7, 0x76, 0x91, 0x57, 0x0f, 0x16, 0xa3, 0xbd, 0x54,
+	0xe4, 0x4d, 0x29, 0x20, 0x4c, 0x9c, 0x55, 0x17, 0x79, 0x56, 0xff, 0x49, 0x5a, 0xe0, 0xf3, 0x65,
+	0x77, 0xef, 0x2f, 0x94, 0xe6, 0x0f, 0xc6, 0x42, 0xea, 0xc3, 0xfd, 0xab, 0xdf, 0xfb, 0x49, 0x3a,
+	0xb3, 0x4a, 0x26, 0x31, 0xe3, 0x6f, 0x4f, 0xf4, 0x50, 0x8e, 0x40, 0x3b, 0x1b, 0x66, 0x8d, 0xed,
+	0xdc, 0x7b, 0x64, 0x9c, 0xf9, 0xfe, 0xbf, 0x54, 0xf1, 0x7a, 0xba, 0xc0, 0xe7, 0x4c, 0x69, 0x21,
+	0x93, 0x67, 0x5c, 0xcb, 0xe4, 0x1f, 0x64, 0xba, 0x34, 0x51, 0xf5, 0xff, 0x4e, 0x74, 0x5d, 0x18,
+	0xb5, 0x65, 0x61, 0x2c, 0xf1, 0x6d, 0xdd, 0xe0, 0xbb, 0x90, 0x63, 0xbd, 0x24, 0xc7, 0x5f, 0xab,
+	0x89, 0x60, 0x6b, 0x3c, 0x54, 0x63, 0x23, 0xa5, 0xd5, 0xc0, 0xd8, 0x69, 0x07, 0x12, 0x22, 0x21,
+	0xa9, 0xe9, 0xa0, 0x99, 0x75, 0x50, 0x78, 0x06, 0xa5, 0x2b, 0x6d, 0x5d, 0xbb, 0xd2, 0xa7, 0xd8,
+	0x2e, 0x91, 0x4b, 0x1e, 0xe2, 0x06, 0x70, 0x2d, 0x19, 0x14, 0x47, 0xba, 0x59, 0x1c, 0x69, 0x99,
+	0xfe, 0x82, 0xdb, 0x3c, 0xb4, 0xf7, 0x01, 0xe1, 0xe6, 0x3e, 0x93, 0x03, 0x3d, 0xd4, 0xb0, 0x44,
+	0x05, 0xfa, 0x0d, 0x15, 0xd5, 0x1b, 0x54, 0x6c, 0xe2, 0x3a, 0xe3, 0x82, 0x66, 0xa7, 0x69, 0x05,
+	0x19, 0x48, 0xd5, 0x1c, 0x8d, 0x59, 0x4c, 0x25, 0xf0, 0x9c, 0xbe, 0x05, 0x4e, 0x89, 0x52, 0xb3,
+	0x90, 0x32, 0x99, 0x9e, 0x64, 0xcd, 0x6b, 0x05, 0x05, 0xec, 0x6f, 0x5e, 0x7c, 0xeb, 0x54, 0x2e,
+	0xe6, 0x1d, 0xf4, 0x69, 0xde, 0x41, 0x5f, 0xe7, 0x9d, 0xca, 0xf9, 0xf7, 0x0e, 0x0a, 0x57, 0xcc,
+	0x2a, 0xf7, 0x7e, 0x0e, 0x00, 0x4f, 0x0f, 0x65, 0x3a, 0x74, 0x05, 0x00, 0x00,
 }
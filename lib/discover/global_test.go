@@ -11,6 +11,8 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -218,6 +220,68 @@ func TestGlobalAnnounce(t *testing.T) {
 	}
 }
 
+func TestGlobalAnnounceKeyTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Devices and discovery servers with any of our key types must be able
+	// to talk to each other, with the server verified by its ID.
+	var certs []tls.Certificate
+	for _, kt := range []tlsutil.KeyType{tlsutil.KeyTypeRSA, tlsutil.KeyTypeECDSA, tlsutil.KeyTypeEd25519} {
+		cert, err := tlsutil.NewCertificateWithKeyType(filepath.Join(dir, string(kt)+"-cert.pem"), filepath.Join(dir, string(kt)+"-key.pem"), "syncthing", kt, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+
+	for _, serverCert := range certs {
+		for _, deviceCert := range certs {
+			testAnnounce(t, serverCert, deviceCert)
+		}
+	}
+}
+
+func testAnnounce(t *testing.T, serverCert, deviceCert tls.Certificate) {
+	list, err := tls.Listen("tcp4", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequestClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	s := new(fakeDiscoveryServer)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handler)
+	go http.Serve(list, mux)
+
+	serverID := protocol.NewDeviceID(serverCert.Certificate[0])
+	disco, err := NewGlobal("https://"+list.Addr().String()+"?id="+serverID.String(), deviceCert, new(fakeAddressLister))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go disco.Serve()
+	defer disco.Stop()
+
+	t0 := time.Now()
+	for err := disco.Error(); err != nil; err = disco.Error() {
+		if time.Since(t0) > 10*time.Second {
+			t.Fatal("announce failed:", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if deviceID := protocol.NewDeviceID(deviceCert.Certificate[0]); s.announcer != deviceID {
+		t.Errorf("Server saw announcement from %v, expected %v", s.announcer, deviceID)
+	}
+}
+
 func testLookup(url string) ([]string, error) {
 	disco, err := NewGlobal(url, tls.Certificate{}, nil)
 	if err != nil {
@@ -230,7 +294,8 @@ func testLookup(url string) ([]string, error) {
 }
 
 type fakeDiscoveryServer struct {
-	announce []byte
+	announce  []byte
+	announcer protocol.DeviceID
 }
 
 func (s *fakeDiscoveryServer) handler(w http.ResponseWriter, r *http.Request) {
@@ -241,6 +306,9 @@ func (s *fakeDiscoveryServer) handler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "POST" {
 		s.announce, _ = ioutil.ReadAll(r.Body)
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			s.announcer = protocol.NewDeviceID(r.TLS.PeerCertificates[0].Raw)
+		}
 		w.WriteHeader(204)
 	} else {
 		w.Header().Set("Content-Type", "application/json")
@@ -45,6 +45,9 @@ const (
 	FolderResumed
 	ListenAddressesChanged
 	LoginAttempt
+	IntroducerAction
+	DeviceIdentityMigrated
+	FolderScrubResult
 
 	AllEvents = (1 << iota) - 1
 )
@@ -109,6 +112,12 @@ func (t EventType) String() string {
 		return "ListenAddressesChanged"
 	case LoginAttempt:
 		return "LoginAttempt"
+	case IntroducerAction:
+		return "IntroducerAction"
+	case DeviceIdentityMigrated:
+		return "DeviceIdentityMigrated"
+	case FolderScrubResult:
+		return "FolderScrubResult"
 	default:
 		return "Unknown"
 	}
@@ -174,6 +183,12 @@ func UnmarshalEventType(s string) EventType {
 		return ListenAddressesChanged
 	case "LoginAttempt":
 		return LoginAttempt
+	case "IntroducerAction":
+		return IntroducerAction
+	case "DeviceIdentityMigrated":
+		return DeviceIdentityMigrated
+	case "FolderScrubResult":
+		return FolderScrubResult
 	default:
 		return 0
 	}
@@ -10,6 +10,8 @@ import (
 	"errors"
 	"os"
 	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
 )
 
 // The BasicFilesystem implements all aspects by delegating to package os.
@@ -32,6 +34,10 @@ func (f *BasicFilesystem) Mkdir(name string, perm FileMode) error {
 	return os.Mkdir(name, os.FileMode(perm))
 }
 
+func (f *BasicFilesystem) MkdirAll(name string, perm FileMode) error {
+	return osutil.MkdirAll(name, os.FileMode(perm))
+}
+
 func (f *BasicFilesystem) Lstat(name string) (FileInfo, error) {
 	fi, err := underlyingLstat(name)
 	if err != nil {
@@ -44,6 +50,10 @@ func (f *BasicFilesystem) Remove(name string) error {
 	return os.Remove(name)
 }
 
+func (f *BasicFilesystem) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
 func (f *BasicFilesystem) Rename(oldpath, newpath string) error {
 	return os.Rename(oldpath, newpath)
 }
@@ -79,6 +89,14 @@ func (f *BasicFilesystem) Open(name string) (File, error) {
 	return fsFile{fd}, err
 }
 
+func (f *BasicFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	fd, err := os.OpenFile(name, flags, os.FileMode(mode))
+	if err != nil {
+		return nil, err
+	}
+	return fsFile{fd}, err
+}
+
 func (f *BasicFilesystem) Create(name string) (File, error) {
 	fd, err := os.Create(name)
 	if err != nil {
@@ -87,6 +105,14 @@ func (f *BasicFilesystem) Create(name string) (File, error) {
 	return fsFile{fd}, err
 }
 
+func (f *BasicFilesystem) Glob(pattern string) ([]string, error) {
+	return osutil.Glob(pattern)
+}
+
+func (f *BasicFilesystem) Hide(name string) error {
+	return osutil.HideFile(name)
+}
+
 func (f *BasicFilesystem) Walk(root string, walkFn WalkFunc) error {
 	// implemented in WalkFilesystem
 	return errors.New("not implemented")
@@ -8,10 +8,6 @@
 
 package fs
 
-import "errors"
-
-var errNotSupported = errors.New("symlinks not supported")
-
 func DisableSymlinks() {}
 
 func (BasicFilesystem) SymlinksSupported() bool {
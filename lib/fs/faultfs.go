@@ -0,0 +1,459 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The operations a FaultRule can apply to.
+const (
+	FaultOpChmod   = "chmod"   // Chmod, Hide
+	FaultOpChtimes = "chtimes" // Chtimes
+	FaultOpClose   = "close"   // File.Close
+	FaultOpCreate  = "create"  // Create, OpenFile with O_CREATE
+	FaultOpMkdir   = "mkdir"   // Mkdir, MkdirAll
+	FaultOpOpen    = "open"    // Open, OpenFile without O_CREATE
+	FaultOpRead    = "read"    // File.Read, File.ReadAt
+	FaultOpReaddir = "readdir" // DirNames, Glob
+	FaultOpRemove  = "remove"  // Remove, RemoveAll
+	FaultOpRename  = "rename"  // Rename, matching either name
+	FaultOpStat    = "stat"    // Lstat, Stat, ReadSymlink, File.Stat
+	FaultOpSymlink = "symlink" // CreateSymlink
+	FaultOpSync    = "sync"    // File.Sync
+	FaultOpWrite   = "write"   // File.Write, File.WriteAt, File.Truncate
+)
+
+var faultOps = map[string]bool{
+	FaultOpChmod:   true,
+	FaultOpChtimes: true,
+	FaultOpClose:   true,
+	FaultOpCreate:  true,
+	FaultOpMkdir:   true,
+	FaultOpOpen:    true,
+	FaultOpRead:    true,
+	FaultOpReaddir: true,
+	FaultOpRemove:  true,
+	FaultOpRename:  true,
+	FaultOpStat:    true,
+	FaultOpSymlink: true,
+	FaultOpSync:    true,
+	FaultOpWrite:   true,
+}
+
+// The errors a FaultRule can inject, by name.
+var faultErrors = map[string]error{
+	"EACCES": syscall.EACCES,
+	"EIO":    syscall.EIO,
+	"ENOSPC": syscall.ENOSPC,
+	"EROFS":  syscall.EROFS,
+}
+
+// A FaultRule describes misbehaviour to inject into matching operations.
+type FaultRule struct {
+	// Pattern is matched, as by filepath.Match, against the full path and
+	// against the base name of the file operated on. Empty matches all
+	// files.
+	Pattern string `json:"pattern"`
+	// Ops are the operations the rule applies to, empty for all.
+	Ops []string `json:"ops"`
+	// Error is the name of the error to return, one of EACCES, EIO, ENOSPC
+	// and EROFS, or empty for none.
+	Error string `json:"error"`
+	// ShortWrite makes writes write only half the data before failing.
+	ShortWrite bool `json:"shortWrite"`
+	// DelayMs delays the operation, to simulate slow storage.
+	DelayMs int `json:"delayMs"`
+	// Probability is the chance of the rule applying to a matching
+	// operation, between zero and one. Zero means always.
+	Probability float64 `json:"probability"`
+	// Injected is the number of times the rule has applied. It's ignored
+	// when setting rules.
+	Injected int `json:"injected"`
+}
+
+func (r FaultRule) validate() error {
+	if _, err := filepath.Match(r.Pattern, ""); err != nil {
+		return fmt.Errorf("pattern %q: %v", r.Pattern, err)
+	}
+	for _, op := range r.Ops {
+		if !faultOps[op] {
+			return fmt.Errorf("unknown operation %q", op)
+		}
+	}
+	if _, ok := faultErrors[r.Error]; r.Error != "" && !ok {
+		return fmt.Errorf("unknown error %q", r.Error)
+	}
+	if r.DelayMs < 0 {
+		return fmt.Errorf("negative delay %d", r.DelayMs)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability %v out of range", r.Probability)
+	}
+	return nil
+}
+
+func (r FaultRule) matches(op string, names []string) bool {
+	if len(r.Ops) > 0 {
+		found := false
+		for _, o := range r.Ops {
+			if o == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Pattern == "" {
+		return true
+	}
+	for _, name := range names {
+		if ok, _ := filepath.Match(r.Pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(r.Pattern, filepath.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// What to do to an operation.
+type faultAction struct {
+	err        error
+	shortWrite bool
+}
+
+// The FaultFilesystem makes operations on the underlying filesystem fail or
+// misbehave according to a set of rules, which can be changed at any time.
+// Without rules it passes everything through untouched. Wrap it in a
+// WalkFilesystem for Walk to be affected as well.
+type FaultFilesystem struct {
+	Filesystem
+	active int32 // number of rules, accessed atomically
+	rules  []FaultRule
+	rnd    *rand.Rand
+	mut    sync.Mutex // protects rules and rnd
+}
+
+func NewFaultFilesystem(underlying Filesystem) *FaultFilesystem {
+	return &FaultFilesystem{
+		Filesystem: underlying,
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		mut:        sync.NewMutex(),
+	}
+}
+
+// SetRules replaces the current rules. The first rule applying to an
+// operation decides its fate. An empty set of rules stops all fault
+// injection.
+func (f *FaultFilesystem) SetRules(rules []FaultRule) error {
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+
+	newRules := make([]FaultRule, len(rules))
+	copy(newRules, rules)
+	for i := range newRules {
+		newRules[i].Injected = 0
+	}
+
+	f.mut.Lock()
+	f.rules = newRules
+	atomic.StoreInt32(&f.active, int32(len(newRules)))
+	f.mut.Unlock()
+	return nil
+}
+
+// Rules returns a copy of the current rules, with the number of times each
+// has applied.
+func (f *FaultFilesystem) Rules() []FaultRule {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	rules := make([]FaultRule, len(f.rules))
+	copy(rules, f.rules)
+	return rules
+}
+
+// check returns what to do to the operation on the named files, if
+// anything, after the delay of the applying rule.
+func (f *FaultFilesystem) check(op string, names ...string) *faultAction {
+	if atomic.LoadInt32(&f.active) == 0 {
+		return nil
+	}
+
+	var act *faultAction
+	var delay time.Duration
+	f.mut.Lock()
+	for i := range f.rules {
+		r := &f.rules[i]
+		if !r.matches(op, names) {
+			continue
+		}
+		if r.Probability > 0 && f.rnd.Float64() >= r.Probability {
+			continue
+		}
+		r.Injected++
+		act = &faultAction{
+			err:        faultErrors[r.Error],
+			shortWrite: r.ShortWrite,
+		}
+		delay = time.Duration(r.DelayMs) * time.Millisecond
+		break
+	}
+	f.mut.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return act
+}
+
+// fail returns the error to fail the operation on the named file with, if
+// any.
+func (f *FaultFilesystem) fail(op, name string) error {
+	if act := f.check(op, name); act != nil && act.err != nil {
+		return &os.PathError{Op: op, Path: name, Err: act.err}
+	}
+	return nil
+}
+
+func (f *FaultFilesystem) Chmod(name string, mode FileMode) error {
+	if err := f.fail(FaultOpChmod, name); err != nil {
+		return err
+	}
+	return f.Filesystem.Chmod(name, mode)
+}
+
+func (f *FaultFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := f.fail(FaultOpChtimes, name); err != nil {
+		return err
+	}
+	return f.Filesystem.Chtimes(name, atime, mtime)
+}
+
+func (f *FaultFilesystem) Create(name string) (File, error) {
+	if err := f.fail(FaultOpCreate, name); err != nil {
+		return nil, err
+	}
+	fd, err := f.Filesystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return faultFile{fd, f}, nil
+}
+
+func (f *FaultFilesystem) CreateSymlink(name, target string) error {
+	if err := f.fail(FaultOpSymlink, name); err != nil {
+		return err
+	}
+	return f.Filesystem.CreateSymlink(name, target)
+}
+
+func (f *FaultFilesystem) DirNames(name string) ([]string, error) {
+	if err := f.fail(FaultOpReaddir, name); err != nil {
+		return nil, err
+	}
+	return f.Filesystem.DirNames(name)
+}
+
+func (f *FaultFilesystem) Glob(pattern string) ([]string, error) {
+	if err := f.fail(FaultOpReaddir, filepath.Dir(pattern)); err != nil {
+		return nil, err
+	}
+	return f.Filesystem.Glob(pattern)
+}
+
+func (f *FaultFilesystem) Hide(name string) error {
+	if err := f.fail(FaultOpChmod, name); err != nil {
+		return err
+	}
+	return f.Filesystem.Hide(name)
+}
+
+func (f *FaultFilesystem) Lstat(name string) (FileInfo, error) {
+	if err := f.fail(FaultOpStat, name); err != nil {
+		return nil, err
+	}
+	return f.Filesystem.Lstat(name)
+}
+
+func (f *FaultFilesystem) Mkdir(name string, perm FileMode) error {
+	if err := f.fail(FaultOpMkdir, name); err != nil {
+		return err
+	}
+	return f.Filesystem.Mkdir(name, perm)
+}
+
+func (f *FaultFilesystem) MkdirAll(name string, perm FileMode) error {
+	if err := f.fail(FaultOpMkdir, name); err != nil {
+		return err
+	}
+	return f.Filesystem.MkdirAll(name, perm)
+}
+
+func (f *FaultFilesystem) Open(name string) (File, error) {
+	if err := f.fail(FaultOpOpen, name); err != nil {
+		return nil, err
+	}
+	fd, err := f.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return faultFile{fd, f}, nil
+}
+
+func (f *FaultFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	op := FaultOpOpen
+	if flags&os.O_CREATE != 0 {
+		op = FaultOpCreate
+	}
+	if err := f.fail(op, name); err != nil {
+		return nil, err
+	}
+	fd, err := f.Filesystem.OpenFile(name, flags, mode)
+	if err != nil {
+		return nil, err
+	}
+	return faultFile{fd, f}, nil
+}
+
+func (f *FaultFilesystem) ReadSymlink(name string) (string, error) {
+	if err := f.fail(FaultOpStat, name); err != nil {
+		return "", err
+	}
+	return f.Filesystem.ReadSymlink(name)
+}
+
+func (f *FaultFilesystem) Remove(name string) error {
+	if err := f.fail(FaultOpRemove, name); err != nil {
+		return err
+	}
+	return f.Filesystem.Remove(name)
+}
+
+func (f *FaultFilesystem) RemoveAll(name string) error {
+	if err := f.fail(FaultOpRemove, name); err != nil {
+		return err
+	}
+	return f.Filesystem.RemoveAll(name)
+}
+
+func (f *FaultFilesystem) Rename(oldname, newname string) error {
+	if act := f.check(FaultOpRename, oldname, newname); act != nil && act.err != nil {
+		return &os.LinkError{Op: FaultOpRename, Old: oldname, New: newname, Err: act.err}
+	}
+	return f.Filesystem.Rename(oldname, newname)
+}
+
+func (f *FaultFilesystem) Stat(name string) (FileInfo, error) {
+	if err := f.fail(FaultOpStat, name); err != nil {
+		return nil, err
+	}
+	return f.Filesystem.Stat(name)
+}
+
+// faultFile is a File of a FaultFilesystem.
+type faultFile struct {
+	File
+	fs *FaultFilesystem
+}
+
+func (f faultFile) Close() error {
+	err := f.File.Close()
+	// The file is closed regardless, so as not to leak it.
+	if ferr := f.fs.fail(FaultOpClose, f.Name()); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+func (f faultFile) Read(p []byte) (int, error) {
+	if err := f.fs.fail(FaultOpRead, f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+func (f faultFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.fs.fail(FaultOpRead, f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.ReadAt(p, off)
+}
+
+func (f faultFile) Stat() (FileInfo, error) {
+	if err := f.fs.fail(FaultOpStat, f.Name()); err != nil {
+		return nil, err
+	}
+	return f.File.Stat()
+}
+
+func (f faultFile) Sync() error {
+	if err := f.fs.fail(FaultOpSync, f.Name()); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+func (f faultFile) Truncate(size int64) error {
+	if err := f.fs.fail(FaultOpWrite, f.Name()); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+func (f faultFile) Write(p []byte) (int, error) {
+	return f.write(p, f.File.Write)
+}
+
+func (f faultFile) WriteAt(p []byte, off int64) (int, error) {
+	return f.write(p, func(p []byte) (int, error) {
+		return f.File.WriteAt(p, off)
+	})
+}
+
+func (f faultFile) write(p []byte, write func([]byte) (int, error)) (int, error) {
+	act := f.fs.check(FaultOpWrite, f.Name())
+	if act == nil {
+		return write(p)
+	}
+
+	var err error
+	if act.err != nil {
+		err = &os.PathError{Op: FaultOpWrite, Path: f.Name(), Err: act.err}
+	}
+	if !act.shortWrite {
+		if err != nil {
+			return 0, err
+		}
+		return write(p)
+	}
+
+	// Write the first half for real, like a disk filling up midway.
+	n, werr := write(p[:len(p)/2])
+	if werr != nil {
+		return n, werr
+	}
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
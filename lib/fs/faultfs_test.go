@@ -0,0 +1,139 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFaultFilesystemRules(t *testing.T) {
+	mem := NewMemoryFilesystem(MemoryOptions{})
+	f := NewFaultFilesystem(mem)
+
+	writeMemFile(t, mem, "a.txt", "a")
+	writeMemFile(t, mem, "b.dat", "b")
+
+	// Without rules, everything passes through.
+	if _, err := f.Lstat("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.SetRules([]FaultRule{
+		{Pattern: "*.txt", Ops: []string{FaultOpRemove}, Error: "EACCES"},
+		{Pattern: "b.*", Ops: []string{FaultOpRename}, Error: "EROFS"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Remove("a.txt"); !os.IsPermission(err) {
+		t.Errorf("remove should fail with permission denied, got %v", err)
+	}
+	if _, err := f.Lstat("a.txt"); err != nil {
+		t.Errorf("stat should not be affected, got %v", err)
+	}
+
+	// Renames are matched on both names.
+	err = f.Rename("a.txt", "b.txt")
+	if lerr, ok := err.(*os.LinkError); !ok || lerr.Err != syscall.EROFS {
+		t.Errorf("rename should fail with EROFS, got %v", err)
+	}
+	if _, err := mem.Lstat("a.txt"); err != nil {
+		t.Error("failed rename should leave the file in place")
+	}
+
+	if err := f.Remove("b.dat"); err != nil {
+		t.Errorf("remove of other files should work, got %v", err)
+	}
+
+	rules := f.Rules()
+	if rules[0].Injected != 1 || rules[1].Injected != 1 {
+		t.Errorf("unexpected injection counts %d, %d", rules[0].Injected, rules[1].Injected)
+	}
+
+	// Clearing the rules stops the faults.
+	if err := f.SetRules(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("a.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFaultFilesystemWrites(t *testing.T) {
+	mem := NewMemoryFilesystem(MemoryOptions{})
+	f := NewFaultFilesystem(mem)
+
+	fd, err := f.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	if err := f.SetRules([]FaultRule{{Ops: []string{FaultOpWrite}, ShortWrite: true}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := fd.WriteAt([]byte("abcd"), 0)
+	if n != 2 || err != io.ErrShortWrite {
+		t.Errorf("expected a short write, got %d, %v", n, err)
+	}
+
+	if err := f.SetRules([]FaultRule{{Ops: []string{FaultOpWrite}, Error: "ENOSPC"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte("efgh"))
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.ENOSPC {
+		t.Errorf("write should fail with ENOSPC, got %v", err)
+	}
+
+	if data := readMemFile(t, mem, "file"); data != "ab" {
+		t.Errorf("got %q, expected %q", data, "ab")
+	}
+}
+
+func TestFaultFilesystemProbability(t *testing.T) {
+	f := NewFaultFilesystem(NewMemoryFilesystem(MemoryOptions{}))
+	if err := f.SetRules([]FaultRule{{Ops: []string{FaultOpMkdir}, Error: "EIO", Probability: 0.5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if err := f.MkdirAll("dir", 0755); err != nil {
+			failed++
+		}
+	}
+	if failed < 350 || failed > 650 {
+		t.Errorf("%d of 1000 operations failed, expected about half", failed)
+	}
+	if injected := f.Rules()[0].Injected; injected != failed {
+		t.Errorf("%d injections counted, expected %d", injected, failed)
+	}
+}
+
+func TestFaultFilesystemInvalidRules(t *testing.T) {
+	f := NewFaultFilesystem(NewMemoryFilesystem(MemoryOptions{}))
+
+	invalid := []FaultRule{
+		{Pattern: "[", Error: "EIO"},
+		{Ops: []string{"frobnicate"}},
+		{Error: "EWHATEVER"},
+		{DelayMs: -1},
+		{Probability: 1.5},
+	}
+	for _, r := range invalid {
+		if err := f.SetRules([]FaultRule{r}); err == nil {
+			t.Errorf("rule %+v should be invalid", r)
+		}
+	}
+	if len(f.Rules()) != 0 {
+		t.Error("invalid rules should not be set")
+	}
+}
@@ -7,6 +7,7 @@
 package fs
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -20,11 +21,16 @@ type Filesystem interface {
 	Create(name string) (File, error)
 	CreateSymlink(name, target string) error
 	DirNames(name string) ([]string, error)
+	Glob(pattern string) ([]string, error)
+	Hide(name string) error
 	Lstat(name string) (FileInfo, error)
 	Mkdir(name string, perm FileMode) error
+	MkdirAll(name string, perm FileMode) error
 	Open(name string) (File, error)
+	OpenFile(name string, flags int, mode FileMode) (File, error)
 	ReadSymlink(name string) (string, error)
 	Remove(name string) error
+	RemoveAll(name string) error
 	Rename(oldname, newname string) error
 	Stat(name string) (FileInfo, error)
 	SymlinksSupported() bool
@@ -35,10 +41,14 @@ type Filesystem interface {
 // smaller interface than os.File
 type File interface {
 	io.Reader
+	io.ReaderAt
+	io.Writer
 	io.WriterAt
 	io.Closer
+	Name() string
 	Truncate(size int64) error
 	Stat() (FileInfo, error)
+	Sync() error
 }
 
 // The FileInfo interface is almost the same as os.FileInfo, but with the
@@ -62,6 +72,13 @@ type FileMode uint32
 // ModePerm is the equivalent of os.ModePerm
 const ModePerm = FileMode(os.ModePerm)
 
+// The equivalents of os.ModeSetuid, os.ModeSetgid and os.ModeSticky
+const (
+	ModeSetuid = FileMode(os.ModeSetuid)
+	ModeSetgid = FileMode(os.ModeSetgid)
+	ModeSticky = FileMode(os.ModeSticky)
+)
+
 // DefaultFilesystem is the fallback to use when nothing explicitly has
 // been passed.
 var DefaultFilesystem Filesystem = NewWalkFilesystem(NewBasicFilesystem())
@@ -71,8 +88,13 @@ var DefaultFilesystem Filesystem = NewWalkFilesystem(NewBasicFilesystem())
 // as an error by any function.
 var SkipDir = filepath.SkipDir
 
+var errNotSupported = errors.New("symlinks not supported")
+
 // IsExist is the equivalent of os.IsExist
 var IsExist = os.IsExist
 
 // IsNotExist is the equivalent of os.IsNotExist
 var IsNotExist = os.IsNotExist
+
+// IsPermission is the equivalent of os.IsPermission
+var IsPermission = os.IsPermission
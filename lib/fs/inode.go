@@ -0,0 +1,22 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+// Inode returns the inode number of the file, or zero when the file system
+// doesn't provide one.
+func Inode(info FileInfo) uint64 {
+	for {
+		switch i := info.(type) {
+		case mtimeFileInfo:
+			info = i.FileInfo
+		case fsFileInfo:
+			return inode(i.Sys())
+		default:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !windows
+
+package fs
+
+import "syscall"
+
+func inode(sys interface{}) uint64 {
+	if st, ok := sys.(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,823 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// How many symlinks we follow when resolving a path before giving up, as
+// Linux does.
+const maxSymlinkHops = 40
+
+// MemoryOptions select the behavior of a MemoryFilesystem, so that it can
+// stand in for the various kinds of filesystems out there.
+type MemoryOptions struct {
+	// CaseInsensitive makes names that differ only in case refer to the
+	// same file, as on Windows and macOS. Files keep the case they were
+	// created with.
+	CaseInsensitive bool
+	// MtimeGranularity is the precision of modification times, which are
+	// truncated to it when set. For example FAT has a two second
+	// granularity. Zero means nanosecond precision.
+	MtimeGranularity time.Duration
+	// NoSymlinks makes the filesystem not support symlinks, as on Windows.
+	NoSymlinks bool
+	// EnforcePermissions makes operations the permission bits don't allow
+	// fail with a permission error, as for a non root user on Unix.
+	// Otherwise permission bits are recorded but not enforced.
+	EnforcePermissions bool
+}
+
+// The MemoryFilesystem keeps an entire filesystem tree in memory. Paths may
+// be absolute or relative; both are taken to be relative to the root of
+// the in memory tree.
+type MemoryFilesystem struct {
+	opts MemoryOptions
+	root *memNode
+	mut  sync.Mutex
+}
+
+// A memNode is a file, directory or symlink.
+type memNode struct {
+	name     string // as created, possibly differing in case from the key
+	mode     os.FileMode
+	mtime    time.Time
+	data     []byte
+	target   string
+	children map[string]*memNode // keyed by memKey(name)
+}
+
+func NewMemoryFilesystem(opts MemoryOptions) *MemoryFilesystem {
+	f := &MemoryFilesystem{
+		opts: opts,
+		mut:  sync.NewMutex(),
+	}
+	f.root = &memNode{
+		mode:     os.ModeDir | 0777,
+		mtime:    f.now(),
+		children: make(map[string]*memNode),
+	}
+	return f
+}
+
+func (f *MemoryFilesystem) Chmod(name string, mode FileMode) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	const changeable = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	node.mode = node.mode&^changeable | os.FileMode(mode)&changeable
+	return nil
+}
+
+func (f *MemoryFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	node.mtime = f.truncate(mtime)
+	return nil
+}
+
+func (f *MemoryFilesystem) Create(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (f *MemoryFilesystem) CreateSymlink(name, target string) error {
+	if f.opts.NoSymlinks {
+		return errNotSupported
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	_, err := f.create("symlink", name, &memNode{
+		mode:   os.ModeSymlink | 0777,
+		target: target,
+	})
+	return err
+}
+
+func (f *MemoryFilesystem) DirNames(name string) ([]string, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, pathError("readdirent", name, syscall.ENOTDIR)
+	}
+	if !f.permitted(node, 0400) {
+		return nil, pathError("open", name, os.ErrPermission)
+	}
+
+	names := make([]string, 0, len(node.children))
+	for _, child := range node.children {
+		names = append(names, child.name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *MemoryFilesystem) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	return f.glob(pattern), nil
+}
+
+func (f *MemoryFilesystem) glob(pattern string) []string {
+	if !hasMeta(pattern) {
+		if _, err := f.resolve("lstat", pattern, false); err != nil {
+			return nil
+		}
+		return []string{pattern}
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	dirs := []string{dir}
+	if hasMeta(dir) {
+		dirs = f.glob(dir)
+	}
+
+	var matches []string
+	for _, dir := range dirs {
+		node, err := f.resolve("open", dir, true)
+		if err != nil || !node.mode.IsDir() || !f.permitted(node, 0400) {
+			continue
+		}
+		var names []string
+		for _, child := range node.children {
+			if ok, _ := filepath.Match(f.memKey(file), f.memKey(child.name)); ok {
+				names = append(names, child.name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			matches = append(matches, filepath.Join(dir, name))
+		}
+	}
+	return matches
+}
+
+// Hide does nothing, as a file is hidden by its name outside of Windows.
+func (f *MemoryFilesystem) Hide(name string) error {
+	_, err := f.Lstat(name)
+	return err
+}
+
+func (f *MemoryFilesystem) Lstat(name string) (FileInfo, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(filepath.Base(name)), nil
+}
+
+func (f *MemoryFilesystem) Mkdir(name string, perm FileMode) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	_, err := f.create("mkdir", name, &memNode{
+		mode:     os.ModeDir | os.FileMode(perm)&os.ModePerm,
+		children: make(map[string]*memNode),
+	})
+	return err
+}
+
+func (f *MemoryFilesystem) MkdirAll(name string, perm FileMode) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	parts := splitPath(name)
+	for i := range parts {
+		path := filepath.Join(parts[:i+1]...)
+		if filepath.IsAbs(name) {
+			path = filepath.VolumeName(name) + string(filepath.Separator) + path
+		}
+		node, err := f.resolve("mkdir", path, true)
+		if err == nil {
+			if !node.mode.IsDir() {
+				return pathError("mkdir", path, syscall.ENOTDIR)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		_, err = f.create("mkdir", path, &memNode{
+			mode:     os.ModeDir | os.FileMode(perm)&os.ModePerm,
+			children: make(map[string]*memNode),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *MemoryFilesystem) Open(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *MemoryFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	access := flags & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	read := access == os.O_RDONLY || access == os.O_RDWR
+	write := access == os.O_WRONLY || access == os.O_RDWR
+
+	node, err := f.resolve("open", name, true)
+	switch {
+	case os.IsNotExist(err) && flags&os.O_CREATE != 0:
+		node, err = f.create("open", name, &memNode{
+			mode: os.FileMode(mode) & os.ModePerm,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case err != nil:
+		return nil, err
+
+	case flags&os.O_CREATE != 0 && flags&os.O_EXCL != 0:
+		return nil, pathError("open", name, os.ErrExist)
+
+	case node.mode.IsDir() && (write || flags&os.O_TRUNC != 0):
+		return nil, pathError("open", name, syscall.EISDIR)
+
+	default:
+		if read && !f.permitted(node, 0400) || write && !f.permitted(node, 0200) {
+			return nil, pathError("open", name, os.ErrPermission)
+		}
+		if flags&os.O_TRUNC != 0 && write {
+			node.data = nil
+			node.mtime = f.now()
+		}
+	}
+
+	return &memFile{
+		fs:     f,
+		node:   node,
+		name:   name,
+		read:   read,
+		write:  write,
+		append: flags&os.O_APPEND != 0,
+	}, nil
+}
+
+func (f *MemoryFilesystem) ReadSymlink(name string) (string, error) {
+	if f.opts.NoSymlinks {
+		return "", errNotSupported
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&os.ModeSymlink == 0 {
+		return "", pathError("readlink", name, syscall.EINVAL)
+	}
+	return node.target, nil
+}
+
+func (f *MemoryFilesystem) Remove(name string) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	parent, node, err := f.resolveEntry("remove", name)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() && len(node.children) > 0 {
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+	return f.unlink("remove", name, parent, node)
+}
+
+func (f *MemoryFilesystem) RemoveAll(name string) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	parent, node, err := f.resolveEntry("remove", name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !f.removable(node) {
+		return pathError("remove", name, os.ErrPermission)
+	}
+	return f.unlink("remove", name, parent, node)
+}
+
+func (f *MemoryFilesystem) Rename(oldname, newname string) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	oldParent, node, err := f.resolveEntry("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newParent, err := f.resolveParent("rename", newname)
+	if err != nil {
+		return err
+	}
+	if !f.permitted(oldParent, 0200) || !f.permitted(newParent, 0200) {
+		return pathError("rename", oldname, os.ErrPermission)
+	}
+
+	// A directory can't be moved into itself.
+	for _, ancestor := range f.ancestors(newname) {
+		if ancestor == node {
+			return pathError("rename", oldname, syscall.EINVAL)
+		}
+	}
+
+	base := filepath.Base(newname)
+	key := f.memKey(base)
+	if existing, ok := newParent.children[key]; ok && existing != node {
+		switch {
+		case node.mode.IsDir() && !existing.mode.IsDir():
+			return pathError("rename", newname, syscall.ENOTDIR)
+		case !node.mode.IsDir() && existing.mode.IsDir():
+			return pathError("rename", newname, syscall.EISDIR)
+		case existing.mode.IsDir() && len(existing.children) > 0:
+			return pathError("rename", newname, syscall.ENOTEMPTY)
+		}
+	}
+
+	now := f.now()
+	delete(oldParent.children, f.memKey(node.name))
+	oldParent.mtime = now
+	node.name = base
+	newParent.children[key] = node
+	newParent.mtime = now
+	return nil
+}
+
+func (f *MemoryFilesystem) Stat(name string) (FileInfo, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	node, err := f.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(filepath.Base(name)), nil
+}
+
+func (f *MemoryFilesystem) SymlinksSupported() bool {
+	return !f.opts.NoSymlinks
+}
+
+func (f *MemoryFilesystem) Walk(root string, walkFn WalkFunc) error {
+	return NewWalkFilesystem(f).Walk(root, walkFn)
+}
+
+// resolve returns the node at the given path, following symlinks on the
+// way and, if follow is set, at the end.
+func (f *MemoryFilesystem) resolve(op, name string, follow bool) (*memNode, error) {
+	return f.resolveFrom(op, name, []*memNode{f.root}, splitPath(name), follow, 0)
+}
+
+// resolveFrom resolves the path parts starting in the last of dirs, which
+// are the directories leading up to it from the root.
+func (f *MemoryFilesystem) resolveFrom(op, name string, dirs []*memNode, parts []string, follow bool, hops int) (*memNode, error) {
+	node := dirs[len(dirs)-1]
+	for i, part := range parts {
+		if !node.mode.IsDir() {
+			return nil, pathError(op, name, syscall.ENOTDIR)
+		}
+		if !f.permitted(node, 0100) {
+			return nil, pathError(op, name, os.ErrPermission)
+		}
+
+		if part == ".." {
+			// The parent of the root is the root.
+			if len(dirs) > 1 {
+				dirs = dirs[:len(dirs)-1]
+			}
+			node = dirs[len(dirs)-1]
+			continue
+		}
+
+		child, ok := node.children[f.memKey(part)]
+		if !ok {
+			return nil, pathError(op, name, os.ErrNotExist)
+		}
+
+		last := i == len(parts)-1
+		if child.mode&os.ModeSymlink != 0 && (!last || follow) {
+			hops++
+			if hops > maxSymlinkHops {
+				return nil, pathError(op, name, syscall.ELOOP)
+			}
+			start := dirs
+			if filepath.IsAbs(child.target) {
+				start = []*memNode{f.root}
+			}
+			var err error
+			child, err = f.resolveFrom(op, name, start, splitPath(child.target), true, hops)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		node = child
+		dirs = append(dirs[:len(dirs):len(dirs)], node)
+	}
+	return node, nil
+}
+
+// resolveParent returns the directory that the named entry is, or would
+// be, in.
+func (f *MemoryFilesystem) resolveParent(op, name string) (*memNode, error) {
+	parts := splitPath(name)
+	if len(parts) == 0 {
+		return nil, pathError(op, name, syscall.EINVAL)
+	}
+	parent, err := f.resolveFrom(op, name, []*memNode{f.root}, parts[:len(parts)-1], true, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !parent.mode.IsDir() {
+		return nil, pathError(op, name, syscall.ENOTDIR)
+	}
+	return parent, nil
+}
+
+// resolveEntry returns the named entry itself, not following a symlink,
+// and the directory it's in.
+func (f *MemoryFilesystem) resolveEntry(op, name string) (*memNode, *memNode, error) {
+	parent, err := f.resolveParent(op, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	node, ok := parent.children[f.memKey(filepath.Base(name))]
+	if !ok {
+		return nil, nil, pathError(op, name, os.ErrNotExist)
+	}
+	return parent, node, nil
+}
+
+// ancestors returns the directories leading up to the named entry, root
+// first.
+func (f *MemoryFilesystem) ancestors(name string) []*memNode {
+	nodes := []*memNode{f.root}
+	node := f.root
+	for _, part := range splitPath(filepath.Dir(name)) {
+		child, ok := node.children[f.memKey(part)]
+		if !ok {
+			break
+		}
+		nodes = append(nodes, child)
+		node = child
+	}
+	return nodes
+}
+
+// create adds the node as the named entry, which must not exist.
+func (f *MemoryFilesystem) create(op, name string, node *memNode) (*memNode, error) {
+	parent, err := f.resolveParent(op, name)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(name)
+	key := f.memKey(base)
+	if _, ok := parent.children[key]; ok {
+		return nil, pathError(op, name, os.ErrExist)
+	}
+	if !f.permitted(parent, 0200) {
+		return nil, pathError(op, name, os.ErrPermission)
+	}
+
+	now := f.now()
+	node.name = base
+	node.mtime = now
+	parent.children[key] = node
+	parent.mtime = now
+	return node, nil
+}
+
+func (f *MemoryFilesystem) unlink(op, name string, parent, node *memNode) error {
+	if !f.permitted(parent, 0200) {
+		return pathError(op, name, os.ErrPermission)
+	}
+	delete(parent.children, f.memKey(node.name))
+	parent.mtime = f.now()
+	return nil
+}
+
+// removable returns true if the node's contents can be removed, all the
+// way down.
+func (f *MemoryFilesystem) removable(node *memNode) bool {
+	if len(node.children) == 0 {
+		return true
+	}
+	if !f.permitted(node, 0300) {
+		return false
+	}
+	for _, child := range node.children {
+		if !f.removable(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// permitted returns true if the node's permission bits allow the given
+// access, or when permissions aren't enforced.
+func (f *MemoryFilesystem) permitted(node *memNode, bits os.FileMode) bool {
+	return !f.opts.EnforcePermissions || node.mode&bits == bits
+}
+
+func (f *MemoryFilesystem) memKey(name string) string {
+	if f.opts.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (f *MemoryFilesystem) now() time.Time {
+	return f.truncate(time.Now())
+}
+
+func (f *MemoryFilesystem) truncate(t time.Time) time.Time {
+	if f.opts.MtimeGranularity > 0 {
+		return t.Truncate(f.opts.MtimeGranularity)
+	}
+	return t
+}
+
+func (n *memNode) info(name string) FileInfo {
+	size := int64(len(n.data))
+	if n.mode&os.ModeSymlink != 0 {
+		size = int64(len(n.target))
+	}
+	return memFileInfo{
+		name:  name,
+		mode:  n.mode,
+		size:  size,
+		mtime: n.mtime,
+	}
+}
+
+// memFile is an open file in a MemoryFilesystem.
+type memFile struct {
+	fs     *MemoryFilesystem
+	node   *memNode
+	name   string
+	offset int64
+	read   bool
+	write  bool
+	append bool
+	closed bool
+}
+
+func (f *memFile) Close() error {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if f.closed {
+		return pathError("close", f.name, os.ErrClosed)
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Read(bs []byte) (int, error) {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	n, err := f.readAt("read", bs, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(bs []byte, offset int64) (int, error) {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	n, err := f.readAt("read", bs, offset)
+	if err == nil && n < len(bs) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(op string, bs []byte, offset int64) (int, error) {
+	if err := f.check(op, f.read); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, pathError(op, f.name, syscall.EINVAL)
+	}
+	if offset >= int64(len(f.node.data)) {
+		if len(bs) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(bs, f.node.data[offset:]), nil
+}
+
+func (f *memFile) Stat() (FileInfo, error) {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if f.closed {
+		return nil, pathError("stat", f.name, os.ErrClosed)
+	}
+	return f.node.info(filepath.Base(f.name)), nil
+}
+
+// Sync does nothing, as there is no more stable storage to flush to.
+func (f *memFile) Sync() error {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if f.closed {
+		return pathError("sync", f.name, os.ErrClosed)
+	}
+	return nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if err := f.check("truncate", f.write); err != nil {
+		return err
+	}
+	if size < 0 {
+		return pathError("truncate", f.name, syscall.EINVAL)
+	}
+	if size <= int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size]
+	} else {
+		f.node.data = append(f.node.data, make([]byte, size-int64(len(f.node.data)))...)
+	}
+	f.node.mtime = f.fs.now()
+	return nil
+}
+
+func (f *memFile) Write(bs []byte) (int, error) {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+	n, err := f.writeAt("write", bs, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(bs []byte, offset int64) (int, error) {
+	f.fs.mut.Lock()
+	defer f.fs.mut.Unlock()
+
+	if f.append {
+		return 0, pathError("write", f.name, syscall.EINVAL)
+	}
+	return f.writeAt("write", bs, offset)
+}
+
+func (f *memFile) writeAt(op string, bs []byte, offset int64) (int, error) {
+	if err := f.check(op, f.write); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, pathError(op, f.name, syscall.EINVAL)
+	}
+	if end := offset + int64(len(bs)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[offset:], bs)
+	f.node.mtime = f.fs.now()
+	return len(bs), nil
+}
+
+// check returns the error for an operation on the file, if it's closed, a
+// directory or not opened for the kind of access.
+func (f *memFile) check(op string, allowed bool) error {
+	switch {
+	case f.closed:
+		return pathError(op, f.name, os.ErrClosed)
+	case f.node.mode.IsDir():
+		return pathError(op, f.name, syscall.EISDIR)
+	case !allowed:
+		return pathError(op, f.name, syscall.EBADF)
+	}
+	return nil
+}
+
+// memFileInfo is the FileInfo of a memNode at the time of the Stat call.
+type memFileInfo struct {
+	name  string
+	mode  os.FileMode
+	size  int64
+	mtime time.Time
+}
+
+func (i memFileInfo) Name() string {
+	return i.name
+}
+
+func (i memFileInfo) Mode() FileMode {
+	return FileMode(i.mode)
+}
+
+func (i memFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i memFileInfo) ModTime() time.Time {
+	return i.mtime
+}
+
+func (i memFileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+func (i memFileInfo) IsRegular() bool {
+	return i.mode.IsRegular()
+}
+
+func (i memFileInfo) IsSymlink() bool {
+	return i.mode&os.ModeSymlink != 0
+}
+
+// splitPath returns the components of the cleaned path, ignoring any
+// volume name and leading separator.
+func splitPath(name string) []string {
+	name = filepath.Clean(name)
+	name = strings.TrimPrefix(name, filepath.VolumeName(name))
+	name = strings.Trim(name, string(filepath.Separator))
+	if name == "" || name == "." {
+		return nil
+	}
+	return strings.Split(name, string(filepath.Separator))
+}
+
+func hasMeta(path string) bool {
+	magicChars := `*?[`
+	if runtime.GOOS != "windows" {
+		magicChars = `*?[\`
+	}
+	return strings.ContainsAny(path, magicChars)
+}
+
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	default:
+		return path[:len(path)-1] // chop off trailing separator
+	}
+}
+
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
@@ -0,0 +1,321 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeMemFile(t *testing.T, f *MemoryFilesystem, name, data string) {
+	fd, err := f.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readMemFile(t *testing.T, f *MemoryFilesystem, name string) string {
+	fd, err := f.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	bs, err := ioutil.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
+
+func TestMemoryFilesystemFiles(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{})
+
+	if err := f.MkdirAll("a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeMemFile(t, f, "a/b/file", "hello")
+
+	if data := readMemFile(t, f, "a/b/file"); data != "hello" {
+		t.Errorf("got %q, expected %q", data, "hello")
+	}
+
+	info, err := f.Lstat("a/b/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "file" || info.Size() != 5 || !info.IsRegular() {
+		t.Errorf("unexpected file info %v", info)
+	}
+
+	// Writing past the end extends the file, as does truncating.
+	fd, err := f.OpenFile("a/b/file", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte("!"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if data := readMemFile(t, f, "a/b/file"); data != "hello\x00!\x00" {
+		t.Errorf("got %q", data)
+	}
+
+	if _, err := f.OpenFile("a/b/file", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); !os.IsExist(err) {
+		t.Errorf("exclusive create of existing file should fail, got %v", err)
+	}
+	if _, err := f.Open("a/b/missing"); !os.IsNotExist(err) {
+		t.Errorf("open of missing file should fail, got %v", err)
+	}
+	if _, err := f.Create("a/missing/file"); !os.IsNotExist(err) {
+		t.Errorf("create in missing directory should fail, got %v", err)
+	}
+
+	names, err := f.DirNames("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"file"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	if err := f.Remove("a"); err == nil {
+		t.Error("removing a non empty directory should fail")
+	}
+	if err := f.RemoveAll("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Lstat("a"); !os.IsNotExist(err) {
+		t.Errorf("directory should be gone, got %v", err)
+	}
+}
+
+func TestMemoryFilesystemRename(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{})
+
+	f.MkdirAll("dir/sub", 0755)
+	f.Mkdir("other", 0755)
+	writeMemFile(t, f, "dir/file", "one")
+	writeMemFile(t, f, "other/file", "two")
+
+	// A file replaces a file.
+	if err := f.Rename("dir/file", "other/file"); err != nil {
+		t.Fatal(err)
+	}
+	if data := readMemFile(t, f, "other/file"); data != "one" {
+		t.Errorf("got %q, expected %q", data, "one")
+	}
+
+	// A file doesn't replace a directory and a directory can't be moved
+	// into itself.
+	if err := f.Rename("other/file", "dir/sub"); err == nil {
+		t.Error("replacing a directory with a file should fail")
+	}
+	if err := f.Rename("dir", "dir/sub/dir"); err == nil {
+		t.Error("moving a directory into itself should fail")
+	}
+
+	if err := f.Rename("dir", "moved"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := f.Stat("moved/sub"); err != nil || !info.IsDir() {
+		t.Errorf("directory contents should have moved along, got %v", err)
+	}
+}
+
+func TestMemoryFilesystemCaseInsensitive(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{CaseInsensitive: true})
+
+	writeMemFile(t, f, "File.txt", "hello")
+	if data := readMemFile(t, f, "FILE.TXT"); data != "hello" {
+		t.Errorf("got %q, expected %q", data, "hello")
+	}
+	if err := f.Mkdir("file.TXT", 0755); !os.IsExist(err) {
+		t.Errorf("name differing in case should exist, got %v", err)
+	}
+
+	// A case only rename changes the name as listed.
+	if err := f.Rename("file.txt", "FILE.txt"); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := f.DirNames(".")
+	if !reflect.DeepEqual(names, []string{"FILE.txt"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	matches, err := f.Glob("f*.TXT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"FILE.txt"}) {
+		t.Errorf("unexpected matches %v", matches)
+	}
+
+	sensitive := NewMemoryFilesystem(MemoryOptions{})
+	writeMemFile(t, sensitive, "File.txt", "hello")
+	if _, err := sensitive.Lstat("FILE.TXT"); !os.IsNotExist(err) {
+		t.Errorf("names should be case sensitive, got %v", err)
+	}
+}
+
+func TestMemoryFilesystemMtimeGranularity(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{MtimeGranularity: 2 * time.Second})
+
+	writeMemFile(t, f, "file", "hello")
+	mtime := time.Unix(1234567891, 123456789)
+	if err := f.Chtimes("file", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := f.Lstat("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Unix(1234567890, 0); !info.ModTime().Equal(expected) {
+		t.Errorf("got mtime %v, expected %v", info.ModTime(), expected)
+	}
+}
+
+func TestMemoryFilesystemSymlinks(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{})
+
+	f.MkdirAll("dir/sub", 0755)
+	writeMemFile(t, f, "dir/sub/file", "hello")
+
+	if err := f.CreateSymlink("abs", "/dir/sub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.CreateSymlink("dir/rel", filepath.Join("..", "dir", "sub", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.CreateSymlink("loop", "loop"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data := readMemFile(t, f, "abs/file"); data != "hello" {
+		t.Errorf("got %q through absolute symlink", data)
+	}
+	if data := readMemFile(t, f, "dir/rel"); data != "hello" {
+		t.Errorf("got %q through relative symlink", data)
+	}
+
+	info, err := f.Lstat("dir/rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsSymlink() {
+		t.Error("Lstat should not follow the symlink")
+	}
+	if info, err := f.Stat("dir/rel"); err != nil || !info.IsRegular() {
+		t.Errorf("Stat should follow the symlink, got %v", err)
+	}
+	if target, err := f.ReadSymlink("abs"); err != nil || target != "/dir/sub" {
+		t.Errorf("got target %q, %v", target, err)
+	}
+	if _, err := f.Stat("loop"); err == nil {
+		t.Error("symlink loop should fail")
+	}
+
+	// Removing a symlink doesn't remove the target.
+	if err := f.Remove("abs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Lstat("dir/sub/file"); err != nil {
+		t.Error(err)
+	}
+
+	nolinks := NewMemoryFilesystem(MemoryOptions{NoSymlinks: true})
+	if nolinks.SymlinksSupported() {
+		t.Error("symlinks should not be supported")
+	}
+	if err := nolinks.CreateSymlink("link", "target"); err == nil {
+		t.Error("creating a symlink should fail")
+	}
+}
+
+func TestMemoryFilesystemPermissions(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{EnforcePermissions: true})
+
+	f.Mkdir("dir", 0755)
+	writeMemFile(t, f, "dir/file", "hello")
+
+	if err := f.Chmod("dir/file", 0444); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.OpenFile("dir/file", os.O_WRONLY, 0); !os.IsPermission(err) {
+		t.Errorf("writing a read only file should fail, got %v", err)
+	}
+	if data := readMemFile(t, f, "dir/file"); data != "hello" {
+		t.Errorf("got %q, expected %q", data, "hello")
+	}
+
+	if err := f.Chmod("dir", 0555); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("dir/file"); !os.IsPermission(err) {
+		t.Errorf("removing from a read only directory should fail, got %v", err)
+	}
+	if _, err := f.Create("dir/other"); !os.IsPermission(err) {
+		t.Errorf("creating in a read only directory should fail, got %v", err)
+	}
+
+	if err := f.Chmod("dir", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Lstat("dir/file"); !os.IsPermission(err) {
+		t.Errorf("traversing an inaccessible directory should fail, got %v", err)
+	}
+
+	lax := NewMemoryFilesystem(MemoryOptions{})
+	lax.Mkdir("dir", 0555)
+	if _, err := lax.Create("dir/file"); err != nil {
+		t.Errorf("permissions should not be enforced, got %v", err)
+	}
+}
+
+func TestMemoryFilesystemWalk(t *testing.T) {
+	f := NewMemoryFilesystem(MemoryOptions{})
+
+	f.MkdirAll("root/b/c", 0755)
+	writeMemFile(t, f, "root/a", "a")
+	writeMemFile(t, f, "root/b/c/d", "d")
+
+	var seen []string
+	err := f.Walk("root", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		seen = append(seen, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"root",
+		filepath.Join("root", "a"),
+		filepath.Join("root", "b"),
+		filepath.Join("root", "b", "c"),
+		filepath.Join("root", "b", "c", "d"),
+	}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("walked %v, expected %v", seen, expected)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Try to keep this entire operation atomic-like. We shouldn't be doing this
+// often enough that there is any contention on this lock.
+var renameLock = sync.NewMutex()
+
+// TryRename renames a file, leaving source file intact in case of failure.
+// Tries hard to succeed on various systems by temporarily tweaking directory
+// permissions and removing the destination file when necessary. Use it
+// rather than Rename where the source is the only copy of the data, such as
+// when archiving a file version.
+func TryRename(filesystem Filesystem, from, to string) error {
+	renameLock.Lock()
+	defer renameLock.Unlock()
+
+	return withPreparedTarget(filesystem, from, to, func() error {
+		return filesystem.Rename(from, to)
+	})
+}
+
+// Copy copies the file content from source to destination.
+// Tries hard to succeed on various systems by temporarily tweaking directory
+// permissions and removing the destination file when necessary.
+func Copy(filesystem Filesystem, from, to string) error {
+	return withPreparedTarget(filesystem, from, to, func() error {
+		return copyFileContents(filesystem, from, to)
+	})
+}
+
+// InWritableDir calls fn(path), while making sure that the directory
+// containing `path` is writable for the duration of the call.
+func InWritableDir(fn func(string) error, filesystem Filesystem, path string) error {
+	dir := filepath.Dir(path)
+	info, err := filesystem.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("Not a directory: " + path)
+	}
+	if info.Mode()&0200 == 0 {
+		// A non-writeable directory (for this user; we assume that's the
+		// relevant part). Temporarily change the mode so we can delete the
+		// file or directory inside it.
+		err = filesystem.Chmod(dir, 0755)
+		if err == nil {
+			defer func() {
+				err = filesystem.Chmod(dir, info.Mode())
+				if err != nil {
+					// We managed to change the permission bits like a
+					// millisecond ago, so it'd be bizarre if we couldn't
+					// change it back.
+					panic(err)
+				}
+			}()
+		}
+	}
+
+	return fn(path)
+}
+
+// TraversesSymlinkError is an error indicating symlink traversal
+type TraversesSymlinkError struct {
+	path string
+}
+
+func (e TraversesSymlinkError) Error() string {
+	return fmt.Sprintf("traverses symlink: %s", e.path)
+}
+
+// NotADirectoryError is an error indicating an expected path is not a directory
+type NotADirectoryError struct {
+	path string
+}
+
+func (e NotADirectoryError) Error() string {
+	return fmt.Sprintf("not a directory: %s", e.path)
+}
+
+// TraversesSymlink returns an error if base and any path component of name up to and
+// including filepath.Join(base, name) traverses a symlink.
+// Base and name must both be clean and name must be relative to base.
+func TraversesSymlink(filesystem Filesystem, base, name string) error {
+	path := base
+	info, err := filesystem.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &NotADirectoryError{path: base}
+	}
+
+	if name == "." {
+		// The result of calling TraversesSymlink("some/where", filepath.Dir("foo"))
+		return nil
+	}
+
+	parts := strings.Split(name, string(os.PathSeparator))
+	for _, part := range parts {
+		path = filepath.Join(path, part)
+		info, err := filesystem.Lstat(path)
+		if err != nil {
+			if IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsSymlink() {
+			return &TraversesSymlinkError{path: strings.TrimPrefix(path, base)}
+		}
+		if !info.IsDir() {
+			return &NotADirectoryError{path: strings.TrimPrefix(path, base)}
+		}
+	}
+	return nil
+}
+
+// SyncFile flushes the named file to stable storage.
+func SyncFile(filesystem Filesystem, path string) error {
+	flag := 0
+	if runtime.GOOS == "windows" {
+		flag = os.O_WRONLY
+	}
+	fd, err := filesystem.OpenFile(path, flag, 0)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	// MacOS and Windows do not flush the disk cache
+	return fd.Sync()
+}
+
+// SyncDir flushes the named directory to stable storage, where supported.
+func SyncDir(filesystem Filesystem, path string) error {
+	if runtime.GOOS == "windows" {
+		// not supported by Windows
+		return nil
+	}
+	return SyncFile(filesystem, path)
+}
+
+// Tries hard to succeed on various systems by temporarily tweaking directory
+// permissions and removing the destination file when necessary.
+func withPreparedTarget(filesystem Filesystem, from, to string, f func() error) error {
+	// Make sure the destination directory is writeable
+	toDir := filepath.Dir(to)
+	if info, err := filesystem.Stat(toDir); err == nil && info.IsDir() && info.Mode()&0200 == 0 {
+		filesystem.Chmod(toDir, 0755)
+		defer filesystem.Chmod(toDir, info.Mode())
+	}
+
+	// On Windows, make sure the destination file is writeable (or we can't delete it)
+	if runtime.GOOS == "windows" {
+		filesystem.Chmod(to, 0666)
+		if !strings.EqualFold(from, to) {
+			err := filesystem.Remove(to)
+			if err != nil && !IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return f()
+}
+
+// copyFileContents copies the contents of the file named src to the file named
+// by dst. The file will be created if it does not already exist. If the
+// destination file exists, all it's contents will be replaced by the contents
+// of the source file.
+func copyFileContents(filesystem Filesystem, src, dst string) (err error) {
+	in, err := filesystem.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := filesystem.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return
+}
@@ -6,13 +6,11 @@
 
 // +build !windows
 
-package osutil_test
+package fs
 
 import (
 	"os"
 	"testing"
-
-	"github.com/syncthing/syncthing/lib/osutil"
 )
 
 func TestTraversesSymlink(t *testing.T) {
@@ -21,8 +19,10 @@ func TestTraversesSymlink(t *testing.T) {
 	os.MkdirAll("testdata/a/b/c", 0755)
 	os.Symlink("b", "testdata/a/l")
 
+	filesystem := NewBasicFilesystem()
+
 	// a/l -> b, so a/l/c should resolve by normal stat
-	info, err := osutil.Lstat("testdata/a/l/c")
+	info, err := filesystem.Lstat("testdata/a/l/c")
 	if err != nil {
 		t.Fatal("unexpected error", err)
 	}
@@ -52,7 +52,7 @@ func TestTraversesSymlink(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		if res := osutil.TraversesSymlink("testdata", tc.name); tc.traverses == (res == nil) {
+		if res := TraversesSymlink(filesystem, "testdata", tc.name); tc.traverses == (res == nil) {
 			t.Errorf("TraversesSymlink(%q) = %v, should be %v", tc.name, res, tc.traverses)
 		}
 	}
@@ -65,8 +65,9 @@ func BenchmarkTraversesSymlink(b *testing.B) {
 	defer os.RemoveAll("testdata")
 	os.MkdirAll("testdata/a/b/c", 0755)
 
+	filesystem := NewBasicFilesystem()
 	for i := 0; i < b.N; i++ {
-		traversesSymlinkResult = osutil.TraversesSymlink("testdata", "a/b/c")
+		traversesSymlinkResult = TraversesSymlink(filesystem, "testdata", "a/b/c")
 	}
 
 	b.ReportAllocs()
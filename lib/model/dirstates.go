@@ -0,0 +1,108 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+const (
+	dirStateKeyPrefix  = "dir:"
+	lastFullScanKey    = "lastFullScan"
+	fullScanIgnoresKey = "fullScanIgnores"
+)
+
+// dirStates is the scanner.DirStateStore of an incremental scan. The states
+// of the listed directories are committed to the database only once the
+// scan has completed, as a directory recorded as unchanged won't be listed
+// again to find the files that didn't make it into the database.
+type dirStates struct {
+	kv      *db.NamespacedKV
+	started time.Time
+	pending map[string]scanner.DirState
+	skipped map[string]struct{}
+}
+
+func newDirStates(kv *db.NamespacedKV) *dirStates {
+	return &dirStates{
+		kv:      kv,
+		started: time.Now(),
+		pending: make(map[string]scanner.DirState),
+		skipped: make(map[string]struct{}),
+	}
+}
+
+func (s *dirStates) DirState(relPath string) (scanner.DirState, bool) {
+	bs, ok := s.kv.Bytes(dirStateKeyPrefix + relPath)
+	if !ok {
+		return scanner.DirState{}, false
+	}
+	var state db.DirState
+	if err := state.Unmarshal(bs); err != nil {
+		l.Debugln("unmarshal error:", err)
+		return scanner.DirState{}, false
+	}
+	return scanner.DirState{
+		ModTime:  time.Unix(state.ModifiedS, int64(state.ModifiedNs)),
+		Inode:    state.Inode,
+		Children: int(state.Children),
+		Subdirs:  state.Subdirs,
+	}, true
+}
+
+func (s *dirStates) SetDirState(relPath string, state scanner.DirState) {
+	s.pending[relPath] = state
+}
+
+func (s *dirStates) setSkipped(relPath string) {
+	s.skipped[relPath] = struct{}{}
+}
+
+// wasSkipped returns whether the directory was unchanged, and thus the
+// files in it are all still there.
+func (s *dirStates) wasSkipped(relPath string) bool {
+	_, ok := s.skipped[relPath]
+	return ok
+}
+
+// fullScanDue returns whether all directories should be listed, because
+// the interval has passed or the ignore patterns changed since the last
+// full scan. Directories that have become unignored aren't in the states.
+func (s *dirStates) fullScanDue(ignoresHash string, interval time.Duration) bool {
+	last, ok := s.kv.Time(lastFullScanKey)
+	if !ok || s.started.Sub(last) >= interval {
+		return true
+	}
+	hash, ok := s.kv.String(fullScanIgnoresKey)
+	return !ok || hash != ignoresHash
+}
+
+// commit stores the states of the listed directories, as DirState messages
+// in a single batch. After a full scan of the folder the states of the
+// directories that are gone are removed.
+func (s *dirStates) commit(fullScan bool, ignoresHash string) {
+	if fullScan {
+		s.kv.Reset()
+		s.kv.PutTime(lastFullScanKey, s.started)
+		s.kv.PutString(fullScanIgnoresKey, ignoresHash)
+	}
+	vals := make(map[string][]byte, len(s.pending))
+	for relPath, state := range s.pending {
+		bs, _ := (&db.DirState{
+			ModifiedS:  state.ModTime.Unix(),
+			ModifiedNs: int32(state.ModTime.Nanosecond()),
+			Inode:      state.Inode,
+			Children:   int32(state.Children),
+			Subdirs:    state.Subdirs,
+		}).Marshal()
+		vals[dirStateKeyPrefix+relPath] = bs
+	}
+	s.kv.PutAllBytes(vals)
+}
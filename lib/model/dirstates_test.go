@@ -0,0 +1,61 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+func TestDirStates(t *testing.T) {
+	kv := db.NewFileSet("default", db.OpenMemory()).DirStates()
+
+	s := newDirStates(kv)
+	if !s.fullScanDue("ignores", time.Hour) {
+		t.Error("the first scan should be full")
+	}
+	s.SetDirState("gone", scanner.DirState{Children: 1})
+	s.commit(true, "ignores")
+
+	s = newDirStates(kv)
+	if s.fullScanDue("ignores", time.Hour) {
+		t.Error("full scan due right after one")
+	}
+	if !s.fullScanDue("changed ignores", time.Hour) {
+		t.Error("full scan not due after the ignores changed")
+	}
+	if !s.fullScanDue("ignores", 0) {
+		t.Error("full scan not due after the interval")
+	}
+	if _, ok := s.DirState("gone"); !ok {
+		t.Error("state not committed")
+	}
+
+	// States are only visible once committed, and a full scan removes the
+	// ones of the directories it didn't list.
+	a := scanner.DirState{ModTime: time.Unix(1234567890, 123456789), Inode: 42, Children: 2, Subdirs: []string{"b"}}
+	s.SetDirState("a", a)
+	s.SetDirState("unstable", scanner.DirState{Children: -1})
+	if _, ok := s.DirState("a"); ok {
+		t.Error("state visible before commit")
+	}
+	s.commit(true, "ignores")
+
+	s = newDirStates(kv)
+	if state, ok := s.DirState("a"); !ok || !state.ModTime.Equal(a.ModTime) || state.Inode != 42 || state.Children != 2 || len(state.Subdirs) != 1 {
+		t.Errorf("unexpected state %+v, %v", state, ok)
+	}
+	if state, ok := s.DirState("unstable"); !ok || state.Children != -1 {
+		t.Errorf("unexpected state %+v, %v", state, ok)
+	}
+	if _, ok := s.DirState("gone"); ok {
+		t.Error("state of a directory that's gone kept after full scan")
+	}
+}
@@ -25,7 +25,10 @@ func (f *folder) IndexUpdated() {
 }
 
 func (f *folder) DelayScan(next time.Duration) {
-	f.scan.Delay(next)
+	select {
+	case f.scan.delay <- next:
+	case <-f.ctx.Done():
+	}
 }
 
 func (f *folder) Scan(subdirs []string) error {
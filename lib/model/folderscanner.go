@@ -54,10 +54,6 @@ func (f *folderScanner) Scan(subdirs []string) error {
 	return <-req.err
 }
 
-func (f *folderScanner) Delay(next time.Duration) {
-	f.delay <- next
-}
-
 func (f *folderScanner) HasNoInterval() bool {
 	return f.interval == 0
 }
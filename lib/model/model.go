@@ -7,6 +7,7 @@
 package model
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -22,6 +23,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/audit"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/connections"
 	"github.com/syncthing/syncthing/lib/db"
@@ -76,6 +78,7 @@ type Model struct {
 	shortID           protocol.ShortID
 	cacheIgnoredFiles bool
 	protectedFiles    []string
+	auditLog          *audit.Log // nil unless auditing file operations
 
 	deviceName    string
 	clientName    string
@@ -90,6 +93,8 @@ type Model struct {
 	folderRunners      map[string]service                                     // folder -> puller or scanner
 	folderRunnerTokens map[string][]suture.ServiceToken                       // folder -> tokens for puller or scanner
 	folderStatRefs     map[string]*stats.FolderStatisticsReference            // folder -> statsRef
+	folderUnsettled    map[string][]string                                    // folder -> files not settled at last scan
+	folderSettles      map[string]*scanner.SettleTracker                      // folder -> sizes and modification times of unsettled files
 	fmut               sync.RWMutex                                           // protects the above
 
 	conn                map[protocol.DeviceID]connections.Connection
@@ -97,6 +102,8 @@ type Model struct {
 	helloMessages       map[protocol.DeviceID]protocol.HelloResult
 	deviceDownloads     map[protocol.DeviceID]*deviceDownloadState
 	remotePausedFolders map[protocol.DeviceID][]string // deviceID -> folders
+	identityMigration   *protocol.IdentityMigration    // our move to a new identity, if any
+	migrationConfirmed  map[protocol.DeviceID]bool     // deviceID -> identityMigration confirmed
 	pmut                sync.RWMutex                   // protects the above
 }
 
@@ -117,6 +124,8 @@ var (
 	errNotRelative         = errors.New("not a relative path")
 	errFolderPaused        = errors.New("folder is paused")
 	errFolderMissing       = errors.New("no such folder")
+	errNoFileHistory       = errors.New("file history is disabled for the folder")
+	errNoAuditLog          = errors.New("audit log is disabled")
 	errNetworkNotAllowed   = errors.New("network not allowed")
 )
 
@@ -150,17 +159,22 @@ func NewModel(cfg *config.Wrapper, id protocol.DeviceID, deviceName, clientName,
 		folderRunners:       make(map[string]service),
 		folderRunnerTokens:  make(map[string][]suture.ServiceToken),
 		folderStatRefs:      make(map[string]*stats.FolderStatisticsReference),
+		folderUnsettled:     make(map[string][]string),
+		folderSettles:       make(map[string]*scanner.SettleTracker),
 		conn:                make(map[protocol.DeviceID]connections.Connection),
 		closed:              make(map[protocol.DeviceID]chan struct{}),
 		helloMessages:       make(map[protocol.DeviceID]protocol.HelloResult),
 		deviceDownloads:     make(map[protocol.DeviceID]*deviceDownloadState),
 		remotePausedFolders: make(map[protocol.DeviceID][]string),
+		migrationConfirmed:  make(map[protocol.DeviceID]bool),
 		fmut:                sync.NewRWMutex(),
 		pmut:                sync.NewRWMutex(),
 	}
 	if cfg.Options().ProgressUpdateIntervalS > -1 {
 		go m.progressEmitter.Serve()
 	}
+	// The config may have been changed while we weren't running
+	m.cleanPending(cfg.RawCopy())
 	cfg.Subscribe(m)
 
 	return m
@@ -204,15 +218,15 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 		panic(fmt.Sprintf("unknown folder type 0x%x", cfg.Type))
 	}
 
-	fs := m.folderFiles[folder]
+	fset := m.folderFiles[folder]
 
 	// Find any devices for which we hold the index in the db, but the folder
 	// is not shared, and drop it.
 	expected := mapDevices(cfg.DeviceIDs())
-	for _, available := range fs.ListDevices() {
+	for _, available := range fset.ListDevices() {
 		if _, ok := expected[available]; !ok {
 			l.Debugln("dropping", folder, "state for", available)
-			fs.Replace(available, nil)
+			fset.Replace(available, nil)
 		}
 	}
 
@@ -221,7 +235,7 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 		m.closeLocked(id)
 	}
 
-	v, ok := fs.Sequence(protocol.LocalDeviceID), true
+	v, ok := fset.Sequence(protocol.LocalDeviceID), true
 	indexHasFiles := ok && v > 0
 	if !indexHasFiles {
 		// It's a blank folder, so this may the first time we're looking at
@@ -247,7 +261,7 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 			l.Fatalf("Requested versioning type %q that does not exist", cfg.Versioning.Type)
 		}
 
-		ver = versionerFactory(folder, cfg.Path(), cfg.Versioning.Params)
+		ver = versionerFactory(folder, fset.MtimeFS(), cfg.Path(), cfg.Versioning.Params)
 		if service, ok := ver.(suture.Service); ok {
 			// The versioner implements the suture.Service interface, so
 			// expects to be run in the background in addition to being called
@@ -257,9 +271,14 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 		}
 	}
 
-	p := folderFactory(m, cfg, ver, fs.MtimeFS())
+	p := folderFactory(m, cfg, ver, fset.MtimeFS())
 	m.folderRunners[folder] = p
 
+	if cfg.ScrubIntervalS > 0 {
+		token := m.Add(newScrubber(m, cfg, fset))
+		m.folderRunnerTokens[folder] = append(m.folderRunnerTokens[folder], token)
+	}
+
 	m.warnAboutOverwritingProtectedFiles(folder)
 
 	token := m.Add(p)
@@ -309,11 +328,21 @@ func (m *Model) AddFolder(cfg config.FolderConfiguration) {
 
 func (m *Model) addFolderLocked(cfg config.FolderConfiguration) {
 	m.folderCfgs[cfg.ID] = cfg
-	m.folderFiles[cfg.ID] = db.NewFileSet(cfg.ID, m.db)
-
-	for _, device := range cfg.Devices {
-		m.folderDevices.set(device.DeviceID, cfg.ID)
-		m.deviceFolders[device.DeviceID] = append(m.deviceFolders[device.DeviceID], cfg.ID)
+	files := db.NewFileSet(cfg.ID, m.db)
+	// Changes from devices that may only receive from us are ignored
+	files.SetReceiveOnlyDevices(cfg.DevicesWithRole(config.FolderDeviceRoleReceiveOnly))
+	files.SetHistory(cfg.FileHistoryEntries, time.Duration(cfg.FileHistoryMaxAgeDays)*24*time.Hour)
+	if !cfg.IncrementalScan {
+		// Stale states would hide the changes made until incremental
+		// scanning is enabled again.
+		files.DirStates().Reset()
+	}
+	m.folderFiles[cfg.ID] = files
+
+	// Includes the members of the device groups the folder is shared with
+	for _, id := range cfg.DeviceIDs() {
+		m.folderDevices.set(id, cfg.ID)
+		m.deviceFolders[id] = append(m.deviceFolders[id], cfg.ID)
 	}
 
 	ignores := ignore.New(m.cacheIgnoredFiles)
@@ -330,7 +359,7 @@ func (m *Model) RemoveFolder(folder string) {
 	// Delete syncthing specific files
 	folderCfg := m.folderCfgs[folder]
 	folderPath := folderCfg.Path()
-	os.Remove(filepath.Join(folderPath, ".stfolder"))
+	fs.DefaultFilesystem.Remove(filepath.Join(folderPath, ".stfolder"))
 
 	m.tearDownFolderLocked(folder)
 	// Remove it from the database
@@ -361,6 +390,8 @@ func (m *Model) tearDownFolderLocked(folder string) {
 	delete(m.folderRunners, folder)
 	delete(m.folderRunnerTokens, folder)
 	delete(m.folderStatRefs, folder)
+	delete(m.folderUnsettled, folder)
+	delete(m.folderSettles, folder)
 	for dev, folders := range m.deviceFolders {
 		m.deviceFolders[dev] = stringSliceWithout(folders, folder)
 	}
@@ -799,8 +830,11 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 		dropSymlinks = true
 	}
 
+	deviceCfg, _ := m.cfg.Device(deviceID)
+
 	m.fmut.Lock()
 	var paused []string
+	var acceptFolders []protocol.Folder
 	for _, folder := range cm.Folders {
 		if folder.Paused {
 			paused = append(paused, folder.ID)
@@ -812,6 +846,12 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 		}
 
 		if !m.folderSharedWithLocked(folder.ID, deviceID) {
+			if _, exists := m.cfg.Folder(folder.ID); !exists && deviceCfg.AutoAcceptFolders {
+				// Added once we're done with the cluster config
+				acceptFolders = append(acceptFolders, folder)
+				continue
+			}
+			m.db.AddOrUpdatePendingFolder(folder.ID, folder.Label, deviceID)
 			events.Default.Log(events.FolderRejected, map[string]string{
 				"folder":      folder.ID,
 				"folderLabel": folder.Label,
@@ -820,7 +860,11 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			l.Infof("Unexpected folder %s sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", folder.Description(), deviceID)
 			continue
 		}
-		if !folder.DisableTempIndexes {
+		// A send only device gets neither our index nor our download
+		// progress for the folder, so it has nothing to pull from us.
+		folderCfg := m.folderCfgs[folder.ID]
+		sendOnly := folderCfg.DeviceRole(deviceID) == config.FolderDeviceRoleSendOnly
+		if !folder.DisableTempIndexes && !sendOnly {
 			tempIndexFolders = append(tempIndexFolders, folder.ID)
 		}
 
@@ -895,7 +939,9 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			}
 		}
 
-		go sendIndexes(conn, folder.ID, fs, m.folderIgnores[folder.ID], startSequence, dbLocation, dropSymlinks)
+		if !sendOnly {
+			go sendIndexes(conn, folder.ID, fs, m.folderIgnores[folder.ID], startSequence, dbLocation, dropSymlinks)
+		}
 	}
 
 	m.pmut.Lock()
@@ -915,7 +961,7 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 	}
 
 	var changed = false
-	if deviceCfg := m.cfg.Devices()[deviceID]; deviceCfg.Introducer {
+	if deviceCfg.Introducer {
 		foldersDevices, introduced := m.handleIntroductions(deviceCfg, cm)
 		if introduced {
 			changed = true
@@ -928,11 +974,26 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 	}
 	m.fmut.Unlock()
 
-	if changed {
+	accepted := false
+	for _, folder := range acceptFolders {
+		if m.autoAcceptFolder(deviceCfg, folder) {
+			accepted = true
+		}
+	}
+
+	if changed || accepted {
 		if err := m.cfg.Save(); err != nil {
 			l.Warnln("Failed to save config", err)
 		}
 	}
+
+	if accepted {
+		// The cluster config we sent didn't have the accepted folders, so
+		// no indexes are exchanged for them on this connection. Reconnecting
+		// sends a new one.
+		l.Debugln("closing connection to", deviceID, "after accepting folders")
+		m.close(deviceID)
+	}
 }
 
 // handleIntroductions handles adding devices/shares that are shared by an introducer device
@@ -950,6 +1011,11 @@ func (m *Model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 			continue
 		}
 
+		// The introducer may not be allowed to introduce anything to this
+		// folder. We still keep track of what it shares, so that we don't
+		// remove what it introduced before the policy changed.
+		allowedFolder := introducerCfg.IntroducesFolder(folder.ID)
+
 		// Adds devices which we do not have, but the introducer has
 		// for the folders that we have in common. Also, shares folders
 		// with devices that we have in common, yet are currently not sharing
@@ -958,7 +1024,18 @@ func (m *Model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 		for _, device := range folder.Devices {
 			foldersDevices.set(device.ID, folder.ID)
 
+			if !allowedFolder || !introducerCfg.IntroducesDevice(device.ID) {
+				l.Debugf("Introducer %v may not introduce %v to folder %s", introducerCfg.DeviceID, device.ID, folder.Description())
+				continue
+			}
+
 			if _, ok := m.cfg.Devices()[device.ID]; !ok {
+				if introducerCfg.IntroducerRequireApproval {
+					// The device stays pending until approved, after
+					// which the folder is shared with it as usual.
+					m.introducePendingDevice(device, introducerCfg)
+					continue
+				}
 				// The device is currently unknown. Add it to the config.
 				m.introduceDevice(device, introducerCfg)
 				changed = true
@@ -997,6 +1074,7 @@ func (m *Model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 					// introducer with the device that was introduced to us.
 					// We should follow and unshare aswell.
 					l.Infof("Unsharing folder %s with %v as introducer %v no longer shares the folder with that device", folderCfg.Description(), folderCfg.Devices[i].DeviceID, folderCfg.Devices[i].IntroducedBy)
+					m.auditIntroduction(introducerCfg.DeviceID, db.IntroductionUnshareFolder, folderCfg.Devices[i].DeviceID, folderCfg.ID)
 					folderCfg.Devices = append(folderCfg.Devices[:i], folderCfg.Devices[i+1:]...)
 					i--
 					folderChanged = true
@@ -1027,6 +1105,7 @@ func (m *Model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 				// device, remove the device.
 				l.Infof("Removing device %v as introducer %v no longer shares any folders with that device", device.DeviceID, device.IntroducedBy)
 				m.cfg.RemoveDevice(device.DeviceID)
+				m.auditIntroduction(introducerCfg.DeviceID, db.IntroductionRemoveDevice, device.DeviceID, "")
 				changed = true
 			}
 		}
@@ -1061,6 +1140,21 @@ func (m *Model) introduceDevice(device protocol.Device, introducerCfg config.Dev
 	}
 
 	m.cfg.SetDevice(newDeviceCfg)
+	m.auditIntroduction(introducerCfg.DeviceID, db.IntroductionAddDevice, device.ID, "")
+}
+
+func (m *Model) introducePendingDevice(device protocol.Device, introducerCfg config.DeviceConfiguration) {
+	if pd, ok := m.db.PendingDevices()[device.ID]; ok && pd.IntroducedBy == introducerCfg.DeviceID.String() {
+		// Already waiting for approval
+		return
+	}
+	if m.db.PendingDeviceDismissed(device.ID) {
+		return
+	}
+
+	l.Infof("Device %v introduced by %v is pending approval", device.ID, introducerCfg.DeviceID)
+	m.db.AddPendingIntroducedDevice(device.ID, device.Name, introducerCfg.DeviceID)
+	m.auditIntroduction(introducerCfg.DeviceID, db.IntroductionPendingDevice, device.ID, "")
 }
 
 func (m *Model) introduceDeviceToFolder(device protocol.Device, folder protocol.Folder, introducerCfg config.DeviceConfiguration) {
@@ -1075,6 +1169,176 @@ func (m *Model) introduceDeviceToFolder(device protocol.Device, folder protocol.
 		IntroducedBy: introducerCfg.DeviceID,
 	})
 	m.cfg.SetFolder(folderCfg)
+	m.auditIntroduction(introducerCfg.DeviceID, db.IntroductionShareFolder, device.ID, folder.ID)
+}
+
+// auditIntroduction records a change made on behalf of an introducer, in the
+// database and as an event.
+func (m *Model) auditIntroduction(introducer protocol.DeviceID, action string, device protocol.DeviceID, folder string) {
+	m.db.AddIntroduction(db.Introduction{
+		Introducer: introducer,
+		Action:     action,
+		Device:     device,
+		Folder:     folder,
+	})
+	events.Default.Log(events.IntroducerAction, map[string]string{
+		"introducer": introducer.String(),
+		"action":     action,
+		"device":     device.String(),
+		"folder":     folder,
+	})
+}
+
+// autoAcceptFolder adds a folder offered by a device that we accept all
+// folders from. The caller reconnects to the device afterwards, to exchange
+// indexes for it.
+func (m *Model) autoAcceptFolder(deviceCfg config.DeviceConfiguration, folder protocol.Folder) bool {
+	path, err := m.autoAcceptPath(deviceCfg, folder)
+	if err != nil {
+		l.Infof("Not auto accepting folder %s from %v: %v", folder.Description(), deviceCfg.DeviceID, err)
+		return false
+	}
+
+	folderCfg := config.NewDefaultFolderConfiguration(m.id, folder.ID, folder.Label, path)
+	folderCfg.Type = deviceCfg.AutoAcceptType
+	if deviceCfg.AutoAcceptVersioning != nil {
+		folderCfg.Versioning = deviceCfg.AutoAcceptVersioning.Copy()
+	}
+	folderCfg.Devices = append(folderCfg.Devices, config.FolderDeviceConfiguration{DeviceID: deviceCfg.DeviceID})
+
+	l.Infof("Auto accepting folder %s from %v at %s", folder.Description(), deviceCfg.DeviceID, path)
+	if err := m.cfg.SetFolder(folderCfg); err != nil {
+		l.Warnln("Auto accepting folder:", err)
+		return false
+	}
+	return true
+}
+
+// autoAcceptPath returns the path for an auto accepted folder, based on the
+// path template of the offering device. The path never points at an
+// existing folder or directory; in that case a number is added to it.
+func (m *Model) autoAcceptPath(deviceCfg config.DeviceConfiguration, folder protocol.Folder) (string, error) {
+	id := sanitizedFolderName(folder.ID)
+	label := sanitizedFolderName(folder.Label)
+	if label == "" {
+		label = id
+	}
+	if label == "" {
+		return "", errors.New("folder has neither a usable ID nor label")
+	}
+
+	template := deviceCfg.AutoAcceptPath
+	if template == "" {
+		template = config.DefaultAutoAcceptPath
+	}
+	base, err := osutil.ExpandTilde(strings.NewReplacer("{id}", id, "{label}", label).Replace(template))
+	if err != nil {
+		return "", err
+	}
+	base = filepath.Clean(base)
+
+	used := make(map[string]bool)
+	for _, cfg := range m.cfg.Folders() {
+		used[filepath.Clean(cfg.Path())] = true
+	}
+
+	path := base
+	for i := 2; i < 100; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) && !used[path] {
+			return path, nil
+		}
+		path = fmt.Sprintf("%s (%d)", base, i)
+	}
+	return "", fmt.Errorf("%s and its alternatives already exist", base)
+}
+
+// sanitizedFolderName returns the folder ID or label as something usable as
+// a single path component.
+func sanitizedFolderName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 32, r == '/', r == '\\', r == ':', r == '*', r == '?', r == '"', r == '<', r == '>', r == '|':
+			return '_'
+		}
+		return r
+	}, name)
+	// No ".." or hidden directories
+	return strings.Trim(name, ". ")
+}
+
+// PendingDevices returns the unknown devices that have tried to connect.
+func (m *Model) PendingDevices() map[protocol.DeviceID]db.PendingDevice {
+	return m.db.PendingDevices()
+}
+
+// PendingFolders returns the folders offered to us by devices we don't
+// share them with, by folder ID and offering device.
+func (m *Model) PendingFolders() map[string]map[protocol.DeviceID]db.PendingFolder {
+	return m.db.PendingFolders()
+}
+
+// DismissPendingDevice hides a pending device, also when it tries to
+// connect again, until it's added.
+func (m *Model) DismissPendingDevice(device protocol.DeviceID) {
+	m.db.DismissPendingDevice(device)
+}
+
+// DismissPendingFolder forgets about a folder offered by the given device,
+// until it's offered again.
+func (m *Model) DismissPendingFolder(folder string, device protocol.DeviceID) {
+	m.db.RemovePendingFolder(folder, device)
+}
+
+// Introductions returns the audit trail of changes made on behalf of
+// introducers, oldest first.
+func (m *Model) Introductions() []db.Introduction {
+	return m.db.Introductions()
+}
+
+// BackupDatabase writes a consistent copy of the database to a new file at
+// the location.
+func (m *Model) BackupDatabase(location string) (int, error) {
+	return m.db.Backup(location)
+}
+
+// ExportDatabase writes the portable export of the database to the writer.
+func (m *Model) ExportDatabase(w io.Writer) error {
+	return m.db.Export(w)
+}
+
+// cleanPending forgets about pending devices that have since been added or
+// ignored, and pending folders that have since been shared with the
+// offering device or whose offering device has been removed.
+func (m *Model) cleanPending(cfg config.Configuration) {
+	known := make(map[protocol.DeviceID]bool, len(cfg.Devices))
+	for _, dev := range cfg.Devices {
+		known[dev.DeviceID] = true
+	}
+	ignored := make(map[protocol.DeviceID]bool, len(cfg.IgnoredDevices))
+	for _, id := range cfg.IgnoredDevices {
+		ignored[id] = true
+	}
+
+	for id := range m.db.AllPendingDevices() {
+		if known[id] || ignored[id] {
+			m.db.RemovePendingDevice(id)
+		}
+	}
+
+	folders := mapFolders(cfg.Folders)
+	for folder, offers := range m.db.PendingFolders() {
+		shared := make(map[protocol.DeviceID]bool)
+		if folderCfg, ok := folders[folder]; ok {
+			for _, id := range folderCfg.DeviceIDs() {
+				shared[id] = true
+			}
+		}
+		for id := range offers {
+			if !known[id] || shared[id] {
+				m.db.RemovePendingFolder(folder, id)
+			}
+		}
+	}
 }
 
 // Closed is called when a connection has been closed
@@ -1140,6 +1404,11 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 	folderIgnores := m.folderIgnores[folder]
 	m.fmut.RUnlock()
 
+	if folderCfg.DeviceRole(deviceID) == config.FolderDeviceRoleSendOnly {
+		l.Debugf("%v REQ(in) from send only device: %s: %q / %q o=%d s=%d", m, deviceID, folder, name, offset, len(buf))
+		return protocol.ErrNoSuchFile
+	}
+
 	fn, err := rootedJoinedPath(folderPath, name)
 	if err != nil {
 		// Request tries to escape!
@@ -1161,7 +1430,7 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 		return protocol.ErrNoSuchFile
 	}
 
-	if err := osutil.TraversesSymlink(folderPath, filepath.Dir(name)); err != nil {
+	if err := fs.TraversesSymlink(fs.DefaultFilesystem, folderPath, filepath.Dir(name)); err != nil {
 		l.Debugf("%v REQ(in) traversal check: %s - %s: %q / %q o=%d s=%d", m, err, deviceID, folder, name, offset, len(buf))
 		return protocol.ErrNoSuchFile
 	}
@@ -1171,7 +1440,7 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 	if fromTemporary && !folderCfg.DisableTempIndexes {
 		tempFn := filepath.Join(folderPath, ignore.TempName(name))
 
-		if info, err := osutil.Lstat(tempFn); err != nil || !info.Mode().IsRegular() {
+		if info, err := fs.DefaultFilesystem.Lstat(tempFn); err != nil || !info.IsRegular() {
 			// Reject reads for anything that doesn't exist or is something
 			// other than a regular file.
 			return protocol.ErrNoSuchFile
@@ -1184,14 +1453,14 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 		// file has finished downloading.
 	}
 
-	if info, err := osutil.Lstat(fn); err != nil || !info.Mode().IsRegular() {
+	if info, err := fs.DefaultFilesystem.Lstat(fn); err != nil || !info.IsRegular() {
 		// Reject reads for anything that doesn't exist or is something
 		// other than a regular file.
 		return protocol.ErrNoSuchFile
 	}
 
 	err = readOffsetIntoBuf(fn, offset, buf)
-	if os.IsNotExist(err) {
+	if fs.IsNotExist(err) {
 		return protocol.ErrNoSuchFile
 	} else if err != nil {
 		return protocol.ErrGeneric
@@ -1219,6 +1488,21 @@ func (m *Model) CurrentGlobalFile(folder string, file string) (protocol.FileInfo
 	return fs.GetGlobal(file)
 }
 
+// FileHistory returns the known versions of the file, oldest first.
+func (m *Model) FileHistory(folder, file string) ([]db.HistoryEntry, error) {
+	m.fmut.RLock()
+	fs, ok := m.folderFiles[folder]
+	cfg := m.folderCfgs[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	if cfg.FileHistoryEntries <= 0 {
+		return nil, errNoFileHistory
+	}
+	return fs.History(file), nil
+}
+
 type cFiler struct {
 	m *Model
 	r string
@@ -1296,8 +1580,19 @@ func (m *Model) OnHello(remoteID protocol.DeviceID, addr net.Addr, hello protoco
 		return errDeviceIgnored
 	}
 
+	// A device that announced its move to a new identity is known under
+	// the new one from the moment it connects with it.
+	if _, ok := m.cfg.Device(remoteID); !ok {
+		if from, ok := m.db.IdentityMigrationTo(remoteID); ok {
+			if err := m.migrateDevice(from, remoteID); err != nil {
+				l.Warnf("Migrating device %s to %s: %v", from, remoteID, err)
+			}
+		}
+	}
+
 	cfg, ok := m.cfg.Device(remoteID)
 	if !ok {
+		m.db.AddOrUpdatePendingDevice(remoteID, hello.DeviceName, addr.String())
 		events.Default.Log(events.DeviceRejected, map[string]string{
 			"name":    hello.DeviceName,
 			"device":  remoteID.String(),
@@ -1379,6 +1674,8 @@ func (m *Model) AddConnection(conn connections.Connection, hello protocol.HelloR
 	cm := m.generateClusterConfig(deviceID)
 	conn.ClusterConfig(cm)
 
+	m.announceIdentityMigration(deviceID, conn)
+
 	device, ok := m.cfg.Devices()[deviceID]
 	if ok && (device.Name == "" || m.cfg.Options().OverwriteRemoteDevNames) {
 		device.Name = hello.DeviceName
@@ -1414,6 +1711,132 @@ func (m *Model) DownloadProgress(device protocol.DeviceID, folder string, update
 	})
 }
 
+// IdentityMigration is called when a device announces that it is moving to
+// a new identity. The announcement is remembered, and the device keeps its
+// current ID until it connects with the new certificate, at which point it
+// keeps its settings, folder shares and index IDs under its new device ID.
+// The announcement is sent back to the device, to confirm that it was
+// understood.
+func (m *Model) IdentityMigration(deviceID protocol.DeviceID, migration protocol.IdentityMigration) {
+	if protocol.NewDeviceID(migration.OldCertificate) == m.id {
+		m.identityMigrationConfirmed(deviceID, migration)
+		return
+	}
+
+	newID, err := migration.Verify(deviceID)
+	if err != nil {
+		l.Infof("Ignoring identity migration from %s: %v", deviceID, err)
+		return
+	}
+
+	// Make sure the config will accept the change when the time comes.
+	cfg := m.cfg.RawCopy()
+	if err := cfg.MigrateDevice(deviceID, newID); err != nil {
+		l.Infof("Ignoring identity migration from %s: %v", deviceID, err)
+		return
+	}
+
+	l.Infof("Device %s is moving to new identity %s", deviceID, newID)
+	m.db.AddIdentityMigration(deviceID, newID)
+
+	m.pmut.RLock()
+	conn, ok := m.conn[deviceID]
+	m.pmut.RUnlock()
+	if ok {
+		conn.IdentityMigration(migration)
+	}
+}
+
+// migrateDevice moves a device that announced its move to a new identity
+// over to the new device ID, once it connects with it.
+func (m *Model) migrateDevice(from, to protocol.DeviceID) error {
+	// Make sure the config will accept the change before touching the
+	// database, so that the two don't get out of step.
+	cfg := m.cfg.RawCopy()
+	if err := cfg.MigrateDevice(from, to); err != nil {
+		return err
+	}
+
+	l.Infof("Device %s is now known as %s", from, to)
+
+	if err := m.db.MigrateDevice(from, to); err != nil {
+		return fmt.Errorf("migrating database: %v", err)
+	}
+
+	m.fmut.Lock()
+	delete(m.deviceStatRefs, from)
+	m.fmut.Unlock()
+
+	if err := m.cfg.MigrateDevice(from, to); err != nil {
+		return fmt.Errorf("migrating config: %v", err)
+	}
+	m.cfg.Save()
+
+	events.Default.Log(events.DeviceIdentityMigrated, map[string]string{
+		"device":    from.String(),
+		"newDevice": to.String(),
+	})
+
+	// Any connection left with the old identity is stale.
+	m.close(from)
+	return nil
+}
+
+// SetIdentityMigration sets the announcement of our own move to a new
+// identity. It is sent to all connected devices, and to devices as they
+// connect, until cleared by passing nil.
+func (m *Model) SetIdentityMigration(migration *protocol.IdentityMigration) {
+	m.pmut.Lock()
+	m.identityMigration = migration
+	m.migrationConfirmed = make(map[protocol.DeviceID]bool)
+	conns := make(map[protocol.DeviceID]connections.Connection, len(m.conn))
+	for deviceID, conn := range m.conn {
+		conns[deviceID] = conn
+	}
+	m.pmut.Unlock()
+
+	for deviceID, conn := range conns {
+		m.announceIdentityMigration(deviceID, conn)
+	}
+}
+
+// IdentityMigrationConfirmed returns the devices that have confirmed our
+// current identity migration. Devices too old to know about identity
+// migrations ignore the announcement, and never confirm it.
+func (m *Model) IdentityMigrationConfirmed() []protocol.DeviceID {
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+
+	devices := make([]protocol.DeviceID, 0, len(m.migrationConfirmed))
+	for deviceID := range m.migrationConfirmed {
+		devices = append(devices, deviceID)
+	}
+	return devices
+}
+
+func (m *Model) identityMigrationConfirmed(deviceID protocol.DeviceID, migration protocol.IdentityMigration) {
+	m.pmut.Lock()
+	defer m.pmut.Unlock()
+
+	if m.identityMigration == nil || !bytes.Equal(m.identityMigration.Signature, migration.Signature) {
+		l.Debugln("Ignoring confirmation of unknown identity migration from", deviceID)
+		return
+	}
+	l.Infof("Device %s confirmed our identity migration", deviceID)
+	m.migrationConfirmed[deviceID] = true
+}
+
+func (m *Model) announceIdentityMigration(deviceID protocol.DeviceID, conn protocol.Connection) {
+	m.pmut.RLock()
+	migration := m.identityMigration
+	m.pmut.RUnlock()
+
+	if migration != nil {
+		l.Infof("Announcing identity migration to %s", deviceID)
+		conn.IdentityMigration(*migration)
+	}
+}
+
 func (m *Model) deviceStatRef(deviceID protocol.DeviceID) *stats.DeviceStatisticsReference {
 	m.fmut.Lock()
 	defer m.fmut.Unlock()
@@ -1561,7 +1984,11 @@ func sendIndexTo(minSequence int64, conn protocol.Connection, folder string, fs
 }
 
 func (m *Model) updateLocalsFromScanning(folder string, fs []protocol.FileInfo) {
+	entries := m.auditChanges(folder, audit.OriginLocal, fs)
 	m.updateLocals(folder, fs)
+	if len(entries) > 0 {
+		m.auditLog.Record(entries...)
+	}
 
 	m.fmut.RLock()
 	folderCfg := m.folderCfgs[folder]
@@ -1571,7 +1998,11 @@ func (m *Model) updateLocalsFromScanning(folder string, fs []protocol.FileInfo)
 }
 
 func (m *Model) updateLocalsFromPulling(folder string, fs []protocol.FileInfo) {
+	entries := m.auditChanges(folder, audit.OriginRemote, fs)
 	m.updateLocals(folder, fs)
+	if len(entries) > 0 {
+		m.auditLog.Record(entries...)
+	}
 
 	m.fmut.RLock()
 	folderCfg := m.folderCfgs[folder]
@@ -1603,6 +2034,96 @@ func (m *Model) updateLocals(folder string, fs []protocol.FileInfo) {
 	})
 }
 
+// auditChanges returns the audit log entries for the changes of our files
+// about to be made, if auditing.
+func (m *Model) auditChanges(folder, origin string, fs []protocol.FileInfo) []audit.Entry {
+	if m.auditLog == nil {
+		return nil
+	}
+
+	m.fmut.RLock()
+	files := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if files == nil {
+		return nil
+	}
+
+	entries := make([]audit.Entry, 0, len(fs))
+	for _, f := range fs {
+		if f.IsInvalid() {
+			continue
+		}
+		cur, ok := files.Get(protocol.LocalDeviceID, f.Name)
+		existed := ok && !cur.IsDeleted() && !cur.IsInvalid()
+		var action string
+		switch {
+		case f.IsDeleted() && existed && f.RenamedTo != "" && origin == audit.OriginLocal:
+			// Moves found by scanning; the puller records its own renames
+			entries = append(entries, auditEntry(folder, audit.ActionDelete, origin, f))
+			e := auditEntry(folder, audit.ActionRename, origin, f)
+			e.Path, e.OldPath = f.RenamedTo, f.Name
+			entries = append(entries, e)
+			continue
+		case f.IsDeleted() && existed:
+			action = audit.ActionDelete
+		case f.IsDeleted():
+			continue
+		case !existed:
+			action = audit.ActionCreate
+		case !cur.Version.Equal(f.Version):
+			action = audit.ActionModify
+		default:
+			continue
+		}
+		entries = append(entries, auditEntry(folder, action, origin, f))
+	}
+	return entries
+}
+
+func auditEntry(folder, action, origin string, f protocol.FileInfo) audit.Entry {
+	e := audit.Entry{
+		Folder: folder,
+		Path:   f.Name,
+		Action: action,
+		Type:   "file",
+		Origin: origin,
+		Device: f.ModifiedBy.String(),
+	}
+	switch {
+	case f.IsDirectory():
+		e.Type = "dir"
+	case f.IsSymlink():
+		e.Type = "symlink"
+	case !f.IsDeleted():
+		e.Size = f.Size
+		e.Hash = fmt.Sprintf("%x", f.BlocksHash())
+	}
+	return e
+}
+
+// SetAuditLog makes the model record the operations on the files of all
+// folders in the audit log. It must be called before starting folders.
+func (m *Model) SetAuditLog(a *audit.Log) {
+	m.auditLog = a
+}
+
+// AuditLog returns the audit log entries of the folder matching the query.
+func (m *Model) AuditLog(folder string, q audit.Query) ([]audit.Entry, error) {
+	if m.auditLog == nil {
+		return nil, errNoAuditLog
+	}
+	return m.auditLog.Query(folder, q)
+}
+
+// VerifyAuditLog checks the hash chain of the audit log of the folder and
+// returns the number of entries checked.
+func (m *Model) VerifyAuditLog(folder string) (int, error) {
+	if m.auditLog == nil {
+		return 0, errNoAuditLog
+	}
+	return m.auditLog.Verify(folder)
+}
+
 func (m *Model) diskChangeDetected(folderCfg config.FolderConfiguration, files []protocol.FileInfo, typeOfEvent events.EventType) {
 	path := strings.Replace(folderCfg.Path(), `\\?\`, "", 1)
 
@@ -1716,6 +2237,13 @@ func (m *Model) ScanFolderSubdirs(folder string, subs []string) error {
 	return runner.Scan(subs)
 }
 
+// sizeModTime identifies a file by its size and modification time.
+type sizeModTime struct {
+	size       int64
+	modifiedS  int64
+	modifiedNs int32
+}
+
 func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, subDirs []string) error {
 	for i := 0; i < len(subDirs); i++ {
 		sub := osutil.NativeFilename(subDirs[i])
@@ -1788,7 +2316,7 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 
 	runner.setState(FolderScanning)
 
-	fchan, err := scanner.Walk(ctx, scanner.Config{
+	scanCfg := scanner.Config{
 		Folder:                folderCfg.ID,
 		Dir:                   folderCfg.Path(),
 		Subs:                  subDirs,
@@ -1800,11 +2328,50 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 		IgnorePerms:           folderCfg.IgnorePerms,
 		AutoNormalize:         folderCfg.AutoNormalize,
 		Hashers:               m.numHashers(folder),
+		Walkers:               folderCfg.Walkers,
 		ShortID:               m.shortID,
 		ProgressTickIntervalS: folderCfg.ScanProgressIntervalS,
 		UseWeakHashes:         weakhash.Enabled,
-	})
+	}
 
+	// Only the directories that changed since the last scan are listed,
+	// with a full scan now and then to find the files changed in place.
+	var dirs *dirStates
+	ignoresHash := ignores.Hash()
+	if folderCfg.IncrementalScan {
+		dirs = newDirStates(fs.DirStates())
+		scanCfg.DirStates = dirs
+		scanCfg.DirSkipped = dirs.setSkipped
+		scanCfg.FullScan = dirs.fullScanDue(ignoresHash, time.Duration(folderCfg.FullScanIntervalS)*time.Second)
+		l.Debugf("Scanning folder %s incrementally, full scan: %v", folder, scanCfg.FullScan)
+	}
+	wholeFolder := len(subDirs) == 0
+
+	// Files still being written are looked at again once they've settled
+	var unsettled []string
+	var settled time.Time
+	var settles *scanner.SettleTracker
+	if folderCfg.SettleTimeS > 0 {
+		m.fmut.Lock()
+		settles = m.folderSettles[folder]
+		if settles == nil {
+			settles = scanner.NewSettleTracker()
+			m.folderSettles[folder] = settles
+		}
+		m.fmut.Unlock()
+
+		scanCfg.SettleTime = time.Duration(folderCfg.SettleTimeS) * time.Second
+		scanCfg.Settles = settles
+		scanCfg.Unsettled = func(relPath string, settleAt time.Time) {
+			unsettled = append(unsettled, relPath)
+			if settled.IsZero() || settleAt.Before(settled) {
+				settled = settleAt
+			}
+		}
+	}
+	scannedSubs := subDirs
+
+	fchan, err := scanner.Walk(ctx, scanCfg)
 	if err != nil {
 		// The error we get here is likely an OS level error, which might not be
 		// as readable as our health check errors. Check if we can get a health
@@ -1818,6 +2385,7 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 
 	batch := make([]protocol.FileInfo, 0, maxBatchSizeFiles)
 	batchSizeBytes := 0
+	moves := newMoveDetector(folder, folderCfg.Path(), fs, m.finder, mtimefs, m.shortID)
 
 	for f := range fchan {
 		if len(batch) == maxBatchSizeFiles || batchSizeBytes > maxBatchSizeBytes {
@@ -1829,6 +2397,10 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 			batch = batch[:0]
 			batchSizeBytes = 0
 		}
+		if df, ok := moves.deletion(f); ok {
+			batch = append(batch, df)
+			batchSizeBytes += df.ProtoSize()
+		}
 		batch = append(batch, f)
 		batchSizeBytes += f.ProtoSize()
 	}
@@ -1840,6 +2412,17 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 		m.updateLocalsFromScanning(folder, batch)
 	}
 
+	// A moved file keeps its size and modification time, but is new, and
+	// thus unsettled, at its new name. The deletion of the old name is held
+	// back until the new file has settled and is found to be the same,
+	// to be announced as a move.
+	unsettledFiles := make(map[sizeModTime]struct{}, len(unsettled))
+	for _, name := range unsettled {
+		if size, modTime, ok := settles.Seen(name); ok {
+			unsettledFiles[sizeModTime{size, modTime.Unix(), int32(modTime.Nanosecond())}] = struct{}{}
+		}
+	}
+
 	if len(subDirs) == 0 {
 		// If we have no specific subdirectories to traverse, set it to one
 		// empty prefix so we traverse the entire folder contents once.
@@ -1884,6 +2467,9 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 				batch = append(batch, nf)
 				batchSizeBytes += nf.ProtoSize()
 
+			case dirs != nil && dirs.wasSkipped(filepath.Dir(f.Name)):
+				// The directory is unchanged, so the file is still there.
+
 			case !f.IsInvalid() && !f.IsDeleted():
 				// The file is valid and not deleted. Lets check if it's
 				// still here.
@@ -1896,6 +2482,11 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 					// file) are deleted but will return a confusing error ("not a
 					// directory") when we try to Lstat() them.
 
+					if _, ok := unsettledFiles[sizeModTime{f.Size, f.ModifiedS, f.ModifiedNs}]; ok && f.Type == protocol.FileInfoTypeFile {
+						l.Debugln("holding back deletion of possibly moved", f)
+						return true
+					}
+
 					nf := protocol.FileInfo{
 						Name:       f.Name,
 						Type:       f.Type,
@@ -1927,11 +2518,64 @@ func (m *Model) internalScanFolderSubdirs(ctx context.Context, folder string, su
 		m.updateLocalsFromScanning(folder, batch)
 	}
 
+	if dirs != nil {
+		dirs.commit(scanCfg.FullScan && wholeFolder, ignoresHash)
+	}
+
+	m.setUnsettled(folder, scannedSubs, unsettled)
+	if settles != nil && wholeFolder {
+		// Forget the files that were removed while being written
+		settles.Retain(unsettled)
+	}
+	if len(unsettled) > 0 {
+		next := settled.Sub(time.Now())
+		if next < time.Second {
+			next = time.Second
+		}
+		if folderCfg.RescanIntervalS == 0 || next < time.Duration(folderCfg.RescanIntervalS)*time.Second {
+			l.Debugf("Folder %s has %d unsettled files, rescanning in %v", folder, len(unsettled), next)
+			// The folder takes the delay once done with this scan, or
+			// drops it when stopped
+			go m.DelayScan(folder, next)
+		}
+	}
+
 	m.folderStatRef(folder).ScanCompleted()
 	runner.setState(FolderIdle)
 	return nil
 }
 
+// UnsettledFiles returns the files that were still being written at the
+// last scan, and so weren't scanned.
+func (m *Model) UnsettledFiles(folder string) []string {
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+	return append([]string{}, m.folderUnsettled[folder]...)
+}
+
+// setUnsettled replaces the unsettled files in the scanned subdirectories,
+// or all of them when the whole folder was scanned.
+func (m *Model) setUnsettled(folder string, subs []string, files []string) {
+	m.fmut.Lock()
+	defer m.fmut.Unlock()
+
+	var kept []string
+	if len(subs) > 0 {
+	next:
+		for _, file := range m.folderUnsettled[folder] {
+			for _, sub := range subs {
+				if file == sub || strings.HasPrefix(file, sub+string(filepath.Separator)) {
+					continue next
+				}
+			}
+			kept = append(kept, file)
+		}
+	}
+	kept = append(kept, files...)
+	sort.Strings(kept)
+	m.folderUnsettled[folder] = kept
+}
+
 func (m *Model) DelayScan(folder string, next time.Duration) {
 	m.fmut.Lock()
 	runner, ok := m.folderRunners[folder]
@@ -1996,6 +2640,8 @@ func (m *Model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 			Paused:             folderCfg.Paused,
 		}
 
+		sendOnly := folderCfg.DeviceRole(device) == config.FolderDeviceRoleSendOnly
+
 		// Devices are sorted, so we always get the same order.
 		for _, device := range m.folderDevices.sortedDevices(folder) {
 			deviceCfg := m.cfg.Devices()[device]
@@ -2003,8 +2649,12 @@ func (m *Model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 			var indexID protocol.IndexID
 			var maxSequence int64
 			if device == m.id {
-				indexID = fs.IndexID(protocol.LocalDeviceID)
-				maxSequence = fs.Sequence(protocol.LocalDeviceID)
+				// We announce no index to a send only device, making it drop
+				// anything it has from us.
+				if !sendOnly {
+					indexID = fs.IndexID(protocol.LocalDeviceID)
+					maxSequence = fs.Sequence(protocol.LocalDeviceID)
+				}
 			} else {
 				indexID = fs.IndexID(device)
 				maxSequence = fs.Sequence(device)
@@ -2272,7 +2922,7 @@ func (m *Model) checkFolderPath(folder config.FolderConfiguration) error {
 		return errFolderPathEmpty
 	}
 
-	if fi, err := os.Stat(folder.Path()); err != nil || !fi.IsDir() {
+	if fi, err := fs.DefaultFilesystem.Stat(folder.Path()); err != nil || !fi.IsDir() {
 		return errFolderPathMissing
 	}
 
@@ -2389,7 +3039,9 @@ func (m *Model) CommitConfiguration(from, to config.Configuration) bool {
 		}
 
 		// This folder exists on both sides. Settings might have changed.
-		// Check if anything differs, apart from the label.
+		// Check if anything differs, apart from the label. This includes
+		// the resolved members of the device groups the folder is shared
+		// with, so a change in group membership restarts the folder.
 		toCfgCopy := toCfg
 		fromCfgCopy := fromCfg
 		fromCfgCopy.Label = ""
@@ -2434,6 +3086,8 @@ func (m *Model) CommitConfiguration(from, to config.Configuration) bool {
 		}
 	}
 
+	m.cleanPending(to)
+
 	// Some options don't require restart as those components handle it fine
 	// by themselves.
 	from.Options.URAccepted = to.Options.URAccepted
@@ -2527,7 +3181,7 @@ func stringSliceWithout(ss []string, s string) []string {
 }
 
 func readOffsetIntoBuf(file string, offset int64, buf []byte) error {
-	fd, err := os.Open(file)
+	fd, err := fs.DefaultFilesystem.Open(file)
 	if err != nil {
 		l.Debugln("readOffsetIntoBuf.Open", file, err)
 		return err
@@ -23,13 +23,16 @@ import (
 	"time"
 
 	"github.com/d4l3k/messagediff"
+	"github.com/syncthing/syncthing/lib/audit"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	srand "github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/scanner"
+	"github.com/syncthing/syncthing/lib/tlsutil"
 )
 
 var device1, device2 protocol.DeviceID
@@ -229,6 +232,7 @@ type fakeConnection struct {
 	model                    *Model
 	indexFn                  func(string, []protocol.FileInfo)
 	requestFn                func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error)
+	identityMigrations       []protocol.IdentityMigration
 	mut                      sync.Mutex
 }
 
@@ -314,6 +318,12 @@ func (f *fakeConnection) DownloadProgress(folder string, updates []protocol.File
 	})
 }
 
+func (f *fakeConnection) IdentityMigration(migration protocol.IdentityMigration) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.identityMigrations = append(f.identityMigrations, migration)
+}
+
 func (f *fakeConnection) addFile(name string, flags uint32, ftype protocol.FileInfoType, data []byte) {
 	f.mut.Lock()
 	defer f.mut.Unlock()
@@ -565,6 +575,168 @@ func TestClusterConfig(t *testing.T) {
 	}
 }
 
+func TestClusterConfigDeviceGroup(t *testing.T) {
+	cfg := config.New(device1)
+	cfg.Devices = []config.DeviceConfiguration{
+		{DeviceID: device1},
+		{DeviceID: device2},
+	}
+	cfg.DeviceGroups = []config.DeviceGroupConfiguration{
+		{Name: "group", Devices: []protocol.DeviceID{device2}},
+	}
+	cfg.Folders = []config.FolderConfiguration{
+		{
+			ID:           "folder1",
+			Devices:      []config.FolderDeviceConfiguration{{DeviceID: device1}},
+			DeviceGroups: []config.FolderDeviceGroupConfiguration{{Name: "group"}},
+		},
+	}
+
+	wrapper := config.Wrap("/tmp/test", config.New(device1))
+	if err := wrapper.Replace(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	db := db.OpenMemory()
+
+	m := NewModel(wrapper, protocol.LocalDeviceID, "device", "syncthing", "dev", db, nil)
+	m.AddFolder(wrapper.Folders()["folder1"])
+	m.ServeBackground()
+	defer m.Stop()
+
+	if !m.folderSharedWith("folder1", device2) {
+		t.Error("Folder should be shared with the group member")
+	}
+
+	cm := m.generateClusterConfig(device2)
+	if l := len(cm.Folders); l != 1 {
+		t.Fatalf("Incorrect number of folders %d != 1", l)
+	}
+	if l := len(cm.Folders[0].Devices); l != 2 {
+		t.Errorf("Incorrect number of devices %d != 2", l)
+	}
+}
+
+func TestAutoAcceptFolders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Already taken, so should not be used for the folder labeled Photos
+	if err := os.Mkdir(filepath.Join(dir, "Photos"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := config.NewFolderConfiguration("existing", filepath.Join(dir, "existing"))
+	wcfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{
+				DeviceID:          device1,
+				AutoAcceptFolders: true,
+				AutoAcceptPath:    filepath.Join(dir, "{label}"),
+				AutoAcceptType:    config.FolderTypeSendOnly,
+			},
+			{
+				DeviceID: device2,
+			},
+		},
+		Folders: []config.FolderConfiguration{existing},
+	})
+
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(existing)
+	m.ServeBackground()
+	defer m.Stop()
+	fc1 := &fakeConnection{id: device1}
+	fc2 := &fakeConnection{id: device2}
+	m.AddConnection(fc1, protocol.HelloResult{})
+	m.AddConnection(fc2, protocol.HelloResult{})
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{ID: "photos", Label: "Photos"},
+			{ID: "evil", Label: "../../evil"},
+			{ID: "existing", Label: "Existing"},
+		},
+	})
+
+	photos, ok := wcfg.Folder("photos")
+	if !ok {
+		t.Fatal("Folder should have been accepted")
+	}
+	if !fc1.closed {
+		t.Error("Connection should be closed to exchange indexes for the accepted folders")
+	}
+	if expected := filepath.Join(dir, "Photos (2)"); filepath.Clean(photos.Path()) != expected {
+		t.Errorf("Incorrect path %q != %q", photos.Path(), expected)
+	}
+	if photos.Type != config.FolderTypeSendOnly {
+		t.Errorf("Incorrect folder type %v", photos.Type)
+	}
+	// The local device ID used by the model here is not in the config
+	if devs := photos.DeviceIDs(); len(devs) != 1 || devs[0] != device1 {
+		t.Errorf("Folder should be shared with the offering device, not %v", devs)
+	}
+
+	evil, ok := wcfg.Folder("evil")
+	if !ok {
+		t.Fatal("Folder should have been accepted")
+	}
+	if filepath.Dir(filepath.Clean(evil.Path())) != dir {
+		t.Errorf("Label should not escape the template directory: %q", evil.Path())
+	}
+
+	// Existing folders are not changed
+	if cfg, _ := wcfg.Folder("existing"); len(cfg.Devices) != 0 {
+		t.Error("Existing folder should not be shared", cfg.Devices)
+	}
+
+	// Devices without auto accept get nothing
+	m.ClusterConfig(device2, protocol.ClusterConfig{
+		Folders: []protocol.Folder{{ID: "other", Label: "Other"}},
+	})
+	if _, ok := wcfg.Folder("other"); ok {
+		t.Error("Folder should not have been accepted")
+	}
+	if fc2.closed {
+		t.Error("Connection should be kept when nothing was accepted")
+	}
+}
+
+func TestPendingFolders(t *testing.T) {
+	wcfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: device1},
+		},
+	})
+
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.ServeBackground()
+	defer m.Stop()
+	m.AddConnection(&fakeConnection{id: device1}, protocol.HelloResult{})
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{{ID: "pending", Label: "Pending"}},
+	})
+
+	pending := m.PendingFolders()
+	if pf, ok := pending["pending"][device1]; !ok || pf.Label != "Pending" {
+		t.Fatalf("Folder should be pending: %v", pending)
+	}
+
+	// Sharing the folder with the device makes it no longer pending
+	raw := wcfg.RawCopy()
+	folder := config.NewFolderConfiguration("pending", "testdata")
+	folder.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}}
+	raw.Folders = append(raw.Folders, folder)
+	m.cleanPending(raw)
+	if pending := m.PendingFolders(); len(pending) != 0 {
+		t.Errorf("Folder should no longer be pending: %v", pending)
+	}
+}
+
 func TestIntroducer(t *testing.T) {
 	var introducedByAnyone protocol.DeviceID
 
@@ -928,6 +1100,114 @@ func TestIntroducer(t *testing.T) {
 	}
 }
 
+func TestIntroducerPolicy(t *testing.T) {
+	device3, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	newState := func(introducerCfg config.DeviceConfiguration) (*config.Wrapper, *Model) {
+		introducerCfg.DeviceID = device1
+		introducerCfg.Introducer = true
+		wcfg := config.Wrap("/tmp/test", config.Configuration{
+			Devices: []config.DeviceConfiguration{introducerCfg},
+			Folders: []config.FolderConfiguration{
+				{ID: "work-docs", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}}},
+				{ID: "private", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}}},
+			},
+		})
+		m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+		for _, folder := range wcfg.Folders() {
+			m.AddFolder(folder)
+		}
+		m.ServeBackground()
+		m.AddConnection(&fakeConnection{id: device1}, protocol.HelloResult{})
+		return wcfg, m
+	}
+
+	cc := protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{ID: "work-docs", Devices: []protocol.Device{{ID: device2}, {ID: device3}}},
+			{ID: "private", Devices: []protocol.Device{{ID: device2}}},
+		},
+	}
+
+	shared := func(wcfg *config.Wrapper, folder string, id protocol.DeviceID) bool {
+		for _, dev := range wcfg.Folders()[folder].Devices {
+			if dev.DeviceID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Only folders matching the patterns, and devices not denied
+
+	wcfg, m := newState(config.DeviceConfiguration{
+		IntroducerFolders:       []string{"work-*"},
+		IntroducerDeniedDevices: []protocol.DeviceID{device3},
+	})
+	m.ClusterConfig(device1, cc)
+	if _, ok := wcfg.Device(device2); !ok {
+		t.Error("device2 should have been introduced")
+	}
+	if _, ok := wcfg.Device(device3); ok {
+		t.Error("device3 is denied and should not have been introduced")
+	}
+	if !shared(wcfg, "work-docs", device2) {
+		t.Error("work-docs should be shared with device2")
+	}
+	if shared(wcfg, "private", device2) {
+		t.Error("private does not match the folder patterns and should not be shared with device2")
+	}
+
+	intros := m.Introductions()
+	if len(intros) != 2 {
+		t.Fatalf("expected two introductions, got %v", intros)
+	}
+	if intros[0].Action != db.IntroductionAddDevice || intros[0].Device != device2 || intros[0].Introducer != device1 {
+		t.Errorf("unexpected first introduction: %+v", intros[0])
+	}
+	if intros[1].Action != db.IntroductionShareFolder || intros[1].Folder != "work-docs" {
+		t.Errorf("unexpected second introduction: %+v", intros[1])
+	}
+
+	// Removal is audited as well
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{})
+	if _, ok := wcfg.Device(device2); ok {
+		t.Error("device2 should have been removed")
+	}
+	intros = m.Introductions()
+	if len(intros) != 4 || intros[2].Action != db.IntroductionUnshareFolder || intros[3].Action != db.IntroductionRemoveDevice {
+		t.Errorf("expected unshare and removal to be audited, got %v", intros)
+	}
+	m.Stop()
+
+	// Only allowed devices, pending approval
+
+	wcfg, m = newState(config.DeviceConfiguration{
+		IntroducerAllowedDevices:  []protocol.DeviceID{device2},
+		IntroducerRequireApproval: true,
+	})
+	defer m.Stop()
+	m.ClusterConfig(device1, cc)
+	if _, ok := wcfg.Device(device2); ok {
+		t.Error("device2 should be pending approval, not added")
+	}
+	pending := m.PendingDevices()
+	if pd, ok := pending[device2]; !ok || pd.IntroducedBy != device1.String() {
+		t.Errorf("device2 should be pending, introduced by device1: %v", pending)
+	}
+	if _, ok := pending[device3]; ok {
+		t.Error("device3 is not allowed and should not be pending")
+	}
+
+	// Once approved, the introducer shares the folders with the device
+	wcfg.SetDevice(config.DeviceConfiguration{DeviceID: device2, IntroducedBy: device1})
+	m.ClusterConfig(device1, cc)
+	if !shared(wcfg, "work-docs", device2) || !shared(wcfg, "private", device2) {
+		t.Error("folders should be shared with the approved device2")
+	}
+}
+
 func changeIgnores(t *testing.T, m *Model, expected []string) {
 	arrEqual := func(a, b []string) bool {
 		if len(a) != len(b) {
@@ -996,6 +1276,188 @@ func changeIgnores(t *testing.T, m *Model, expected []string) {
 	}
 }
 
+func TestFolderDeviceRoles(t *testing.T) {
+	// device1 may only send to us, device2 may only receive from us
+	fcfg := config.NewFolderConfiguration("default", "testdata")
+	fcfg.Devices = []config.FolderDeviceConfiguration{
+		{DeviceID: protocol.LocalDeviceID},
+		{DeviceID: device1, Role: config.FolderDeviceRoleSendOnly},
+		{DeviceID: device2, Role: config.FolderDeviceRoleReceiveOnly},
+	}
+	wcfg := config.Wrap("/tmp/test", config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{
+			config.NewDeviceConfiguration(device1, "device1"),
+			config.NewDeviceConfiguration(device2, "device2"),
+		},
+	})
+
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.ServeBackground()
+	defer m.Stop()
+
+	bs := make([]byte, 6)
+	if err := m.Request(device1, "default", "foo", 0, nil, false, bs); err == nil {
+		t.Error("Unexpected nil error on request from send only device")
+	}
+	if err := m.Request(device2, "default", "foo", 0, nil, false, bs); err != nil {
+		t.Error("Unexpected error on request from receive only device:", err)
+	}
+
+	version := protocol.Vector{Counters: []protocol.Counter{{ID: 42, Value: 1}}}
+	m.Index(device1, "default", []protocol.FileInfo{{Name: "fromSender", Version: version}})
+	m.Index(device2, "default", []protocol.FileInfo{{Name: "fromReceiver", Version: version}})
+
+	if _, ok := m.CurrentGlobalFile("default", "fromSender"); !ok {
+		t.Error("File from send only device should be global")
+	}
+	if _, ok := m.CurrentGlobalFile("default", "fromReceiver"); ok {
+		t.Error("File from receive only device should not be global")
+	}
+
+	// The send only device is told we have no index for the folder
+	for _, tc := range []struct {
+		device  protocol.DeviceID
+		indexID bool
+	}{
+		{device1, false},
+		{device2, true},
+	} {
+		cm := m.generateClusterConfig(tc.device)
+		if len(cm.Folders) != 1 {
+			t.Fatalf("Unexpected folders for %v: %v", tc.device, cm.Folders)
+		}
+		for _, dev := range cm.Folders[0].Devices {
+			if dev.ID == protocol.LocalDeviceID && (dev.IndexID != 0) != tc.indexID {
+				t.Errorf("Announced index ID %v to %v", dev.IndexID, tc.device)
+			}
+		}
+	}
+}
+
+func TestIdentityMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCert, err := tlsutil.NewCertificate(filepath.Join(dir, "old-cert.pem"), filepath.Join(dir, "old-key.pem"), "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCert, err := tlsutil.NewCertificate(filepath.Join(dir, "new-cert.pem"), filepath.Join(dir, "new-key.pem"), "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := protocol.NewDeviceID(oldCert.Certificate[0])
+	newID := protocol.NewDeviceID(newCert.Certificate[0])
+	migration, err := protocol.NewIdentityMigration(oldCert, newCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fcfg := config.NewFolderConfiguration("default", "testdata")
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: oldID}}
+	wcfg := config.Wrap(filepath.Join(dir, "config.xml"), config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{config.NewDeviceConfiguration(oldID, "old")},
+	})
+
+	ldb := db.OpenMemory()
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", ldb, nil)
+	m.AddFolder(fcfg)
+	m.ServeBackground()
+	defer m.Stop()
+
+	version := protocol.Vector{Counters: []protocol.Counter{{ID: 42, Value: 1}}}
+	m.Index(oldID, "default", []protocol.FileInfo{{Name: "foo", Version: version}})
+
+	// Only the old device can announce its migration
+	m.IdentityMigration(device1, migration)
+	if _, ok := ldb.IdentityMigrationTo(newID); ok {
+		t.Fatal("Migration from the wrong device should be ignored")
+	}
+
+	// The announcement is confirmed, but the old device is kept until it
+	// connects with the new certificate
+	fc := &fakeConnection{id: oldID, model: m}
+	m.AddConnection(fc, protocol.HelloResult{})
+	m.IdentityMigration(oldID, migration)
+	if len(fc.identityMigrations) != 1 {
+		t.Error("Migration should be confirmed")
+	}
+	if _, ok := wcfg.Device(oldID); !ok {
+		t.Error("Old device should be kept until the new one connects")
+	}
+	if _, ok := wcfg.Device(newID); ok {
+		t.Error("New device should not be known before it connects")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22000}
+	if err := m.OnHello(newID, addr, protocol.HelloResult{}); err != nil {
+		t.Fatal("New device should be accepted:", err)
+	}
+	if _, ok := wcfg.Device(oldID); ok {
+		t.Error("Old device should be gone")
+	}
+	if dev, ok := wcfg.Device(newID); !ok || dev.Name != "old" {
+		t.Errorf("New device should take over the old one, not %+v", dev)
+	}
+	if fcfg, _ := wcfg.Folder("default"); len(fcfg.Devices) != 1 || fcfg.Devices[0].DeviceID != newID {
+		t.Errorf("Folder should be shared with the new device, not %v", fcfg.Devices)
+	}
+	if _, ok := db.NewFileSet("default", ldb).Get(newID, "foo"); !ok {
+		t.Error("Files of the old device should belong to the new device")
+	}
+	if _, ok := ldb.IdentityMigrationTo(newID); ok {
+		t.Error("Migration should be forgotten once done")
+	}
+}
+
+func TestIdentityMigrationConfirmed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCert, err := tlsutil.NewCertificate(filepath.Join(dir, "old-cert.pem"), filepath.Join(dir, "old-key.pem"), "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCert, err := tlsutil.NewCertificate(filepath.Join(dir, "new-cert.pem"), filepath.Join(dir, "new-key.pem"), "syncthing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration, err := protocol.NewIdentityMigration(oldCert, newCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewModel(defaultConfig, protocol.NewDeviceID(oldCert.Certificate[0]), "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.ServeBackground()
+	defer m.Stop()
+
+	// Our own migration is announced to connected devices, but only counts
+	// as seen once confirmed
+	m.SetIdentityMigration(&migration)
+	fc := &fakeConnection{id: device1, model: m}
+	m.AddConnection(fc, protocol.HelloResult{})
+	if len(fc.identityMigrations) != 1 {
+		t.Error("Migration should be announced on connect")
+	}
+	if confirmed := m.IdentityMigrationConfirmed(); len(confirmed) != 0 {
+		t.Errorf("Migration should not be confirmed yet, not by %v", confirmed)
+	}
+
+	m.IdentityMigration(device1, migration)
+	if confirmed := m.IdentityMigrationConfirmed(); len(confirmed) != 1 || confirmed[0] != device1 {
+		t.Errorf("Migration should be confirmed by device1, not %v", confirmed)
+	}
+}
+
 func TestIgnores(t *testing.T) {
 	// Assure a clean start state
 	ioutil.WriteFile("testdata/.stfolder", nil, 0644)
@@ -1851,6 +2313,7 @@ func TestIssue3164(t *testing.T) {
 	fl := sendReceiveFolder{
 		dbUpdates: make(chan dbUpdateJob, 1),
 		dir:       "testdata",
+		mtimeFS:   fs.NewMtimeFS(fs.DefaultFilesystem, db.NewNamespacedKV(db.OpenMemory(), "mtime")),
 	}
 
 	fl.deleteDir(f, m)
@@ -2224,6 +2687,56 @@ func TestIssue3829(t *testing.T) {
 	}
 }
 
+func TestAuditLocalChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbi := db.OpenMemory()
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", dbi, nil)
+	m.SetAuditLog(audit.New(dir, audit.Options{}))
+	m.AddFolder(defaultFolderConfig)
+
+	file := protocol.FileInfo{
+		Name:       "file",
+		Size:       1,
+		ModifiedBy: device1.Short(),
+		Version:    protocol.Vector{}.Update(device1.Short()),
+		Blocks:     []protocol.BlockInfo{{Size: 1, Hash: []byte("hash")}},
+	}
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{file})
+	// Unchanged files aren't recorded
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{file})
+	file.Version = file.Version.Update(device1.Short())
+	m.updateLocalsFromPulling("default", []protocol.FileInfo{file})
+	file.Version = file.Version.Update(device1.Short())
+	file.Deleted = true
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{file})
+
+	entries, err := m.AuditLog("default", audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ action, origin string }{
+		{audit.ActionCreate, audit.OriginLocal},
+		{audit.ActionModify, audit.OriginRemote},
+		{audit.ActionDelete, audit.OriginLocal},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, expected %d: %+v", len(entries), len(expected), entries)
+	}
+	for i, e := range entries {
+		if e.Action != expected[i].action || e.Origin != expected[i].origin || e.Path != "file" || e.Device != device1.Short().String() {
+			t.Errorf("unexpected entry %d: %+v", i, e)
+		}
+	}
+	if entries[0].Size != 1 || entries[0].Hash == "" || entries[2].Hash != "" {
+		t.Errorf("unexpected sizes and hashes: %+v", entries)
+	}
+}
+
 func TestNoRequestsFromPausedDevices(t *testing.T) {
 	t.Skip("broken, fails randomly, #3843")
 
@@ -2490,3 +3003,173 @@ func (fakeAddr) Network() string {
 func (fakeAddr) String() string {
 	return "address"
 }
+
+func TestScanDetectsMoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"file", "copied"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "a", name), []byte("contents of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	if err := fcfg.CreateMarker(); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	// One file is moved, and the other one copied
+	moved, copied := filepath.Join("a", "file"), filepath.Join("a", "copied")
+	movedTo, copiedTo := filepath.Join("b", "file"), filepath.Join("b", "copied")
+	if err := os.Rename(filepath.Join(dir, moved), filepath.Join(dir, movedTo)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, copiedTo), []byte("contents of copied"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	old, _ := m.CurrentFolderFile("default", moved)
+	cur, ok := m.CurrentFolderFile("default", movedTo)
+	if !old.IsDeleted() || old.RenamedTo != movedTo {
+		t.Errorf("move not recorded: %v", old)
+	}
+	if !ok || cur.IsDeleted() || cur.Sequence < old.Sequence {
+		t.Errorf("the new file should follow the deletion: %v", cur)
+	}
+	if f, _ := m.CurrentFolderFile("default", copied); f.IsDeleted() || f.RenamedTo != "" {
+		t.Errorf("copy recorded as a move: %v", f)
+	}
+}
+
+func TestScanDetectsMovesWhileSettling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moved, movedTo := filepath.Join("a", "file"), filepath.Join("b", "file")
+	if err := ioutil.WriteFile(filepath.Join(dir, moved), []byte("contents of file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	fcfg.SettleTimeS = 1
+	if err := fcfg.CreateMarker(); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	// Every file is scanned once it has settled
+	settle := func() {
+		time.Sleep(1100 * time.Millisecond)
+		if err := m.ScanFolder("default"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settle()
+	if f, ok := m.CurrentFolderFile("default", moved); !ok || f.IsDeleted() {
+		t.Fatalf("file not scanned: %v", f)
+	}
+
+	// The moved file is new at its new name, so the deletion of the old
+	// one waits for it to settle
+	if err := os.Rename(filepath.Join(dir, moved), filepath.Join(dir, movedTo)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+	if files := m.UnsettledFiles("default"); len(files) != 1 || files[0] != movedTo {
+		t.Fatalf("unexpected unsettled files %v", files)
+	}
+	if f, _ := m.CurrentFolderFile("default", moved); f.IsDeleted() {
+		t.Errorf("deletion not held back: %v", f)
+	}
+
+	settle()
+	old, _ := m.CurrentFolderFile("default", moved)
+	cur, ok := m.CurrentFolderFile("default", movedTo)
+	if !old.IsDeleted() || old.RenamedTo != movedTo {
+		t.Errorf("move not recorded: %v", old)
+	}
+	if !ok || cur.IsDeleted() {
+		t.Errorf("moved file not scanned: %v", cur)
+	}
+}
+
+func TestUnsettledFiles(t *testing.T) {
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(defaultFolderConfig)
+
+	a, b, c := filepath.Join("dir", "a"), filepath.Join("dir", "b"), filepath.Join("other", "c")
+	m.setUnsettled("default", nil, []string{b, a, c})
+	if files := m.UnsettledFiles("default"); fmt.Sprint(files) != fmt.Sprint([]string{a, b, c}) {
+		t.Errorf("unexpected unsettled files %v", files)
+	}
+
+	// Scanning a subdirectory only replaces the files in it
+	m.setUnsettled("default", []string{"dir"}, []string{b})
+	if files := m.UnsettledFiles("default"); fmt.Sprint(files) != fmt.Sprint([]string{b, c}) {
+		t.Errorf("unexpected unsettled files after subdirectory scan %v", files)
+	}
+
+	m.setUnsettled("default", nil, nil)
+	if files := m.UnsettledFiles("default"); len(files) != 0 {
+		t.Errorf("unexpected unsettled files after full scan %v", files)
+	}
+}
+
+func TestDelayScanStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &folder{
+		scan:   newFolderScanner(defaultFolderConfig),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.DelayScan(time.Second)
+		close(done)
+	}()
+
+	// Nothing takes the delay, so it's dropped when the folder stops
+	f.Stop()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("delaying the scan of a stopped folder blocks")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// A moveDetector finds the files found by a scan that were moved, not
+// created. A new file was moved from a file of the same size and blocks
+// that no longer exists. The deletion of the old file is announced with
+// the new name, and before the new file, so that devices that see the new
+// file know it's a rename, and devices that see only the deletion can keep
+// the data for the new file.
+type moveDetector struct {
+	folder  string
+	dir     string
+	files   *db.FileSet
+	finder  *db.BlockFinder
+	mtimefs *fs.MtimeFS
+	shortID protocol.ShortID
+	moved   map[string]struct{} // old names, which may not be deleted in the db yet
+}
+
+func newMoveDetector(folder, dir string, files *db.FileSet, finder *db.BlockFinder, mtimefs *fs.MtimeFS, shortID protocol.ShortID) *moveDetector {
+	return &moveDetector{
+		folder:  folder,
+		dir:     dir,
+		files:   files,
+		finder:  finder,
+		mtimefs: mtimefs,
+		shortID: shortID,
+		moved:   make(map[string]struct{}),
+	}
+}
+
+// deletion returns the deletion of the file that the scanned file was moved
+// from, if any.
+func (d *moveDetector) deletion(f protocol.FileInfo) (protocol.FileInfo, bool) {
+	if f.IsDeleted() || f.IsInvalid() || f.Type != protocol.FileInfoTypeFile || len(f.Blocks) == 0 {
+		return protocol.FileInfo{}, false
+	}
+	if cur, ok := d.files.Get(protocol.LocalDeviceID, f.Name); ok && !cur.IsDeleted() && !cur.IsInvalid() {
+		// The file existed before and was changed
+		return protocol.FileInfo{}, false
+	}
+
+	var old protocol.FileInfo
+	found := d.finder.Iterate([]string{d.folder}, f.Blocks[0].Hash, func(_, name string, index int32) bool {
+		if index != 0 || name == f.Name {
+			return false
+		}
+		if _, ok := d.moved[name]; ok {
+			return false
+		}
+		cand, ok := d.files.Get(protocol.LocalDeviceID, name)
+		if !ok || cand.IsDeleted() || cand.IsInvalid() || cand.Type != protocol.FileInfoTypeFile ||
+			cand.Size != f.Size || !scanner.BlocksEqual(cand.Blocks, f.Blocks) {
+			return false
+		}
+		if _, err := d.mtimefs.Lstat(filepath.Join(d.dir, name)); !fs.IsNotExist(err) {
+			// Still there, so the new file is a copy
+			return false
+		}
+		old = cand
+		return true
+	})
+	if !found {
+		return protocol.FileInfo{}, false
+	}
+
+	l.Debugf("Folder %s: %s was moved to %s", d.folder, old.Name, f.Name)
+	d.moved[old.Name] = struct{}{}
+	return protocol.FileInfo{
+		Name:       old.Name,
+		Type:       old.Type,
+		ModifiedS:  old.ModifiedS,
+		ModifiedNs: old.ModifiedNs,
+		ModifiedBy: d.shortID,
+		Deleted:    true,
+		Version:    old.Version.Update(d.shortID),
+		RenamedTo:  f.Name,
+	}, true
+}
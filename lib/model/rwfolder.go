@@ -19,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/audit"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/events"
@@ -52,7 +53,7 @@ type copyBlocksState struct {
 }
 
 // Which filemode bits to preserve
-const retainBits = os.ModeSetgid | os.ModeSetuid | os.ModeSticky
+const retainBits = fs.ModeSetgid | fs.ModeSetuid | fs.ModeSticky
 
 var (
 	activity               = newDeviceActivity()
@@ -450,7 +451,7 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 	for _, fi := range processDirectly {
 		// Verify that the thing we are handling lives inside a directory,
 		// and not a symlink or empty space.
-		if err := osutil.TraversesSymlink(f.dir, filepath.Dir(fi.Name)); err != nil {
+		if err := fs.TraversesSymlink(f.mtimeFS, f.dir, filepath.Dir(fi.Name)); err != nil {
 			f.newError(fi.Name, err)
 			continue
 		}
@@ -539,7 +540,7 @@ nextFile:
 
 		// Verify that the thing we are handling lives inside a directory,
 		// and not a symlink or empty space.
-		if err := osutil.TraversesSymlink(f.dir, filepath.Dir(fi.Name)); err != nil {
+		if err := fs.TraversesSymlink(f.mtimeFS, f.dir, filepath.Dir(fi.Name)); err != nil {
 			f.newError(fi.Name, err)
 			continue
 		}
@@ -587,7 +588,7 @@ nextFile:
 
 	for _, file := range fileDeletions {
 		l.Debugln("Deleting file", file.Name)
-		f.deleteFile(file)
+		f.deleteFile(file, ignores)
 	}
 
 	for i := range dirDeletions {
@@ -631,7 +632,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 		f.newError(file.Name, err)
 		return
 	}
-	mode := os.FileMode(file.Permissions & 0777)
+	mode := fs.FileMode(file.Permissions & 0777)
 	if f.ignorePermissions(file) {
 		mode = 0777
 	}
@@ -647,7 +648,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 	// Most likely a file/link is getting replaced with a directory.
 	// Remove the file/link and fall through to directory creation.
 	case err == nil && (!info.IsDir() || info.IsSymlink()):
-		err = osutil.InWritableDir(os.Remove, realName)
+		err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, realName)
 		if err != nil {
 			l.Infof("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
 			f.newError(file.Name, err)
@@ -656,12 +657,12 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 		fallthrough
 	// The directory doesn't exist, so we create it with the right
 	// mode bits from the start.
-	case err != nil && os.IsNotExist(err):
+	case err != nil && fs.IsNotExist(err):
 		// We declare a function that acts on only the path name, so
 		// we can pass it to InWritableDir. We use a regular Mkdir and
 		// not MkdirAll because the parent should already exist.
 		mkdir := func(path string) error {
-			err = os.Mkdir(path, mode)
+			err = f.mtimeFS.Mkdir(path, mode)
 			if err != nil || f.ignorePermissions(file) {
 				return err
 			}
@@ -674,10 +675,10 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 
 			// Mask for the bits we want to preserve and add them in to the
 			// directories permissions.
-			return os.Chmod(path, mode|(os.FileMode(info.Mode())&retainBits))
+			return f.mtimeFS.Chmod(path, mode|(info.Mode()&retainBits))
 		}
 
-		if err = osutil.InWritableDir(mkdir, realName); err == nil {
+		if err = fs.InWritableDir(mkdir, f.mtimeFS, realName); err == nil {
 			f.dbUpdates <- dbUpdateJob{file, dbUpdateHandleDir}
 		} else {
 			l.Infof("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
@@ -697,7 +698,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 	// It's OK to change mode bits on stuff within non-writable directories.
 	if f.ignorePermissions(file) {
 		f.dbUpdates <- dbUpdateJob{file, dbUpdateHandleDir}
-	} else if err := os.Chmod(realName, mode|(os.FileMode(info.Mode())&retainBits)); err == nil {
+	} else if err := f.mtimeFS.Chmod(realName, mode|(info.Mode()&retainBits)); err == nil {
 		f.dbUpdates <- dbUpdateJob{file, dbUpdateHandleDir}
 	} else {
 		l.Infof("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
@@ -752,7 +753,7 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
 		// There is already something under that name. Remove it to replace
 		// with the symlink. This also handles the "change symlink type"
 		// path.
-		err = osutil.InWritableDir(os.Remove, realName)
+		err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, realName)
 		if err != nil {
 			l.Infof("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
 			f.newError(file.Name, err)
@@ -763,10 +764,10 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
 	// We declare a function that acts on only the path name, so
 	// we can pass it to InWritableDir.
 	createLink := func(path string) error {
-		return os.Symlink(file.SymlinkTarget, path)
+		return f.mtimeFS.CreateSymlink(path, file.SymlinkTarget)
 	}
 
-	if err = osutil.InWritableDir(createLink, realName); err == nil {
+	if err = fs.InWritableDir(createLink, f.mtimeFS, realName); err == nil {
 		f.dbUpdates <- dbUpdateJob{file, dbUpdateHandleSymlink}
 	} else {
 		l.Infof("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
@@ -804,24 +805,20 @@ func (f *sendReceiveFolder) deleteDir(file protocol.FileInfo, matcher *ignore.Ma
 	}
 
 	// Delete any temporary files lying around in the directory
-	dir, _ := os.Open(realName)
-	if dir != nil {
-		files, _ := dir.Readdirnames(-1)
-		for _, dirFile := range files {
-			fullDirFile := filepath.Join(file.Name, dirFile)
-			if ignore.IsTemporary(dirFile) || (matcher != nil &&
-				matcher.Match(fullDirFile).IsDeletable()) {
-				os.RemoveAll(filepath.Join(f.dir, fullDirFile))
-			}
+	files, _ := f.mtimeFS.DirNames(realName)
+	for _, dirFile := range files {
+		fullDirFile := filepath.Join(file.Name, dirFile)
+		if ignore.IsTemporary(dirFile) || (matcher != nil &&
+			matcher.Match(fullDirFile).IsDeletable()) {
+			f.mtimeFS.RemoveAll(filepath.Join(f.dir, fullDirFile))
 		}
-		dir.Close()
 	}
 
-	err = osutil.InWritableDir(os.Remove, realName)
-	if err == nil || os.IsNotExist(err) {
+	err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, realName)
+	if err == nil || fs.IsNotExist(err) {
 		// It was removed or it doesn't exist to start with
 		f.dbUpdates <- dbUpdateJob{file, dbUpdateDeleteDir}
-	} else if _, serr := f.mtimeFS.Lstat(realName); serr != nil && !os.IsPermission(serr) {
+	} else if _, serr := f.mtimeFS.Lstat(realName); serr != nil && !fs.IsPermission(serr) {
 		// We get an error just looking at the directory, and it's not a
 		// permission problem. Lets assume the error is in fact some variant
 		// of "file does not exist" (possibly expressed as some parent being a
@@ -834,7 +831,7 @@ func (f *sendReceiveFolder) deleteDir(file protocol.FileInfo, matcher *ignore.Ma
 }
 
 // deleteFile attempts to delete the given file
-func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
+func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo, matcher *ignore.Matcher) {
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -868,17 +865,21 @@ func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
 		// of deleting. Also merge with the version vector we had, to indicate
 		// we have resolved the conflict.
 		file.Version = file.Version.Merge(cur.Version)
-		err = osutil.InWritableDir(f.moveForConflict, realName)
+		err = fs.InWritableDir(f.moveForConflict, f.mtimeFS, realName)
+	} else if file.RenamedTo != "" && f.keepForRename(realName, file.RenamedTo, matcher) {
+		// The file was moved to where we don't have it yet. Its data is
+		// kept for the pull of the new file to reuse.
+		l.Debugln(f, "keeping", file.Name, "for", file.RenamedTo)
 	} else if f.versioner != nil {
-		err = osutil.InWritableDir(f.versioner.Archive, realName)
+		err = fs.InWritableDir(f.archive, f.mtimeFS, realName)
 	} else {
-		err = osutil.InWritableDir(os.Remove, realName)
+		err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, realName)
 	}
 
-	if err == nil || os.IsNotExist(err) {
+	if err == nil || fs.IsNotExist(err) {
 		// It was removed or it doesn't exist to start with
 		f.dbUpdates <- dbUpdateJob{file, dbUpdateDeleteFile}
-	} else if _, serr := f.mtimeFS.Lstat(realName); serr != nil && !os.IsPermission(serr) {
+	} else if _, serr := f.mtimeFS.Lstat(realName); serr != nil && !fs.IsPermission(serr) {
 		// We get an error just looking at the file, and it's not a permission
 		// problem. Lets assume the error is in fact some variant of "file
 		// does not exist" (possibly expressed as some parent being a file and
@@ -890,6 +891,38 @@ func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
 	}
 }
 
+// keepForRename moves the file to the temporary file of the name it was
+// renamed to, which the puller reuses the blocks of. That's only done when
+// the renamed file is still to be pulled, as the data would otherwise be
+// left behind in a temporary file instead of being removed or versioned.
+func (f *sendReceiveFolder) keepForRename(realName, renamedTo string, matcher *ignore.Matcher) bool {
+	if cur, ok := f.model.CurrentFolderFile(f.folderID, renamedTo); ok && !cur.IsDeleted() {
+		return false
+	}
+	global, ok := f.model.CurrentGlobalFile(f.folderID, renamedTo)
+	if !ok || global.IsDeleted() || global.IsInvalid() || global.Type != protocol.FileInfoTypeFile {
+		return false
+	}
+	if matcher != nil && matcher.Match(renamedTo).IsIgnored() {
+		return false
+	}
+	tempName, err := rootedJoinedPath(f.dir, ignore.TempName(renamedTo))
+	if err != nil {
+		return false
+	}
+	err = fs.InWritableDir(func(name string) error {
+		return f.mtimeFS.Rename(name, tempName)
+	}, f.mtimeFS, realName)
+	if err != nil {
+		l.Debugln(f, "keeping for rename:", err)
+		return false
+	}
+	// Not to be removed as an old temporary file by the next scan
+	now := time.Now()
+	f.mtimeFS.Chtimes(tempName, now, now)
+	return true
+}
+
 // renameFile attempts to rename an existing file to a destination
 // and set the right attributes on it.
 func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
@@ -941,12 +974,12 @@ func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
 	}
 
 	if f.versioner != nil {
-		err = osutil.Copy(from, to)
+		err = fs.Copy(f.mtimeFS, from, to)
 		if err == nil {
-			err = osutil.InWritableDir(f.versioner.Archive, from)
+			err = fs.InWritableDir(f.archive, f.mtimeFS, from)
 		}
 	} else {
-		err = osutil.TryRename(from, to)
+		err = fs.TryRename(f.mtimeFS, from, to)
 	}
 
 	if err == nil {
@@ -963,13 +996,19 @@ func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
 			return
 		}
 
+		if f.model.auditLog != nil {
+			e := auditEntry(f.folderID, audit.ActionRename, audit.OriginRemote, target)
+			e.OldPath = source.Name
+			f.model.auditLog.Record(e)
+		}
+
 		f.dbUpdates <- dbUpdateJob{target, dbUpdateHandleFile}
 	} else {
 		// We failed the rename so we have a source file that we still need to
 		// get rid of. Attempt to delete it instead so that we make *some*
 		// progress. The target is unhandled.
 
-		err = osutil.InWritableDir(os.Remove, from)
+		err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, from)
 		if err != nil {
 			l.Infof("Puller (folder %q, file %q): delete %q after failed rename: %v", f.folderID, target.Name, source.Name, err)
 			f.newError(target.Name, err)
@@ -1096,7 +1135,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 
 	// Check for an old temporary file which might have some blocks we could
 	// reuse.
-	tempBlocks, err := scanner.HashFile(f.ctx, fs.DefaultFilesystem, tempName, protocol.BlockSize, nil, false)
+	tempBlocks, err := scanner.HashFile(f.ctx, f.mtimeFS, tempName, protocol.BlockSize, nil, false)
 	if err == nil {
 		// Check for any reusable blocks in the temp file
 		tempCopyBlocks, _ := scanner.BlockDiff(tempBlocks, file.Blocks)
@@ -1124,7 +1163,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 			// Otherwise, discard the file ourselves in order for the
 			// sharedpuller not to panic when it fails to exclusively create a
 			// file which already exists
-			osutil.InWritableDir(os.Remove, tempName)
+			fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, tempName)
 		}
 	} else {
 		// Copy the blocks, as we don't want to shuffle them on the FileInfo
@@ -1155,6 +1194,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 
 	s := sharedPullerState{
 		file:             file,
+		fs:               f.mtimeFS,
 		folder:           f.folderID,
 		tempName:         tempName,
 		realName:         realName,
@@ -1190,7 +1230,7 @@ func (f *sendReceiveFolder) shortcutFile(file protocol.FileInfo) error {
 		return err
 	}
 	if !f.ignorePermissions(file) {
-		if err := os.Chmod(realName, os.FileMode(file.Permissions&0777)); err != nil {
+		if err := f.mtimeFS.Chmod(realName, fs.FileMode(file.Permissions&0777)); err != nil {
 			l.Infof("Puller (folder %q, file %q): shortcut: chmod: %v", f.folderID, file.Name, err)
 			f.newError(file.Name, err)
 			return err
@@ -1251,7 +1291,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 				}
 
 				if len(hashesToFind) > 0 {
-					weakHashFinder, err = weakhash.NewFinder(state.realName, protocol.BlockSize, hashesToFind)
+					weakHashFinder, err = weakhash.NewFinder(f.mtimeFS, state.realName, protocol.BlockSize, hashesToFind)
 					if err != nil {
 						l.Debugln("weak hasher", err)
 					}
@@ -1308,7 +1348,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 					if err != nil {
 						return false
 					}
-					fd, err := os.Open(inFile)
+					fd, err := f.mtimeFS.Open(inFile)
 					if err != nil {
 						return false
 					}
@@ -1442,7 +1482,7 @@ func (f *sendReceiveFolder) pullerRoutine(in <-chan pullBlockState, out chan<- *
 func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 	// Set the correct permission bits on the new file
 	if !f.ignorePermissions(state.file) {
-		if err := os.Chmod(state.tempName, os.FileMode(state.file.Permissions&0777)); err != nil {
+		if err := f.mtimeFS.Chmod(state.tempName, fs.FileMode(state.file.Permissions&0777)); err != nil {
 			return err
 		}
 	}
@@ -1461,7 +1501,7 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 			// and future hard ignores before attempting a directory delete.
 			// Should share code with f.deletDir().
 
-			if err = osutil.InWritableDir(os.Remove, state.realName); err != nil {
+			if err = fs.InWritableDir(f.mtimeFS.Remove, f.mtimeFS, state.realName); err != nil {
 				return err
 			}
 
@@ -1472,7 +1512,7 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 			// we have resolved the conflict.
 
 			state.file.Version = state.file.Version.Merge(state.version)
-			if err = osutil.InWritableDir(f.moveForConflict, state.realName); err != nil {
+			if err = fs.InWritableDir(f.moveForConflict, f.mtimeFS, state.realName); err != nil {
 				return err
 			}
 
@@ -1481,7 +1521,7 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 			// file before we replace it. Archiving a non-existent file is not
 			// an error.
 
-			if err = f.versioner.Archive(state.realName); err != nil {
+			if err = f.archive(state.realName); err != nil {
 				return err
 			}
 		}
@@ -1489,7 +1529,7 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 
 	// Replace the original content with the new one. If it didn't work,
 	// leave the temp file in place for reuse.
-	if err := osutil.TryRename(state.tempName, state.realName); err != nil {
+	if err := fs.TryRename(f.mtimeFS, state.tempName, state.realName); err != nil {
 		return err
 	}
 
@@ -1560,7 +1600,7 @@ func (f *sendReceiveFolder) dbUpdaterRoutine() {
 		changedDirs = make([]string, 0, maxBatchSize)
 	}
 
-	syncFilesOnce := func(files []string, syncFn func(string) error) {
+	syncFilesOnce := func(files []string, syncFn func(fs.Filesystem, string) error) {
 		sort.Strings(files)
 		var lastFile string
 		for _, file := range files {
@@ -1568,7 +1608,7 @@ func (f *sendReceiveFolder) dbUpdaterRoutine() {
 				continue
 			}
 			lastFile = file
-			if err := syncFn(file); err != nil {
+			if err := syncFn(f.mtimeFS, file); err != nil {
 				l.Infof("fsync %q failed: %v", file, err)
 			}
 		}
@@ -1608,9 +1648,9 @@ func (f *sendReceiveFolder) dbUpdaterRoutine() {
 
 		if f.Fsync {
 			// sync files and dirs to disk
-			syncFilesOnce(changedFiles, osutil.SyncFile)
+			syncFilesOnce(changedFiles, fs.SyncFile)
 			changedFiles = changedFiles[:0]
-			syncFilesOnce(changedDirs, osutil.SyncDir)
+			syncFilesOnce(changedDirs, fs.SyncDir)
 			changedDirs = changedDirs[:0]
 		}
 
@@ -1682,24 +1722,28 @@ func removeAvailability(availabilities []Availability, availability Availability
 func (f *sendReceiveFolder) moveForConflict(name string) error {
 	if strings.Contains(filepath.Base(name), ".sync-conflict-") {
 		l.Infoln("Conflict for", name, "which is already a conflict copy; not copying again.")
-		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		if err := f.mtimeFS.Remove(name); err != nil && !fs.IsNotExist(err) {
 			return err
 		}
+		f.auditLocalVersion(audit.ActionConflict, name, "")
 		return nil
 	}
 
 	if f.MaxConflicts == 0 {
-		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		if err := f.mtimeFS.Remove(name); err != nil && !fs.IsNotExist(err) {
 			return err
 		}
+		f.auditLocalVersion(audit.ActionConflict, name, "")
 		return nil
 	}
 
 	ext := filepath.Ext(name)
 	withoutExt := name[:len(name)-len(ext)]
 	newName := withoutExt + time.Now().Format(".sync-conflict-20060102-150405") + ext
-	err := os.Rename(name, newName)
-	if os.IsNotExist(err) {
+	err := f.mtimeFS.Rename(name, newName)
+	if err == nil {
+		f.auditLocalVersion(audit.ActionConflict, name, newName)
+	} else if fs.IsNotExist(err) {
 		// We were supposed to move a file away but it does not exist. Either
 		// the user has already moved it away, or the conflict was between a
 		// remote modification and a local delete. In either way it does not
@@ -1707,11 +1751,11 @@ func (f *sendReceiveFolder) moveForConflict(name string) error {
 		err = nil
 	}
 	if f.MaxConflicts > -1 {
-		matches, gerr := osutil.Glob(withoutExt + ".sync-conflict-????????-??????" + ext)
+		matches, gerr := f.mtimeFS.Glob(withoutExt + ".sync-conflict-????????-??????" + ext)
 		if gerr == nil && len(matches) > f.MaxConflicts {
 			sort.Sort(sort.Reverse(sort.StringSlice(matches)))
 			for _, match := range matches[f.MaxConflicts:] {
-				gerr = os.Remove(match)
+				gerr = f.mtimeFS.Remove(match)
 				if gerr != nil {
 					l.Debugln(f, "removing extra conflict", gerr)
 				}
@@ -1723,6 +1767,48 @@ func (f *sendReceiveFolder) moveForConflict(name string) error {
 	return err
 }
 
+// archive lets the versioner archive the file.
+func (f *sendReceiveFolder) archive(name string) error {
+	if err := f.versioner.Archive(name); err != nil {
+		return err
+	}
+	f.auditLocalVersion(audit.ActionArchive, name, "")
+	return nil
+}
+
+// auditLocalVersion records what was done to our version of the file, if
+// auditing.
+func (f *sendReceiveFolder) auditLocalVersion(action, realName, conflictCopy string) {
+	if f.model.auditLog == nil {
+		return
+	}
+
+	name := f.relativeName(realName)
+	e := audit.Entry{
+		Folder: f.folderID,
+		Path:   name,
+		Action: action,
+		Origin: audit.OriginRemote,
+	}
+	if cur, ok := f.model.CurrentFolderFile(f.folderID, name); ok {
+		e = auditEntry(f.folderID, action, audit.OriginRemote, cur)
+	}
+	if conflictCopy != "" {
+		e.ConflictCopy = f.relativeName(conflictCopy)
+	}
+	f.model.auditLog.Record(e)
+}
+
+// relativeName returns the name in the folder, in wire format, of the file
+// at the real path.
+func (f *sendReceiveFolder) relativeName(realName string) string {
+	rel, err := filepath.Rel(f.dir, realName)
+	if err != nil {
+		return realName
+	}
+	return osutil.NormalizedFilename(rel)
+}
+
 func (f *sendReceiveFolder) newError(path string, err error) {
 	f.errorsMut.Lock()
 	defer f.errorsMut.Unlock()
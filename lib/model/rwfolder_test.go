@@ -10,6 +10,7 @@ import (
 	"context"
 	"crypto/rand"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -21,6 +22,7 @@ import (
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/versioner"
 )
 
 func TestMain(m *testing.M) {
@@ -609,3 +611,131 @@ func TestDeregisterOnFailInPull(t *testing.T) {
 		t.Fatal("Didn't get anything to the finisher")
 	}
 }
+
+// When storage misbehaves while finishing a file, the old file must stay as
+// it was and the new data must remain in the temp file, never ending up
+// half written in place.
+func TestPerformFinishFaults(t *testing.T) {
+	realName := filepath.Join("folder", "file")
+	tempName := filepath.Join("folder", ignore.TempName("file"))
+
+	cases := []struct {
+		name      string
+		rules     []fs.FaultRule
+		versioned bool
+		finished  bool
+	}{
+		{"rename", []fs.FaultRule{{Ops: []string{fs.FaultOpRename}, Error: "ENOSPC"}}, false, false},
+		{"chmod", []fs.FaultRule{{Ops: []string{fs.FaultOpChmod}, Error: "EACCES"}}, false, false},
+		{"archive", []fs.FaultRule{{Pattern: "file~*", Ops: []string{fs.FaultOpRename}, Error: "EIO"}}, true, false},
+		{"archive dir", []fs.FaultRule{{Ops: []string{fs.FaultOpMkdir}, Error: "EROFS"}}, true, false},
+		{"chtimes", []fs.FaultRule{{Ops: []string{fs.FaultOpChtimes}, Error: "EIO"}}, false, true},
+	}
+
+	for _, tc := range cases {
+		mem := fs.NewMemoryFilesystem(fs.MemoryOptions{})
+		faults := fs.NewFaultFilesystem(mem)
+
+		mem.Mkdir("folder", 0755)
+		for name, data := range map[string]string{realName: "old", tempName: "new"} {
+			fd, err := mem.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fd.Write([]byte(data))
+			fd.Close()
+		}
+
+		m := setUpModel(setUpFile("file", nil))
+		f := setUpSendReceiveFolder(m)
+		f.dir = "folder"
+		f.mtimeFS = fs.NewMtimeFS(faults, db.NewNamespacedKV(m.db, "mtime"))
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+		if tc.versioned {
+			f.versioner = versioner.NewSimple("default", f.mtimeFS, "folder", map[string]string{"keep": "5"})
+		}
+
+		state := &sharedPullerState{
+			file:     protocol.FileInfo{Name: "file", Permissions: 0644, Size: 3},
+			fs:       f.mtimeFS,
+			folder:   "default",
+			tempName: tempName,
+			realName: realName,
+			mut:      sync.NewRWMutex(),
+		}
+
+		if err := faults.SetRules(tc.rules); err != nil {
+			t.Fatal(err)
+		}
+		err := f.performFinish(state)
+		faults.SetRules(nil)
+
+		expected := map[string]string{realName: "old", tempName: "new"}
+		if tc.finished {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			expected = map[string]string{realName: "new"}
+		} else if err == nil {
+			t.Errorf("%s: finishing should fail", tc.name)
+		}
+
+		for name, data := range expected {
+			fd, err := mem.Open(name)
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+				continue
+			}
+			bs, _ := ioutil.ReadAll(fd)
+			fd.Close()
+			if string(bs) != data {
+				t.Errorf("%s: %s contains %q, expected %q", tc.name, name, bs, data)
+			}
+		}
+	}
+}
+
+func TestDeleteKeepsMovedFile(t *testing.T) {
+	oldName := filepath.Join("testdata", "movedfrom")
+	tempName := filepath.Join("testdata", ignore.TempName("movedto"))
+	defer os.Remove(oldName)
+	defer os.Remove(tempName)
+
+	for _, needed := range []bool{true, false} {
+		if err := ioutil.WriteFile(oldName, []byte("moved"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		file := setUpFile("movedfrom", []int{0})
+		file.Version = protocol.Vector{}.Update(device1.Short())
+		m := setUpModel(file)
+		f := setUpSendReceiveFolder(m)
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+
+		if needed {
+			moved := setUpFile("movedto", []int{0})
+			moved.Version = protocol.Vector{}.Update(device1.Short())
+			m.folderFiles["default"].Update(device1, []protocol.FileInfo{moved})
+		}
+
+		file.Deleted = true
+		file.Version = file.Version.Update(device1.Short())
+		file.RenamedTo = "movedto"
+		f.deleteFile(file, nil)
+
+		if job := <-f.dbUpdates; job.jobType != dbUpdateDeleteFile {
+			t.Errorf("unexpected db update %v", job)
+		}
+		if _, err := os.Lstat(oldName); !os.IsNotExist(err) {
+			t.Error("old file still there:", err)
+		}
+		bs, err := ioutil.ReadFile(tempName)
+		switch {
+		case needed && (err != nil || string(bs) != "moved"):
+			t.Errorf("data not kept in the temporary file: %q, %v", bs, err)
+		case !needed && !os.IsNotExist(err):
+			t.Errorf("data kept for a file that isn't pulled: %v", err)
+		}
+		os.Remove(tempName)
+	}
+}
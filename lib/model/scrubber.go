@@ -0,0 +1,331 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+	"golang.org/x/time/rate"
+)
+
+var errModified = errors.New("file modified since it was scanned")
+
+// A scrubber reads back the files of a folder in the background and checks
+// them against the blocks in the index. Files are only hashed again by the
+// scanner when their size or modification time changes, so this is what
+// finds data that went bad on disk. Corrupted blocks are fetched from
+// devices that have the same version of the file, and the repaired file
+// replaces the old one with the same modification time.
+type scrubber struct {
+	model    *Model
+	folder   string
+	dir      string
+	files    *db.FileSet
+	mtimefs  *fs.MtimeFS
+	interval time.Duration
+	limiter  *rate.Limiter
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// A scrubResult is the outcome of a pass over the folder.
+type scrubResult struct {
+	files      int
+	bytes      int64
+	corrupted  []string
+	repaired   []string
+	unrepaired []string // corrupted, and no device could provide the blocks
+}
+
+func newScrubber(m *Model, cfg config.FolderConfiguration, files *db.FileSet) *scrubber {
+	// The rate is in KiB/s in the config, as for the bandwidth limits, and
+	// -1 when explicitly unlimited.
+	limit := rate.Inf
+	if cfg.ScrubMaxKbps > 0 {
+		limit = 1024 * rate.Limit(cfg.ScrubMaxKbps)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scrubber{
+		model:    m,
+		folder:   cfg.ID,
+		dir:      cfg.Path(),
+		files:    files,
+		mtimefs:  files.MtimeFS(),
+		interval: time.Duration(cfg.ScrubIntervalS) * time.Second,
+		limiter:  rate.NewLimiter(limit, protocol.BlockSize),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (s *scrubber) Serve() {
+	l.Debugln(s, "starting")
+	defer l.Debugln(s, "exiting")
+
+	timer := time.NewTimer(s.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if err := s.model.CheckFolderHealth(s.folder); err != nil {
+				l.Debugln(s, "skipping pass due to folder error:", err)
+			} else if res, err := s.scrub(); err == nil {
+				s.report(res)
+			}
+			timer.Reset(s.interval)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *scrubber) Stop() {
+	s.cancel()
+}
+
+func (s *scrubber) String() string {
+	return fmt.Sprintf("scrubber/%s@%p", s.folder, s)
+}
+
+// scrub checks all the files of the folder. It returns an error only when
+// stopped before the end.
+func (s *scrubber) scrub() (scrubResult, error) {
+	// The names are collected first, as reading the files takes a long time
+	// and the files can change in between.
+	var names []string
+	s.files.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		if f.Type == protocol.FileInfoTypeFile && !f.IsDeleted() && !f.IsInvalid() && f.Size > 0 {
+			names = append(names, f.Name)
+		}
+		return true
+	})
+
+	var res scrubResult
+	for _, name := range names {
+		if err := s.ctx.Err(); err != nil {
+			return res, err
+		}
+
+		f, ok := s.files.Get(protocol.LocalDeviceID, name)
+		if !ok || f.IsDeleted() || f.IsInvalid() || f.Type != protocol.FileInfoTypeFile {
+			continue
+		}
+		bad, err := s.verify(f)
+		if err != nil {
+			// Gone, changed or unreadable, which is for the scanner to find
+			l.Debugln(s, "skipping", name+":", err)
+			continue
+		}
+		res.files++
+		res.bytes += f.Size
+		if len(bad) == 0 {
+			continue
+		}
+
+		l.Warnf("Folder %q: %d corrupted blocks in %s", s.folder, len(bad), name)
+		res.corrupted = append(res.corrupted, name)
+		if err := s.repair(f, bad); err != nil {
+			l.Warnf("Folder %q: repairing %s: %v", s.folder, name, err)
+			res.unrepaired = append(res.unrepaired, name)
+			continue
+		}
+		l.Infof("Folder %q: repaired %s", s.folder, name)
+		res.repaired = append(res.repaired, name)
+	}
+	return res, nil
+}
+
+// unchanged returns an error if the file on disk is not the one in the
+// index anymore.
+func (s *scrubber) unchanged(f protocol.FileInfo) error {
+	info, err := s.mtimefs.Lstat(filepath.Join(s.dir, f.Name))
+	if err != nil {
+		return err
+	}
+	if !info.IsRegular() || info.Size() != f.Size || !info.ModTime().Equal(f.ModTime()) {
+		return errModified
+	}
+	return nil
+}
+
+// verify returns the blocks of the file that don't match their hashes.
+func (s *scrubber) verify(f protocol.FileInfo) ([]protocol.BlockInfo, error) {
+	if err := s.unchanged(f); err != nil {
+		return nil, err
+	}
+	fd, err := s.mtimefs.Open(filepath.Join(s.dir, f.Name))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var bad []protocol.BlockInfo
+	for _, b := range f.Blocks {
+		r := &limitedReader{ctx: s.ctx, r: io.NewSectionReader(fd, b.Offset, int64(b.Size)), limiter: s.limiter}
+		if err := scanner.Verify(r, int(b.Size), []protocol.BlockInfo{b}); err != nil {
+			if cerr := s.ctx.Err(); cerr != nil {
+				return nil, cerr
+			}
+			l.Debugf("%v: %s block %d: %v", s, f.Name, b.Offset/int64(protocol.BlockSize), err)
+			bad = append(bad, b)
+		}
+	}
+	return bad, nil
+}
+
+// repair fetches the blocks from the devices that have the same version of
+// the file and writes them into it.
+func (s *scrubber) repair(f protocol.FileInfo, bad []protocol.BlockInfo) error {
+	devices := s.model.scrubSources(s.folder, f)
+	if len(devices) == 0 {
+		return fmt.Errorf("no connected device has version %v", f.Version)
+	}
+
+	fixed := make([][]byte, len(bad))
+	for i, b := range bad {
+		for _, dev := range devices {
+			buf, err := s.model.requestGlobal(dev, s.folder, f.Name, b.Offset, int(b.Size), b.Hash, false)
+			if err != nil {
+				l.Debugln(s, "request of", f.Name, "from", dev, "failed:", err)
+				continue
+			}
+			if _, err := scanner.VerifyBuffer(buf, b); err != nil {
+				l.Debugln(s, f.Name, "from", dev, "is corrupted as well:", err)
+				continue
+			}
+			fixed[i] = buf
+			break
+		}
+		if fixed[i] == nil {
+			return fmt.Errorf("no device could provide the block at offset %d", b.Offset)
+		}
+	}
+
+	// The repaired file is put together in a temporary file, so that no
+	// data is written into the file should it be replaced meanwhile.
+	path := filepath.Join(s.dir, f.Name)
+	tempName := filepath.Join(s.dir, ignore.TempName(f.Name+".scrub"))
+	if err := s.writeRepaired(path, tempName, bad, fixed); err != nil {
+		s.mtimefs.Remove(tempName)
+		return err
+	}
+
+	// The file may have been changed while the blocks were fetched.
+	if cur, ok := s.files.Get(protocol.LocalDeviceID, f.Name); !ok || !cur.Version.Equal(f.Version) {
+		s.mtimefs.Remove(tempName)
+		return errModified
+	}
+	if err := s.unchanged(f); err != nil {
+		s.mtimefs.Remove(tempName)
+		return err
+	}
+	if err := fs.TryRename(s.mtimefs, tempName, path); err != nil {
+		s.mtimefs.Remove(tempName)
+		return err
+	}
+	// The file is as it was scanned, and should look that way.
+	return s.mtimefs.Chtimes(path, f.ModTime(), f.ModTime())
+}
+
+// writeRepaired writes a copy of the file with the fixed blocks to the
+// temporary file.
+func (s *scrubber) writeRepaired(path, tempName string, bad []protocol.BlockInfo, fixed [][]byte) error {
+	info, err := s.mtimefs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	src, err := s.mtimefs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := s.mtimefs.OpenFile(tempName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode()&fs.ModePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	for i, b := range bad {
+		if _, err := dst.WriteAt(fixed[i], b.Offset); err != nil {
+			dst.Close()
+			return err
+		}
+	}
+	return dst.Close()
+}
+
+func (s *scrubber) report(res scrubResult) {
+	l.Debugf("%v: checked %d files, %d bytes, %d corrupted", s, res.files, res.bytes, len(res.corrupted))
+	events.Default.Log(events.FolderScrubResult, map[string]interface{}{
+		"folder":     s.folder,
+		"files":      res.files,
+		"bytes":      res.bytes,
+		"corrupted":  res.corrupted,
+		"repaired":   res.repaired,
+		"unrepaired": res.unrepaired,
+	})
+}
+
+// scrubSources returns the connected devices that have the same version of
+// the file.
+func (m *Model) scrubSources(folder string, file protocol.FileInfo) []protocol.DeviceID {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	devices := m.folderDevices.sortedDevices(folder)
+	m.fmut.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	var res []protocol.DeviceID
+	for _, dev := range devices {
+		if !m.ConnectedTo(dev) {
+			continue
+		}
+		if f, ok := files.Get(dev, file.Name); ok && !f.IsInvalid() && f.Version.Equal(file.Version) {
+			res = append(res, dev)
+		}
+	}
+	return res
+}
+
+// A limitedReader reads at the rate of the limiter.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *limitedReader) Read(buf []byte) (int, error) {
+	if len(buf) > r.limiter.Burst() {
+		buf = buf[:r.limiter.Burst()]
+	}
+	n, err := r.r.Read(buf)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestScrubRepairsCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make(map[string][]byte)
+	for _, name := range []string{"shared", "local", "intact"} {
+		bs := make([]byte, 2*protocol.BlockSize+100)
+		for i := range bs {
+			bs[i] = byte(i + len(name))
+		}
+		data[name] = bs
+		if err := ioutil.WriteFile(filepath.Join(dir, name), bs, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}}
+	if err := fcfg.CreateMarker(); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other device has the same version of one of the corrupted files
+	fc := addFakeConn(m, device1)
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+		return data[name][offset : offset+int64(size)], nil
+	}
+	shared, _ := m.CurrentFolderFile("default", "shared")
+	m.Index(device1, "default", []protocol.FileInfo{shared})
+
+	for _, name := range []string{"shared", "local"} {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fd.WriteAt([]byte("rot"), protocol.BlockSize+10); err != nil {
+			t.Fatal(err)
+		}
+		fd.Close()
+		if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.fmut.RLock()
+	fset := m.folderFiles["default"]
+	m.fmut.RUnlock()
+	res, err := newScrubber(m, fcfg, fset).scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.files != 3 {
+		t.Errorf("checked %d files, expected 3", res.files)
+	}
+	if len(res.corrupted) != 2 {
+		t.Errorf("unexpected corrupted files %v", res.corrupted)
+	}
+	if len(res.repaired) != 1 || res.repaired[0] != "shared" {
+		t.Errorf("unexpected repaired files %v", res.repaired)
+	}
+	if len(res.unrepaired) != 1 || res.unrepaired[0] != "local" {
+		t.Errorf("unexpected unrepaired files %v", res.unrepaired)
+	}
+
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, data["shared"]) {
+		t.Error("repaired file differs from the original")
+	}
+	info, err := os.Stat(filepath.Join(dir, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(shared.ModTime()) {
+		t.Errorf("modification time changed to %v", info.ModTime())
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ignore.TempPrefix+"*")); len(names) != 0 {
+		t.Errorf("temporary files left behind: %v", names)
+	}
+}
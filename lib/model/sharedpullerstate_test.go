@@ -10,12 +10,15 @@ import (
 	"os"
 	"testing"
 
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
 func TestSourceFileOK(t *testing.T) {
 	s := sharedPullerState{
 		realName: "testdata/foo",
+		fs:       fs.DefaultFilesystem,
 		mut:      sync.NewRWMutex(),
 	}
 
@@ -48,6 +51,7 @@ func TestSourceFileOK(t *testing.T) {
 func TestSourceFileBad(t *testing.T) {
 	s := sharedPullerState{
 		realName: "nonexistent",
+		fs:       fs.DefaultFilesystem,
 		mut:      sync.NewRWMutex(),
 	}
 
@@ -74,6 +78,7 @@ func TestReadOnlyDir(t *testing.T) {
 
 	s := sharedPullerState{
 		tempName: "testdata/read_only_dir/.temp_name",
+		fs:       fs.DefaultFilesystem,
 		mut:      sync.NewRWMutex(),
 	}
 
@@ -88,3 +93,53 @@ func TestReadOnlyDir(t *testing.T) {
 	s.fail("Test done", nil)
 	s.finalClose()
 }
+
+// A failing write fails the whole file and leaves the real file alone.
+func TestTempFileWriteFault(t *testing.T) {
+	mem := fs.NewMemoryFilesystem(fs.MemoryOptions{})
+	faults := fs.NewFaultFilesystem(mem)
+	mem.Mkdir("folder", 0755)
+	fd, err := mem.Create("folder/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Write([]byte("old"))
+	fd.Close()
+
+	s := sharedPullerState{
+		file:     protocol.FileInfo{Name: "file", Size: 8},
+		fs:       faults,
+		tempName: "folder/.temp_name",
+		realName: "folder/file",
+		sparse:   true,
+		mut:      sync.NewRWMutex(),
+	}
+
+	w, err := s.tempFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The disk fills up halfway through writing the data.
+	faults.SetRules([]fs.FaultRule{{Pattern: "*.temp_name", Ops: []string{fs.FaultOpWrite}, Error: "ENOSPC", ShortWrite: true}})
+	if _, err := w.WriteAt([]byte("newdata!"), 0); err == nil {
+		t.Fatal("write should fail")
+	} else {
+		s.fail("dst write", err)
+	}
+
+	if closed, err := s.finalClose(); !closed || err == nil {
+		t.Errorf("file should be closed with an error, got %v, %v", closed, err)
+	}
+
+	rd, err := mem.Open("folder/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	bs := make([]byte, 8)
+	n, _ := rd.Read(bs)
+	if string(bs[:n]) != "old" {
+		t.Errorf("real file contains %q", bs[:n])
+	}
+}
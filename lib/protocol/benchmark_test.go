@@ -244,3 +244,6 @@ func (m *fakeModel) Closed(conn Connection, err error) {
 
 func (m *fakeModel) DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate) {
 }
+
+func (m *fakeModel) IdentityMigration(deviceID DeviceID, migration IdentityMigration) {
+}
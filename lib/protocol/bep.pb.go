@@ -26,6 +26,7 @@
 		FileDownloadProgressUpdate
 		Ping
 		Close
+		IdentityMigration
 */
 package protocol
 
@@ -50,14 +51,15 @@ const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 type MessageType int32
 
 const (
-	messageTypeClusterConfig    MessageType = 0
-	messageTypeIndex            MessageType = 1
-	messageTypeIndexUpdate      MessageType = 2
-	messageTypeRequest          MessageType = 3
-	messageTypeResponse         MessageType = 4
-	messageTypeDownloadProgress MessageType = 5
-	messageTypePing             MessageType = 6
-	messageTypeClose            MessageType = 7
+	messageTypeClusterConfig     MessageType = 0
+	messageTypeIndex             MessageType = 1
+	messageTypeIndexUpdate       MessageType = 2
+	messageTypeRequest           MessageType = 3
+	messageTypeResponse          MessageType = 4
+	messageTypeDownloadProgress  MessageType = 5
+	messageTypePing              MessageType = 6
+	messageTypeClose             MessageType = 7
+	messageTypeIdentityMigration MessageType = 8
 )
 
 var MessageType_name = map[int32]string{
@@ -69,16 +71,18 @@ var MessageType_name = map[int32]string{
 	5: "DOWNLOAD_PROGRESS",
 	6: "PING",
 	7: "CLOSE",
+	8: "IDENTITY_MIGRATION",
 }
 var MessageType_value = map[string]int32{
-	"CLUSTER_CONFIG":    0,
-	"INDEX":             1,
-	"INDEX_UPDATE":      2,
-	"REQUEST":           3,
-	"RESPONSE":          4,
-	"DOWNLOAD_PROGRESS": 5,
-	"PING":              6,
-	"CLOSE":             7,
+	"CLUSTER_CONFIG":     0,
+	"INDEX":              1,
+	"INDEX_UPDATE":       2,
+	"REQUEST":            3,
+	"RESPONSE":           4,
+	"DOWNLOAD_PROGRESS":  5,
+	"PING":               6,
+	"CLOSE":              7,
+	"IDENTITY_MIGRATION": 8,
 }
 
 func (x MessageType) String() string {
@@ -309,6 +313,7 @@ type FileInfo struct {
 	Sequence      int64        `protobuf:"varint,10,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	Blocks        []BlockInfo  `protobuf:"bytes,16,rep,name=Blocks" json:"Blocks"`
 	SymlinkTarget string       `protobuf:"bytes,17,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
+	RenamedTo     string       `protobuf:"bytes,18,opt,name=renamed_to,json=renamedTo,proto3" json:"renamed_to,omitempty"`
 }
 
 func (m *FileInfo) Reset()                    { *m = FileInfo{} }
@@ -410,6 +415,17 @@ func (m *Close) String() string            { return proto.CompactTextString(m) }
 func (*Close) ProtoMessage()               {}
 func (*Close) Descriptor() ([]byte, []int) { return fileDescriptorBep, []int{16} }
 
+type IdentityMigration struct {
+	OldCertificate []byte `protobuf:"bytes,1,opt,name=old_certificate,json=oldCertificate,proto3" json:"old_certificate,omitempty"`
+	NewCertificate []byte `protobuf:"bytes,2,opt,name=new_certificate,json=newCertificate,proto3" json:"new_certificate,omitempty"`
+	Signature      []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *IdentityMigration) Reset()                    { *m = IdentityMigration{} }
+func (m *IdentityMigration) String() string            { return proto.CompactTextString(m) }
+func (*IdentityMigration) ProtoMessage()               {}
+func (*IdentityMigration) Descriptor() ([]byte, []int) { return fileDescriptorBep, []int{17} }
+
 func init() {
 	proto.RegisterType((*Hello)(nil), "protocol.Hello")
 	proto.RegisterType((*Header)(nil), "protocol.Header")
@@ -428,6 +444,7 @@ func init() {
 	proto.RegisterType((*FileDownloadProgressUpdate)(nil), "protocol.FileDownloadProgressUpdate")
 	proto.RegisterType((*Ping)(nil), "protocol.Ping")
 	proto.RegisterType((*Close)(nil), "protocol.Close")
+	proto.RegisterType((*IdentityMigration)(nil), "protocol.IdentityMigration")
 	proto.RegisterEnum("protocol.MessageType", MessageType_name, MessageType_value)
 	proto.RegisterEnum("protocol.MessageCompression", MessageCompression_name, MessageCompression_value)
 	proto.RegisterEnum("protocol.Compression", Compression_name, Compression_value)
@@ -899,6 +916,14 @@ func (m *FileInfo) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintBep(dAtA, i, uint64(len(m.SymlinkTarget)))
 		i += copy(dAtA[i:], m.SymlinkTarget)
 	}
+	if len(m.RenamedTo) > 0 {
+		dAtA[i] = 0x92
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.RenamedTo)))
+		i += copy(dAtA[i:], m.RenamedTo)
+	}
 	return i, nil
 }
 
@@ -1216,6 +1241,42 @@ func (m *Close) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *IdentityMigration) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IdentityMigration) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.OldCertificate) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.OldCertificate)))
+		i += copy(dAtA[i:], m.OldCertificate)
+	}
+	if len(m.NewCertificate) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.NewCertificate)))
+		i += copy(dAtA[i:], m.NewCertificate)
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+
 func encodeFixed64Bep(dAtA []byte, offset int, v uint64) int {
 	dAtA[offset] = uint8(v)
 	dAtA[offset+1] = uint8(v >> 8)
@@ -1438,6 +1499,10 @@ func (m *FileInfo) ProtoSize() (n int) {
 	if l > 0 {
 		n += 2 + l + sovBep(uint64(l))
 	}
+	l = len(m.RenamedTo)
+	if l > 0 {
+		n += 2 + l + sovBep(uint64(l))
+	}
 	return n
 }
 
@@ -1582,6 +1647,24 @@ func (m *Close) ProtoSize() (n int) {
 	return n
 }
 
+func (m *IdentityMigration) ProtoSize() (n int) {
+	var l int
+	_ = l
+	l = len(m.OldCertificate)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.NewCertificate)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
 func sovBep(x uint64) (n int) {
 	for {
 		n++
@@ -2965,6 +3048,35 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 			}
 			m.SymlinkTarget = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RenamedTo", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RenamedTo = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -4057,6 +4169,149 @@ func (m *Close) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *IdentityMigration) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IdentityMigration: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IdentityMigration: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OldCertificate", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OldCertificate = append(m.OldCertificate[:0], dAtA[iNdEx:postIndex]...)
+			if m.OldCertificate == nil {
+				m.OldCertificate = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NewCertificate", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NewCertificate = append(m.NewCertificate[:0], dAtA[iNdEx:postIndex]...)
+			if m.NewCertificate == nil {
+				m.NewCertificate = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipBep(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -4165,114 +4420,120 @@ var (
 func init() { proto.RegisterFile("bep.proto", fileDescriptorBep) }
 
 var fileDescriptorBep = []byte{
-	// 1729 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x4f, 0x8f, 0xdb, 0xc6,
-	0x15, 0x17, 0x25, 0xea, 0xdf, 0x93, 0x76, 0xc3, 0x1d, 0xdb, 0x5b, 0x96, 0xd9, 0x68, 0x69, 0xc5,
-	0x8e, 0x37, 0x8b, 0x64, 0xe3, 0x26, 0x69, 0x8b, 0x16, 0x6d, 0x01, 0xfd, 0xe1, 0xae, 0x85, 0xca,
-	0x94, 0x3a, 0xd2, 0x3a, 0x75, 0x0e, 0x25, 0x28, 0x71, 0xa4, 0x25, 0x4c, 0x71, 0x54, 0x92, 0x5a,
-	0x5b, 0xfd, 0x08, 0xfa, 0x04, 0xbd, 0x08, 0x08, 0xd0, 0x53, 0xef, 0xfd, 0x10, 0x3e, 0x06, 0x3d,
-	0xf4, 0xd0, 0x83, 0xd1, 0x6c, 0x2f, 0x3d, 0xf6, 0x5e, 0xa0, 0x28, 0x38, 0x43, 0x52, 0xd4, 0xae,
-	0x37, 0xf0, 0xa1, 0x27, 0xce, 0xbc, 0xf7, 0x9b, 0x3f, 0xef, 0xf7, 0xde, 0xef, 0x0d, 0xa1, 0x3c,
-	0x22, 0xf3, 0x93, 0xb9, 0x47, 0x03, 0x8a, 0x4a, 0xec, 0x33, 0xa6, 0x8e, 0xf2, 0xe9, 0xd4, 0x0e,
-	0x2e, 0x16, 0xa3, 0x93, 0x31, 0x9d, 0x7d, 0x36, 0xa5, 0x53, 0xfa, 0x19, 0xf3, 0x8c, 0x16, 0x13,
-	0x36, 0x63, 0x13, 0x36, 0xe2, 0x0b, 0xeb, 0x73, 0xc8, 0x3f, 0x21, 0x8e, 0x43, 0xd1, 0x21, 0x54,
-	0x2c, 0x72, 0x69, 0x8f, 0x89, 0xe1, 0x9a, 0x33, 0x22, 0x0b, 0xaa, 0x70, 0x54, 0xc6, 0xc0, 0x4d,
-	0xba, 0x39, 0x23, 0x21, 0x60, 0xec, 0xd8, 0xc4, 0x0d, 0x38, 0x20, 0xcb, 0x01, 0xdc, 0xc4, 0x00,
-	0x0f, 0x61, 0x37, 0x02, 0x5c, 0x12, 0xcf, 0xb7, 0xa9, 0x2b, 0xe7, 0x18, 0x66, 0x87, 0x5b, 0x9f,
-	0x71, 0x63, 0xdd, 0x87, 0xc2, 0x13, 0x62, 0x5a, 0xc4, 0x43, 0x1f, 0x83, 0x18, 0x2c, 0xe7, 0xfc,
-	0xac, 0xdd, 0xcf, 0xef, 0x9d, 0xc4, 0x31, 0x9c, 0x3c, 0x25, 0xbe, 0x6f, 0x4e, 0xc9, 0x70, 0x39,
-	0x27, 0x98, 0x41, 0xd0, 0xaf, 0xa0, 0x32, 0xa6, 0xb3, 0xb9, 0x47, 0x7c, 0xb6, 0x71, 0x96, 0xad,
-	0x38, 0xb8, 0xb1, 0xa2, 0xb5, 0xc1, 0xe0, 0xf4, 0x82, 0x7a, 0x03, 0x76, 0x5a, 0xce, 0xc2, 0x0f,
-	0x88, 0xd7, 0xa2, 0xee, 0xc4, 0x9e, 0xa2, 0xc7, 0x50, 0x9c, 0x50, 0xc7, 0x22, 0x9e, 0x2f, 0x0b,
-	0x6a, 0xee, 0xa8, 0xf2, 0xb9, 0xb4, 0xd9, 0xec, 0x94, 0x39, 0x9a, 0xe2, 0xeb, 0x37, 0x87, 0x19,
-	0x1c, 0xc3, 0xea, 0x7f, 0xca, 0x42, 0x81, 0x7b, 0xd0, 0x3e, 0x64, 0x6d, 0x8b, 0x53, 0xd4, 0x2c,
-	0x5c, 0xbd, 0x39, 0xcc, 0x76, 0xda, 0x38, 0x6b, 0x5b, 0xe8, 0x2e, 0xe4, 0x1d, 0x73, 0x44, 0x9c,
-	0x88, 0x1c, 0x3e, 0x41, 0xef, 0x43, 0xd9, 0x23, 0xa6, 0x65, 0x50, 0xd7, 0x59, 0x32, 0x4a, 0x4a,
-	0xb8, 0x14, 0x1a, 0x7a, 0xae, 0xb3, 0x44, 0x9f, 0x02, 0xb2, 0xa7, 0x2e, 0xf5, 0x88, 0x31, 0x27,
-	0xde, 0xcc, 0x66, 0xb7, 0xf5, 0x65, 0x91, 0xa1, 0xf6, 0xb8, 0xa7, 0xbf, 0x71, 0xa0, 0x0f, 0x61,
-	0x27, 0x82, 0x5b, 0xc4, 0x21, 0x01, 0x91, 0xf3, 0x0c, 0x59, 0xe5, 0xc6, 0x36, 0xb3, 0xa1, 0xc7,
-	0x70, 0xd7, 0xb2, 0x7d, 0x73, 0xe4, 0x10, 0x23, 0x20, 0xb3, 0xb9, 0x61, 0xbb, 0x16, 0x79, 0x45,
-	0x7c, 0xb9, 0xc0, 0xb0, 0x28, 0xf2, 0x0d, 0xc9, 0x6c, 0xde, 0xe1, 0x1e, 0xb4, 0x0f, 0x85, 0xb9,
-	0xb9, 0xf0, 0x89, 0x25, 0x17, 0x19, 0x26, 0x9a, 0x85, 0x2c, 0xf1, 0x0a, 0xf0, 0x65, 0xe9, 0x3a,
-	0x4b, 0x6d, 0xe6, 0x88, 0x59, 0x8a, 0x60, 0xf5, 0x7f, 0x67, 0xa1, 0xc0, 0x3d, 0xe8, 0xa3, 0x84,
-	0xa5, 0x6a, 0x73, 0x3f, 0x44, 0xfd, 0xfd, 0xcd, 0x61, 0x89, 0xfb, 0x3a, 0xed, 0x14, 0x6b, 0x08,
-	0xc4, 0x54, 0x45, 0xb1, 0x31, 0x3a, 0x80, 0xb2, 0x69, 0x59, 0x61, 0xf6, 0x88, 0x2f, 0xe7, 0xd4,
-	0xdc, 0x51, 0x19, 0x6f, 0x0c, 0xe8, 0xa7, 0xdb, 0xd5, 0x20, 0x5e, 0xaf, 0x9f, 0xdb, 0xca, 0x20,
-	0x4c, 0xc5, 0x98, 0x78, 0x51, 0x05, 0xe7, 0xd9, 0x79, 0xa5, 0xd0, 0xc0, 0xea, 0xf7, 0x3e, 0x54,
-	0x67, 0xe6, 0x2b, 0xc3, 0x27, 0xbf, 0x5f, 0x10, 0x77, 0x4c, 0x18, 0x5d, 0x39, 0x5c, 0x99, 0x99,
-	0xaf, 0x06, 0x91, 0x09, 0xd5, 0x00, 0x6c, 0x37, 0xf0, 0xa8, 0xb5, 0x18, 0x13, 0x2f, 0xe2, 0x2a,
-	0x65, 0x41, 0x3f, 0x86, 0x12, 0x23, 0xdb, 0xb0, 0x2d, 0xb9, 0xa4, 0x0a, 0x47, 0x62, 0x53, 0x89,
-	0x02, 0x2f, 0x32, 0xaa, 0x59, 0xdc, 0xf1, 0x10, 0x17, 0x19, 0xb6, 0x63, 0xa1, 0x5f, 0x80, 0xe2,
-	0xbf, 0xb0, 0xc3, 0x44, 0xf1, 0x9d, 0x02, 0x9b, 0xba, 0x86, 0x47, 0x66, 0xf4, 0xd2, 0x74, 0x7c,
-	0xb9, 0xcc, 0x8e, 0x91, 0x43, 0x44, 0x27, 0x05, 0xc0, 0x91, 0xbf, 0xde, 0x83, 0x3c, 0xdb, 0x31,
-	0xcc, 0x22, 0x2f, 0xd6, 0x48, 0xbd, 0xd1, 0x0c, 0x9d, 0x40, 0x7e, 0x62, 0x3b, 0xc4, 0x97, 0xb3,
-	0x2c, 0x87, 0x28, 0x55, 0xe9, 0xb6, 0x43, 0x3a, 0xee, 0x84, 0x46, 0x59, 0xe4, 0xb0, 0xfa, 0x39,
-	0x54, 0xd8, 0x86, 0xe7, 0x73, 0xcb, 0x0c, 0xc8, 0xff, 0x6d, 0xdb, 0xff, 0xe4, 0xa0, 0x14, 0x7b,
-	0x92, 0xa4, 0x0b, 0xa9, 0xa4, 0x1f, 0x47, 0xfd, 0x80, 0xab, 0x7b, 0xff, 0xe6, 0x7e, 0xa9, 0x86,
-	0x80, 0x40, 0xf4, 0xed, 0x3f, 0x10, 0xa6, 0xa7, 0x1c, 0x66, 0x63, 0xa4, 0x42, 0xe5, 0xba, 0x88,
-	0x76, 0x70, 0xda, 0x84, 0x3e, 0x00, 0x98, 0x51, 0xcb, 0x9e, 0xd8, 0xc4, 0x32, 0x7c, 0x56, 0x00,
-	0x39, 0x5c, 0x8e, 0x2d, 0x03, 0x24, 0x87, 0xe5, 0x1e, 0x4a, 0xc8, 0x8a, 0xb4, 0x12, 0x4f, 0x43,
-	0x8f, 0xed, 0x5e, 0x9a, 0x8e, 0x1d, 0x2b, 0x24, 0x9e, 0x86, 0x5d, 0xcf, 0xa5, 0x5b, 0xe2, 0x2d,
-	0x31, 0xc0, 0x8e, 0x4b, 0xd3, 0xc2, 0x7d, 0x0c, 0xc5, 0xb8, 0x2b, 0x86, 0xf9, 0xdc, 0x52, 0xd2,
-	0x33, 0x32, 0x0e, 0x68, 0xd2, 0x6f, 0x22, 0x18, 0x52, 0xa0, 0x94, 0x94, 0x22, 0xb0, 0x9b, 0x26,
-	0xf3, 0xb0, 0x17, 0x27, 0x71, 0xb8, 0xbe, 0x5c, 0x51, 0x85, 0xa3, 0x3c, 0x4e, 0x42, 0xd3, 0xc3,
-	0xe3, 0x36, 0x80, 0xd1, 0x52, 0xae, 0xb2, 0x5a, 0x7c, 0x2f, 0xae, 0xc5, 0xc1, 0x05, 0xf5, 0x82,
-	0x4e, 0x7b, 0xb3, 0xa2, 0xb9, 0x44, 0x3f, 0x82, 0x42, 0xd3, 0xa1, 0xe3, 0x17, 0xb1, 0xd2, 0xef,
-	0x6c, 0xee, 0xc7, 0xec, 0xa9, 0x7c, 0x46, 0xc0, 0x30, 0x74, 0x7f, 0x39, 0x73, 0x6c, 0xf7, 0x85,
-	0x11, 0x98, 0xde, 0x94, 0x04, 0xf2, 0x1e, 0x6f, 0xf8, 0x91, 0x75, 0xc8, 0x8c, 0x3f, 0x17, 0xff,
-	0xf8, 0xcd, 0x61, 0xa6, 0xee, 0x42, 0x39, 0xd9, 0x27, 0x2c, 0x29, 0x3a, 0x99, 0xf8, 0x24, 0x60,
-	0xf9, 0xcf, 0xe1, 0x68, 0x96, 0x64, 0x35, 0xcb, 0x02, 0xe2, 0x59, 0x45, 0x20, 0x5e, 0x98, 0xfe,
-	0x05, 0xcb, 0x74, 0x15, 0xb3, 0x71, 0xa8, 0xe3, 0x97, 0xc4, 0x7c, 0x61, 0x30, 0x07, 0xcf, 0x73,
-	0x29, 0x34, 0x3c, 0x31, 0xfd, 0x8b, 0xe8, 0xbc, 0x5f, 0x42, 0x81, 0xf3, 0x8a, 0xbe, 0x80, 0xd2,
-	0x98, 0x2e, 0xdc, 0x60, 0xd3, 0xeb, 0xf7, 0xd2, 0xad, 0x82, 0x79, 0xa2, 0xc8, 0x12, 0x60, 0xfd,
-	0x14, 0x8a, 0x91, 0x0b, 0x3d, 0x4c, 0xfa, 0x98, 0xd8, 0xbc, 0x77, 0x8d, 0xc2, 0xed, 0xe6, 0x7f,
-	0x69, 0x3a, 0x0b, 0x7e, 0x79, 0x11, 0xf3, 0x49, 0xfd, 0x2f, 0x02, 0x14, 0x71, 0x98, 0x36, 0x3f,
-	0x48, 0x3d, 0x1b, 0xf9, 0xad, 0x67, 0x63, 0x23, 0xb0, 0xec, 0x96, 0xc0, 0x62, 0x8d, 0xe4, 0x52,
-	0x1a, 0xd9, 0x30, 0x27, 0xbe, 0x95, 0xb9, 0xfc, 0x5b, 0x98, 0x2b, 0xa4, 0x98, 0x7b, 0x08, 0xbb,
-	0x13, 0x8f, 0xce, 0xd8, 0xc3, 0x40, 0x3d, 0xd3, 0x5b, 0x46, 0xf5, 0xbc, 0x13, 0x5a, 0x87, 0xb1,
-	0xb1, 0x6e, 0x40, 0x09, 0x13, 0x7f, 0x4e, 0x5d, 0x9f, 0xdc, 0x7a, 0x6d, 0x04, 0xa2, 0x65, 0x06,
-	0x26, 0xbb, 0x74, 0x15, 0xb3, 0x31, 0x7a, 0x04, 0xe2, 0x98, 0x5a, 0xfc, 0xca, 0xbb, 0xe9, 0x1a,
-	0xd2, 0x3c, 0x8f, 0x7a, 0x2d, 0x6a, 0x11, 0xcc, 0x00, 0xf5, 0x39, 0x48, 0x6d, 0xfa, 0xd2, 0x75,
-	0xa8, 0x69, 0xf5, 0x3d, 0x3a, 0x0d, 0x1b, 0xf4, 0xad, 0x8d, 0xa6, 0x0d, 0xc5, 0x05, 0x6b, 0x45,
-	0x71, 0xab, 0x79, 0xb0, 0xdd, 0x1a, 0xae, 0x6f, 0xc4, 0xfb, 0x56, 0xac, 0xa7, 0x68, 0x69, 0xfd,
-	0x6f, 0x02, 0x28, 0xb7, 0xa3, 0x51, 0x07, 0x2a, 0x1c, 0x69, 0xa4, 0xfe, 0x49, 0x8e, 0xde, 0xe5,
-	0x20, 0xd6, 0x95, 0x60, 0x91, 0x8c, 0xdf, 0xfa, 0xa0, 0xa5, 0xf4, 0x9f, 0x7b, 0x37, 0xfd, 0x3f,
-	0x82, 0x9d, 0x51, 0x28, 0x98, 0xe4, 0xf9, 0x16, 0xd5, 0xdc, 0x51, 0xbe, 0x99, 0x95, 0x32, 0xb8,
-	0x3a, 0xe2, 0x4a, 0x62, 0xf6, 0x7a, 0x01, 0xc4, 0xbe, 0xed, 0x4e, 0xeb, 0x87, 0x90, 0x6f, 0x39,
-	0x94, 0x25, 0xac, 0xe0, 0x11, 0xd3, 0xa7, 0x6e, 0xcc, 0x23, 0x9f, 0x1d, 0xff, 0x35, 0x0b, 0x95,
-	0xd4, 0xaf, 0x15, 0x7a, 0x0c, 0xbb, 0xad, 0xee, 0xf9, 0x60, 0xa8, 0x61, 0xa3, 0xd5, 0xd3, 0x4f,
-	0x3b, 0x67, 0x52, 0x46, 0x39, 0x58, 0xad, 0x55, 0x79, 0xb6, 0x01, 0x6d, 0xff, 0x35, 0x1d, 0x42,
-	0xbe, 0xa3, 0xb7, 0xb5, 0xdf, 0x4a, 0x82, 0x72, 0x77, 0xb5, 0x56, 0xa5, 0x14, 0x90, 0x3f, 0x41,
-	0x9f, 0x40, 0x95, 0x01, 0x8c, 0xf3, 0x7e, 0xbb, 0x31, 0xd4, 0xa4, 0xac, 0xa2, 0xac, 0xd6, 0xea,
-	0xfe, 0x75, 0x5c, 0xc4, 0xf9, 0x87, 0x50, 0xc4, 0xda, 0x6f, 0xce, 0xb5, 0xc1, 0x50, 0xca, 0x29,
-	0xfb, 0xab, 0xb5, 0x8a, 0x52, 0xc0, 0x58, 0x35, 0x0f, 0xa1, 0x84, 0xb5, 0x41, 0xbf, 0xa7, 0x0f,
-	0x34, 0x49, 0x54, 0x7e, 0xb0, 0x5a, 0xab, 0x77, 0xb6, 0x50, 0x51, 0x95, 0xfe, 0x04, 0xf6, 0xda,
-	0xbd, 0xaf, 0xf4, 0x6e, 0xaf, 0xd1, 0x36, 0xfa, 0xb8, 0x77, 0x86, 0xb5, 0xc1, 0x40, 0xca, 0x2b,
-	0x87, 0xab, 0xb5, 0xfa, 0x7e, 0x0a, 0x7f, 0xa3, 0xe8, 0x3e, 0x00, 0xb1, 0xdf, 0xd1, 0xcf, 0xa4,
-	0x82, 0x72, 0x67, 0xb5, 0x56, 0xdf, 0x4b, 0x41, 0x43, 0x52, 0xc3, 0x88, 0x5b, 0xdd, 0xde, 0x40,
-	0x93, 0x8a, 0x37, 0x22, 0x66, 0x64, 0x1f, 0xff, 0x0e, 0xd0, 0xcd, 0x9f, 0x4f, 0xf4, 0x00, 0x44,
-	0xbd, 0xa7, 0x6b, 0x52, 0x86, 0xc7, 0x7f, 0x13, 0xa1, 0x53, 0x97, 0xa0, 0x3a, 0xe4, 0xba, 0x5f,
-	0x7f, 0x29, 0x09, 0xca, 0x0f, 0x57, 0x6b, 0xf5, 0xde, 0x4d, 0x50, 0xf7, 0xeb, 0x2f, 0x8f, 0x29,
-	0x54, 0xd2, 0x1b, 0xd7, 0xa1, 0xf4, 0x54, 0x1b, 0x36, 0xda, 0x8d, 0x61, 0x43, 0xca, 0xf0, 0x2b,
-	0xc5, 0xee, 0xa7, 0x24, 0x30, 0x99, 0x08, 0x0f, 0x20, 0xaf, 0x6b, 0xcf, 0x34, 0x2c, 0x09, 0xca,
-	0xde, 0x6a, 0xad, 0xee, 0xc4, 0x00, 0x9d, 0x5c, 0x12, 0x0f, 0xd5, 0xa0, 0xd0, 0xe8, 0x7e, 0xd5,
-	0x78, 0x3e, 0x90, 0xb2, 0x0a, 0x5a, 0xad, 0xd5, 0xdd, 0xd8, 0xdd, 0x70, 0x5e, 0x9a, 0x4b, 0xff,
-	0xf8, 0xbf, 0x02, 0x54, 0xd3, 0x0f, 0x2e, 0xaa, 0x81, 0x78, 0xda, 0xe9, 0x6a, 0xf1, 0x71, 0x69,
-	0x5f, 0x38, 0x46, 0x47, 0x50, 0x6e, 0x77, 0xb0, 0xd6, 0x1a, 0xf6, 0xf0, 0xf3, 0x38, 0x96, 0x34,
-	0xa8, 0x6d, 0x7b, 0xac, 0xc0, 0x97, 0xe8, 0x67, 0x50, 0x1d, 0x3c, 0x7f, 0xda, 0xed, 0xe8, 0xbf,
-	0x36, 0xd8, 0x8e, 0x59, 0xe5, 0xd1, 0x6a, 0xad, 0xde, 0xdf, 0x02, 0x93, 0xb9, 0x47, 0xc6, 0x66,
-	0x40, 0xac, 0x01, 0x7f, 0x44, 0x42, 0x67, 0x49, 0x40, 0x2d, 0xd8, 0x8b, 0x97, 0x6e, 0x0e, 0xcb,
-	0x29, 0x9f, 0xac, 0xd6, 0xea, 0x47, 0xdf, 0xbb, 0x3e, 0x39, 0xbd, 0x24, 0xa0, 0x07, 0x50, 0x8c,
-	0x36, 0x89, 0x2b, 0x29, 0xbd, 0x34, 0x5a, 0x70, 0xfc, 0x67, 0x01, 0xca, 0x49, 0xbb, 0x0a, 0x09,
-	0xd7, 0x7b, 0x86, 0x86, 0x71, 0x0f, 0xc7, 0x0c, 0x24, 0x4e, 0x9d, 0xb2, 0x21, 0xba, 0x0f, 0xc5,
-	0x33, 0x4d, 0xd7, 0x70, 0xa7, 0x15, 0x0b, 0x23, 0x81, 0x9c, 0x11, 0x97, 0x78, 0xf6, 0x18, 0x7d,
-	0x0c, 0x55, 0xbd, 0x67, 0x0c, 0xce, 0x5b, 0x4f, 0xe2, 0xd0, 0xd9, 0xf9, 0xa9, 0xad, 0x06, 0x8b,
-	0xf1, 0x05, 0xe3, 0xf3, 0x38, 0xd4, 0xd0, 0xb3, 0x46, 0xb7, 0xd3, 0xe6, 0xd0, 0x9c, 0x22, 0xaf,
-	0xd6, 0xea, 0xdd, 0x04, 0xda, 0xe1, 0x7f, 0x1e, 0x21, 0xf6, 0xd8, 0x82, 0xda, 0xf7, 0x37, 0x26,
-	0xa4, 0x42, 0xa1, 0xd1, 0xef, 0x6b, 0x7a, 0x3b, 0xbe, 0xfd, 0xc6, 0xd7, 0x98, 0xcf, 0x89, 0x6b,
-	0x85, 0x88, 0xd3, 0x1e, 0x3e, 0xd3, 0x86, 0xf1, 0xe5, 0x37, 0x88, 0x53, 0x1a, 0xbe, 0xe0, 0xcd,
-	0x83, 0xd7, 0xdf, 0xd5, 0x32, 0xdf, 0x7e, 0x57, 0xcb, 0xbc, 0xbe, 0xaa, 0x09, 0xdf, 0x5e, 0xd5,
-	0x84, 0x7f, 0x5c, 0xd5, 0x32, 0xff, 0xba, 0xaa, 0x09, 0xdf, 0xfc, 0xb3, 0x26, 0x8c, 0x0a, 0xac,
-	0x91, 0x7d, 0xf1, 0xbf, 0x00, 0x00, 0x00, 0xff, 0xff, 0xad, 0x8a, 0xef, 0x7f, 0x8f, 0x0e, 0x00,
-	0x00,
+	// 1831 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x96, 0xcb, 0x6f, 0xdb, 0xc8,
+	0x19, 0xc0, 0x4d, 0x89, 0x7a, 0x7d, 0x92, 0x1d, 0x7a, 0x92, 0xb8, 0x2c, 0xd7, 0x2b, 0x33, 0xda,
+	0x64, 0xe3, 0x35, 0x76, 0xbd, 0xe9, 0xee, 0xf6, 0x89, 0xb6, 0x80, 0x2c, 0xd1, 0x0e, 0x51, 0x9b,
+	0x72, 0x47, 0x72, 0xb6, 0xd9, 0x43, 0x09, 0x5a, 0x1c, 0xc9, 0x44, 0x28, 0x8e, 0x4a, 0x52, 0x76,
+	0xd4, 0x6b, 0x6f, 0x42, 0xff, 0x80, 0xa2, 0x80, 0x80, 0x05, 0x7a, 0xea, 0xbd, 0x7f, 0x44, 0x8e,
+	0x7b, 0xea, 0xa1, 0x87, 0xa0, 0xeb, 0x5e, 0x7a, 0xec, 0x5f, 0x50, 0x14, 0x33, 0x43, 0x4a, 0x94,
+	0x9d, 0x2c, 0x72, 0xe8, 0x89, 0x33, 0xdf, 0xf7, 0x9b, 0xc7, 0xf7, 0x1c, 0x42, 0xe5, 0x9c, 0x8c,
+	0xf7, 0xc7, 0x21, 0x8d, 0x29, 0x2a, 0xf3, 0x4f, 0x9f, 0xfa, 0xda, 0x27, 0x43, 0x2f, 0xbe, 0x98,
+	0x9c, 0xef, 0xf7, 0xe9, 0xe8, 0xd3, 0x21, 0x1d, 0xd2, 0x4f, 0xb9, 0xe6, 0x7c, 0x32, 0xe0, 0x33,
+	0x3e, 0xe1, 0x23, 0xb1, 0xb0, 0x31, 0x86, 0xc2, 0x53, 0xe2, 0xfb, 0x14, 0xed, 0x40, 0xd5, 0x25,
+	0x97, 0x5e, 0x9f, 0xd8, 0x81, 0x33, 0x22, 0xaa, 0xa4, 0x4b, 0xbb, 0x15, 0x0c, 0x42, 0x64, 0x39,
+	0x23, 0xc2, 0x80, 0xbe, 0xef, 0x91, 0x20, 0x16, 0x40, 0x4e, 0x00, 0x42, 0xc4, 0x81, 0x47, 0xb0,
+	0x91, 0x00, 0x97, 0x24, 0x8c, 0x3c, 0x1a, 0xa8, 0x79, 0xce, 0xac, 0x0b, 0xe9, 0x33, 0x21, 0x6c,
+	0x44, 0x50, 0x7c, 0x4a, 0x1c, 0x97, 0x84, 0xe8, 0x23, 0x90, 0xe3, 0xe9, 0x58, 0x9c, 0xb5, 0xf1,
+	0xd9, 0xfd, 0xfd, 0xd4, 0x86, 0xfd, 0x13, 0x12, 0x45, 0xce, 0x90, 0xf4, 0xa6, 0x63, 0x82, 0x39,
+	0x82, 0x7e, 0x09, 0xd5, 0x3e, 0x1d, 0x8d, 0x43, 0x12, 0xf1, 0x8d, 0x73, 0x7c, 0xc5, 0xf6, 0xad,
+	0x15, 0xad, 0x25, 0x83, 0xb3, 0x0b, 0x1a, 0x4d, 0x58, 0x6f, 0xf9, 0x93, 0x28, 0x26, 0x61, 0x8b,
+	0x06, 0x03, 0x6f, 0x88, 0x9e, 0x40, 0x69, 0x40, 0x7d, 0x97, 0x84, 0x91, 0x2a, 0xe9, 0xf9, 0xdd,
+	0xea, 0x67, 0xca, 0x72, 0xb3, 0x43, 0xae, 0x38, 0x90, 0x5f, 0xbd, 0xde, 0x59, 0xc3, 0x29, 0xd6,
+	0xf8, 0x4b, 0x0e, 0x8a, 0x42, 0x83, 0xb6, 0x20, 0xe7, 0xb9, 0xc2, 0x45, 0x07, 0xc5, 0xeb, 0xd7,
+	0x3b, 0x39, 0xb3, 0x8d, 0x73, 0x9e, 0x8b, 0xee, 0x41, 0xc1, 0x77, 0xce, 0x89, 0x9f, 0x38, 0x47,
+	0x4c, 0xd0, 0x7b, 0x50, 0x09, 0x89, 0xe3, 0xda, 0x34, 0xf0, 0xa7, 0xdc, 0x25, 0x65, 0x5c, 0x66,
+	0x82, 0x4e, 0xe0, 0x4f, 0xd1, 0x27, 0x80, 0xbc, 0x61, 0x40, 0x43, 0x62, 0x8f, 0x49, 0x38, 0xf2,
+	0xf8, 0x6d, 0x23, 0x55, 0xe6, 0xd4, 0xa6, 0xd0, 0x9c, 0x2e, 0x15, 0xe8, 0x03, 0x58, 0x4f, 0x70,
+	0x97, 0xf8, 0x24, 0x26, 0x6a, 0x81, 0x93, 0x35, 0x21, 0x6c, 0x73, 0x19, 0x7a, 0x02, 0xf7, 0x5c,
+	0x2f, 0x72, 0xce, 0x7d, 0x62, 0xc7, 0x64, 0x34, 0xb6, 0xbd, 0xc0, 0x25, 0x2f, 0x49, 0xa4, 0x16,
+	0x39, 0x8b, 0x12, 0x5d, 0x8f, 0x8c, 0xc6, 0xa6, 0xd0, 0xa0, 0x2d, 0x28, 0x8e, 0x9d, 0x49, 0x44,
+	0x5c, 0xb5, 0xc4, 0x99, 0x64, 0xc6, 0xbc, 0x24, 0x32, 0x20, 0x52, 0x95, 0x9b, 0x5e, 0x6a, 0x73,
+	0x45, 0xea, 0xa5, 0x04, 0x6b, 0xfc, 0x27, 0x07, 0x45, 0xa1, 0x41, 0x1f, 0x2e, 0xbc, 0x54, 0x3b,
+	0xd8, 0x62, 0xd4, 0x3f, 0x5e, 0xef, 0x94, 0x85, 0xce, 0x6c, 0x67, 0xbc, 0x86, 0x40, 0xce, 0x64,
+	0x14, 0x1f, 0xa3, 0x6d, 0xa8, 0x38, 0xae, 0xcb, 0xa2, 0x47, 0x22, 0x35, 0xaf, 0xe7, 0x77, 0x2b,
+	0x78, 0x29, 0x40, 0x3f, 0x5e, 0xcd, 0x06, 0xf9, 0x66, 0xfe, 0xbc, 0x2d, 0x0d, 0x58, 0x28, 0xfa,
+	0x24, 0x4c, 0x32, 0xb8, 0xc0, 0xcf, 0x2b, 0x33, 0x01, 0xcf, 0xdf, 0x07, 0x50, 0x1b, 0x39, 0x2f,
+	0xed, 0x88, 0xfc, 0x6e, 0x42, 0x82, 0x3e, 0xe1, 0xee, 0xca, 0xe3, 0xea, 0xc8, 0x79, 0xd9, 0x4d,
+	0x44, 0xa8, 0x0e, 0xe0, 0x05, 0x71, 0x48, 0xdd, 0x49, 0x9f, 0x84, 0x89, 0xaf, 0x32, 0x12, 0xf4,
+	0x43, 0x28, 0x73, 0x67, 0xdb, 0x9e, 0xab, 0x96, 0x75, 0x69, 0x57, 0x3e, 0xd0, 0x12, 0xc3, 0x4b,
+	0xdc, 0xd5, 0xdc, 0xee, 0x74, 0x88, 0x4b, 0x9c, 0x35, 0x5d, 0xf4, 0x73, 0xd0, 0xa2, 0x17, 0xde,
+	0xd8, 0x4e, 0x77, 0x8a, 0x3d, 0x1a, 0xd8, 0x21, 0x19, 0xd1, 0x4b, 0xc7, 0x8f, 0xd4, 0x0a, 0x3f,
+	0x46, 0x65, 0x84, 0x99, 0x01, 0x70, 0xa2, 0x6f, 0x74, 0xa0, 0xc0, 0x77, 0x64, 0x51, 0x14, 0xc9,
+	0x9a, 0x54, 0x6f, 0x32, 0x43, 0xfb, 0x50, 0x18, 0x78, 0x3e, 0x89, 0xd4, 0x1c, 0x8f, 0x21, 0xca,
+	0x64, 0xba, 0xe7, 0x13, 0x33, 0x18, 0xd0, 0x24, 0x8a, 0x02, 0x6b, 0x9c, 0x41, 0x95, 0x6f, 0x78,
+	0x36, 0x76, 0x9d, 0x98, 0xfc, 0xdf, 0xb6, 0xfd, 0xb3, 0x0c, 0xe5, 0x54, 0xb3, 0x08, 0xba, 0x94,
+	0x09, 0xfa, 0x5e, 0xd2, 0x0f, 0x44, 0x75, 0x6f, 0xdd, 0xde, 0x2f, 0xd3, 0x10, 0x10, 0xc8, 0x91,
+	0xf7, 0x7b, 0xc2, 0xeb, 0x29, 0x8f, 0xf9, 0x18, 0xe9, 0x50, 0xbd, 0x59, 0x44, 0xeb, 0x38, 0x2b,
+	0x42, 0xef, 0x03, 0x8c, 0xa8, 0xeb, 0x0d, 0x3c, 0xe2, 0xda, 0x11, 0x4f, 0x80, 0x3c, 0xae, 0xa4,
+	0x92, 0x2e, 0x52, 0x59, 0xba, 0xb3, 0x12, 0x72, 0x93, 0x5a, 0x49, 0xa7, 0x4c, 0xe3, 0x05, 0x97,
+	0x8e, 0xef, 0xa5, 0x15, 0x92, 0x4e, 0x59, 0xd7, 0x0b, 0xe8, 0x4a, 0xf1, 0x96, 0x39, 0xb0, 0x1e,
+	0xd0, 0x6c, 0xe1, 0x3e, 0x81, 0x52, 0xda, 0x15, 0x59, 0x3c, 0x57, 0x2a, 0xe9, 0x19, 0xe9, 0xc7,
+	0x74, 0xd1, 0x6f, 0x12, 0x0c, 0x69, 0x50, 0x5e, 0xa4, 0x22, 0xf0, 0x9b, 0x2e, 0xe6, 0xac, 0x17,
+	0x2f, 0xec, 0x08, 0x22, 0xb5, 0xaa, 0x4b, 0xbb, 0x05, 0xbc, 0x30, 0xcd, 0x62, 0xc7, 0x2d, 0x81,
+	0xf3, 0xa9, 0x5a, 0xe3, 0xb9, 0x78, 0x27, 0xcd, 0xc5, 0xee, 0x05, 0x0d, 0x63, 0xb3, 0xbd, 0x5c,
+	0x71, 0x30, 0x45, 0x3f, 0x80, 0xe2, 0x81, 0x4f, 0xfb, 0x2f, 0xd2, 0x4a, 0xbf, 0xbb, 0xbc, 0x1f,
+	0x97, 0x67, 0xe2, 0x99, 0x80, 0xcc, 0xf4, 0x68, 0x3a, 0xf2, 0xbd, 0xe0, 0x85, 0x1d, 0x3b, 0xe1,
+	0x90, 0xc4, 0xea, 0xa6, 0x68, 0xf8, 0x89, 0xb4, 0xc7, 0x85, 0xcc, 0xe9, 0x21, 0x61, 0x01, 0x76,
+	0xed, 0x98, 0xaa, 0x88, 0x23, 0x95, 0x44, 0xd2, 0xa3, 0x3f, 0x93, 0xff, 0xf4, 0xf5, 0xce, 0x5a,
+	0x23, 0x80, 0xca, 0xe2, 0x18, 0x96, 0x71, 0x74, 0x30, 0x88, 0x48, 0xcc, 0xd3, 0x23, 0x8f, 0x93,
+	0xd9, 0x22, 0xe8, 0x39, 0x6e, 0x2f, 0x1f, 0x33, 0xd9, 0x85, 0x13, 0x5d, 0xf0, 0x44, 0xa8, 0x61,
+	0x3e, 0x66, 0x65, 0x7e, 0x45, 0x9c, 0x17, 0x36, 0x57, 0x88, 0x34, 0x28, 0x33, 0xc1, 0x53, 0x27,
+	0xba, 0x48, 0xce, 0xfb, 0x05, 0x14, 0x85, 0xdb, 0xd1, 0xe7, 0x50, 0xee, 0xd3, 0x49, 0x10, 0x2f,
+	0x9f, 0x82, 0xcd, 0x6c, 0x27, 0xe1, 0x9a, 0xc4, 0xf0, 0x05, 0xd8, 0x38, 0x84, 0x52, 0xa2, 0x42,
+	0x8f, 0x16, 0x6d, 0x4e, 0x3e, 0xb8, 0x7f, 0xc3, 0xc3, 0xab, 0x6f, 0xc3, 0xa5, 0xe3, 0x4f, 0xc4,
+	0xe5, 0x65, 0x2c, 0x26, 0x8d, 0xbf, 0x49, 0x50, 0xc2, 0x2c, 0xaa, 0x51, 0x9c, 0x79, 0x55, 0x0a,
+	0x2b, 0xaf, 0xca, 0xb2, 0xfe, 0x72, 0x2b, 0xf5, 0x97, 0x96, 0x50, 0x3e, 0x53, 0x42, 0x4b, 0xcf,
+	0xc9, 0x6f, 0xf4, 0x5c, 0xe1, 0x0d, 0x9e, 0x2b, 0x66, 0x3c, 0xf7, 0x08, 0x36, 0x06, 0x21, 0x1d,
+	0xf1, 0x77, 0x83, 0x86, 0x4e, 0x38, 0x4d, 0xd2, 0x7d, 0x9d, 0x49, 0x7b, 0xa9, 0xb0, 0x61, 0x43,
+	0x19, 0x93, 0x68, 0x4c, 0x83, 0x88, 0xbc, 0xf5, 0xda, 0x08, 0x64, 0xd7, 0x89, 0x1d, 0x7e, 0xe9,
+	0x1a, 0xe6, 0x63, 0xf4, 0x18, 0xe4, 0x3e, 0x75, 0xc5, 0x95, 0x37, 0xb2, 0x29, 0x66, 0x84, 0x21,
+	0x0d, 0x5b, 0xd4, 0x25, 0x98, 0x03, 0x8d, 0x31, 0x28, 0x6d, 0x7a, 0x15, 0xf8, 0xd4, 0x71, 0x4f,
+	0x43, 0x3a, 0x64, 0xfd, 0xfb, 0xad, 0x7d, 0xa8, 0x0d, 0xa5, 0x09, 0xef, 0x54, 0x69, 0x27, 0x7a,
+	0xb8, 0xda, 0x39, 0x6e, 0x6e, 0x24, 0xda, 0x5a, 0x5a, 0x6e, 0xc9, 0xd2, 0xc6, 0xdf, 0x25, 0xd0,
+	0xde, 0x4e, 0x23, 0x13, 0xaa, 0x82, 0xb4, 0x33, 0xbf, 0x2c, 0xbb, 0xef, 0x72, 0x10, 0x6f, 0x5a,
+	0x30, 0x59, 0x8c, 0xdf, 0xf8, 0xde, 0x65, 0xda, 0x43, 0xfe, 0xdd, 0xda, 0xc3, 0x63, 0x58, 0x3f,
+	0x67, 0x05, 0xb3, 0x78, 0xdd, 0x65, 0x3d, 0xbf, 0x5b, 0x38, 0xc8, 0x29, 0x6b, 0xb8, 0x76, 0x2e,
+	0x2a, 0x89, 0xcb, 0x1b, 0x45, 0x90, 0x4f, 0xbd, 0x60, 0xd8, 0xd8, 0x81, 0x42, 0xcb, 0xa7, 0x3c,
+	0x60, 0xc5, 0x90, 0x38, 0x11, 0x0d, 0x52, 0x3f, 0x8a, 0x59, 0xe3, 0x0f, 0x12, 0x6c, 0x9a, 0x2e,
+	0x09, 0x62, 0x2f, 0x9e, 0x9e, 0x78, 0xc3, 0xd0, 0x89, 0xc5, 0x39, 0x77, 0xa8, 0xef, 0xda, 0xec,
+	0x95, 0xf4, 0x06, 0x5e, 0xdf, 0x89, 0x85, 0xf1, 0x35, 0xbc, 0x41, 0x7d, 0xb7, 0xb5, 0x94, 0x32,
+	0x30, 0x20, 0x57, 0x2b, 0xa0, 0x08, 0xfd, 0x46, 0x40, 0xae, 0xb2, 0xe0, 0x36, 0x54, 0x22, 0x6f,
+	0x18, 0x38, 0xf1, 0x24, 0x24, 0x49, 0xd9, 0x2e, 0x05, 0x7b, 0x7f, 0xcc, 0x43, 0x35, 0xf3, 0xff,
+	0x87, 0x9e, 0xc0, 0x46, 0xeb, 0xf8, 0xac, 0xdb, 0x33, 0xb0, 0xdd, 0xea, 0x58, 0x87, 0xe6, 0x91,
+	0xb2, 0xa6, 0x6d, 0xcf, 0xe6, 0xba, 0x3a, 0x5a, 0x42, 0xab, 0xbf, 0x76, 0x3b, 0x50, 0x30, 0xad,
+	0xb6, 0xf1, 0x1b, 0x45, 0xd2, 0xee, 0xcd, 0xe6, 0xba, 0x92, 0x01, 0xc5, 0x3b, 0xf9, 0x31, 0xd4,
+	0x38, 0x60, 0x9f, 0x9d, 0xb6, 0x9b, 0x3d, 0x43, 0xc9, 0x69, 0xda, 0x6c, 0xae, 0x6f, 0xdd, 0xe4,
+	0x92, 0xc8, 0x7f, 0x00, 0x25, 0x6c, 0xfc, 0xfa, 0xcc, 0xe8, 0xf6, 0x94, 0xbc, 0xb6, 0x35, 0x9b,
+	0xeb, 0x28, 0x03, 0xa6, 0xb5, 0xfb, 0x08, 0xca, 0xd8, 0xe8, 0x9e, 0x76, 0xac, 0xae, 0xa1, 0xc8,
+	0xda, 0xf7, 0x66, 0x73, 0xfd, 0xee, 0x0a, 0x95, 0xd4, 0xca, 0x8f, 0x60, 0xb3, 0xdd, 0xf9, 0xd2,
+	0x3a, 0xee, 0x34, 0xdb, 0xf6, 0x29, 0xee, 0x1c, 0x61, 0xa3, 0xdb, 0x55, 0x0a, 0xda, 0xce, 0x6c,
+	0xae, 0xbf, 0x97, 0xe1, 0x6f, 0xa5, 0xfe, 0xfb, 0x20, 0x9f, 0x9a, 0xd6, 0x91, 0x52, 0xd4, 0xee,
+	0xce, 0xe6, 0xfa, 0x9d, 0x0c, 0xca, 0x42, 0xcb, 0x2c, 0x6e, 0x1d, 0x77, 0xba, 0x86, 0x52, 0xba,
+	0x65, 0xb1, 0x08, 0xf9, 0x4f, 0x00, 0x99, 0x6d, 0xc3, 0xea, 0x99, 0xbd, 0xe7, 0xf6, 0x89, 0x79,
+	0x84, 0x9b, 0x3d, 0xb3, 0x63, 0x29, 0x65, 0x4d, 0x9f, 0xcd, 0xf5, 0xed, 0xac, 0xdd, 0x37, 0xc3,
+	0xbf, 0xf7, 0x5b, 0x40, 0xb7, 0xff, 0xad, 0xd1, 0x43, 0x90, 0xad, 0x8e, 0x65, 0x28, 0x6b, 0xc2,
+	0x73, 0xb7, 0x09, 0x8b, 0x06, 0x04, 0x35, 0x20, 0x7f, 0xfc, 0xd5, 0x17, 0x8a, 0xa4, 0x7d, 0x7f,
+	0x36, 0xd7, 0xef, 0xdf, 0x86, 0x8e, 0xbf, 0xfa, 0x62, 0x8f, 0x42, 0x35, 0xbb, 0x71, 0x03, 0xca,
+	0x27, 0x46, 0xaf, 0xd9, 0x6e, 0xf6, 0x9a, 0xca, 0x9a, 0x30, 0x26, 0x55, 0x9f, 0x90, 0xd8, 0xe1,
+	0x4d, 0x64, 0x1b, 0x0a, 0x96, 0xf1, 0xcc, 0xc0, 0x8a, 0xa4, 0x6d, 0xce, 0xe6, 0xfa, 0x7a, 0x0a,
+	0x58, 0xe4, 0x92, 0x84, 0xa8, 0x0e, 0xc5, 0xe6, 0xf1, 0x97, 0xcd, 0xe7, 0x5d, 0x25, 0xa7, 0xa1,
+	0xd9, 0x5c, 0xdf, 0x48, 0xd5, 0x4d, 0xff, 0xca, 0x99, 0x46, 0x7b, 0xff, 0x95, 0xa0, 0x96, 0xfd,
+	0x9f, 0x40, 0x75, 0x90, 0x0f, 0xcd, 0x63, 0x23, 0x3d, 0x2e, 0xab, 0x63, 0x63, 0xb4, 0x0b, 0x95,
+	0xb6, 0x89, 0x8d, 0x56, 0xaf, 0x83, 0x9f, 0xa7, 0xb6, 0x64, 0xa1, 0xb6, 0x17, 0xf2, 0x02, 0x9d,
+	0xa2, 0x9f, 0x42, 0xad, 0xfb, 0xfc, 0xe4, 0xd8, 0xb4, 0x7e, 0x65, 0xf3, 0x1d, 0x73, 0xda, 0xe3,
+	0xd9, 0x5c, 0x7f, 0xb0, 0x02, 0x93, 0x71, 0x48, 0x58, 0x15, 0xb8, 0x5d, 0xf1, 0x46, 0x32, 0x65,
+	0x59, 0x42, 0x2d, 0xd8, 0x4c, 0x97, 0x2e, 0x0f, 0xcb, 0x6b, 0x1f, 0xcf, 0xe6, 0xfa, 0x87, 0xdf,
+	0xb9, 0x7e, 0x71, 0x7a, 0x59, 0x42, 0x0f, 0xa1, 0x94, 0x6c, 0x92, 0xe6, 0x60, 0x76, 0x69, 0xb2,
+	0x60, 0xef, 0xaf, 0x12, 0x54, 0x16, 0xed, 0x96, 0x39, 0xdc, 0xea, 0xd8, 0x06, 0xc6, 0x1d, 0x9c,
+	0x7a, 0x60, 0xa1, 0xb4, 0x28, 0x1f, 0xa2, 0x07, 0x50, 0x3a, 0x32, 0x2c, 0x03, 0x9b, 0xad, 0xb4,
+	0xa4, 0x16, 0xc8, 0x11, 0x09, 0x48, 0xe8, 0xf5, 0xd1, 0x47, 0x50, 0xb3, 0x3a, 0x76, 0xf7, 0xac,
+	0xf5, 0x34, 0x35, 0x9d, 0x9f, 0x9f, 0xd9, 0xaa, 0x3b, 0xe9, 0x5f, 0x70, 0x7f, 0xee, 0xb1, 0xea,
+	0x7b, 0xd6, 0x3c, 0x36, 0xdb, 0x02, 0xcd, 0x6b, 0xea, 0x6c, 0xae, 0xdf, 0x5b, 0xa0, 0xa6, 0xf8,
+	0xb1, 0x62, 0xec, 0x9e, 0x0b, 0xf5, 0xef, 0x6e, 0xac, 0x48, 0x87, 0x62, 0xf3, 0xf4, 0xd4, 0xb0,
+	0xda, 0xe9, 0xed, 0x97, 0xba, 0xe6, 0x78, 0x4c, 0x02, 0x97, 0x11, 0x87, 0x1d, 0x7c, 0x64, 0xf4,
+	0x14, 0xe9, 0x26, 0x71, 0x48, 0xd9, 0x0f, 0xca, 0xc1, 0xf6, 0xab, 0x6f, 0xeb, 0x6b, 0xdf, 0x7c,
+	0x5b, 0x5f, 0x7b, 0x75, 0x5d, 0x97, 0xbe, 0xb9, 0xae, 0x4b, 0xff, 0xbc, 0xae, 0xaf, 0xfd, 0xfb,
+	0xba, 0x2e, 0x7d, 0xfd, 0xaf, 0xba, 0x74, 0x5e, 0xe4, 0x8d, 0xf8, 0xf3, 0xff, 0x0d, 0x00, 0xf2,
+	0xb7, 0x99, 0x8b, 0x6e, 0x0f, 0x00, 0x00,
 }
@@ -50,6 +50,9 @@ func (t *TestModel) ClusterConfig(deviceID DeviceID, config ClusterConfig) {
 func (t *TestModel) DownloadProgress(DeviceID, string, []FileDownloadProgressUpdate) {
 }
 
+func (t *TestModel) IdentityMigration(DeviceID, IdentityMigration) {
+}
+
 func (t *TestModel) closedError() error {
 	select {
 	case <-t.closedCh:
@@ -0,0 +1,108 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// identityMigrationContext is prepended to the signed data, so that the
+// signature can't be taken for anything else made with the same key.
+const identityMigrationContext = "syncthing identity migration\x00"
+
+var (
+	errMigrationWrongDevice  = errors.New("identity migration: old certificate does not belong to the device")
+	errMigrationSameIdentity = errors.New("identity migration: new identity is the same as the old")
+	errUnsupportedKey        = errors.New("identity migration: unsupported key type")
+)
+
+// NewIdentityMigration returns an announcement of the move from the old to
+// the new certificate, signed with the key of the old certificate.
+func NewIdentityMigration(oldCert, newCert tls.Certificate) (IdentityMigration, error) {
+	signer, ok := oldCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return IdentityMigration{}, errUnsupportedKey
+	}
+	_, hash, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return IdentityMigration{}, err
+	}
+
+	m := IdentityMigration{
+		OldCertificate: oldCert.Certificate[0],
+		NewCertificate: newCert.Certificate[0],
+	}
+	if NewDeviceID(m.OldCertificate) == NewDeviceID(m.NewCertificate) {
+		return IdentityMigration{}, errMigrationSameIdentity
+	}
+
+	// Ed25519 signs the message itself, the others a hash of it
+	digest := m.signedData()
+	if hash != 0 {
+		h := hash.New()
+		h.Write(digest)
+		digest = h.Sum(nil)
+	}
+	m.Signature, err = signer.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		return IdentityMigration{}, err
+	}
+	return m, nil
+}
+
+// Verify checks that the migration is from the given device and signed with
+// its key, and returns the device ID of the new identity.
+func (m IdentityMigration) Verify(device DeviceID) (DeviceID, error) {
+	if NewDeviceID(m.OldCertificate) != device {
+		return DeviceID{}, errMigrationWrongDevice
+	}
+	oldCert, err := x509.ParseCertificate(m.OldCertificate)
+	if err != nil {
+		return DeviceID{}, err
+	}
+	if _, err := x509.ParseCertificate(m.NewCertificate); err != nil {
+		return DeviceID{}, err
+	}
+
+	algo, _, err := signatureAlgorithm(oldCert.PublicKey)
+	if err != nil {
+		return DeviceID{}, err
+	}
+	if err := oldCert.CheckSignature(algo, m.signedData(), m.Signature); err != nil {
+		return DeviceID{}, err
+	}
+
+	newID := NewDeviceID(m.NewCertificate)
+	if newID == device {
+		return DeviceID{}, errMigrationSameIdentity
+	}
+	return newID, nil
+}
+
+func (m IdentityMigration) signedData() []byte {
+	bs := make([]byte, 0, len(identityMigrationContext)+len(m.OldCertificate)+len(m.NewCertificate))
+	bs = append(bs, identityMigrationContext...)
+	bs = append(bs, m.OldCertificate...)
+	return append(bs, m.NewCertificate...)
+}
+
+func signatureAlgorithm(pub crypto.PublicKey) (x509.SignatureAlgorithm, crypto.Hash, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA256, crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, 0, nil
+	default:
+		return 0, 0, errUnsupportedKey
+	}
+}
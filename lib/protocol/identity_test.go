@@ -0,0 +1,91 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestIdentityMigration(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ecdsaKey, ed25519Key} {
+		oldCert := testCertificate(t, key)
+		newCert := testCertificate(t, otherKey)
+		oldID := NewDeviceID(oldCert.Certificate[0])
+
+		m, err := NewIdentityMigration(oldCert, newCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Survives the wire
+		bs, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var received IdentityMigration
+		if err := received.Unmarshal(bs); err != nil {
+			t.Fatal(err)
+		}
+
+		newID, err := received.Verify(oldID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newID != NewDeviceID(newCert.Certificate[0]) {
+			t.Errorf("Wrong new device ID %v", newID)
+		}
+
+		// Announced by another device
+		if _, err := received.Verify(newID); err == nil {
+			t.Error("Unexpected nil error for migration announced by the wrong device")
+		}
+
+		// Tampered with
+		received.NewCertificate = testCertificate(t, rsaKey).Certificate[0]
+		if _, err := received.Verify(oldID); err == nil {
+			t.Error("Unexpected nil error for migration to a different certificate")
+		}
+	}
+}
+
+func testCertificate(t *testing.T, key crypto.Signer) tls.Certificate {
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "syncthing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -75,6 +75,8 @@ type Model interface {
 	Closed(conn Connection, err error)
 	// The peer device sent progress updates for the files it is currently downloading
 	DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate)
+	// The peer device announced that it is moving to a new identity
+	IdentityMigration(deviceID DeviceID, migration IdentityMigration)
 }
 
 type Connection interface {
@@ -86,6 +88,7 @@ type Connection interface {
 	Request(folder string, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error)
 	ClusterConfig(config ClusterConfig)
 	DownloadProgress(folder string, updates []FileDownloadProgressUpdate)
+	IdentityMigration(migration IdentityMigration)
 	Statistics() Statistics
 	Closed() bool
 }
@@ -271,6 +274,12 @@ func (c *rawConnection) DownloadProgress(folder string, updates []FileDownloadPr
 	}, nil)
 }
 
+// IdentityMigration announces to the peer that we are moving to a new
+// identity, or confirms the peer's announcement by sending it back.
+func (c *rawConnection) IdentityMigration(migration IdentityMigration) {
+	c.send(&migration, nil)
+}
+
 func (c *rawConnection) ping() bool {
 	return c.send(&Ping{}, nil)
 }
@@ -353,6 +362,13 @@ func (c *rawConnection) readerLoop() (err error) {
 			}
 			c.receiver.DownloadProgress(c.id, msg.Folder, msg.Updates)
 
+		case *IdentityMigration:
+			l.Debugln("read IdentityMigration message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: identity migration message in state %d", state)
+			}
+			c.receiver.IdentityMigration(c.id, *msg)
+
 		case *Ping:
 			l.Debugln("read Ping message")
 			if state != stateReady {
@@ -714,6 +730,8 @@ func (c *rawConnection) typeOf(msg message) MessageType {
 		return messageTypePing
 	case *Close:
 		return messageTypeClose
+	case *IdentityMigration:
+		return messageTypeIdentityMigration
 	default:
 		panic("bug: unknown message type")
 	}
@@ -737,6 +755,8 @@ func (c *rawConnection) newMessage(t MessageType) (message, error) {
 		return new(Ping), nil
 	case messageTypeClose:
 		return new(Close), nil
+	case messageTypeIdentityMigration:
+		return new(IdentityMigration), nil
 	default:
 		return nil, errUnknownMessage
 	}
@@ -16,12 +16,14 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	stdsync "sync"
 	"time"
 
@@ -54,7 +56,7 @@ type Process struct {
 }
 
 // NewProcess returns a new Process talking to Syncthing at the specified address.
-// Example: NewProcess("127.0.0.1:8082")
+// Example: NewProcess("127.0.0.1:8082") or NewProcess("unix:///tmp/syncthing.sock")
 func NewProcess(addr string) *Process {
 	p := &Process{
 		addr:     addr,
@@ -162,9 +164,9 @@ func (p *Process) Get(path string) ([]byte, error) {
 			DisableKeepAlives: true,
 		},
 	}
+	p.useUnixSocket(client)
 
-	url := fmt.Sprintf("http://%s%s", p.addr, path)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", p.url(path), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -188,8 +190,9 @@ func (p *Process) Post(path string, data io.Reader) ([]byte, error) {
 			DisableKeepAlives: true,
 		},
 	}
-	url := fmt.Sprintf("http://%s%s", p.addr, path)
-	req, err := http.NewRequest("POST", url, data)
+	p.useUnixSocket(client)
+
+	req, err := http.NewRequest("POST", p.url(path), data)
 	if err != nil {
 		return nil, err
 	}
@@ -205,6 +208,30 @@ func (p *Process) Post(path string, data io.Reader) ([]byte, error) {
 	return p.readResponse(resp)
 }
 
+const unixSocketPrefix = "unix://"
+
+// url returns the URL for the given API path.
+func (p *Process) url(path string) string {
+	if strings.HasPrefix(p.addr, unixSocketPrefix) {
+		return "http://localhost" + path
+	}
+	return fmt.Sprintf("http://%s%s", p.addr, path)
+}
+
+// useUnixSocket makes the client connect to the Unix socket given as the
+// address, if that is what it is.
+func (p *Process) useUnixSocket(client *http.Client) {
+	if !strings.HasPrefix(p.addr, unixSocketPrefix) {
+		return
+	}
+	path := p.addr[len(unixSocketPrefix):]
+	tr := client.Transport.(*http.Transport)
+	tr.Proxy = nil
+	tr.Dial = func(_, _ string) (net.Conn, error) {
+		return net.Dial("unix", path)
+	}
+}
+
 type Event struct {
 	ID   int
 	Time time.Time
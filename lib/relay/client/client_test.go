@@ -0,0 +1,98 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	syncthingprotocol "github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/relay/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestInvitationKeyTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Devices and relays with any of our key types must be able to talk to
+	// each other.
+	var certs []tls.Certificate
+	for _, kt := range []tlsutil.KeyType{tlsutil.KeyTypeRSA, tlsutil.KeyTypeECDSA, tlsutil.KeyTypeEd25519} {
+		cert, err := tlsutil.NewCertificateWithKeyType(filepath.Join(dir, string(kt)+"-cert.pem"), filepath.Join(dir, string(kt)+"-key.pem"), "syncthing", kt, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+
+	for _, relayCert := range certs {
+		for _, deviceCert := range certs {
+			testInvitation(t, relayCert, deviceCert)
+		}
+	}
+}
+
+// testInvitation gets an invitation from a fake relay, which hands out
+// invitations from the device asking for them.
+func testInvitation(t *testing.T, relayCert, deviceCert tls.Certificate) {
+	list, err := tls.Listen("tcp", "127.0.0.1:0", configForCerts([]tls.Certificate{relayCert}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	go func() {
+		conn, err := list.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := conn.(*tls.Conn)
+		if err := tc.Handshake(); err != nil {
+			return
+		}
+		if _, err := protocol.ReadMessage(tc); err != nil {
+			return
+		}
+		peer := tc.ConnectionState().PeerCertificates
+		if len(peer) != 1 {
+			protocol.WriteMessage(tc, protocol.ResponseNotFound)
+			return
+		}
+		from := syncthingprotocol.NewDeviceID(peer[0].Raw)
+		protocol.WriteMessage(tc, protocol.SessionInvitation{From: from[:], Key: []byte("key"), Port: 22067})
+	}()
+
+	relayID := syncthingprotocol.NewDeviceID(relayCert.Certificate[0])
+	deviceID := syncthingprotocol.NewDeviceID(deviceCert.Certificate[0])
+	uri, err := url.Parse("relay://" + list.Addr().String() + "/?id=" + relayID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := GetInvitationFromRelay(uri, deviceID, []tls.Certificate{deviceCert}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(inv.From, deviceID[:]) {
+		t.Errorf("Relay saw device %x, expected %v", inv.From, deviceID)
+	}
+	if !net.IP(inv.Address).IsLoopback() {
+		t.Errorf("Unexpected invitation address %v", net.IP(inv.Address))
+	}
+}
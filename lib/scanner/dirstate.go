@@ -0,0 +1,100 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// A DirState is the state of a directory when it was last listed. Adding,
+// removing or renaming entries changes the modification time of the
+// directory; the inode and the number of entries catch the changes that
+// happen within the granularity of the modification time.
+type DirState struct {
+	ModTime  time.Time
+	Inode    uint64 // zero where not available
+	Children int
+	Subdirs  []string // names of the subdirectories
+}
+
+// A DirStateStore keeps the states of the directories of a folder between
+// scans. The root directory of the folder is named ".".
+type DirStateStore interface {
+	DirState(relPath string) (DirState, bool)
+	SetDirState(relPath string, state DirState)
+}
+
+// A dirTracker collects the states of the directories listed during a walk.
+type dirTracker struct {
+	states   map[string]*DirState
+	unstable map[string]struct{} // to be listed again
+}
+
+func newDirTracker() *dirTracker {
+	return &dirTracker{
+		states:   make(map[string]*DirState),
+		unstable: make(map[string]struct{}),
+	}
+}
+
+// listing starts the state of a directory that is about to be listed.
+func (t *dirTracker) listing(relPath string, info fs.FileInfo) {
+	t.states[relPath] = &DirState{
+		ModTime: info.ModTime(),
+		Inode:   fs.Inode(info),
+	}
+}
+
+// visited counts the entry in the state of its directory, if that is being
+// listed. The walk func is called a second time for a directory that can't
+// be listed, which then has no state.
+func (t *dirTracker) visited(relPath string, info fs.FileInfo, err error) {
+	if err != nil && info != nil {
+		delete(t.states, relPath)
+		return
+	}
+	if relPath == "." {
+		return
+	}
+	state, ok := t.states[filepath.Dir(relPath)]
+	if !ok {
+		return
+	}
+	state.Children++
+	if err == nil && info.IsDir() && !info.IsSymlink() {
+		state.Subdirs = append(state.Subdirs, filepath.Base(relPath))
+	}
+}
+
+// relist makes the directory be listed again by the next scan, even if it
+// doesn't change, as it has files that should be looked at again.
+func (t *dirTracker) relist(relPath string) {
+	t.unstable[relPath] = struct{}{}
+}
+
+func (t *dirTracker) save(store DirStateStore) {
+	for relPath, state := range t.states {
+		if _, ok := t.unstable[relPath]; ok {
+			// Never matches a directory listing
+			state.Children = -1
+		}
+		store.SetDirState(relPath, *state)
+	}
+}
+
+// unchanged returns whether the directory is in the state it was in when
+// it was last listed.
+func (w *walker) unchanged(absPath string, info fs.FileInfo, state DirState) bool {
+	if !state.ModTime.Equal(info.ModTime()) || state.Inode != fs.Inode(info) {
+		return false
+	}
+	names, err := w.Filesystem.DirNames(absPath)
+	return err == nil && len(names) == state.Children
+}
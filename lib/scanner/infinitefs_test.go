@@ -54,14 +54,22 @@ func (infiniteFS) Chmod(name string, mode fs.FileMode) error                   {
 func (infiniteFS) Chtimes(name string, atime time.Time, mtime time.Time) error { return errNotSupp }
 func (infiniteFS) Create(name string) (fs.File, error)                         { return nil, errNotSupp }
 func (infiniteFS) CreateSymlink(name, target string) error                     { return errNotSupp }
+func (infiniteFS) Glob(pattern string) ([]string, error)                       { return nil, errNotSupp }
+func (infiniteFS) Hide(name string) error                                      { return errNotSupp }
 func (infiniteFS) Mkdir(name string, perm fs.FileMode) error                   { return errNotSupp }
+func (infiniteFS) MkdirAll(name string, perm fs.FileMode) error                { return errNotSupp }
 func (infiniteFS) ReadSymlink(name string) (string, error)                     { return "", errNotSupp }
 func (infiniteFS) Remove(name string) error                                    { return errNotSupp }
+func (infiniteFS) RemoveAll(name string) error                                 { return errNotSupp }
 func (infiniteFS) Rename(oldname, newname string) error                        { return errNotSupp }
 func (infiniteFS) Stat(name string) (fs.FileInfo, error)                       { return nil, errNotSupp }
 func (infiniteFS) SymlinksSupported() bool                                     { return false }
 func (infiniteFS) Walk(root string, walkFn fs.WalkFunc) error                  { return errNotSupp }
 
+func (infiniteFS) OpenFile(name string, flags int, mode fs.FileMode) (fs.File, error) {
+	return nil, errNotSupp
+}
+
 type fakeInfo struct {
 	name string
 	size int64
@@ -98,6 +106,10 @@ func (f *fakeFile) Stat() (fs.FileInfo, error) {
 	return fakeInfo{f.name, f.size}, nil
 }
 
+func (f *fakeFile) Name() string                               { return f.name }
+func (f *fakeFile) ReadAt(bs []byte, offs int64) (int, error)  { return 0, errNotSupp }
+func (f *fakeFile) Write(bs []byte) (int, error)               { return 0, errNotSupp }
 func (f *fakeFile) WriteAt(bs []byte, offs int64) (int, error) { return 0, errNotSupp }
 func (f *fakeFile) Close() error                               { return nil }
+func (f *fakeFile) Sync() error                                { return nil }
 func (f *fakeFile) Truncate(size int64) error                  { return errNotSupp }
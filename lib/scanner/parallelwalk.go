@@ -0,0 +1,109 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// A parallelWalk walks a tree like fs.WalkFilesystem does, calling the walk
+// func in the same order, but stats the entries of each directory with a
+// number of concurrent routines. This pays off where stat calls are latency
+// bound, as on network file systems. The infos are from the Lstat of the
+// filesystem itself.
+type parallelWalk struct {
+	ctx        context.Context
+	filesystem fs.Filesystem
+	workers    int
+}
+
+func (p *parallelWalk) Walk(root string, walkFn fs.WalkFunc) error {
+	info, err := p.filesystem.Lstat(root)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	return p.walk(root, info, walkFn)
+}
+
+func (p *parallelWalk) walk(path string, info fs.FileInfo, walkFn fs.WalkFunc) error {
+	err := walkFn(path, info, nil)
+	if err != nil {
+		if info.IsDir() && err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	// The directory is only listed once the walk func has accepted it, so
+	// that ignored and skipped directories aren't listed.
+	names, err := p.filesystem.DirNames(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	infos, errs := p.lstat(path, names)
+
+	for i, name := range names {
+		filename := filepath.Join(path, name)
+		if errs[i] != nil {
+			if err := walkFn(filename, nil, errs[i]); err != nil && err != fs.SkipDir {
+				return err
+			}
+		} else {
+			err = p.walk(filename, infos[i], walkFn)
+			if err != nil {
+				if !infos[i].IsDir() || err != fs.SkipDir {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lstat returns the infos of the entries of the directory, or the errors
+// getting them, in the order of the names.
+func (p *parallelWalk) lstat(dir string, names []string) ([]fs.FileInfo, []error) {
+	infos := make([]fs.FileInfo, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for i := 0; i < p.workers && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				infos[i], errs[i] = p.filesystem.Lstat(filepath.Join(dir, names[i]))
+			}
+		}()
+	}
+
+loop:
+	for i := range names {
+		select {
+		case next <- i:
+		case <-p.ctx.Done():
+			// The walk func returns the error on the next call
+			for ; i < len(names); i++ {
+				errs[i] = p.ctx.Err()
+			}
+			break loop
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	return infos, errs
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A SettleTracker remembers the size and modification time that changed
+// files were seen with between scans, to tell whether they're still being
+// written.
+type SettleTracker struct {
+	seen map[string]settleState
+	mut  sync.Mutex
+}
+
+type settleState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // when the file was first seen with this size and modification time
+}
+
+func NewSettleTracker() *SettleTracker {
+	return &SettleTracker{
+		seen: make(map[string]settleState),
+		mut:  sync.NewMutex(),
+	}
+}
+
+// settledAt records the size and modification time of the file, and returns
+// the time they will have been unchanged for d.
+func (t *SettleTracker) settledAt(relPath string, size int64, modTime time.Time, d time.Duration, now time.Time) time.Time {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	s, ok := t.seen[relPath]
+	if !ok || s.size != size || !s.modTime.Equal(modTime) {
+		s = settleState{size: size, modTime: modTime, since: now}
+		t.seen[relPath] = s
+	}
+	return s.since.Add(d)
+}
+
+// Seen returns the size and modification time the file was last seen
+// with, if it hasn't settled.
+func (t *SettleTracker) Seen(relPath string) (size int64, modTime time.Time, ok bool) {
+	t.mut.Lock()
+	s, ok := t.seen[relPath]
+	t.mut.Unlock()
+	return s.size, s.modTime, ok
+}
+
+// forget drops the file, which has settled.
+func (t *SettleTracker) forget(relPath string) {
+	t.mut.Lock()
+	delete(t.seen, relPath)
+	t.mut.Unlock()
+}
+
+// Retain drops all files but the given ones, such as those still unsettled
+// after scanning the whole folder.
+func (t *SettleTracker) Retain(relPaths []string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	seen := make(map[string]settleState, len(relPaths))
+	for _, relPath := range relPaths {
+		if s, ok := t.seen[relPath]; ok {
+			seen[relPath] = s
+		}
+	}
+	t.seen = seen
+}
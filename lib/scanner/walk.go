@@ -65,6 +65,9 @@ type Config struct {
 	AutoNormalize bool
 	// Number of routines to use for hashing
 	Hashers int
+	// Number of routines to stat the entries of each directory with. One or
+	// less walks the tree sequentially.
+	Walkers int
 	// Our vector clock id
 	ShortID protocol.ShortID
 	// Optional progress tick interval which defines how often FolderScanProgress
@@ -72,6 +75,27 @@ type Config struct {
 	ProgressTickIntervalS int
 	// Whether or not we should also compute weak hashes
 	UseWeakHashes bool
+	// If DirStates is not nil, the states of the listed directories are
+	// recorded in it, and directories that are unchanged since their state
+	// was recorded are not listed again; only their subdirectories are
+	// walked. Changes to the contents or permissions of files in unchanged
+	// directories are not detected until the next full scan. The Subs
+	// given to scan are always listed.
+	DirStates DirStateStore
+	// If FullScan is true, all directories are listed even if unchanged,
+	// and their states recorded afresh.
+	FullScan bool
+	// If DirSkipped is not nil, it is called with each unchanged directory
+	// that was not listed.
+	DirSkipped func(relPath string)
+	// Changed files are only hashed once Settles has seen them with the
+	// same size and modification time for SettleTime, as they may
+	// otherwise still be being written. If Unsettled is not nil, it is
+	// called with each file not hashed yet and the time it will have
+	// settled, if left alone.
+	SettleTime time.Duration
+	Settles    *SettleTracker
+	Unsettled  func(relPath string, settleAt time.Time)
 }
 
 type CurrentFiler interface {
@@ -80,7 +104,7 @@ type CurrentFiler interface {
 }
 
 func Walk(ctx context.Context, cfg Config) (chan protocol.FileInfo, error) {
-	w := walker{cfg}
+	w := walker{Config: cfg}
 
 	if w.CurrentFiler == nil {
 		w.CurrentFiler = noCurrentFiler{}
@@ -88,12 +112,37 @@ func Walk(ctx context.Context, cfg Config) (chan protocol.FileInfo, error) {
 	if w.Filesystem == nil {
 		w.Filesystem = fs.DefaultFilesystem
 	}
+	if w.DirStates != nil {
+		w.dirs = newDirTracker()
+	}
+	if w.DirSkipped == nil {
+		w.DirSkipped = func(string) {}
+	}
+	if w.Settles == nil {
+		w.Settles = NewSettleTracker()
+	}
+	if w.Unsettled == nil {
+		w.Unsettled = func(string, time.Time) {}
+	}
+	if w.Walkers > 1 {
+		w.tree = &parallelWalk{ctx: ctx, filesystem: w.Filesystem, workers: w.Walkers}
+		w.treeLstat = true
+	} else {
+		w.tree = w.Filesystem
+	}
 
 	return w.walk(ctx)
 }
 
 type walker struct {
 	Config
+	tree      treeWalker
+	treeLstat bool        // the tree walker passes infos from Filesystem.Lstat
+	dirs      *dirTracker // nil unless scanning incrementally
+}
+
+type treeWalker interface {
+	Walk(root string, walkFn fs.WalkFunc) error
 }
 
 // Walk returns the list of files found in the local folder by scanning the
@@ -113,12 +162,15 @@ func (w *walker) walk(ctx context.Context) (chan protocol.FileInfo, error) {
 	go func() {
 		hashFiles := w.walkAndHashFiles(ctx, toHashChan, finishedChan)
 		if len(w.Subs) == 0 {
-			w.Filesystem.Walk(w.Dir, hashFiles)
+			w.tree.Walk(w.Dir, hashFiles)
 		} else {
 			for _, sub := range w.Subs {
-				w.Filesystem.Walk(filepath.Join(w.Dir, sub), hashFiles)
+				w.tree.Walk(filepath.Join(w.Dir, sub), hashFiles)
 			}
 		}
+		if w.dirs != nil && ctx.Err() == nil {
+			w.dirs.save(w.DirStates)
+		}
 		close(toHashChan)
 	}()
 
@@ -203,13 +255,20 @@ func (w *walker) walk(ctx context.Context) (chan protocol.FileInfo, error) {
 
 func (w *walker) walkAndHashFiles(ctx context.Context, fchan, dchan chan protocol.FileInfo) fs.WalkFunc {
 	now := time.Now()
-	return func(absPath string, info fs.FileInfo, err error) error {
+	var walkFn fs.WalkFunc
+	walkFn = func(absPath string, info fs.FileInfo, err error) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
+		if w.dirs != nil {
+			if relPath, relErr := filepath.Rel(w.Dir, absPath); relErr == nil {
+				w.dirs.visited(relPath, info, err)
+			}
+		}
+
 		// Return value used when we are returning early and don't want to
 		// process the item. For directories, this means do-not-descend.
 		var skip error // nil
@@ -230,13 +289,18 @@ func (w *walker) walkAndHashFiles(ctx context.Context, fchan, dchan chan protoco
 		}
 
 		if relPath == "." {
+			if w.dirs != nil && info.IsDir() {
+				return w.walkDirState(absPath, relPath, info, walkFn)
+			}
 			return nil
 		}
 
-		info, err = w.Filesystem.Lstat(absPath)
-		// An error here would be weird as we've already gotten to this point, but act on it nonetheless
-		if err != nil {
-			return skip
+		if !w.treeLstat {
+			info, err = w.Filesystem.Lstat(absPath)
+			// An error here would be weird as we've already gotten to this point, but act on it nonetheless
+			if err != nil {
+				return skip
+			}
 		}
 
 		if ignore.IsTemporary(relPath) {
@@ -281,6 +345,9 @@ func (w *walker) walkAndHashFiles(ctx context.Context, fchan, dchan chan protoco
 
 		case info.IsDir():
 			err = w.walkDir(ctx, relPath, info, dchan)
+			if err == nil && w.dirs != nil {
+				err = w.walkDirState(absPath, relPath, info, walkFn)
+			}
 
 		case info.IsRegular():
 			err = w.walkRegular(ctx, relPath, info, fchan)
@@ -288,6 +355,38 @@ func (w *walker) walkAndHashFiles(ctx context.Context, fchan, dchan chan protoco
 
 		return err
 	}
+	return walkFn
+}
+
+// walkDirState walks the subdirectories of the directory and returns
+// fs.SkipDir if the directory is unchanged since its state was recorded.
+// Otherwise, or if the directory was explicitly asked to be scanned, the
+// directory is listed as usual and its state recorded.
+func (w *walker) walkDirState(absPath, relPath string, info fs.FileInfo, walkFn fs.WalkFunc) error {
+	state, ok := w.DirStates.DirState(relPath)
+	if w.FullScan || w.isSub(relPath) || !ok || !w.unchanged(absPath, info, state) {
+		w.dirs.listing(relPath, info)
+		return nil
+	}
+
+	l.Debugln("unchanged dir:", relPath)
+	w.DirSkipped(relPath)
+	for _, name := range state.Subdirs {
+		if err := w.tree.Walk(filepath.Join(absPath, name), walkFn); err != nil {
+			return err
+		}
+	}
+	return fs.SkipDir
+}
+
+// isSub returns true if the path is one of the Subs to scan.
+func (w *walker) isSub(relPath string) bool {
+	for _, sub := range w.Subs {
+		if filepath.Clean(sub) == relPath {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileInfo, fchan chan protocol.FileInfo) error {
@@ -312,6 +411,20 @@ func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileIn
 		return nil
 	}
 
+	if w.SettleTime > 0 {
+		now := time.Now()
+		settleAt := w.Settles.settledAt(relPath, info.Size(), info.ModTime(), w.SettleTime, now)
+		if now.Before(settleAt) {
+			l.Debugln("unsettled:", relPath, settleAt)
+			w.Unsettled(relPath, settleAt)
+			if w.dirs != nil {
+				w.dirs.relist(filepath.Dir(relPath))
+			}
+			return nil
+		}
+		w.Settles.forget(relPath)
+	}
+
 	if ok {
 		l.Debugln("rescan:", cf, info.ModTime().Unix(), info.Mode()&fs.ModePerm)
 	}
@@ -12,6 +12,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -19,6 +20,7 @@ import (
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -524,3 +526,408 @@ func TestStopWalk(t *testing.T) {
 		t.Error("unexpected extra entries received after cancel")
 	}
 }
+
+func TestWalkParallel(t *testing.T) {
+	ignores := ignore.New(false)
+	if err := ignores.Load("testdata/.stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	fchan, err := Walk(context.TODO(), Config{
+		Dir:       "testdata",
+		BlockSize: 128 * 1024,
+		Matcher:   ignores,
+		Hashers:   2,
+		Walkers:   4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tmp []protocol.FileInfo
+	for f := range fchan {
+		tmp = append(tmp, f)
+	}
+	sort.Sort(fileList(tmp))
+	files := fileList(tmp).testfiles()
+
+	if diff, equal := messagediff.PrettyDiff(testdata, files); !equal {
+		t.Errorf("Walk returned unexpected data. Diff:\n%s", diff)
+	}
+}
+
+func TestParallelWalkOrder(t *testing.T) {
+	record := func(paths *[]string) fs.WalkFunc {
+		return func(path string, info fs.FileInfo, err error) error {
+			*paths = append(*paths, fmt.Sprint(path, err))
+			if info != nil && info.IsDir() && filepath.Base(path) == "dir2" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+	}
+
+	var sequential, parallel []string
+	fs.DefaultFilesystem.Walk("testdata", record(&sequential))
+	p := &parallelWalk{ctx: context.Background(), filesystem: fs.DefaultFilesystem, workers: 4}
+	p.Walk("testdata", record(&parallel))
+
+	if len(sequential) < 10 {
+		t.Fatalf("too few entries walked: %v", sequential)
+	}
+	if fmt.Sprint(sequential) != fmt.Sprint(parallel) {
+		t.Errorf("parallel walk order differs:\n%v\n%v", sequential, parallel)
+	}
+}
+
+func TestStopParallelWalk(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fchan, err := Walk(ctx, Config{
+		Dir:                   "testdir",
+		BlockSize:             128 * 1024,
+		Hashers:               2,
+		Walkers:               4,
+		Filesystem:            &infiniteFS{100, 100, 1e6},
+		ProgressTickIntervalS: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		<-fchan
+	}
+	cancel()
+
+	extra := 0
+	for range fchan {
+		extra++
+	}
+	if extra > 2 {
+		t.Error("unexpected extra entries received after cancel:", extra)
+	}
+}
+
+type mapDirStates map[string]DirState
+
+func (m mapDirStates) DirState(relPath string) (DirState, bool) {
+	s, ok := m[relPath]
+	return s, ok
+}
+
+func (m mapDirStates) SetDirState(relPath string, state DirState) {
+	m[relPath] = state
+}
+
+type mapCurrentFiler map[string]protocol.FileInfo
+
+func (m mapCurrentFiler) CurrentFile(name string) (protocol.FileInfo, bool) {
+	f, ok := m[name]
+	return f, ok
+}
+
+func TestWalkIncremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a/file1", "a/b/file2", "c/file3"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states := make(mapDirStates)
+	current := make(mapCurrentFiler)
+	scan := func(full bool) (changed []string, skipped []string) {
+		fchan, err := Walk(context.TODO(), Config{
+			Dir:          dir,
+			BlockSize:    128 * 1024,
+			Hashers:      2,
+			CurrentFiler: current,
+			DirStates:    states,
+			FullScan:     full,
+			DirSkipped:   func(relPath string) { skipped = append(skipped, relPath) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []protocol.FileInfo
+		for f := range fchan {
+			found = append(found, f)
+		}
+		for _, f := range found {
+			current[f.Name] = f
+			changed = append(changed, filepath.ToSlash(f.Name))
+		}
+		sort.Strings(changed)
+		sort.Strings(skipped)
+		return changed, skipped
+	}
+
+	if changed, _ := scan(false); len(changed) != 6 {
+		t.Fatalf("first scan found %v", changed)
+	}
+	if len(states) != 4 || states["a"].Children != 2 || len(states["a"].Subdirs) != 1 {
+		t.Fatalf("unexpected dir states %+v", states)
+	}
+
+	// Changing a file in place doesn't change its directory, so it goes
+	// unnoticed until a full scan. A new file is found, but its directory
+	// is listed without listing the unchanged ones.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a", "file1"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "c", "file4"), []byte("file4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, skipped := scan(false)
+	if len(changed) != 1 || changed[0] != "c/file4" {
+		t.Errorf("incremental scan found %v", changed)
+	}
+	if exp := []string{".", "a", filepath.Join("a", "b")}; fmt.Sprint(skipped) != fmt.Sprint(exp) {
+		t.Errorf("incremental scan skipped %v, expected %v", skipped, exp)
+	}
+
+	changed, skipped = scan(true)
+	if len(changed) != 1 || changed[0] != "a/file1" || len(skipped) != 0 {
+		t.Errorf("full scan found %v and skipped %v", changed, skipped)
+	}
+}
+
+func TestWalkIncrementalSub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a/file1", "a/b/file2"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states := make(mapDirStates)
+	current := make(mapCurrentFiler)
+	scan := func(subs ...string) (changed []string, skipped []string) {
+		fchan, err := Walk(context.TODO(), Config{
+			Dir:          dir,
+			Subs:         subs,
+			BlockSize:    128 * 1024,
+			Hashers:      2,
+			CurrentFiler: current,
+			DirStates:    states,
+			DirSkipped:   func(relPath string) { skipped = append(skipped, relPath) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for f := range fchan {
+			current[f.Name] = f
+			changed = append(changed, filepath.ToSlash(f.Name))
+		}
+		sort.Strings(changed)
+		return changed, skipped
+	}
+
+	if changed, _ := scan(); len(changed) != 4 {
+		t.Fatalf("first scan found %v", changed)
+	}
+
+	// A file changed in place is found when its parent is scanned
+	// explicitly, though the directory itself is unchanged. Its unchanged
+	// subdirectories are still skipped.
+	later := time.Now().Add(time.Hour)
+	for _, name := range []string{"a/file1", "a/b/file2"} {
+		if err := os.Chtimes(filepath.Join(dir, name), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed, skipped := scan("a")
+	if len(changed) != 1 || changed[0] != "a/file1" {
+		t.Errorf("sub scan found %v", changed)
+	}
+	if exp := []string{filepath.Join("a", "b")}; fmt.Sprint(skipped) != fmt.Sprint(exp) {
+		t.Errorf("sub scan skipped %v, expected %v", skipped, exp)
+	}
+
+	changed, skipped = scan(filepath.Join("a", "b"))
+	if len(changed) != 1 || changed[0] != "a/b/file2" || len(skipped) != 0 {
+		t.Errorf("sub scan found %v and skipped %v", changed, skipped)
+	}
+}
+
+func TestWalkSettleTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"settled", "writing"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One file was seen with its current size and modification time long
+	// ago, the other has grown since
+	settles := NewSettleTracker()
+	for _, name := range []string{"settled", "writing"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := info.Size()
+		if name == "writing" {
+			size--
+		}
+		settles.settledAt(name, size, info.ModTime(), time.Hour, time.Now().Add(-2*time.Hour))
+	}
+
+	states := make(mapDirStates)
+	var unsettled []string
+	fchan, err := Walk(context.TODO(), Config{
+		Dir:        dir,
+		BlockSize:  128 * 1024,
+		Hashers:    2,
+		DirStates:  states,
+		SettleTime: time.Hour,
+		Settles:    settles,
+		Unsettled:  func(relPath string, _ time.Time) { unsettled = append(unsettled, relPath) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []protocol.FileInfo
+	for f := range fchan {
+		found = append(found, f)
+	}
+	if len(found) != 1 || found[0].Name != "settled" {
+		t.Errorf("unexpected files scanned: %v", found)
+	}
+	if len(unsettled) != 1 || unsettled[0] != "writing" {
+		t.Errorf("unexpected unsettled files: %v", unsettled)
+	}
+	if _, ok := settles.seen["settled"]; ok {
+		t.Error("settled file still tracked")
+	}
+	// The directory has to be listed again to get to the file
+	if s, ok := states["."]; !ok || s.Children >= 0 {
+		t.Errorf("unexpected state of the directory: %+v", s)
+	}
+}
+
+type mapKV map[string][]byte
+
+func (m mapKV) Bytes(key string) ([]byte, bool) {
+	data, ok := m[key]
+	return data, ok
+}
+
+func (m mapKV) PutBytes(key string, data []byte) {
+	m[key] = data
+}
+
+func (m mapKV) Delete(key string) {
+	delete(m, key)
+}
+
+func writeMemFile(t *testing.T, filesystem fs.Filesystem, name, data string) {
+	if err := filesystem.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := filesystem.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func walkFilesystem(t *testing.T, filesystem fs.Filesystem, current mapCurrentFiler) []protocol.FileInfo {
+	fchan, err := Walk(context.TODO(), Config{
+		Dir:          "folder",
+		BlockSize:    128 * 1024,
+		Hashers:      2,
+		CurrentFiler: current,
+		Filesystem:   filesystem,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []protocol.FileInfo
+	for f := range fchan {
+		found = append(found, f)
+	}
+	sort.Sort(fileList(found))
+	return found
+}
+
+func TestWalkCaseInsensitive(t *testing.T) {
+	mem := fs.NewMemoryFilesystem(fs.MemoryOptions{CaseInsensitive: true})
+	writeMemFile(t, mem, filepath.Join("folder", "Dir", "File.txt"), "hello")
+
+	// Names are reported in the case they were created with, not the one
+	// they are looked up by
+	current := make(mapCurrentFiler)
+	found := walkFilesystem(t, mem, current)
+	if len(found) != 2 || found[0].Name != "Dir" || found[1].Name != filepath.Join("Dir", "File.txt") {
+		t.Fatalf("unexpected files scanned: %v", found)
+	}
+	for _, f := range found {
+		current[f.Name] = f
+	}
+	if found := walkFilesystem(t, mem, current); len(found) != 0 {
+		t.Errorf("unchanged files scanned again: %v", found)
+	}
+
+	// After a case only rename the file is found under its new name
+	if err := mem.Rename(filepath.Join("folder", "Dir", "File.txt"), filepath.Join("folder", "dir", "file.TXT")); err != nil {
+		t.Fatal(err)
+	}
+	found = walkFilesystem(t, mem, current)
+	if len(found) != 1 || found[0].Name != filepath.Join("Dir", "file.TXT") {
+		t.Errorf("unexpected files scanned after rename: %v", found)
+	}
+}
+
+func TestWalkMtimeGranularity(t *testing.T) {
+	mem := fs.NewMemoryFilesystem(fs.MemoryOptions{MtimeGranularity: 2 * time.Second})
+	mtimefs := fs.NewMtimeFS(mem, make(mapKV))
+	name := filepath.Join("folder", "file")
+	writeMemFile(t, mem, name, "hello")
+
+	mtime := time.Unix(1234567891, 123456789)
+	if err := mtimefs.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	// The filesystem keeps only two second precision, but the file is
+	// announced with the modification time it was given
+	current := make(mapCurrentFiler)
+	found := walkFilesystem(t, mtimefs, current)
+	if len(found) != 1 || !found[0].ModTime().Equal(mtime) {
+		t.Fatalf("unexpected files scanned: %v", found)
+	}
+	current[found[0].Name] = found[0]
+	if found := walkFilesystem(t, mtimefs, current); len(found) != 0 {
+		t.Errorf("unchanged file scanned again: %v", found)
+	}
+
+	// Without the mtime store the truncated time looks like a change
+	found = walkFilesystem(t, mem, current)
+	if len(found) != 1 || !found[0].ModTime().Equal(time.Unix(1234567890, 0)) {
+		t.Errorf("unexpected files scanned without mtime store: %v", found)
+	}
+}
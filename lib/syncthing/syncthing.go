@@ -0,0 +1,507 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package syncthing runs a Syncthing instance: the database, the model with
+// its folders, connection management and discovery. It's what the syncthing
+// binary runs, minus process level concerns, and can be embedded in other
+// programs. The GUI and REST API live in the syncthing binary, which runs
+// them as part of its App through AddService; embedders can do the same with
+// their own services.
+package syncthing
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/audit"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/discover"
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+	"github.com/thejerf/suture"
+)
+
+// The names of the files kept in the home directory.
+const (
+	CertFileName     = "cert.pem"
+	KeyFileName      = "key.pem"
+	ConfigFileName   = "config.xml"
+	DatabaseFileName = "index-v0.14.0.db"
+	AuditDirName     = "audit"
+)
+
+const (
+	// TLSDefaultCommonName is the common name of device certificates.
+	TLSDefaultCommonName = "syncthing"
+
+	bepProtocolName = "bep/1.0"
+	bepRSABits      = 3072 // only used for RSA keys
+)
+
+// The discovery results are sorted by their source priority.
+const (
+	ipv6LocalDiscoveryPriority = iota
+	ipv4LocalDiscoveryPriority
+	globalDiscoveryPriority
+)
+
+const (
+	stateNew = iota
+	stateStarted
+	stateStopped
+)
+
+var (
+	errAlreadyStarted = errors.New("already started")
+	errNotStarted     = errors.New("not started")
+)
+
+// Options are the settings an App is created with. Only HomeDir is
+// required.
+type Options struct {
+	// HomeDir holds the certificate and key, the config and the database.
+	// It is created if it doesn't exist.
+	HomeDir string
+
+	// Config is the configuration to run with. When nil, it is loaded from
+	// the home directory, or a default one is created there.
+	Config *config.Wrapper
+
+	// Certificate is the device certificate. When nil, it is loaded from the
+	// home directory, or one with a key of KeyType is generated there.
+	Certificate *tls.Certificate
+	KeyType     tlsutil.KeyType
+
+	// Logger receives the messages of the App itself. The packages it uses
+	// log to logger.DefaultLogger, which handlers can be added to.
+	Logger logger.Logger
+
+	// ClientName and ClientVersion are announced to other devices. They
+	// default to "syncthing" and "unknown".
+	ClientName    string
+	ClientVersion string
+
+	// DeadlockTimeout enables the model deadlock detector when non-zero.
+	DeadlockTimeout time.Duration
+
+	// ResetDeltaIndexes drops the delta index IDs, forcing a full index
+	// exchange with all devices.
+	ResetDeltaIndexes bool
+
+	// The subsystems that can be disabled. Without connections there is
+	// no discovery either.
+	NoConnections     bool
+	NoGlobalDiscovery bool
+	NoLocalDiscovery  bool
+}
+
+// An App is a running Syncthing instance.
+type App struct {
+	opts   Options
+	l      logger.Logger
+	cert   tls.Certificate
+	myID   protocol.DeviceID
+	cfg    *config.Wrapper
+	db     *db.Instance
+	audit  *audit.Log
+	model  *model.Model
+	disco  discover.CachingMux
+	conns  *connections.Service
+	svc    *suture.Supervisor
+	stop   chan struct{}
+	state  int
+	mut    sync.Mutex
+	closed chan struct{}
+}
+
+// New returns an App with the given options. Nothing happens until it's
+// started.
+func New(opts Options) *App {
+	if opts.KeyType == "" {
+		opts.KeyType = tlsutil.KeyTypeECDSA
+	}
+	if opts.ClientName == "" {
+		opts.ClientName = "syncthing"
+	}
+	if opts.ClientVersion == "" {
+		opts.ClientVersion = "unknown"
+	}
+	a := &App{
+		opts:   opts,
+		l:      opts.Logger,
+		stop:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+	if a.l == nil {
+		a.l = l
+	}
+	return a
+}
+
+// Start sets up everything and starts running in the background.
+func (a *App) Start() error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if a.state != stateNew {
+		return errAlreadyStarted
+	}
+	if err := a.startup(); err != nil {
+		if a.db != nil {
+			a.db.Close()
+		}
+		// There is nothing to wait for
+		a.state = stateStopped
+		close(a.closed)
+		return err
+	}
+	a.state = stateStarted
+	return nil
+}
+
+func (a *App) startup() error {
+	if a.opts.HomeDir == "" {
+		return errors.New("no home directory")
+	}
+	if err := os.MkdirAll(a.opts.HomeDir, 0700); err != nil {
+		return err
+	}
+
+	certFile := filepath.Join(a.opts.HomeDir, CertFileName)
+	keyFile := filepath.Join(a.opts.HomeDir, KeyFileName)
+	if a.opts.Certificate != nil {
+		a.cert = *a.opts.Certificate
+	} else {
+		cert, err := loadOrGenerateCertificate(certFile, keyFile, a.opts.KeyType, a.l)
+		if err != nil {
+			return err
+		}
+		a.cert = cert
+	}
+	a.myID = protocol.NewDeviceID(a.cert.Certificate[0])
+
+	a.cfg = a.opts.Config
+	if a.cfg == nil {
+		cfg, err := loadOrCreateConfig(filepath.Join(a.opts.HomeDir, ConfigFileName), a.myID, a.l)
+		if err != nil {
+			return err
+		}
+		a.cfg = cfg
+	}
+
+	dbFile := filepath.Join(a.opts.HomeDir, DatabaseFileName)
+	ldb, err := db.Open(dbFile)
+	if err != nil {
+		return fmt.Errorf("cannot open database: %v - is another copy of Syncthing already running?", err)
+	}
+	a.db = ldb
+
+	if a.opts.ResetDeltaIndexes {
+		a.l.Infoln("Reinitializing delta index IDs")
+		ldb.DropDeltaIndexIDs()
+	}
+
+	// Remove database entries for folders that no longer exist in the config
+	folders := a.cfg.Folders()
+	for _, folder := range ldb.ListFolders() {
+		if _, ok := folders[folder]; !ok {
+			a.l.Infof("Cleaning data for dropped folder %q", folder)
+			db.DropFolder(ldb, folder)
+		}
+	}
+
+	if a.cfg.RawCopy().OriginalVersion == 15 {
+		// The config version 15->16 migration is about handling ignores and
+		// delta indexes and requires that we drop existing indexes that
+		// have been incorrectly ignore filtered.
+		ldb.DropDeltaIndexIDs()
+	}
+	if a.cfg.RawCopy().OriginalVersion < 19 {
+		// Converts old symlink types to new in the entire database.
+		ldb.ConvertSymlinkTypes()
+	}
+
+	protectedFiles := []string{
+		dbFile,
+		a.cfg.ConfigPath(),
+		certFile,
+		keyFile,
+	}
+
+	// Create a main service manager. We'll add things to this as we go
+	// along. We want any logging it does to go through our log system.
+	a.svc = suture.New("app", suture.Spec{
+		Log: func(line string) {
+			a.l.Debugln(line)
+		},
+	})
+
+	a.model = model.NewModel(a.cfg, a.myID, a.deviceName(), a.opts.ClientName, a.opts.ClientVersion, ldb, protectedFiles)
+	if a.opts.DeadlockTimeout > 0 {
+		a.model.StartDeadlockDetector(a.opts.DeadlockTimeout)
+	}
+	if opts := a.cfg.Options(); opts.AuditLogEnabled {
+		auditDir := filepath.Join(a.opts.HomeDir, AuditDirName)
+		a.audit = audit.New(auditDir, audit.Options{
+			MaxSize:   int64(opts.AuditLogMaxSizeKiB) * 1024,
+			MaxAge:    time.Duration(opts.AuditLogMaxAgeDays) * 24 * time.Hour,
+			MaxFiles:  opts.AuditLogMaxFiles,
+			HashChain: opts.AuditLogHashChain,
+		})
+		a.model.SetAuditLog(a.audit)
+		a.l.Infoln("File operation audit log in", auditDir)
+	}
+
+	// Add and start folders
+	for _, folderCfg := range a.cfg.Folders() {
+		if folderCfg.Paused {
+			folderCfg.CreateRoot()
+			continue
+		}
+		a.model.AddFolder(folderCfg)
+		a.model.StartFolder(folderCfg.ID)
+	}
+
+	a.svc.Add(a.model)
+
+	if !a.opts.NoConnections {
+		a.startConnections()
+	}
+
+	a.svc.ServeBackground()
+	go a.run()
+	return nil
+}
+
+func (a *App) startConnections() {
+	// Start discovery
+
+	a.disco = discover.NewCachingMux()
+	a.svc.Add(a.disco)
+
+	// Start connection management
+
+	a.conns = connections.NewService(a.cfg, a.myID, a.model, tlsConfig(a.cert), a.disco, bepProtocolName, TLSDefaultCommonName, a.localNetworks())
+	a.svc.Add(a.conns)
+
+	opts := a.cfg.Options()
+
+	if opts.GlobalAnnEnabled && !a.opts.NoGlobalDiscovery {
+		for _, srv := range a.cfg.GlobalDiscoveryServers() {
+			a.l.Infoln("Using discovery server", srv)
+			gd, err := discover.NewGlobal(srv, a.cert, a.conns)
+			if err != nil {
+				a.l.Warnln("Global discovery:", err)
+				continue
+			}
+
+			// Each global discovery server gets its results cached for five
+			// minutes, and is not asked again for a minute when it's returned
+			// unsuccessfully.
+			a.disco.Add(gd, 5*time.Minute, time.Minute, globalDiscoveryPriority)
+		}
+	}
+
+	if opts.LocalAnnEnabled && !a.opts.NoLocalDiscovery {
+		// v4 broadcasts
+		bcd, err := discover.NewLocal(a.myID, fmt.Sprintf(":%d", opts.LocalAnnPort), a.conns)
+		if err != nil {
+			a.l.Warnln("IPv4 local discovery:", err)
+		} else {
+			a.disco.Add(bcd, 0, 0, ipv4LocalDiscoveryPriority)
+		}
+		// v6 multicasts
+		mcd, err := discover.NewLocal(a.myID, opts.LocalAnnMCAddr, a.conns)
+		if err != nil {
+			a.l.Warnln("IPv6 local discovery:", err)
+		} else {
+			a.disco.Add(mcd, 0, 0, ipv6LocalDiscoveryPriority)
+		}
+	}
+}
+
+// localNetworks returns the networks that are not subject to bandwidth
+// limits, if any.
+func (a *App) localNetworks() []*net.IPNet {
+	opts := a.cfg.Options()
+	if (opts.MaxRecvKbps <= 0 && opts.MaxSendKbps <= 0) || opts.LimitBandwidthInLan {
+		return nil
+	}
+
+	lans, _ := osutil.GetLans()
+	for _, lan := range opts.AlwaysLocalNets {
+		_, ipnet, err := net.ParseCIDR(lan)
+		if err != nil {
+			a.l.Infoln("Network", lan, "is malformed:", err)
+			continue
+		}
+		lans = append(lans, ipnet)
+	}
+
+	networks := make([]string, len(lans))
+	for i, lan := range lans {
+		networks[i] = lan.String()
+	}
+	a.l.Infoln("Local networks:", strings.Join(networks, ", "))
+
+	return lans
+}
+
+func (a *App) deviceName() string {
+	name := a.cfg.Devices()[a.myID].Name
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	return name
+}
+
+func (a *App) run() {
+	<-a.stop
+	a.svc.Stop()
+	if a.audit != nil {
+		a.audit.Close()
+	}
+	a.db.Close()
+	close(a.closed)
+}
+
+// Stop stops everything and closes the database, and returns once that's
+// done.
+func (a *App) Stop() error {
+	a.mut.Lock()
+	if a.state != stateStarted {
+		a.mut.Unlock()
+		return errNotStarted
+	}
+	a.state = stateStopped
+	close(a.stop)
+	a.mut.Unlock()
+
+	<-a.closed
+	return nil
+}
+
+// Wait blocks until the App has been stopped, or returns immediately if it
+// failed to start.
+func (a *App) Wait() {
+	<-a.closed
+}
+
+// AddService runs the service as part of the started App. It's stopped
+// before the model and the database when the App is stopped.
+func (a *App) AddService(svc suture.Service) {
+	a.svc.Add(svc)
+}
+
+// DeviceID returns our device ID. Valid once started.
+func (a *App) DeviceID() protocol.DeviceID {
+	return a.myID
+}
+
+// Certificate returns the device certificate. Valid once started.
+func (a *App) Certificate() tls.Certificate {
+	return a.cert
+}
+
+// Config returns the configuration. Valid once started.
+func (a *App) Config() *config.Wrapper {
+	return a.cfg
+}
+
+// Model returns the model. Valid once started.
+func (a *App) Model() *model.Model {
+	return a.model
+}
+
+// Database returns the database. Valid once started.
+func (a *App) Database() *db.Instance {
+	return a.db
+}
+
+// Connections returns the connection service, or nil if connections are
+// disabled. Valid once started.
+func (a *App) Connections() *connections.Service {
+	return a.conns
+}
+
+// Discovery returns the discovery cache, or nil if connections are
+// disabled. Valid once started.
+func (a *App) Discovery() discover.CachingMux {
+	return a.disco
+}
+
+// LoadOrGenerateCertificate loads the certificate and key from the given
+// files, or generates them with a key of the given type if they can't be
+// loaded.
+func LoadOrGenerateCertificate(certFile, keyFile string, keyType tlsutil.KeyType) (tls.Certificate, error) {
+	return loadOrGenerateCertificate(certFile, keyFile, keyType, l)
+}
+
+func loadOrGenerateCertificate(certFile, keyFile string, keyType tlsutil.KeyType, l logger.Logger) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil {
+		return cert, nil
+	}
+
+	l.Infof("Generating %s key and certificate for %s...", keyType, TLSDefaultCommonName)
+	return NewDeviceCertificate(certFile, keyFile, keyType)
+}
+
+// NewDeviceCertificate generates a device certificate and key of the given
+// type, writing them to the given files.
+func NewDeviceCertificate(certFile, keyFile string, keyType tlsutil.KeyType) (tls.Certificate, error) {
+	return tlsutil.NewCertificateWithKeyType(certFile, keyFile, TLSDefaultCommonName, keyType, bepRSABits)
+}
+
+func loadOrCreateConfig(path string, myID protocol.DeviceID, l logger.Logger) (*config.Wrapper, error) {
+	cfg, err := config.Load(path, myID)
+	if os.IsNotExist(err) {
+		cfg = config.Wrap(path, config.New(myID))
+		if err := cfg.Save(); err != nil {
+			return nil, err
+		}
+		l.Infof("Defaults saved. Edit %s to taste or use the GUI", path)
+		return cfg, nil
+	}
+	return cfg, err
+}
+
+// tlsConfig returns the TLS configuration used for both the listening
+// socket and outgoing connections.
+func tlsConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates:           []tls.Certificate{cert},
+		NextProtos:             []string{bepProtocolName},
+		ClientAuth:             tls.RequestClientCert,
+		SessionTicketsDisabled: true,
+		InsecureSkipVerify:     true,
+		MinVersion:             tls.VersionTLS12,
+		CipherSuites: []uint16{
+			0xCCA8, // TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305, Go 1.8
+			0xCCA9, // TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305, Go 1.8
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		},
+	}
+}
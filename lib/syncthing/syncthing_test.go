@@ -0,0 +1,201 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app := New(Options{
+		HomeDir:       dir,
+		KeyType:       tlsutil.KeyTypeEd25519,
+		NoConnections: true,
+	})
+	if err := app.Stop(); err == nil {
+		t.Error("Unexpected nil error stopping an app that isn't started")
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err == nil {
+		t.Error("Unexpected nil error starting an app twice")
+	}
+
+	// The certificate and config are created in the home directory
+	for _, file := range []string{CertFileName, KeyFileName, ConfigFileName} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, ok := app.Config().Devices()[app.DeviceID()]; !ok {
+		t.Error("Config should contain our own device")
+	}
+	if app.Model() == nil || app.Database() == nil {
+		t.Error("Model and database should be available once started")
+	}
+	if app.Connections() != nil || app.Discovery() != nil {
+		t.Error("Connections are disabled")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		app.Wait()
+		close(waited)
+	}()
+
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-waited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait did not return after Stop")
+	}
+
+	// Restarting with the same home directory keeps our identity
+	app2 := New(Options{HomeDir: dir, NoConnections: true})
+	if err := app2.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer app2.Stop()
+	if app2.DeviceID() != app.DeviceID() {
+		t.Errorf("Device ID changed from %v to %v", app.DeviceID(), app2.DeviceID())
+	}
+}
+
+func TestStartupLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mut sync.Mutex
+	var msgs []string
+	log := logger.New()
+	log.AddHandler(logger.LevelInfo, func(_ logger.LogLevel, msg string) {
+		mut.Lock()
+		msgs = append(msgs, msg)
+		mut.Unlock()
+	})
+
+	app := New(Options{
+		HomeDir:       dir,
+		KeyType:       tlsutil.KeyTypeEd25519,
+		Logger:        log,
+		NoConnections: true,
+	})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Stop()
+
+	// Generating the certificate and config is logged to our logger
+	mut.Lock()
+	defer mut.Unlock()
+	for _, prefix := range []string{"Generating", "Defaults saved"} {
+		found := false
+		for _, msg := range msgs {
+			if strings.HasPrefix(msg, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("No message starting with %q in %v", prefix, msgs)
+		}
+	}
+}
+
+func TestWaitAfterFailedStart(t *testing.T) {
+	app := New(Options{NoConnections: true})
+	if err := app.Start(); err == nil {
+		t.Fatal("Unexpected nil error starting without a home directory")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		app.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait did not return after a failed Start")
+	}
+}
+
+type fakeService struct {
+	started chan struct{}
+	stopped chan struct{}
+	stop    chan struct{}
+}
+
+func (s *fakeService) Serve() {
+	close(s.started)
+	<-s.stop
+	close(s.stopped)
+}
+
+func (s *fakeService) Stop() {
+	close(s.stop)
+}
+
+func TestAddService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app := New(Options{
+		HomeDir:       dir,
+		KeyType:       tlsutil.KeyTypeEd25519,
+		NoConnections: true,
+	})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &fakeService{
+		started: make(chan struct{}),
+		stopped: make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	app.AddService(svc)
+	select {
+	case <-svc.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Service was not started")
+	}
+
+	// The service is stopped with the App
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-svc.stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Service was not stopped")
+	}
+}
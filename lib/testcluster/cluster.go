@@ -0,0 +1,425 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package testcluster runs a cluster of devices in a single process, for
+// integration tests that don't need built binaries or real ports. Each
+// device is a Model with its own in memory database and folder
+// directories. Devices talk BEP to each other over simulated network links
+// that can be delayed, dropped and partitioned.
+//
+// The folders live in fs.DefaultFilesystem, which the model and the
+// scanner use for all folder access. It's shared by the whole process, so
+// tests set it to a MemoryFilesystem for all devices at once, keeping each
+// device's folders apart under its own directory.
+//
+// The Clock only controls the latency of the links. Scans, pulls and the
+// timers of the model run in real time, so tests must leave them time to
+// happen and can't skip ahead.
+package testcluster
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How often lost connections are re-established, as the connection
+// service would do.
+const reconnectInterval = 50 * time.Millisecond
+
+// The version the devices claim to be running. It needs to be recent
+// enough for the models not to hold back on features for old clients.
+const clientVersion = "v0.14.99-testcluster"
+
+var errTimeout = errors.New("timeout")
+
+// A Device is one member of the cluster.
+type Device struct {
+	Name   string
+	ID     protocol.DeviceID
+	Model  *model.Model
+	Config *config.Wrapper
+	DB     *db.Instance
+	dir    string
+	addr   net.Addr
+}
+
+// Path returns the path of the named file in the folder, on this device.
+func (d *Device) Path(folder, name string) string {
+	return filepath.Join(d.dir, folder, filepath.FromSlash(name))
+}
+
+// WriteFile creates or replaces the named file in the folder. Call Scan to
+// have the device notice.
+func (d *Device) WriteFile(folder, name string, data []byte) error {
+	path := d.Path(folder, name)
+	if err := fs.DefaultFilesystem.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fd, err := fs.DefaultFilesystem.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(data); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+// ReadFile returns the contents of the named file in the folder.
+func (d *Device) ReadFile(folder, name string) ([]byte, error) {
+	fd, err := fs.DefaultFilesystem.Open(d.Path(folder, name))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return ioutil.ReadAll(fd)
+}
+
+// RemoveFile removes the named file in the folder. Call Scan to have the
+// device notice.
+func (d *Device) RemoveFile(folder, name string) error {
+	return fs.DefaultFilesystem.Remove(d.Path(folder, name))
+}
+
+// Scan rescans the folder.
+func (d *Device) Scan(folder string) error {
+	return d.Model.ScanFolder(folder)
+}
+
+type devicePair [2]protocol.DeviceID
+
+func pairOf(a, b *Device) devicePair {
+	if a.ID.Compare(b.ID) > 0 {
+		a, b = b, a
+	}
+	return devicePair{a.ID, b.ID}
+}
+
+// A Cluster is a set of devices and the network between them.
+type Cluster struct {
+	dir         string
+	clock       Clock
+	devices     map[protocol.DeviceID]*Device
+	wanted      map[devicePair]bool
+	partitioned map[devicePair]bool
+	latency     map[devicePair]time.Duration
+	links       map[devicePair]*link
+	mut         sync.Mutex
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+// New returns an empty cluster keeping its devices' folders under dir, in
+// fs.DefaultFilesystem. The clock controls network latency only; if nil,
+// the real clock is used.
+func New(dir string, clock Clock) *Cluster {
+	if clock == nil {
+		clock = realClock{}
+	}
+	c := &Cluster{
+		dir:         dir,
+		clock:       clock,
+		devices:     make(map[protocol.DeviceID]*Device),
+		wanted:      make(map[devicePair]bool),
+		partitioned: make(map[devicePair]bool),
+		latency:     make(map[devicePair]time.Duration),
+		links:       make(map[devicePair]*link),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go c.serve()
+	return c
+}
+
+// AddDevice creates and starts a new device. The device ID is derived from
+// the name, which must be unique within the cluster.
+func (c *Cluster) AddDevice(name string) *Device {
+	id := protocol.NewDeviceID([]byte(name))
+	dir := filepath.Join(c.dir, name)
+
+	cfg := config.New(id)
+	cfg.Devices[0].Name = name
+	wcfg := config.Wrap(filepath.Join(dir, "config.xml"), cfg)
+
+	ldb := db.OpenMemory()
+	m := model.NewModel(wcfg, id, name, "syncthing", clientVersion, ldb, nil)
+	m.ServeBackground()
+
+	d := &Device{
+		Name:   name,
+		ID:     id,
+		Model:  m,
+		Config: wcfg,
+		DB:     ldb,
+		dir:    dir,
+	}
+
+	c.mut.Lock()
+	// The cluster is one LAN, as the model treats connections from global
+	// addresses differently.
+	d.addr = &net.TCPAddr{IP: net.IPv4(192, 168, 0, byte(len(c.devices)+1)), Port: 22000}
+	c.devices[id] = d
+	c.mut.Unlock()
+	return d
+}
+
+// ShareFolder shares the folder among the devices, which get to know each
+// other as needed. It returns once the folder is running on all of them.
+func (c *Cluster) ShareFolder(folder string, devices ...*Device) error {
+	for _, d := range devices {
+		raw := d.Config.RawCopy()
+
+		known := make(map[protocol.DeviceID]bool)
+		for _, dev := range raw.Devices {
+			known[dev.DeviceID] = true
+		}
+		fcfg := config.NewFolderConfiguration(folder, filepath.Join(d.dir, folder))
+		for _, other := range devices {
+			fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{DeviceID: other.ID})
+			if !known[other.ID] {
+				raw.Devices = append(raw.Devices, config.NewDeviceConfiguration(other.ID, other.Name))
+			}
+		}
+		if err := fcfg.CreateRoot(); err != nil {
+			return err
+		}
+		if err := fcfg.CreateMarker(); err != nil {
+			return err
+		}
+		raw.Folders = append(raw.Folders, fcfg)
+
+		if err := d.Config.Replace(raw); err != nil {
+			return err
+		}
+	}
+
+	// The folders are added asynchronously
+	return c.await(10*time.Second, func() bool {
+		for _, d := range devices {
+			if _, ok := d.Model.CurrentSequence(folder); !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Connect connects the two devices. Lost connections are re-established,
+// unless the devices are partitioned.
+func (c *Cluster) Connect(a, b *Device) {
+	c.mut.Lock()
+	c.wanted[pairOf(a, b)] = true
+	c.mut.Unlock()
+}
+
+// ConnectAll connects all devices to each other.
+func (c *Cluster) ConnectAll() {
+	c.mut.Lock()
+	for id, a := range c.devices {
+		for other, b := range c.devices {
+			if id != other {
+				c.wanted[pairOf(a, b)] = true
+			}
+		}
+	}
+	c.mut.Unlock()
+}
+
+// Disconnect drops the connection between the devices, as by a network
+// hiccup. It is re-established shortly.
+func (c *Cluster) Disconnect(a, b *Device) {
+	c.mut.Lock()
+	if l, ok := c.links[pairOf(a, b)]; ok {
+		l.close()
+	}
+	c.mut.Unlock()
+}
+
+// Partition splits the network into the given groups of devices. Devices in
+// different groups are disconnected and stay so until Heal is called.
+func (c *Cluster) Partition(groups ...[]*Device) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for i, group := range groups {
+		for _, other := range groups[i+1:] {
+			for _, a := range group {
+				for _, b := range other {
+					p := pairOf(a, b)
+					c.partitioned[p] = true
+					if l, ok := c.links[p]; ok {
+						l.close()
+					}
+				}
+			}
+		}
+	}
+}
+
+// Heal undoes all partitions.
+func (c *Cluster) Heal() {
+	c.mut.Lock()
+	c.partitioned = make(map[devicePair]bool)
+	c.mut.Unlock()
+}
+
+// SetLatency delays all messages between the two devices, in both
+// directions, by the given duration according to the cluster clock.
+func (c *Cluster) SetLatency(a, b *Device, latency time.Duration) {
+	c.mut.Lock()
+	c.latency[pairOf(a, b)] = latency
+	c.mut.Unlock()
+}
+
+// InSync returns true when the devices, or all devices if none are given,
+// have the same view of the folder and need nothing from each other.
+func (c *Cluster) InSync(folder string, devices ...*Device) bool {
+	if len(devices) == 0 {
+		devices = c.allDevices()
+	}
+
+	global := devices[0].Model.GlobalSize(folder)
+	for _, d := range devices {
+		if d.Model.GlobalSize(folder) != global || d.Model.LocalSize(folder) != global {
+			return false
+		}
+		if need := d.Model.NeedSize(folder); need != (db.Counts{}) {
+			return false
+		}
+		// Our view of what the others have must also be up to date.
+		for _, other := range devices {
+			if other == d {
+				continue
+			}
+			if comp := d.Model.Completion(other.ID, folder); comp.NeedBytes != 0 || comp.NeedDeletes != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AwaitSync waits for the devices, or all devices if none are given, to be
+// in sync on the folder.
+func (c *Cluster) AwaitSync(folder string, timeout time.Duration, devices ...*Device) error {
+	if err := c.await(timeout, func() bool { return c.InSync(folder, devices...) }); err != nil {
+		return fmt.Errorf("folder %q: %v", folder, err)
+	}
+	return nil
+}
+
+// AwaitConnected waits for the two devices to be connected to each other.
+func (c *Cluster) AwaitConnected(a, b *Device, timeout time.Duration) error {
+	return c.await(timeout, func() bool {
+		return a.Model.ConnectedTo(b.ID) && b.Model.ConnectedTo(a.ID)
+	})
+}
+
+// Close stops all devices and the network.
+func (c *Cluster) Close() {
+	close(c.stop)
+	<-c.stopped
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, l := range c.links {
+		l.close()
+	}
+	for _, d := range c.devices {
+		d.Model.Stop()
+		d.DB.Close()
+	}
+}
+
+func (c *Cluster) allDevices() []*Device {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	devices := make([]*Device, 0, len(c.devices))
+	for _, d := range c.devices {
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+func (c *Cluster) await(timeout time.Duration, done func() bool) error {
+	t0 := time.Now()
+	for !done() {
+		if time.Since(t0) > timeout {
+			return errTimeout
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// serve keeps the wanted connections up, like the connection service.
+func (c *Cluster) serve() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(reconnectInterval)
+	defer ticker.Stop()
+
+	for {
+		c.connectWanted()
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Cluster) connectWanted() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for p := range c.wanted {
+		if c.partitioned[p] {
+			continue
+		}
+		a, b := c.devices[p[0]], c.devices[p[1]]
+		if l, ok := c.links[p]; ok && !l.closed() && a.Model.ConnectedTo(b.ID) && b.Model.ConnectedTo(a.ID) {
+			continue
+		}
+		c.connect(p, a, b)
+	}
+}
+
+func (c *Cluster) connect(p devicePair, a, b *Device) {
+	if l, ok := c.links[p]; ok {
+		l.close()
+	}
+
+	l := newLink(c.clock, func() time.Duration {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		return c.latency[p]
+	})
+	c.links[p] = l
+
+	// The model waits for a replaced connection to close, which needs the
+	// lock for the latency, so the connections are added in the
+	// background.
+	ac := &simConn{link: l, remote: b.addr}
+	ac.Connection = protocol.NewConnection(b.ID, l.ba, l.ab, a.Model, b.Name, protocol.CompressMetadata)
+	bc := &simConn{link: l, remote: a.addr}
+	bc.Connection = protocol.NewConnection(a.ID, l.ab, l.ba, b.Model, a.Name, protocol.CompressMetadata)
+
+	go a.Model.AddConnection(ac, protocol.HelloResult{DeviceName: b.Name, ClientName: "syncthing", ClientVersion: clientVersion})
+	go b.Model.AddConnection(bc, protocol.HelloResult{DeviceName: a.Name, ClientName: "syncthing", ClientVersion: clientVersion})
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package testcluster
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A link is the simulated network connection between two devices.
+type link struct {
+	ab, ba *delayedPipe // a -> b and b -> a
+}
+
+func newLink(clock Clock, latency func() time.Duration) *link {
+	return &link{
+		ab: newDelayedPipe(clock, latency),
+		ba: newDelayedPipe(clock, latency),
+	}
+}
+
+func (l *link) close() {
+	l.ab.close()
+	l.ba.close()
+}
+
+func (l *link) closed() bool {
+	select {
+	case <-l.ab.closed:
+		return true
+	case <-l.ba.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// A delayedPipe delivers what's written to it to its reader in order, each
+// write after the latency in effect when it was made.
+type delayedPipe struct {
+	clock   Clock
+	latency func() time.Duration
+	queue   chan delayedWrite
+	pr      *io.PipeReader
+	pw      *io.PipeWriter
+	closed  chan struct{}
+	once    sync.Once
+}
+
+type delayedWrite struct {
+	data []byte
+	at   time.Time
+}
+
+func newDelayedPipe(clock Clock, latency func() time.Duration) *delayedPipe {
+	pr, pw := io.Pipe()
+	p := &delayedPipe{
+		clock:   clock,
+		latency: latency,
+		queue:   make(chan delayedWrite, 1024),
+		pr:      pr,
+		pw:      pw,
+		closed:  make(chan struct{}),
+	}
+	go p.deliver()
+	return p
+}
+
+func (p *delayedPipe) Read(bs []byte) (int, error) {
+	return p.pr.Read(bs)
+}
+
+func (p *delayedPipe) Write(bs []byte) (int, error) {
+	w := delayedWrite{
+		data: append([]byte(nil), bs...),
+		at:   p.clock.Now().Add(p.latency()),
+	}
+	select {
+	case p.queue <- w:
+		return len(bs), nil
+	case <-p.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (p *delayedPipe) deliver() {
+	for {
+		select {
+		case w := <-p.queue:
+			if d := w.at.Sub(p.clock.Now()); d > 0 {
+				select {
+				case <-p.clock.After(d):
+				case <-p.closed:
+					return
+				}
+			}
+			if _, err := p.pw.Write(w.data); err != nil {
+				return
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+func (p *delayedPipe) close() {
+	p.once.Do(func() {
+		close(p.closed)
+		p.pw.CloseWithError(io.ErrClosedPipe)
+		p.pr.Close()
+	})
+}
+
+// simConn is a protocol.Connection over a link, as handed to the model.
+type simConn struct {
+	protocol.Connection
+	link   *link
+	remote net.Addr
+}
+
+func (c *simConn) Close() error {
+	c.link.close()
+	return nil
+}
+
+func (c *simConn) Type() string {
+	return "simulated"
+}
+
+func (c *simConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package testcluster
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+const syncTimeout = 30 * time.Second
+
+func TestMain(m *testing.M) {
+	// The devices' folders live in memory, off the disk
+	fs.DefaultFilesystem = fs.NewMemoryFilesystem(fs.MemoryOptions{})
+	os.Exit(m.Run())
+}
+
+func newTestCluster(t *testing.T, names ...string) (*Cluster, []*Device, func()) {
+	dir := filepath.Join(string(filepath.Separator), "testcluster", t.Name())
+
+	c := New(dir, nil)
+	var devices []*Device
+	for _, name := range names {
+		devices = append(devices, c.AddDevice(name))
+	}
+	if err := c.ShareFolder("default", devices...); err != nil {
+		t.Fatal(err)
+	}
+	c.ConnectAll()
+
+	return c, devices, func() {
+		c.Close()
+		fs.DefaultFilesystem.RemoveAll(dir)
+	}
+}
+
+func TestSyncFile(t *testing.T) {
+	c, devs, cleanup := newTestCluster(t, "a", "b", "c")
+	defer cleanup()
+
+	data := []byte("hello, world\n")
+	if err := devs[0].WriteFile("default", "dir/file", data); err != nil {
+		t.Fatal(err)
+	}
+	if err := devs[0].Scan("default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AwaitSync("default", syncTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range devs[1:] {
+		bs, err := d.ReadFile("default", "dir/file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bs, data) {
+			t.Errorf("%s: got %q, expected %q", d.Name, bs, data)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	c, devs, cleanup := newTestCluster(t, "a", "b")
+	defer cleanup()
+
+	a, b := devs[0], devs[1]
+	if err := c.AwaitConnected(a, b, syncTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Partition([]*Device{a}, []*Device{b})
+
+	if err := a.WriteFile("default", "file", []byte("partitioned")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Scan("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Give the network a chance to misbehave.
+	time.Sleep(4 * reconnectInterval)
+	if a.Model.ConnectedTo(b.ID) || b.Model.ConnectedTo(a.ID) {
+		t.Fatal("partitioned devices should not be connected")
+	}
+	if _, err := b.ReadFile("default", "file"); !fs.IsNotExist(err) {
+		t.Fatal("file should not have crossed the partition")
+	}
+
+	c.Heal()
+	if err := c.AwaitSync("default", syncTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ReadFile("default", "file"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	c, devs, cleanup := newTestCluster(t, "a", "b")
+	defer cleanup()
+
+	a, b := devs[0], devs[1]
+	if err := c.AwaitConnected(a, b, syncTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Disconnect(a, b)
+	if err := a.WriteFile("default", "file", []byte("reconnected")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Scan("default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AwaitSync("default", syncTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDelayedPipe(t *testing.T) {
+	clock := NewManualClock(time.Unix(1500000000, 0))
+	p := newDelayedPipe(clock, func() time.Duration { return time.Second })
+	defer p.close()
+
+	if _, err := p.Write([]byte("delayed")); err != nil {
+		t.Fatal(err)
+	}
+
+	read := make(chan []byte)
+	go func() {
+		bs := make([]byte, 16)
+		n, _ := p.Read(bs)
+		read <- bs[:n]
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("write should not be delivered before the latency has passed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case bs := <-read:
+		if string(bs) != "delayed" {
+			t.Errorf("got %q, expected %q", bs, "delayed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write should be delivered once the latency has passed")
+	}
+}
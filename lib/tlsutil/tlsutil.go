@@ -9,6 +9,7 @@ package tlsutil
 import (
 	"bufio"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/tls"
@@ -29,6 +30,25 @@ var (
 	ErrIdentificationFailed = fmt.Errorf("failed to identify socket type")
 )
 
+// KeyType is the type of key used for a certificate.
+type KeyType string
+
+const (
+	KeyTypeRSA     KeyType = "rsa"
+	KeyTypeECDSA   KeyType = "ecdsa"
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+// ParseKeyType returns the key type with the given name.
+func ParseKeyType(s string) (KeyType, error) {
+	switch t := KeyType(s); t {
+	case KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown key type %q", s)
+	}
+}
+
 // NewCertificate generates and returns a new TLS certificate. If tlsRSABits
 // is greater than zero we generate an RSA certificate with the specified
 // number of bits. Otherwise we create a 384 bit ECDSA certificate.
@@ -44,6 +64,33 @@ func NewCertificate(certFile, keyFile, tlsDefaultCommonName string, tlsRSABits i
 		return tls.Certificate{}, fmt.Errorf("generate key: %s", err)
 	}
 
+	return newCertificate(certFile, keyFile, tlsDefaultCommonName, priv)
+}
+
+// NewCertificateWithKeyType generates and returns a new TLS certificate with
+// a key of the given type. RSA keys have tlsRSABits bits, ECDSA keys use the
+// P-256 curve.
+func NewCertificateWithKeyType(certFile, keyFile, tlsDefaultCommonName string, keyType KeyType, tlsRSABits int) (tls.Certificate, error) {
+	var priv interface{}
+	var err error
+	switch keyType {
+	case KeyTypeRSA:
+		priv, err = rsa.GenerateKey(rand.Reader, tlsRSABits)
+	case KeyTypeECDSA:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unknown key type %q", keyType)
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate key: %s", err)
+	}
+
+	return newCertificate(certFile, keyFile, tlsDefaultCommonName, priv)
+}
+
+func newCertificate(certFile, keyFile, tlsDefaultCommonName string, priv interface{}) (tls.Certificate, error) {
 	notBefore := time.Now()
 	notAfter := time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC)
 
@@ -55,10 +102,14 @@ func NewCertificate(certFile, keyFile, tlsDefaultCommonName string, tlsRSABits i
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 	}
+	if _, ok := priv.(*rsa.PrivateKey); ok {
+		// Only RSA keys are used for key exchange
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
 
 	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, publicKey(priv), priv)
 	if err != nil {
@@ -159,6 +210,8 @@ func publicKey(priv interface{}) interface{} {
 		return &k.PublicKey
 	case *ecdsa.PrivateKey:
 		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
 	default:
 		return nil
 	}
@@ -174,6 +227,12 @@ func pemBlockForKey(priv interface{}) (*pem.Block, error) {
 			return nil, err
 		}
 		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}, nil
+	case ed25519.PrivateKey:
+		b, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: b}, nil
 	default:
 		return nil, fmt.Errorf("unknown key type")
 	}
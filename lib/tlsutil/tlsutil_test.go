@@ -0,0 +1,101 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseKeyType(t *testing.T) {
+	for _, s := range []string{"rsa", "ecdsa", "ed25519"} {
+		if kt, err := ParseKeyType(s); err != nil || string(kt) != s {
+			t.Errorf("ParseKeyType(%q) = %v, %v", s, kt, err)
+		}
+	}
+	if _, err := ParseKeyType("dsa"); err == nil {
+		t.Error("Unexpected nil error for unknown key type")
+	}
+}
+
+func TestKeyTypeHandshake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Peers with different key types must be able to talk to each other,
+	// using TLS 1.2 with the cipher suites we allow as well as TLS 1.3.
+	var certs []tls.Certificate
+	for _, kt := range []KeyType{KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519} {
+		certFile := filepath.Join(dir, string(kt)+"-cert.pem")
+		keyFile := filepath.Join(dir, string(kt)+"-key.pem")
+		if _, err := NewCertificateWithKeyType(certFile, keyFile, "syncthing", kt, 1024); err != nil {
+			t.Fatal(err)
+		}
+		// As loaded on startup
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		for _, serverCert := range certs {
+			for _, clientCert := range certs {
+				testHandshake(t, version, serverCert, clientCert)
+			}
+		}
+	}
+}
+
+func testHandshake(t *testing.T, version uint16, serverCert, clientCert tls.Certificate) {
+	cfg := func(cert tls.Certificate) *tls.Config {
+		return &tls.Config{
+			Certificates:       []tls.Certificate{cert},
+			ClientAuth:         tls.RequestClientCert,
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+			MaxVersion:         version,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			},
+		}
+	}
+
+	c0, c1 := net.Pipe()
+	defer c0.Close()
+	defer c1.Close()
+	server := tls.Server(c0, cfg(serverCert))
+	client := tls.Client(c1, cfg(clientCert))
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Handshake()
+	}()
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if peer := server.ConnectionState().PeerCertificates; len(peer) != 1 || !bytes.Equal(peer[0].Raw, clientCert.Certificate[0]) {
+		t.Error("Server did not get the client certificate")
+	}
+	if peer := client.ConnectionState().PeerCertificates; len(peer) != 1 || !bytes.Equal(peer[0].Raw, serverCert.Certificate[0]) {
+		t.Error("Client did not get the server certificate")
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 func init() {
@@ -21,16 +21,20 @@ func init() {
 	Factories["external"] = NewExternal
 }
 
+// External calls a command to archive the file. The command works directly
+// on disk, so the filesystem is only used to check for the file.
 type External struct {
 	command    string
+	fs         fs.Filesystem
 	folderPath string
 }
 
-func NewExternal(folderID, folderPath string, params map[string]string) Versioner {
+func NewExternal(folderID string, filesystem fs.Filesystem, folderPath string, params map[string]string) Versioner {
 	command := params["command"]
 
 	s := External{
 		command:    command,
+		fs:         filesystem,
 		folderPath: folderPath,
 	}
 
@@ -41,8 +45,8 @@ func NewExternal(folderID, folderPath string, params map[string]string) Versione
 // Archive moves the named file away to a version archive. If this function
 // returns nil, the named file does not exist any more (has been archived).
 func (v External) Archive(filePath string) error {
-	_, err := osutil.Lstat(filePath)
-	if os.IsNotExist(err) {
+	_, err := v.fs.Lstat(filePath)
+	if fs.IsNotExist(err) {
 		l.Debugln("not archiving nonexistent file", filePath)
 		return nil
 	} else if err != nil {
@@ -76,7 +80,7 @@ func (v External) Archive(filePath string) error {
 	}
 
 	// return error if the file was not removed
-	if _, err = osutil.Lstat(filePath); os.IsNotExist(err) {
+	if _, err = v.fs.Lstat(filePath); fs.IsNotExist(err) {
 		return nil
 	}
 	return errors.New("Versioner: file was not removed by external script")
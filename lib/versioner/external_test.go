@@ -12,6 +12,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 func TestExternalNoCommand(t *testing.T) {
@@ -29,6 +31,7 @@ func TestExternalNoCommand(t *testing.T) {
 
 	e := External{
 		command:    "nonexistent command",
+		fs:         fs.DefaultFilesystem,
 		folderPath: "testdata/folder path",
 	}
 	if err := e.Archive(file); err == nil {
@@ -62,6 +65,7 @@ func TestExternal(t *testing.T) {
 
 	e := External{
 		command:    cmd,
+		fs:         fs.DefaultFilesystem,
 		folderPath: "testdata/folder path",
 	}
 	if err := e.Archive(file); err != nil {
@@ -7,11 +7,10 @@
 package versioner
 
 import (
-	"os"
 	"path/filepath"
 	"strconv"
 
-	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/util"
 )
 
@@ -22,10 +21,11 @@ func init() {
 
 type Simple struct {
 	keep       int
+	fs         fs.Filesystem
 	folderPath string
 }
 
-func NewSimple(folderID, folderPath string, params map[string]string) Versioner {
+func NewSimple(folderID string, filesystem fs.Filesystem, folderPath string, params map[string]string) Versioner {
 	keep, err := strconv.Atoi(params["keep"])
 	if err != nil {
 		keep = 5 // A reasonable default
@@ -33,6 +33,7 @@ func NewSimple(folderID, folderPath string, params map[string]string) Versioner
 
 	s := Simple{
 		keep:       keep,
+		fs:         filesystem,
 		folderPath: folderPath,
 	}
 
@@ -43,8 +44,8 @@ func NewSimple(folderID, folderPath string, params map[string]string) Versioner
 // Archive moves the named file away to a version archive. If this function
 // returns nil, the named file does not exist any more (has been archived).
 func (v Simple) Archive(filePath string) error {
-	fileInfo, err := osutil.Lstat(filePath)
-	if os.IsNotExist(err) {
+	fileInfo, err := v.fs.Lstat(filePath)
+	if fs.IsNotExist(err) {
 		l.Debugln("not archiving nonexistent file", filePath)
 		return nil
 	} else if err != nil {
@@ -52,12 +53,12 @@ func (v Simple) Archive(filePath string) error {
 	}
 
 	versionsDir := filepath.Join(v.folderPath, ".stversions")
-	_, err = os.Stat(versionsDir)
+	_, err = v.fs.Stat(versionsDir)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if fs.IsNotExist(err) {
 			l.Debugln("creating versions dir", versionsDir)
-			osutil.MkdirAll(versionsDir, 0755)
-			osutil.HideFile(versionsDir)
+			v.fs.MkdirAll(versionsDir, 0755)
+			v.fs.Hide(versionsDir)
 		} else {
 			return err
 		}
@@ -72,22 +73,22 @@ func (v Simple) Archive(filePath string) error {
 	}
 
 	dir := filepath.Join(versionsDir, inFolderPath)
-	err = osutil.MkdirAll(dir, 0755)
-	if err != nil && !os.IsExist(err) {
+	err = v.fs.MkdirAll(dir, 0755)
+	if err != nil && !fs.IsExist(err) {
 		return err
 	}
 
 	ver := taggedFilename(file, fileInfo.ModTime().Format(TimeFormat))
 	dst := filepath.Join(dir, ver)
 	l.Debugln("moving to", dst)
-	err = osutil.Rename(filePath, dst)
+	err = fs.TryRename(v.fs, filePath, dst)
 	if err != nil {
 		return err
 	}
 
 	// Glob according to the new file~timestamp.ext pattern.
 	pattern := filepath.Join(dir, taggedFilename(file, TimeGlob))
-	newVersions, err := osutil.Glob(pattern)
+	newVersions, err := v.fs.Glob(pattern)
 	if err != nil {
 		l.Warnln("globbing:", err, "for", pattern)
 		return nil
@@ -95,7 +96,7 @@ func (v Simple) Archive(filePath string) error {
 
 	// Also according to the old file.ext~timestamp pattern.
 	pattern = filepath.Join(dir, file+"~"+TimeGlob)
-	oldVersions, err := osutil.Glob(pattern)
+	oldVersions, err := v.fs.Glob(pattern)
 	if err != nil {
 		l.Warnln("globbing:", err, "for", pattern)
 		return nil
@@ -108,7 +109,7 @@ func (v Simple) Archive(filePath string) error {
 	if len(versions) > v.keep {
 		for _, toRemove := range versions[:len(versions)-v.keep] {
 			l.Debugln("cleaning out", toRemove)
-			err = os.Remove(toRemove)
+			err = v.fs.Remove(toRemove)
 			if err != nil {
 				l.Warnln("removing old version:", err)
 			}
@@ -13,6 +13,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 func TestTaggedFilename(t *testing.T) {
@@ -58,7 +60,7 @@ func TestSimpleVersioningVersionCount(t *testing.T) {
 		t.Error(err)
 	}
 
-	v := NewSimple("", dir, map[string]string{"keep": "2"})
+	v := NewSimple("", fs.DefaultFilesystem, dir, map[string]string{"keep": "2"})
 	versionDir := filepath.Join(dir, ".stversions")
 
 	path := filepath.Join(dir, "test")
@@ -88,3 +90,32 @@ func TestSimpleVersioningVersionCount(t *testing.T) {
 		time.Sleep(time.Second)
 	}
 }
+
+func TestSimpleVersioningMemoryFilesystem(t *testing.T) {
+	filesystem := fs.NewMemoryFilesystem(fs.MemoryOptions{})
+	if err := filesystem.MkdirAll(filepath.Join("folder", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("folder", "dir", "test")
+	fd, err := filesystem.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	v := NewSimple("", filesystem, "folder", map[string]string{"keep": "2"})
+	if err := v.Archive(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := filesystem.Lstat(path); !fs.IsNotExist(err) {
+		t.Error("archived file should be gone")
+	}
+	versions, err := filesystem.Glob(filepath.Join("folder", ".stversions", "dir", "test~*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("expected one version, got %v", versions)
+	}
+}
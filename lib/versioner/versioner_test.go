@@ -0,0 +1,58 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// A versioner that fails to move the file away must leave it untouched.
+func TestArchiveFailureKeepsFile(t *testing.T) {
+	for name, factory := range Factories {
+		if name == "external" {
+			// Runs a command directly on disk
+			continue
+		}
+
+		mem := fs.NewMemoryFilesystem(fs.MemoryOptions{})
+		faults := fs.NewFaultFilesystem(mem)
+		path := filepath.Join("folder", "file")
+
+		mem.MkdirAll("folder", 0755)
+		fd, err := mem.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd.Write([]byte("precious"))
+		fd.Close()
+
+		err = faults.SetRules([]fs.FaultRule{{Ops: []string{fs.FaultOpRename}, Error: "ENOSPC"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := factory("default", faults, "folder", map[string]string{})
+		if err := v.Archive(path); err == nil {
+			t.Errorf("%s: archiving should fail", name)
+		}
+
+		fd, err = mem.Open(path)
+		if err != nil {
+			t.Errorf("%s: file should remain: %v", name, err)
+			continue
+		}
+		data, _ := ioutil.ReadAll(fd)
+		fd.Close()
+		if string(data) != "precious" {
+			t.Errorf("%s: file contents changed to %q", name, data)
+		}
+	}
+}
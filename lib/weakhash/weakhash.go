@@ -9,9 +9,9 @@ package weakhash
 import (
 	"bufio"
 	"io"
-	"os"
 
 	"github.com/chmduquesne/rollinghash/adler32"
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 const (
@@ -72,8 +72,8 @@ func Find(ir io.Reader, hashesToFind []uint32, size int) (map[uint32][]int64, er
 	return offsets, nil
 }
 
-func NewFinder(path string, size int, hashesToFind []uint32) (*Finder, error) {
-	file, err := os.Open(path)
+func NewFinder(ifs fs.Filesystem, path string, size int, hashesToFind []uint32) (*Finder, error) {
+	file, err := ifs.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func NewFinder(path string, size int, hashesToFind []uint32) (*Finder, error) {
 }
 
 type Finder struct {
-	file    *os.File
+	file    fs.File
 	size    int
 	offsets map[uint32][]int64
 }
@@ -15,6 +15,8 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 var payload = []byte("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz")
@@ -32,7 +34,7 @@ func TestFinder(t *testing.T) {
 	}
 
 	hashes := []uint32{65143183, 65798547}
-	finder, err := NewFinder(f.Name(), 4, hashes)
+	finder, err := NewFinder(fs.DefaultFilesystem, f.Name(), 4, hashes)
 	if err != nil {
 		t.Error(err)
 	}
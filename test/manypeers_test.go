@@ -15,7 +15,7 @@ import (
 	"testing"
 
 	"github.com/syncthing/syncthing/lib/config"
-	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rc"
 )
@@ -54,8 +54,8 @@ func TestManyPeers(t *testing.T) {
 		cfg.Folders[0].Devices = append(cfg.Folders[0].Devices, config.FolderDeviceConfiguration{DeviceID: id})
 	}
 
-	osutil.Rename("h2/config.xml", "h2/config.xml.orig")
-	defer osutil.Rename("h2/config.xml.orig", "h2/config.xml")
+	fs.TryRename(fs.DefaultFilesystem, "h2/config.xml", "h2/config.xml.orig")
+	defer fs.TryRename(fs.DefaultFilesystem, "h2/config.xml.orig", "h2/config.xml")
 
 	var buf bytes.Buffer
 	json.NewEncoder(&buf).Encode(cfg)
@@ -14,7 +14,7 @@ import (
 	"testing"
 
 	"github.com/syncthing/syncthing/lib/config"
-	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rc"
 )
@@ -51,7 +51,7 @@ func TestAddDeviceWithoutRestart(t *testing.T) {
 
 	os.Remove("h4/config.xml.orig")
 	os.Rename("h4/config.xml", "h4/config.xml.orig")
-	defer osutil.Rename("h4/config.xml.orig", "h4/config.xml")
+	defer fs.TryRename(fs.DefaultFilesystem, "h4/config.xml.orig", "h4/config.xml")
 
 	cfg, err := p4.GetConfig()
 	if err != nil {
@@ -118,7 +118,7 @@ func TestFolderWithoutRestart(t *testing.T) {
 
 	os.Remove("h1/config.xml.orig")
 	os.Rename("h1/config.xml", "h1/config.xml.orig")
-	defer osutil.Rename("h1/config.xml.orig", "h1/config.xml")
+	defer fs.TryRename(fs.DefaultFilesystem, "h1/config.xml.orig", "h1/config.xml")
 
 	cfg, err := p1.GetConfig()
 	if err != nil {
@@ -155,7 +155,7 @@ func TestFolderWithoutRestart(t *testing.T) {
 
 	os.Remove("h4/config.xml.orig")
 	os.Rename("h4/config.xml", "h4/config.xml.orig")
-	defer osutil.Rename("h4/config.xml.orig", "h4/config.xml")
+	defer fs.TryRename(fs.DefaultFilesystem, "h4/config.xml.orig", "h4/config.xml")
 
 	cfg, err = p4.GetConfig()
 	if err != nil {
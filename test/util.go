@@ -26,6 +26,7 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/rc"
 )
@@ -188,7 +189,7 @@ func alterFiles(dir string) error {
 			}
 			newPath := filepath.Join(filepath.Dir(path), string(base))
 			if newPath != path {
-				return osutil.TryRename(path, newPath)
+				return fs.TryRename(fs.DefaultFilesystem, path, newPath)
 			}
 
 			/*
@@ -231,7 +232,7 @@ func alterFiles(dir string) error {
 								rpath = filepath.Join(rpath, "..")
 							}
 						}
-						return osutil.TryRename(path, filepath.Join(rpath, randomName()))
+						return fs.TryRename(fs.DefaultFilesystem, path, filepath.Join(rpath, randomName()))
 			*/
 		}
 